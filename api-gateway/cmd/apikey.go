@@ -0,0 +1,123 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/pkg/apikey"
+	"api-gateway/pkg/postgres"
+
+	"gopkg.in/yaml.v3"
+)
+
+const apikeyUsage = "usage: apikey create -owner OWNER [-scopes a,b] [-roles a,b] | revoke ID | list"
+
+// apikeyCommand runs the apikey subcommand with args, the arguments after apikey. With
+// API_KEYS_STORE=postgres keys are created in the database, otherwise the entry for
+// API_KEYS_FILE is printed. The key itself is printed once and cannot be shown again.
+func apikeyCommand(ctx context.Context, cfg *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(apikeyUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		owner := flags.String("owner", "", "who the key is for, the subject the services see")
+		scopes := flags.String("scopes", "", "scopes of the key, separated by commas")
+		roles := flags.String("roles", "", "roles passed on to the services, separated by commas")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *owner == "" {
+			return errors.New(apikeyUsage)
+		}
+
+		raw, key, err := apikey.Generate(*owner, list(*scopes), list(*roles))
+		if err != nil {
+			return err
+		}
+
+		if cfg.APIKeys.Store != apikey.StorePostgres {
+			entry, err := yaml.Marshal([]apikey.Key{key})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "key: %s\n\nAdd to the keys of %s:\n%s", raw, cmp.Or(cfg.APIKeys.File, "API_KEYS_FILE"), entry)
+			return nil
+		}
+
+		return withKeys(ctx, cfg.Postgres, func(store *apikey.Postgres) error {
+			if err := store.Create(ctx, key); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "id: %s\nkey: %s\n", key.ID, raw)
+			return nil
+		})
+	case "revoke":
+		if len(args) < 2 {
+			return errors.New(apikeyUsage)
+		}
+		return withKeys(ctx, cfg.Postgres, func(store *apikey.Postgres) error {
+			if err := store.Revoke(ctx, args[1]); err != nil {
+				return fmt.Errorf("key %s: %w", args[1], err)
+			}
+			fmt.Fprintf(out, "revoked %s, replicas refuse it once API_KEYS_CACHE_TTL (%v) passed\n", args[1], cfg.APIKeys.CacheTTL)
+			return nil
+		})
+	case "list":
+		return withKeys(ctx, cfg.Postgres, func(store *apikey.Postgres) error {
+			keys, err := store.List(ctx)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tOWNER\tSCOPES\tROLES\tCREATED\tREVOKED")
+			for _, key := range keys {
+				revoked := "-"
+				if key.RevokedAt != nil {
+					revoked = key.RevokedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Owner, strings.Join(key.Scopes, ","), strings.Join(key.Roles, ","), key.CreatedAt.Format(time.RFC3339), revoked)
+			}
+			return w.Flush()
+		})
+	default:
+		return errors.New(apikeyUsage)
+	}
+}
+
+// withKeys calls fn with the keys in Postgres.
+func withKeys(ctx context.Context, cfg postgres.Config, fn func(store *apikey.Postgres) error) error {
+	if cfg.Dsn == "" {
+		return errors.New("POSTGRES_DSN is not set")
+	}
+
+	db, err := postgres.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("postgres: %w", err)
+	}
+	defer db.Pool.Close()
+
+	return fn(apikey.NewPostgres(db.Pool, 0))
+}
+
+// list splits a list separated by commas, nil if it is empty.
+func list(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
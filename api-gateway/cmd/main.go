@@ -2,72 +2,80 @@ package main
 
 import (
 	"api-gateway/config"
-	"io"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/tracing"
+	"api-gateway/proxy"
+	"context"
+	"log"
+	"log/slog"
 	"net/http"
-	"strings"
-
-	"github.com/gin-gonic/gin"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	config := config.New()
 
-	r := gin.Default()
-
-	r.Any("/*proxyPath", func(c *gin.Context) {
-		targetURL := ""
-		path := c.Param("proxyPath")
-		rawQuery := c.Request.URL.RawQuery
+	// Setup logger, the log package writes through it as well
+	l, err := logger.New(os.Stdout, config.Log, "api-gateway")
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	slog.SetDefault(l)
 
-		// Выбор сервиса в зависимости от пути
-		if strings.HasPrefix(path, "/orders") {
-			targetURL = config.OrderService.Addr + path
-		} else if strings.HasPrefix(path, "/products") {
-			targetURL = config.InventoryService.Addr + path
-		} else {
-			c.JSON(http.StatusNotFound, gin.H{"error": "unknown route"})
+	// The migrate and apikey subcommands work on the database and exit
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := migrate(context.Background(), config.Postgres, os.Args[2:]); err != nil {
+				log.Fatalf("Error: migrate: %v", err)
+			}
+			return
+		case "apikey":
+			if err := apikeyCommand(context.Background(), config, os.Args[2:], os.Stdout); err != nil {
+				log.Fatalf("Error: apikey: %v", err)
+			}
 			return
 		}
+	}
 
-		// Добавляем query параметры, если они есть
-		if rawQuery != "" {
-			targetURL += "?" + rawQuery
-		}
+	// Setup tracing, the spans left are flushed on exit
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Tracing, "api-gateway", "")
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 
-		// Создаём новый запрос
-		req, err := http.NewRequest(c.Request.Method, targetURL, c.Request.Body)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot create request"})
-			return
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
 		}
+	}()
 
-		// Копируем заголовки
-		for key, values := range c.Request.Header {
-			for _, value := range values {
-				req.Header.Add(key, value)
-			}
-		}
+	gw, err := proxy.New(config)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 
-		// Отправляем запрос
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "target service unavailable"})
-			return
-		}
-		defer resp.Body.Close()
+	// The route file is read again when it changes, or on SIGHUP
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go gw.Watch(ctx)
 
-		// Копируем заголовки ответа
-		for k, vv := range resp.Header {
-			for _, v := range vv {
-				c.Writer.Header().Add(k, v)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := gw.Reload(); err != nil {
+				slog.Error("routes: reload failed, the previous routes stay in use", "error", err)
 			}
 		}
+	}()
 
-		// Устанавливаем статус и возвращаем тело ответа
-		c.Status(resp.StatusCode)
-		io.Copy(c.Writer, resp.Body)
-	})
-
-	r.Run(":8080") // API Gateway будет слушать здесь
+	if err := http.ListenAndServe(":8080", gw.Handler()); err != nil { // API Gateway будет слушать здесь
+		slog.Error("failed to run gateway", "error", err)
+	}
 }
@@ -3,6 +3,17 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
+
+	"api-gateway/pkg/apikey"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/health"
+	"api-gateway/pkg/httpcache"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/postgres"
+	"api-gateway/pkg/ratelimit"
+	"api-gateway/pkg/tracing"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +22,16 @@ type (
 	Config struct {
 		OrderService     OrderService
 		InventoryService InventoryService
+		Log              logger.Config
+		Tracing          tracing.Config
+		Health           health.Config
+		Routes           Routes
+		Transport        Transport
+		Auth             auth.Config
+		APIKeys          apikey.Config
+		RateLimit        ratelimit.Config
+		Postgres         postgres.Config // Only needed by the postgres stores of API keys and rate limits
+		Cache            httpcache.Config
 	}
 
 	OrderService struct {
@@ -20,6 +41,21 @@ type (
 	InventoryService struct {
 		Addr string
 	}
+
+	// Routes is the route table of the gateway. Without a file, /orders goes to
+	// OrderService and /products to InventoryService.
+	Routes struct {
+		File           string
+		ReloadInterval time.Duration // The file is read again when it changes, and on SIGHUP
+	}
+
+	// Transport tunes the connections to the upstreams, shared by every route.
+	Transport struct {
+		DialTimeout           time.Duration
+		ResponseHeaderTimeout time.Duration // Zero waits as long as the route timeout allows
+		IdleConnTimeout       time.Duration
+		MaxIdleConnsPerHost   int
+	}
 )
 
 func New() *Config {
@@ -28,6 +64,81 @@ func New() *Config {
 		log.Fatalf("Error: %v", err.Error())
 	}
 
+	sampleRatio, err := strconv.ParseFloat(getenv("OTEL_TRACES_SAMPLER_ARG", "1"), 64)
+	if err != nil {
+		log.Fatalf("Error: OTEL_TRACES_SAMPLER_ARG: %v", err.Error())
+	}
+
+	readinessTimeout, err := time.ParseDuration(getenv("READINESS_TIMEOUT", "2s"))
+	if err != nil {
+		log.Fatalf("Error: READINESS_TIMEOUT: %v", err.Error())
+	}
+
+	reloadInterval, err := time.ParseDuration(getenv("ROUTES_RELOAD_INTERVAL", "10s"))
+	if err != nil {
+		log.Fatalf("Error: ROUTES_RELOAD_INTERVAL: %v", err.Error())
+	}
+
+	dialTimeout, err := time.ParseDuration(getenv("PROXY_DIAL_TIMEOUT", "5s"))
+	if err != nil {
+		log.Fatalf("Error: PROXY_DIAL_TIMEOUT: %v", err.Error())
+	}
+
+	responseHeaderTimeout, err := time.ParseDuration(getenv("PROXY_RESPONSE_HEADER_TIMEOUT", "30s"))
+	if err != nil {
+		log.Fatalf("Error: PROXY_RESPONSE_HEADER_TIMEOUT: %v", err.Error())
+	}
+
+	idleConnTimeout, err := time.ParseDuration(getenv("PROXY_IDLE_CONN_TIMEOUT", "90s"))
+	if err != nil {
+		log.Fatalf("Error: PROXY_IDLE_CONN_TIMEOUT: %v", err.Error())
+	}
+
+	maxIdleConnsPerHost, err := strconv.Atoi(getenv("PROXY_MAX_IDLE_CONNS_PER_HOST", "64"))
+	if err != nil {
+		log.Fatalf("Error: PROXY_MAX_IDLE_CONNS_PER_HOST: %v", err.Error())
+	}
+
+	jwksRefresh, err := time.ParseDuration(getenv("JWT_JWKS_REFRESH", "5m"))
+	if err != nil {
+		log.Fatalf("Error: JWT_JWKS_REFRESH: %v", err.Error())
+	}
+
+	jwtLeeway, err := time.ParseDuration(getenv("JWT_LEEWAY", "30s"))
+	if err != nil {
+		log.Fatalf("Error: JWT_LEEWAY: %v", err.Error())
+	}
+
+	apiKeysCacheTTL, err := time.ParseDuration(getenv("API_KEYS_CACHE_TTL", "30s"))
+	if err != nil {
+		log.Fatalf("Error: API_KEYS_CACHE_TTL: %v", err.Error())
+	}
+
+	maxOpenConns, err := strconv.ParseInt(getenv("POSTGRES_MAX_OPEN_CONN", "25"), 10, 32)
+	if err != nil {
+		log.Fatalf("Error: POSTGRES_MAX_OPEN_CONN: %v", err.Error())
+	}
+
+	maxIdleConns, err := strconv.Atoi(getenv("POSTGRES_MAX_IDLE_CONN", "25"))
+	if err != nil {
+		log.Fatalf("Error: POSTGRES_MAX_IDLE_CONN: %v", err.Error())
+	}
+
+	migrateOnStart, err := strconv.ParseBool(getenv("POSTGRES_MIGRATE_ON_START", "false"))
+	if err != nil {
+		log.Fatalf("Error: POSTGRES_MIGRATE_ON_START: %v", err.Error())
+	}
+
+	cacheMaxBytes, err := strconv.ParseInt(getenv("CACHE_MAX_BYTES", "67108864"), 10, 64)
+	if err != nil {
+		log.Fatalf("Error: CACHE_MAX_BYTES: %v", err.Error())
+	}
+
+	cacheMaxEntryBytes, err := strconv.ParseInt(getenv("CACHE_MAX_ENTRY_BYTES", "1048576"), 10, 64)
+	if err != nil {
+		log.Fatalf("Error: CACHE_MAX_ENTRY_BYTES: %v", err.Error())
+	}
+
 	return &Config{
 		OrderService: OrderService{
 			Addr: os.Getenv("ORDER_SERVICE"),
@@ -35,5 +146,62 @@ func New() *Config {
 		InventoryService: InventoryService{
 			Addr: os.Getenv("INVENTORY_SERVICE"),
 		},
+		Log: logger.Config{
+			Level:  getenv("LOG_LEVEL", "info"),
+			Format: getenv("LOG_FORMAT", "json"),
+		},
+		Tracing: tracing.Config{
+			Exporter:    getenv("OTEL_TRACES_EXPORTER", "none"),
+			Endpoint:    os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+			SampleRatio: sampleRatio,
+		},
+		Health: health.Config{
+			Timeout: readinessTimeout,
+		},
+		Routes: Routes{
+			File:           os.Getenv("ROUTES_FILE"),
+			ReloadInterval: reloadInterval,
+		},
+		Transport: Transport{
+			DialTimeout:           dialTimeout,
+			ResponseHeaderTimeout: responseHeaderTimeout,
+			IdleConnTimeout:       idleConnTimeout,
+			MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		},
+		Auth: auth.Config{
+			Secret:      os.Getenv("JWT_SECRET"),
+			JWKSFile:    os.Getenv("JWT_JWKS_FILE"),
+			JWKSURL:     os.Getenv("JWT_JWKS_URL"),
+			JWKSRefresh: jwksRefresh,
+			Issuer:      os.Getenv("JWT_ISSUER"),
+			Audience:    os.Getenv("JWT_AUDIENCE"),
+			Leeway:      jwtLeeway,
+		},
+		APIKeys: apikey.Config{
+			Store:    os.Getenv("API_KEYS_STORE"),
+			File:     os.Getenv("API_KEYS_FILE"),
+			CacheTTL: apiKeysCacheTTL,
+		},
+		RateLimit: ratelimit.Config{
+			Store: getenv("RATE_LIMIT_STORE", ratelimit.StoreMemory),
+		},
+		Postgres: postgres.Config{
+			Dsn:            os.Getenv("POSTGRES_DSN"),
+			MaxOpenConns:   int32(maxOpenConns),
+			MaxIdleConns:   maxIdleConns,
+			MaxIdleTime:    getenv("POSTGRES_MAX_IDLE_TIME", "15m"),
+			MigrateOnStart: migrateOnStart,
+		},
+		Cache: httpcache.Config{
+			MaxBytes:      cacheMaxBytes,
+			MaxEntryBytes: cacheMaxEntryBytes,
+		},
+	}
+}
+
+func getenv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
 	}
+	return fallback
 }
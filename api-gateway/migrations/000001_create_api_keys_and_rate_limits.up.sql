@@ -0,0 +1,22 @@
+-- Only the SHA-256 hash of a key is stored, the key itself is shown once when created
+CREATE TABLE IF NOT EXISTS api_keys (
+    id         TEXT PRIMARY KEY,
+    owner      TEXT NOT NULL,
+    scopes     TEXT[] NOT NULL DEFAULT '{}',
+    roles      TEXT[] NOT NULL DEFAULT '{}',
+    hash       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_owner_idx ON api_keys (owner);
+
+-- The token buckets shared by the replicas of the gateway. Losing them in a crash only
+-- refills them, so they skip the write-ahead log.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+    key        TEXT PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL migrations of the gateway, in the format of
+// golang-migrate, so that the binary can migrate its database by itself.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
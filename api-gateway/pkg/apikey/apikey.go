@@ -0,0 +1,155 @@
+// Package apikey verifies the API keys of partners, which call the gateway from their own
+// systems rather than on behalf of a signed-in user. A key is shown once when it is
+// created and only its SHA-256 hash is stored. Keys read gk_ID_SECRET: the ID finds the
+// stored key, the hash of the whole key proves it.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"api-gateway/pkg/authz"
+)
+
+// Header carries the key.
+const Header = "X-API-Key"
+
+const prefix = "gk_"
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+type Config struct {
+	Store    string        `env:"API_KEYS_STORE"`                      // memory, which reads File, or postgres. Keys are refused if empty
+	File     string        `env:"API_KEYS_FILE"`                       // The keys of the memory store
+	CacheTTL time.Duration `env:"API_KEYS_CACHE_TTL" envDefault:"30s"` // How long the postgres store keeps a key it read, and a revoked key stays usable
+}
+
+var (
+	// ErrNoKey is returned for requests without a key.
+	ErrNoKey = errors.New("no API key")
+	// ErrInvalid is returned for a key that is malformed, unknown or revoked.
+	ErrInvalid = errors.New("invalid API key")
+)
+
+// Key is a stored key.
+type Key struct {
+	ID        string     `yaml:"id"`
+	Owner     string     `yaml:"owner"`  // Passed on to the services as the subject of the requests
+	Scopes    []string   `yaml:"scopes"` // What the key may call, routes can require one of them
+	Roles     []string   `yaml:"roles"`  // Passed on to the services like the roles of a token
+	Hash      string     `yaml:"hash"`   // Hex SHA-256 of the whole key
+	CreatedAt time.Time  `yaml:"-"`
+	RevokedAt *time.Time `yaml:"revoked_at,omitempty"`
+}
+
+// HasScope tells whether the key has one of scopes.
+func (k Key) HasScope(scopes ...string) bool {
+	for _, scope := range scopes {
+		if slices.Contains(k.Scopes, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Identity is who the services are told made the request.
+func (k Key) Identity() authz.Identity {
+	return authz.Identity{Subject: k.Owner, Roles: k.Roles}
+}
+
+// Store finds keys by their ID.
+type Store interface {
+	// Get returns the key with id, ErrInvalid if there is none.
+	Get(ctx context.Context, id string) (Key, error)
+}
+
+// Generate returns a new key for owner and what is stored of it.
+func Generate(owner string, scopes, roles []string) (string, Key, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", Key{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", Key{}, err
+	}
+
+	raw := prefix + hex.EncodeToString(id) + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return raw, Key{
+		ID:     hex.EncodeToString(id),
+		Owner:  owner,
+		Scopes: scopes,
+		Roles:  roles,
+		Hash:   Hash(raw),
+	}, nil
+}
+
+// Hash returns the hex SHA-256 of a key, which is what is stored.
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verifier checks keys against a store.
+type Verifier struct {
+	store Store
+}
+
+func NewVerifier(store Store) *Verifier {
+	return &Verifier{store: store}
+}
+
+// Verify checks the key of req and returns it. Errors other than ErrNoKey and ErrInvalid
+// come from the store.
+func (v *Verifier) Verify(req *http.Request) (Key, error) {
+	raw := req.Header.Get(Header)
+	if raw == "" {
+		return Key{}, ErrNoKey
+	}
+
+	id, _, ok := strings.Cut(strings.TrimPrefix(raw, prefix), "_")
+	if !strings.HasPrefix(raw, prefix) || !ok || id == "" {
+		return Key{}, ErrInvalid
+	}
+
+	key, err := v.store.Get(req.Context(), id)
+	if err != nil {
+		return Key{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(Hash(raw)), []byte(key.Hash)) != 1 || key.RevokedAt != nil {
+		return Key{}, ErrInvalid
+	}
+
+	return key, nil
+}
+
+// validate checks a key read from a file or given to a store.
+func (k Key) validate() error {
+	var errs []error
+	if k.ID == "" {
+		errs = append(errs, errors.New("no id"))
+	}
+	if strings.Contains(k.ID, "_") {
+		errs = append(errs, fmt.Errorf("id %q contains _", k.ID))
+	}
+	if k.Owner == "" {
+		errs = append(errs, errors.New("no owner"))
+	}
+	if b, err := hex.DecodeString(k.Hash); err != nil || len(b) != sha256.Size {
+		errs = append(errs, errors.New("hash is not a hex SHA-256"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,220 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway/pkg/postgres/postgrestest"
+)
+
+// request returns a request carrying raw as its key, none if raw is empty.
+func request(raw string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/orders/", nil)
+	if raw != "" {
+		req.Header.Set(Header, raw)
+	}
+	return req
+}
+
+func TestGenerate(t *testing.T) {
+	raw, key, err := Generate("acme", []string{"orders"}, []string{"customer"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if !strings.HasPrefix(raw, prefix+key.ID+"_") {
+		t.Errorf("key %q does not start with %s%s_", raw, prefix, key.ID)
+	}
+	if key.Hash != Hash(raw) || strings.Contains(key.Hash, raw) {
+		t.Errorf("hash = %q, want the hash of the key", key.Hash)
+	}
+	if err := key.validate(); err != nil {
+		t.Errorf("generated key is invalid: %v", err)
+	}
+
+	other, _, err := Generate("acme", nil, nil)
+	if err != nil || other == raw {
+		t.Errorf("second key = %q, %v, want another one", other, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	raw, key, err := Generate("acme", []string{"orders"}, nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	revokedRaw, revoked, err := Generate("acme", nil, nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	revokedAt := time.Now()
+	revoked.RevokedAt = &revokedAt
+
+	store, err := NewMemory(key, revoked)
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	v := NewVerifier(store)
+
+	id, secret, _ := strings.Cut(strings.TrimPrefix(raw, prefix), "_")
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr error
+	}{
+		{name: "valid", raw: raw},
+		{name: "no key", raw: "", wantErr: ErrNoKey},
+		{name: "no prefix", raw: id + "_" + secret, wantErr: ErrInvalid},
+		{name: "no secret", raw: prefix + id, wantErr: ErrInvalid},
+		{name: "no id", raw: prefix + "_" + secret, wantErr: ErrInvalid},
+		{name: "unknown id", raw: prefix + "0000000000000000_" + secret, wantErr: ErrInvalid},
+		{name: "wrong secret", raw: prefix + id + "_" + strings.ToUpper(secret), wantErr: ErrInvalid},
+		{name: "the stored hash", raw: prefix + id + "_" + key.Hash, wantErr: ErrInvalid},
+		{name: "revoked", raw: revokedRaw, wantErr: ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(request(tt.raw))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.ID != key.ID || got.Owner != "acme") {
+				t.Errorf("Verify = %+v, want the key of acme", got)
+			}
+		})
+	}
+}
+
+func TestNewMemoryValidates(t *testing.T) {
+	_, key, err := Generate("acme", nil, nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		keys []Key
+		want string
+	}{
+		{name: "taken id", keys: []Key{key, key}, want: "is taken"},
+		{name: "no id", keys: []Key{{Owner: "acme", Hash: key.Hash}}, want: "no id"},
+		{name: "id with _", keys: []Key{{ID: "a_b", Owner: "acme", Hash: key.Hash}}, want: "contains _"},
+		{name: "no owner", keys: []Key{{ID: "a", Hash: key.Hash}}, want: "no owner"},
+		{name: "short hash", keys: []Key{{ID: "a", Owner: "acme", Hash: "abcd"}}, want: "not a hex SHA-256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMemory(tt.keys...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewMemory = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`
+keys:
+  - id: 3f9a0c7d1b2e4f60
+    owner: acme
+    scopes: [orders]
+    roles: [customer]
+    hash: 9c56cc51b374c3ba189210d5b6d4bf57790d351c96c47c02190ecf1e430635ab
+`)
+	store, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	key, err := store.Get(context.Background(), "3f9a0c7d1b2e4f60")
+	if err != nil || key.Owner != "acme" || !key.HasScope("admin", "orders") || key.HasScope("admin") {
+		t.Errorf("Get = %+v, %v", key, err)
+	}
+	if id := key.Identity(); id.Subject != "acme" || len(id.Roles) != 1 || id.Roles[0] != "customer" {
+		t.Errorf("Identity = %+v", id)
+	}
+
+	// A misspelt field would silently drop what it sets
+	write(`
+keys:
+  - id: 3f9a0c7d1b2e4f60
+    owner: acme
+    scope: [orders]
+    hash: 9c56cc51b374c3ba189210d5b6d4bf57790d351c96c47c02190ecf1e430635ab
+`)
+	if _, err := LoadFile(path); err == nil {
+		t.Error("LoadFile with an unknown field succeeded")
+	}
+}
+
+func TestPostgres(t *testing.T) {
+	ctx := context.Background()
+	pool := postgrestest.New(t)
+	store := NewPostgres(pool, 0)
+	v := NewVerifier(store)
+
+	raw, key, err := Generate("acme", []string{"orders"}, nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if err := store.Create(ctx, key); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := store.Create(ctx, Key{ID: "bad"}); err == nil {
+		t.Error("Create of an invalid key succeeded")
+	}
+
+	got, err := v.Verify(request(raw))
+	if err != nil || got.Owner != "acme" || !got.HasScope("orders") || got.CreatedAt.IsZero() {
+		t.Fatalf("Verify = %+v, %v", got, err)
+	}
+	if _, err := store.Get(ctx, "unknown"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Get of an unknown key = %v, want %v", err, ErrInvalid)
+	}
+
+	// A store that keeps keys still accepts a key revoked after it was read
+	cached := NewPostgres(pool, time.Hour)
+	if _, err := NewVerifier(cached).Verify(request(raw)); err != nil {
+		t.Fatalf("Verify through the cache: %v", err)
+	}
+
+	if err := store.Revoke(ctx, key.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := store.Revoke(ctx, key.ID); !errors.Is(err, ErrInvalid) {
+		t.Errorf("second Revoke = %v, want %v", err, ErrInvalid)
+	}
+	if _, err := v.Verify(request(raw)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Verify of a revoked key = %v, want %v", err, ErrInvalid)
+	}
+	if _, err := NewVerifier(cached).Verify(request(raw)); err != nil {
+		t.Errorf("Verify of a revoked key through the cache = %v, want it kept until the TTL", err)
+	}
+
+	_, newer, err := Generate("globex", nil, nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if err := store.Create(ctx, newer); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	keys, err := store.List(ctx)
+	if err != nil || len(keys) != 2 || keys[0].ID != newer.ID || keys[1].RevokedAt == nil {
+		t.Errorf("List = %+v, %v, want the newest first and the revoked one last", keys, err)
+	}
+}
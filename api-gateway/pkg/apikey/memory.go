@@ -0,0 +1,71 @@
+package apikey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Memory holds a fixed set of keys, such as those of a file.
+type Memory struct {
+	keys map[string]Key
+}
+
+// NewMemory returns a store of keys, which must have distinct IDs.
+func NewMemory(keys ...Key) (*Memory, error) {
+	m := &Memory{keys: make(map[string]Key, len(keys))}
+
+	var errs []error
+	for i, key := range keys {
+		if err := key.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("key %d: %w", i+1, err))
+			continue
+		}
+		if _, taken := m.keys[key.ID]; taken {
+			errs = append(errs, fmt.Errorf("key %d: id %s is taken", i+1, key.ID))
+		}
+		m.keys[key.ID] = key
+	}
+
+	return m, errors.Join(errs...)
+}
+
+// LoadFile reads the keys in the YAML file at path:
+//
+//	keys:
+//	  - id: 3f9a0c7d1b2e4f60
+//	    owner: acme
+//	    scopes: [orders]
+//	    roles: [customer]
+//	    hash: 9c56cc51b374c3ba189210d5b6d4bf57790d351c96c47c02190ecf1e430635ab
+//
+// The apikey subcommand of the gateway generates the entries.
+func LoadFile(path string) (*Memory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Keys []Key `yaml:"keys"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	return NewMemory(file.Keys...)
+}
+
+func (m *Memory) Get(_ context.Context, id string) (Key, error) {
+	key, ok := m.keys[id]
+	if !ok {
+		return Key{}, ErrInvalid
+	}
+	return key, nil
+}
@@ -0,0 +1,137 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Postgres keeps the keys in the table api_keys, where the apikey subcommand of the
+// gateway creates and revokes them. Keys that were found are kept for the cache TTL, so
+// that a busy partner does not cost a query per request.
+type Postgres struct {
+	pool *pgxpool.Pool
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	key     Key
+	expires time.Time
+}
+
+// NewPostgres returns a store on pool that keeps keys for ttl, not at all if ttl is zero.
+func NewPostgres(pool *pgxpool.Pool, ttl time.Duration) *Postgres {
+	return &Postgres{pool: pool, ttl: ttl, cache: make(map[string]cached)}
+}
+
+func (p *Postgres) Get(ctx context.Context, id string) (Key, error) {
+	now := time.Now()
+
+	p.mu.Lock()
+	c, ok := p.cache[id]
+	p.mu.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.key, nil
+	}
+
+	const query = `
+		SELECT id, owner, scopes, roles, hash, created_at, revoked_at
+		FROM api_keys
+		WHERE id = $1`
+
+	key, err := scan(p.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Key{}, ErrInvalid
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	// Only keys that exist are kept, unknown IDs would let anyone fill the cache
+	if p.ttl > 0 {
+		p.mu.Lock()
+		for id, c := range p.cache {
+			if now.After(c.expires) {
+				delete(p.cache, id)
+			}
+		}
+		p.cache[key.ID] = cached{key: key, expires: now.Add(p.ttl)}
+		p.mu.Unlock()
+	}
+
+	return key, nil
+}
+
+// Create stores key.
+func (p *Postgres) Create(ctx context.Context, key Key) error {
+	if err := key.validate(); err != nil {
+		return err
+	}
+
+	const query = `
+		INSERT INTO api_keys (id, owner, scopes, roles, hash)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := p.pool.Exec(ctx, query, key.ID, key.Owner, nonNil(key.Scopes), nonNil(key.Roles), key.Hash)
+	return err
+}
+
+// Revoke refuses the key with id from now on, or once the caches of the replicas expire.
+func (p *Postgres) Revoke(ctx context.Context, id string) error {
+	const query = `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`
+
+	tag, err := p.pool.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// List returns every key, the newest first.
+func (p *Postgres) List(ctx context.Context) ([]Key, error) {
+	const query = `
+		SELECT id, owner, scopes, roles, hash, created_at, revoked_at
+		FROM api_keys
+		ORDER BY created_at DESC, id`
+
+	rows, err := p.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		key, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func scan(row pgx.Row) (Key, error) {
+	var key Key
+	err := row.Scan(&key.ID, &key.Owner, &key.Scopes, &key.Roles, &key.Hash, &key.CreatedAt, &key.RevokedAt)
+	return key, err
+}
+
+// nonNil stores a missing list as an empty one, the columns are NOT NULL.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
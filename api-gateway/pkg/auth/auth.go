@@ -0,0 +1,130 @@
+// Package auth verifies the bearer tokens of the clients. The gateway checks them once and
+// passes the identity they carry on to the services in the trusted headers of package
+// authz, which it strips from the requests of the clients.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-gateway/pkg/authz"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Config struct {
+	Secret      string        `env:"JWT_SECRET"`                       // Key of HS256 tokens
+	JWKSFile    string        `env:"JWT_JWKS_FILE"`                    // Public keys of RS256 and ES256 tokens
+	JWKSURL     string        `env:"JWT_JWKS_URL"`                     // The same, fetched from a URL
+	JWKSRefresh time.Duration `env:"JWT_JWKS_REFRESH" envDefault:"5m"` // How often the keys at JWKSURL are fetched again
+	Issuer      string        `env:"JWT_ISSUER"`                       // Checked if set
+	Audience    string        `env:"JWT_AUDIENCE"`                     // Checked if set
+	Leeway      time.Duration `env:"JWT_LEEWAY" envDefault:"30s"`      // Clock skew allowed when checking exp and nbf
+}
+
+// Enabled tells whether tokens can be verified at all.
+func (cfg Config) Enabled() bool {
+	return cfg.Secret != "" || cfg.JWKSFile != "" || cfg.JWKSURL != ""
+}
+
+var (
+	// ErrNoToken is returned for requests without a bearer token.
+	ErrNoToken = errors.New("no bearer token")
+	// ErrNotEnabled is returned by New when no key is configured.
+	ErrNotEnabled = errors.New("none of JWT_SECRET, JWT_JWKS_FILE and JWT_JWKS_URL is set")
+)
+
+// claims are the claims read from a token. Roles may be a list or a single string.
+type claims struct {
+	jwt.RegisteredClaims
+	Roles jwt.ClaimStrings `json:"roles"`
+}
+
+// Verifier checks tokens against the configured keys.
+type Verifier struct {
+	secret []byte
+	keys   *keySet
+	parser *jwt.Parser
+}
+
+// New returns a verifier for the keys of cfg. Keys from a URL are fetched in the
+// background until ctx is done.
+func New(ctx context.Context, cfg Config, client *http.Client) (*Verifier, error) {
+	if !cfg.Enabled() {
+		return nil, ErrNotEnabled
+	}
+	if cfg.JWKSFile != "" && cfg.JWKSURL != "" {
+		return nil, errors.New("JWT_JWKS_FILE and JWT_JWKS_URL exclude each other")
+	}
+
+	v := &Verifier{secret: []byte(cfg.Secret)}
+
+	var methods []string
+	if cfg.Secret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	switch {
+	case cfg.JWKSFile != "":
+		keys, err := loadKeySet(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_JWKS_FILE: %w", err)
+		}
+		v.keys = keys
+	case cfg.JWKSURL != "":
+		v.keys = fetchKeySet(ctx, cfg.JWKSURL, cfg.JWKSRefresh, client)
+	}
+	if v.keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg())
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+// Verify checks the bearer token of req and returns the identity it was issued to.
+func (v *Verifier) Verify(req *http.Request) (authz.Identity, error) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return authz.Identity{}, ErrNoToken
+	}
+
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.key); err != nil {
+		return authz.Identity{}, err
+	}
+	if c.Subject == "" {
+		return authz.Identity{}, errors.New("token has no subject")
+	}
+
+	return authz.Identity{Subject: c.Subject, Roles: c.Roles}, nil
+}
+
+// key returns the key the token was signed with. The parser has checked the algorithm
+// already.
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.secret, nil
+	}
+	if v.keys == nil {
+		return nil, errors.New("no public keys")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	return v.keys.get(kid, token.Method.Alg())
+}
@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksFetchTimeout bounds a request for the keys.
+	jwksFetchTimeout = 5 * time.Second
+	// jwksMinRefetch is how long a token with an unknown key waits for the keys to be
+	// fetched again, so that keys rotated in are picked up without letting every bad
+	// token trigger a request.
+	jwksMinRefetch = 30 * time.Second
+)
+
+// jwk is a public key of a JSON Web Key Set (RFC 7517). RSA and EC keys are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type publicKey struct {
+	alg string // Empty if the key does not restrict it
+	key any
+}
+
+// keySet holds the public keys by their kid.
+type keySet struct {
+	url    string // Empty for keys from a file
+	client *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]publicKey
+	fetchedAt time.Time // Of the last attempt
+}
+
+func loadKeySet(path string) (*keySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keySet{keys: keys}, nil
+}
+
+// fetchKeySet fetches the keys at url, and again every refresh until ctx is done. Tokens
+// are refused until the first fetch succeeds.
+func fetchKeySet(ctx context.Context, url string, refresh time.Duration, client *http.Client) *keySet {
+	s := &keySet{url: url, client: client, keys: make(map[string]publicKey)}
+	s.refresh(ctx)
+
+	if refresh > 0 {
+		go func() {
+			ticker := time.NewTicker(refresh)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					s.refresh(ctx)
+				}
+			}
+		}()
+	}
+
+	return s
+}
+
+// refresh fetches the keys again. The previous keys stay if that fails.
+func (s *keySet) refresh(ctx context.Context) {
+	s.mu.Lock()
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "auth: fetching the JWKS failed", "url", s.url, "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+}
+
+func (s *keySet) fetch(ctx context.Context) (map[string]publicKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKeySet(data)
+}
+
+// get returns the key with kid for alg. A token without a kid is accepted when the set
+// has a single key.
+func (s *keySet) get(kid, alg string) (any, error) {
+	key, ok := s.lookup(kid)
+	if !ok && s.url != "" {
+		s.mu.RLock()
+		stale := time.Since(s.fetchedAt) > jwksMinRefetch
+		s.mu.RUnlock()
+
+		if stale {
+			s.refresh(context.Background())
+			key, ok = s.lookup(kid)
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if key.alg != "" && key.alg != alg {
+		return nil, fmt.Errorf("key %q is for %s, not %s", kid, key.alg, alg)
+	}
+
+	return key.key, nil
+}
+
+func (s *keySet) lookup(kid string) (publicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// parseKeySet reads the signing keys of a JWKS document. Keys of other types or uses are
+// skipped.
+func parseKeySet(data []byte) (map[string]publicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var key any
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsa()
+		case "EC":
+			key, err = k.ecdsa()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = publicKey{alg: k.Alg, key: key}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA or EC signing keys")
+	}
+
+	return keys, nil
+}
+
+func (k jwk) rsa() (*rsa.PublicKey, error) {
+	n, err := decodeInt(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("n: %w", err)
+	}
+	e, err := decodeInt(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("e: %w", err)
+	}
+	if !e.IsInt64() || e.Int64() < 3 {
+		return nil, errors.New("e is out of range")
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jwk) ecdsa() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unknown curve %q", k.Crv)
+	}
+
+	x, err := decodeInt(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	y, err := decodeInt(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+
+	key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	if _, err := key.ECDH(); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,86 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	b := New(3, time.Hour)
+
+	b.Failure()
+	b.Failure()
+	if s := b.Status(); s.State != StateClosed || s.Failures != 2 || s.RetryIn != 0 {
+		t.Errorf("after 2 failures: %+v, want closed with 2 failures", s)
+	}
+
+	b.Failure()
+	s := b.Status()
+	if s.State != StateOpen || s.Failures != 3 {
+		t.Errorf("after 3 failures: %+v, want open with 3 failures", s)
+	}
+	// RetryIn counts down from the cooldown
+	if s.RetryIn <= time.Hour-time.Minute || s.RetryIn > time.Hour {
+		t.Errorf("RetryIn = %s, want just under an hour", s.RetryIn)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Errorf("Allow() = %v, want %v", err, ErrOpen)
+	}
+
+	b.Success()
+	if s := b.Status(); s.State != StateClosed || s.Failures != 0 || s.RetryIn != 0 {
+		t.Errorf("after a success: %+v, want closed without failures", s)
+	}
+}
+
+func TestStatusAfterCooldown(t *testing.T) {
+	b := New(1, 0)
+	b.Failure()
+
+	// An open breaker past its cooldown reports no wait, the next call is its probe
+	if s := b.Status(); s.State != StateOpen || s.RetryIn != 0 {
+		t.Errorf("status = %+v, want open with no wait", s)
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe: Allow() = %v", err)
+	}
+	if s := b.Status(); s.State != StateHalfOpen || s.RetryIn != 0 {
+		t.Errorf("status = %+v, want half-open", s)
+	}
+}
+
+// A request abandoned by its client, or refused before it was sent, releases its probe
+// without a verdict on the upstream.
+func TestReleaseProbe(t *testing.T) {
+	b := New(1, 0)
+	b.Failure()
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe: Allow() = %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("second probe: Allow() = %v, want %v", err, ErrOpen)
+	}
+
+	b.Release()
+	if s := b.Status(); s.State != StateHalfOpen || s.Failures != 1 {
+		t.Errorf("after release: %+v, want half-open with the failure kept", s)
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe after release: Allow() = %v", err)
+	}
+
+	b.Failure()
+	if s := b.Status(); s.State != StateOpen || s.Failures != 2 {
+		t.Errorf("after a failed probe: %+v, want open with 2 failures", s)
+	}
+}
+
+func TestStateString(t *testing.T) {
+	for s, want := range map[State]string{StateClosed: "closed", StateOpen: "open", StateHalfOpen: "half-open", State(7): "unknown"} {
+		if got := s.String(); got != want {
+			t.Errorf("State(%d).String() = %q, want %q", s, got, want)
+		}
+	}
+}
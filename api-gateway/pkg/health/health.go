@@ -0,0 +1,139 @@
+// Package health serves the liveness and readiness probes. A process is live as long as it
+// answers at all. It is ready when every dependency it needs to serve requests answers
+// within the timeout, and it stops being ready once it starts shutting down, so that no
+// new traffic is sent its way while it drains.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusUp           = "up"
+	StatusDown         = "down"
+	StatusShuttingDown = "shutting_down"
+)
+
+type Config struct {
+	Timeout time.Duration `env:"READINESS_TIMEOUT" envDefault:"2s"` // Every check of the readiness probe gets this long
+}
+
+// CheckFunc checks one dependency. The details it returns are reported along with the
+// result, an error fails the check.
+type CheckFunc func(ctx context.Context) (map[string]any, error)
+
+// Result is the outcome of one check.
+type Result struct {
+	Status   string         `json:"status"`
+	Duration string         `json:"duration"`
+	Error    string         `json:"error,omitempty"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+// Report is the body of both probes.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Health runs the readiness checks. Checks are added before the probes are served.
+type Health struct {
+	timeout      time.Duration
+	checks       []check
+	shuttingDown atomic.Bool
+}
+
+func New(cfg Config) *Health {
+	return &Health{timeout: cfg.Timeout}
+}
+
+// Add adds a check named name to the readiness probe.
+func (h *Health) Add(name string, fn CheckFunc) {
+	h.checks = append(h.checks, check{name: name, fn: fn})
+}
+
+// Shutdown makes the readiness probe fail from now on.
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Check runs every check at once, each with its own timeout.
+func (h *Health) Check(ctx context.Context) Report {
+	if h.shuttingDown.Load() {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	results := make([]Result, len(h.checks))
+
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.run(ctx, c.fn)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(h.checks))}
+	for i, c := range h.checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+func (h *Health) run(ctx context.Context, fn CheckFunc) Result {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	details, err := fn(ctx)
+
+	result := Result{
+		Status:   StatusUp,
+		Duration: time.Since(start).String(),
+		Details:  details,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// Live answers the liveness probe. It checks nothing, answering is enough.
+func (h *Health) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, Report{Status: StatusUp})
+}
+
+// Ready answers the readiness probe with the result of every check, with 503 Service
+// Unavailable when one of them failed or the process is shutting down.
+func (h *Health) Ready(c *gin.Context) {
+	report := h.Check(c.Request.Context())
+
+	code := http.StatusOK
+	if report.Status != StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, report)
+}
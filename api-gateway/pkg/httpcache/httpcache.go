@@ -0,0 +1,273 @@
+// Package httpcache keeps responses in memory for the gateway, the least recently used
+// ones are evicted once the cache is full. Entries are tagged with the route that stored
+// them and dropped together when a write changes what the route serves. Concurrent misses
+// for the same key are coalesced into one call with Do.
+package httpcache
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	MaxBytes      int64 `env:"CACHE_MAX_BYTES" envDefault:"67108864"`      // Size of the cache, 64 MiB
+	MaxEntryBytes int64 `env:"CACHE_MAX_ENTRY_BYTES" envDefault:"1048576"` // Larger responses are streamed and not cached
+}
+
+// Entry is a stored response.
+type Entry struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Tag     string    // Invalidate drops the entries of a tag
+	Stored  time.Time // When the response was received or last revalidated
+	Expires time.Time // The entry is stale from then on, and revalidated if it has an ETag
+
+	gen uint64
+}
+
+// Fresh tells whether the entry may be served without asking the upstream.
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// ETag is the validator of the entry, empty if it has none.
+func (e *Entry) ETag() string {
+	return e.Header.Get("ETag")
+}
+
+func (e *Entry) size(key string) int64 {
+	n := len(key) + len(e.Body)
+	for k, vs := range e.Header {
+		n += len(k)
+		for _, v := range vs {
+			n += len(v)
+		}
+	}
+	return int64(n)
+}
+
+// Cache is an LRU cache of responses bounded by their size.
+type Cache struct {
+	mu      sync.Mutex
+	max     int64
+	size    int64
+	lru     *list.List // Of *item, the most recently used first
+	items   map[string]*list.Element
+	gens    map[string]uint64 // Generation of every tag, bumped by Invalidate
+	flights map[string]*flight
+}
+
+type item struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// flight is a call of Do that others wait for.
+type flight struct {
+	done  chan struct{}
+	entry *Entry
+}
+
+func New(maxBytes int64) *Cache {
+	return &Cache{
+		max:     maxBytes,
+		lru:     list.New(),
+		items:   make(map[string]*list.Element),
+		gens:    make(map[string]uint64),
+		flights: make(map[string]*flight),
+	}
+}
+
+// Get returns the entry at key, fresh or stale.
+func (c *Cache) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	it := el.Value.(*item)
+	if it.entry.gen != c.gens[it.entry.Tag] {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return it.entry, true
+}
+
+// Generation returns the generation of tag, to be passed to Set with the response read
+// after it. A response that was read while the tag was invalidated is not stored.
+func (c *Cache) Generation(tag string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Recorded, so that Purge bumps it as well
+	gen, ok := c.gens[tag]
+	if !ok {
+		c.gens[tag] = 0
+	}
+	return gen
+}
+
+// Set stores e at key unless its tag was invalidated since gen, or it does not fit.
+func (c *Cache) Set(key string, e *Entry, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gens[e.Tag] {
+		return
+	}
+	e.gen = gen
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	size := e.size(key)
+	if size > c.max {
+		return
+	}
+	for c.size+size > c.max {
+		c.remove(c.lru.Back())
+	}
+	c.items[key] = c.lru.PushFront(&item{key: key, entry: e, size: size})
+	c.size += size
+}
+
+// Invalidate drops the entries of tags. They are removed as they are found, or evicted.
+func (c *Cache) Invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tag := range tags {
+		c.gens[tag]++
+	}
+}
+
+// Purge drops every entry.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for tag := range c.gens {
+		c.gens[tag]++
+	}
+	c.lru.Init()
+	clear(c.items)
+	c.size = 0
+}
+
+// Size returns the bytes held by the entries.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+func (c *Cache) remove(el *list.Element) {
+	it := c.lru.Remove(el).(*item)
+	delete(c.items, it.key)
+	c.size -= it.size
+}
+
+// Do calls fn for the first caller with key, and has the callers that come while it runs
+// wait for its entry. It tells whether the entry came from another caller; nil means fn
+// returned no entry to share, the caller has to fetch the response itself then.
+func (c *Cache) Do(ctx context.Context, key string, fn func() *Entry) (*Entry, bool, error) {
+	c.mu.Lock()
+	if f, ok := c.flights[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.entry, true, nil
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		}
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.flights, key)
+		c.mu.Unlock()
+		close(f.done)
+	}()
+
+	f.entry = fn()
+	return f.entry, false, nil
+}
+
+// Lifetime reads how long a response with header stays fresh: s-maxage or max-age of its
+// Cache-Control, or fallback when it has neither. A response that must not be stored, or
+// that can neither be fresh nor revalidated, is not storable.
+func Lifetime(header http.Header, fallback time.Duration) (time.Duration, bool) {
+	cc := Directives(header.Get("Cache-Control"))
+	if _, ok := cc["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := cc["private"]; ok {
+		return 0, false
+	}
+	if header.Get("Set-Cookie") != "" || header.Get("Vary") != "" {
+		return 0, false
+	}
+
+	ttl := fallback
+	if _, ok := cc["no-cache"]; ok {
+		ttl = 0
+	} else if age, ok := seconds(cc, "s-maxage"); ok {
+		ttl = age
+	} else if age, ok := seconds(cc, "max-age"); ok {
+		ttl = age
+	}
+	return ttl, ttl > 0 || header.Get("ETag") != ""
+}
+
+// Directives parses a Cache-Control header.
+func Directives(header string) map[string]string {
+	cc := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return cc
+}
+
+func seconds(cc map[string]string, name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, true
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// Matches tells whether an If-None-Match header names etag. Weak and strong tags match
+// alike, as a GET compares them.
+func Matches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
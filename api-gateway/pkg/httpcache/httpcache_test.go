@@ -0,0 +1,310 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// entry returns an entry of tag whose size is n bytes under a one byte key.
+func entry(tag string, n int) *Entry {
+	return &Entry{Status: http.StatusOK, Tag: tag, Body: []byte(strings.Repeat("x", n-1))}
+}
+
+func TestGetAndSet(t *testing.T) {
+	c := New(100)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get of an empty cache found an entry")
+	}
+
+	e := entry("products", 10)
+	c.Set("a", e, c.Generation("products"))
+	if got, ok := c.Get("a"); !ok || got != e {
+		t.Errorf("Get = %v, %t, want the entry", got, ok)
+	}
+
+	// Storing again replaces the entry and its size
+	c.Set("a", entry("products", 20), c.Generation("products"))
+	if got := c.Size(); got != 20 {
+		t.Errorf("Size = %d, want 20", got)
+	}
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(30)
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set(key, entry("t", 10), 0)
+	}
+	// a is used, so b is the least recently used one
+	c.Get("a")
+	c.Set("d", entry("t", 10), 0)
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if _, ok := c.Get(key); ok != want {
+			t.Errorf("Get(%q) found %t, want %t", key, ok, want)
+		}
+	}
+	if got := c.Size(); got != 30 {
+		t.Errorf("Size = %d, want 30", got)
+	}
+
+	// A large entry evicts as many as it needs
+	c.Set("e", entry("t", 25), 0)
+	if got := c.Size(); got != 25 {
+		t.Errorf("Size = %d, want 25", got)
+	}
+
+	// One larger than the cache is not stored and evicts nothing
+	c.Set("f", entry("t", 31), 0)
+	if _, ok := c.Get("f"); ok {
+		t.Error("an entry larger than the cache was stored")
+	}
+	if _, ok := c.Get("e"); !ok {
+		t.Error("an entry too large to store evicted another one")
+	}
+}
+
+func TestSize(t *testing.T) {
+	e := &Entry{Header: http.Header{"Etag": {`"v1"`}}, Body: []byte("body")}
+	if got, want := e.size("key"), int64(len("key")+len("Etag")+len(`"v1"`)+len("body")); got != want {
+		t.Errorf("size = %d, want %d", got, want)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	c := New(100)
+
+	c.Set("p", entry("products", 10), c.Generation("products"))
+	c.Set("o", entry("orders", 10), c.Generation("orders"))
+	c.Invalidate("products")
+
+	if _, ok := c.Get("p"); ok {
+		t.Error("an entry of an invalidated tag was found")
+	}
+	if _, ok := c.Get("o"); !ok {
+		t.Error("an entry of another tag was dropped")
+	}
+	// Found invalidated, so removed
+	if got := c.Size(); got != 10 {
+		t.Errorf("Size = %d, want 10", got)
+	}
+
+	// New responses of the tag are stored again
+	c.Set("p", entry("products", 10), c.Generation("products"))
+	if _, ok := c.Get("p"); !ok {
+		t.Error("an entry stored after the invalidation was not found")
+	}
+}
+
+// A response read while a write invalidated its tag may predate the write, it is dropped.
+func TestSetRacingInvalidate(t *testing.T) {
+	c := New(100)
+
+	gen := c.Generation("products")
+	c.Invalidate("products")
+	c.Set("p", entry("products", 10), gen)
+
+	if _, ok := c.Get("p"); ok {
+		t.Error("a response read before the invalidation was stored")
+	}
+	if got := c.Size(); got != 0 {
+		t.Errorf("Size = %d, want 0", got)
+	}
+}
+
+func TestSetRacingPurge(t *testing.T) {
+	c := New(100)
+
+	c.Set("o", entry("orders", 10), c.Generation("orders"))
+
+	// products was never invalidated, the purge has to catch it all the same
+	gen := c.Generation("products")
+	c.Purge()
+	c.Set("p", entry("products", 10), gen)
+
+	if _, ok := c.Get("p"); ok {
+		t.Error("a response read before the purge was stored")
+	}
+	if _, ok := c.Get("o"); ok || c.Size() != 0 {
+		t.Errorf("the purge left entries, Size = %d", c.Size())
+	}
+}
+
+func TestDoCoalesces(t *testing.T) {
+	c := New(100)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	e := entry("t", 10)
+
+	var calls int
+	leader := make(chan *Entry)
+	go func() {
+		got, shared, err := c.Do(context.Background(), "k", func() *Entry {
+			calls++
+			close(started)
+			<-release
+			return e
+		})
+		if shared || err != nil {
+			t.Errorf("leader: shared = %t, err = %v", shared, err)
+		}
+		leader <- got
+	}()
+	<-started
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, shared, err := c.Do(context.Background(), "k", func() *Entry {
+				t.Error("a waiter called fn")
+				return nil
+			})
+			if got != e || !shared || err != nil {
+				t.Errorf("waiter: %v, shared = %t, err = %v", got, shared, err)
+			}
+		}()
+	}
+
+	// Waiters join the flight before it lands
+	waitForFlight(t, c, "k")
+	close(release)
+	wg.Wait()
+	if got := <-leader; got != e || calls != 1 {
+		t.Errorf("leader = %v after %d calls, want the entry after 1", got, calls)
+	}
+}
+
+func TestDoCanceledWaiter(t *testing.T) {
+	c := New(100)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	e := entry("t", 10)
+
+	leader := make(chan *Entry)
+	go func() {
+		got, _, _ := c.Do(context.Background(), "k", func() *Entry {
+			close(started)
+			<-release
+			return e
+		})
+		leader <- got
+	}()
+	<-started
+
+	// The waiter gives up, the leader is not affected
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, shared, err := c.Do(ctx, "k", func() *Entry {
+		t.Error("a canceled waiter called fn")
+		return nil
+	})
+	if got != nil || !shared || !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled waiter: %v, shared = %t, err = %v", got, shared, err)
+	}
+
+	close(release)
+	if got := <-leader; got != e {
+		t.Errorf("leader = %v, want the entry", got)
+	}
+
+	// The flight is over, the next caller leads a new one
+	got, shared, err = c.Do(context.Background(), "k", func() *Entry { return nil })
+	if got != nil || shared || err != nil {
+		t.Errorf("after the flight: %v, shared = %t, err = %v", got, shared, err)
+	}
+}
+
+// waitForFlight waits until the flight of key has waiters, as far as that can be seen
+// from outside: it gives them time to block.
+func waitForFlight(t *testing.T, c *Cache, key string) {
+	t.Helper()
+
+	c.mu.Lock()
+	_, ok := c.flights[key]
+	c.mu.Unlock()
+	if !ok {
+		t.Fatalf("no flight of %s", key)
+	}
+	time.Sleep(20 * time.Millisecond)
+}
+
+func TestLifetime(t *testing.T) {
+	const fallback = time.Minute
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		want     time.Duration
+		storable bool
+	}{
+		{name: "no directives", header: http.Header{}, want: fallback, storable: true},
+		{name: "max-age", header: http.Header{"Cache-Control": {"public, max-age=30"}}, want: 30 * time.Second, storable: true},
+		{name: "s-maxage wins", header: http.Header{"Cache-Control": {"max-age=30, s-maxage=90"}}, want: 90 * time.Second, storable: true},
+		{name: "quoted and in capitals", header: http.Header{"Cache-Control": {`Max-Age="45"`}}, want: 45 * time.Second, storable: true},
+		{name: "invalid max-age", header: http.Header{"Cache-Control": {"max-age=soon"}}, want: 0, storable: false},
+		{name: "invalid max-age with etag", header: http.Header{"Cache-Control": {"max-age=-1"}, "Etag": {`"v1"`}}, want: 0, storable: true},
+		{name: "no-cache", header: http.Header{"Cache-Control": {"no-cache, max-age=30"}}, want: 0, storable: false},
+		{name: "no-cache with etag", header: http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}}, want: 0, storable: true},
+		{name: "no-store", header: http.Header{"Cache-Control": {"no-store, max-age=30"}}, storable: false},
+		{name: "private", header: http.Header{"Cache-Control": {"private, max-age=30"}}, storable: false},
+		{name: "set-cookie", header: http.Header{"Set-Cookie": {"session=1"}}, storable: false},
+		{name: "vary", header: http.Header{"Vary": {"Accept-Language"}}, storable: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, storable := Lifetime(tt.header, fallback)
+			if got != tt.want || storable != tt.storable {
+				t.Errorf("Lifetime = %s, %t, want %s, %t", got, storable, tt.want, tt.storable)
+			}
+		})
+	}
+
+	if _, storable := Lifetime(http.Header{}, 0); storable {
+		t.Error("a response without a lifetime or an ETag is storable")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{ifNoneMatch: `"v1"`, etag: `"v1"`, want: true},
+		{ifNoneMatch: `"v0", "v1"`, etag: `"v1"`, want: true},
+		{ifNoneMatch: `W/"v1"`, etag: `"v1"`, want: true},
+		{ifNoneMatch: `"v1"`, etag: `W/"v1"`, want: true},
+		{ifNoneMatch: `*`, etag: `"v1"`, want: true},
+		{ifNoneMatch: `"v2"`, etag: `"v1"`, want: false},
+		{ifNoneMatch: `"v1"`, etag: ``, want: false},
+		{ifNoneMatch: ``, etag: `"v1"`, want: false},
+		{ifNoneMatch: `*`, etag: ``, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := Matches(tt.ifNoneMatch, tt.etag); got != tt.want {
+			t.Errorf("Matches(%q, %q) = %t, want %t", tt.ifNoneMatch, tt.etag, got, tt.want)
+		}
+	}
+}
+
+func TestFresh(t *testing.T) {
+	now := time.Now()
+	e := &Entry{Expires: now.Add(time.Second)}
+
+	if !e.Fresh(now) {
+		t.Error("an entry before its expiry is stale")
+	}
+	if e.Fresh(now.Add(time.Second)) {
+		t.Error("an entry at its expiry is fresh")
+	}
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// migrateLockTimeout is how long an instance waits for another one to finish migrating.
+const migrateLockTimeout = time.Minute
+
+// Migrator applies the migrations of the gateway. Every change runs under a Postgres
+// advisory lock, so that instances starting at once migrate one after another.
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+	db     *sql.DB
+}
+
+// MigrationStatus is the state of the schema next to the migrations at hand.
+type MigrationStatus struct {
+	Version uint // 0 before the first migration
+	Dirty   bool // The migration of Version failed halfway
+	Latest  uint
+	Pending int
+}
+
+// NewMigrator reads the golang-migrate files at the root of fsys, such as
+// 000001_create_orders.up.sql, and applies them through pool.
+func NewMigrator(pool *pgxpool.Pool, fsys fs.FS) (*Migrator, error) {
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("migrations: %w", err)
+	}
+
+	db := stdlib.OpenDBFromPool(pool)
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrations database: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "pgx5", driver)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrations: %w", err)
+	}
+	m.LockTimeout = migrateLockTimeout
+	m.Log = migrateLogger{}
+
+	return &Migrator{m: m, source: src, db: db}, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Down rolls the last steps migrations back.
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("invalid number of steps: %d", steps)
+	}
+	return m.m.Steps(-steps)
+}
+
+// Force sets the version of the schema without running a migration, and clears the dirty
+// flag. It is for repairing a schema by hand after a migration failed halfway.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+func (m *Migrator) Status() (MigrationStatus, error) {
+	var status MigrationStatus
+
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, err
+	}
+	status.Version, status.Dirty = version, dirty
+
+	for v, err := m.source.First(); ; v, err = m.source.Next(v) {
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return status, err
+		}
+
+		status.Latest = v
+		if v > version {
+			status.Pending++
+		}
+	}
+
+	return status, nil
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr, m.db.Close())
+}
+
+// Migrate applies every pending migration in fsys, see NewMigrator.
+func Migrate(pool *pgxpool.Pool, fsys fs.FS) error {
+	m, err := NewMigrator(pool, fsys)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Up()
+}
+
+// migrateLogger logs the progress of golang-migrate with the default logger.
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...any) {
+	slog.Info("migrate: " + strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
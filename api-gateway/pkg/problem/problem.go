@@ -0,0 +1,104 @@
+// Package problem writes error responses as RFC 7807 problem details, so that the services
+// and the gateway all report errors the same way:
+//
+//	{
+//	  "type": ".../docs/problems.md#validation_failed",
+//	  "title": "Unprocessable Entity",
+//	  "status": 422,
+//	  "detail": "the request failed validation",
+//	  "instance": "/products/",
+//	  "code": "validation_failed",
+//	  "errors": {"price": "must be greater than 0"},
+//	  "request_id": "4f0c2d..."
+//	}
+package problem
+
+import (
+	"net/http"
+
+	"api-gateway/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentType = "application/problem+json"
+
+// typeBase is where the problem types are documented, the code of a problem is the anchor.
+const typeBase = "https://github.com/bakhytzhanjzz/go-ecommerce/blob/main/docs/problems.md#"
+
+// Codes tell problems apart without parsing the detail.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeValidationFailed   = "validation_failed"
+	CodeNotFound           = "not_found"
+	CodeRouteNotFound      = "route_not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeEditConflict       = "edit_conflict"
+	CodeEmailTaken         = "email_taken"
+	CodeInsufficientStock  = "insufficient_stock"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+	CodeBadGateway         = "bad_gateway"
+	CodeServiceUnavailable = "service_unavailable"
+	CodeGatewayTimeout     = "gateway_timeout"
+)
+
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	Code      string            `json:"code"`
+	Errors    map[string]string `json:"errors,omitempty"` // Reason for every invalid field
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   typeBase + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Validation reports the errors of a validator.Validator.
+func Validation(errors map[string]string) *Problem {
+	p := New(http.StatusUnprocessableEntity, CodeValidationFailed, "the request failed validation")
+	p.Errors = errors
+	return p
+}
+
+func BadRequest(detail string) *Problem {
+	return New(http.StatusBadRequest, CodeBadRequest, detail)
+}
+
+func NotFound(detail string) *Problem {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+// Internal hides the cause, which is for the logs only.
+func Internal() *Problem {
+	return New(http.StatusInternalServerError, CodeInternal, "something went wrong")
+}
+
+// Write responds with p and stops the handler chain. The instance is the path of the
+// request and the request ID the one requestid.Middleware gave it.
+func Write(c *gin.Context, p *Problem) {
+	resp := *p
+	resp.Instance = c.Request.URL.Path
+	resp.RequestID = requestid.FromContext(c.Request.Context())
+
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(resp.Status, resp)
+}
+
+// NoRoute responds to requests for routes that do not exist.
+func NoRoute(c *gin.Context) {
+	Write(c, New(http.StatusNotFound, CodeRouteNotFound, "unknown route"))
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneEvery is how many takes pass between two sweeps for full buckets.
+const pruneEvery = 4096
+
+// Memory keeps the buckets in the process, every replica counts on its own.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (m *Memory) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.prune(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = refill(b.tokens, now.Sub(b.updated), limit)
+	b.updated = now
+	b.limit = limit
+
+	if b.tokens < 1 {
+		return result(false, b.tokens, limit), nil
+	}
+	b.tokens--
+	return result(true, b.tokens, limit), nil
+}
+
+// prune forgets the buckets that have filled up again from time to time, a full bucket
+// is the same as none.
+func (m *Memory) prune(now time.Time) {
+	m.takes++
+	if m.takes < pruneEvery {
+		return
+	}
+	m.takes = 0
+
+	for key, b := range m.buckets {
+		if refill(b.tokens, now.Sub(b.updated), b.limit) >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// pruneInterval is how often idle buckets are deleted.
+	pruneInterval = time.Minute
+	// pruneIdle is how long a bucket stays unused before it is deleted. Buckets refill
+	// within a day at any sensible rate, so deleting one changes nothing.
+	pruneIdle = 24 * time.Hour
+)
+
+// Postgres keeps the buckets in the table rate_limit_buckets, so that every replica of the
+// gateway takes from the same ones. Time is read from the clock of the database, so the
+// clocks of the replicas do not matter.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgres returns a store on pool. Idle buckets are deleted in the background until
+// ctx is done.
+func NewPostgres(ctx context.Context, pool *pgxpool.Pool) *Postgres {
+	p := &Postgres{pool: pool}
+	go p.run(ctx)
+	return p
+}
+
+// Take refills the bucket and takes a token in one statement, which only updates the row
+// when a token is left. No row means the request was refused, the bucket is read again
+// then to tell when to retry.
+func (p *Postgres) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	const take = `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at)
+		VALUES ($1, $2::float8 - 1, now())
+		ON CONFLICT (key) DO UPDATE
+		SET tokens = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8) - 1,
+			updated_at = now()
+		WHERE LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8) >= 1
+		RETURNING tokens`
+
+	var tokens float64
+	err := p.pool.QueryRow(ctx, take, key, float64(limit.Burst), limit.Rate).Scan(&tokens)
+	if err == nil {
+		return result(true, tokens, limit), nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Result{}, err
+	}
+
+	const read = `
+		SELECT LEAST($2::float8, tokens + EXTRACT(EPOCH FROM now() - updated_at)::float8 * $3::float8)
+		FROM rate_limit_buckets
+		WHERE key = $1`
+
+	if err := p.pool.QueryRow(ctx, read, key, float64(limit.Burst), limit.Rate).Scan(&tokens); err != nil {
+		return Result{}, err
+	}
+	return result(false, tokens, limit), nil
+}
+
+func (p *Postgres) run(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		const query = `DELETE FROM rate_limit_buckets WHERE updated_at < now() - make_interval(secs => $1)`
+		if _, err := p.pool.Exec(ctx, query, pruneIdle.Seconds()); err != nil && ctx.Err() == nil {
+			slog.Error("ratelimit: cannot delete idle buckets", "error", err)
+		}
+	}
+}
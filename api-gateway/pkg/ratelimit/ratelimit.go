@@ -0,0 +1,94 @@
+// Package ratelimit limits requests with token buckets. A bucket holds up to Burst tokens
+// and gains Rate of them every second, every request takes one and is refused while the
+// bucket is empty. The buckets live in a Store: in the process with Memory, or shared by
+// the replicas of the gateway with Postgres.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+type Config struct {
+	Store string `env:"RATE_LIMIT_STORE" envDefault:"memory"` // memory, or postgres to share the buckets between replicas
+}
+
+// Limit is the size of a bucket and how fast it refills.
+type Limit struct {
+	Rate  float64 // Tokens added per second
+	Burst int     // Tokens the bucket holds at most, the requests allowed at once
+}
+
+// ParseRate reads a rate such as 100/s, 600/m or 1000/h into tokens per second.
+func ParseRate(s string) (float64, error) {
+	count, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("rate %q is not COUNT/UNIT, such as 100/m", s)
+	}
+
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("rate %q does not start with a positive number", s)
+	}
+
+	switch unit {
+	case "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	case "h":
+		return n / 3600, nil
+	default:
+		return 0, fmt.Errorf("rate %q has unit %q, not s, m or h", s, unit)
+	}
+}
+
+// Result tells whether a request was allowed and describes its bucket, for the RateLimit
+// headers of the response.
+type Result struct {
+	Allowed    bool
+	Limit      int           // The burst of the bucket
+	Remaining  int           // Whole tokens left after the request
+	RetryAfter time.Duration // Until the next request would be allowed, zero if it is already
+	Reset      time.Duration // Until the bucket is full again
+}
+
+// Store keeps the buckets.
+type Store interface {
+	// Take takes a token from the bucket key, which is created full when it does not
+	// exist yet.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// refill returns the tokens of a bucket that held tokens elapsed ago.
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	return min(float64(limit.Burst), tokens+max(elapsed.Seconds(), 0)*limit.Rate)
+}
+
+// result describes a bucket holding tokens after the request, which was refused when
+// allowed is false.
+func result(allowed bool, tokens float64, limit Limit) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: max(int(tokens), 0),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(max(s, 0) * float64(time.Second))
+}
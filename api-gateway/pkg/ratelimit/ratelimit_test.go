@@ -0,0 +1,204 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"api-gateway/pkg/postgres/postgrestest"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "10/s", want: 10},
+		{in: "120/m", want: 2},
+		{in: "1800/h", want: 0.5},
+		{in: "0.5/s", want: 0.5},
+		{in: "10", wantErr: true},
+		{in: "10/d", wantErr: true},
+		{in: "0/s", wantErr: true},
+		{in: "-1/s", wantErr: true},
+		{in: "Inf/s", wantErr: true},
+		{in: "many/s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, %v, want %v (error %t)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestResult(t *testing.T) {
+	limit := Limit{Rate: 0.5, Burst: 5}
+
+	tests := []struct {
+		name    string
+		allowed bool
+		tokens  float64
+		want    Result
+	}{
+		{
+			name:    "allowed with tokens left",
+			allowed: true,
+			tokens:  3.5,
+			want:    Result{Allowed: true, Limit: 5, Remaining: 3, Reset: 3 * time.Second},
+		},
+		{
+			name:    "allowed with the last token",
+			allowed: true,
+			tokens:  0,
+			want:    Result{Allowed: true, Limit: 5, Remaining: 0, Reset: 10 * time.Second},
+		},
+		{
+			name:   "refused with part of a token",
+			tokens: 0.25,
+			want:   Result{Limit: 5, Remaining: 0, RetryAfter: 1500 * time.Millisecond, Reset: 9500 * time.Millisecond},
+		},
+		{
+			name:   "refused with an empty bucket",
+			tokens: 0,
+			want:   Result{Limit: 5, Remaining: 0, RetryAfter: 2 * time.Second, Reset: 10 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := result(tt.allowed, tt.tokens, limit); got != tt.want {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefill(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 5}
+
+	tests := []struct {
+		tokens  float64
+		elapsed time.Duration
+		want    float64
+	}{
+		{tokens: 0, elapsed: time.Second, want: 2},
+		{tokens: 1.5, elapsed: 250 * time.Millisecond, want: 2},
+		{tokens: 4, elapsed: time.Hour, want: 5},
+		// A clock that went back adds nothing
+		{tokens: 3, elapsed: -time.Second, want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := refill(tt.tokens, tt.elapsed, limit); got != tt.want {
+			t.Errorf("refill(%v, %s) = %v, want %v", tt.tokens, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	limit := Limit{Rate: 2, Burst: 2}
+	take := func(key string) Result {
+		t.Helper()
+		r, err := m.Take(ctx, key, limit)
+		if err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		return r
+	}
+
+	// A new bucket is full
+	for i := range 2 {
+		if r := take("a"); !r.Allowed || r.Remaining != 1-i {
+			t.Fatalf("take %d = %+v, want allowed", i+1, r)
+		}
+	}
+	if r := take("a"); r.Allowed || r.RetryAfter != 500*time.Millisecond {
+		t.Errorf("take from an empty bucket = %+v, want refused for 500ms", r)
+	}
+
+	// Buckets are per key
+	if r := take("b"); !r.Allowed {
+		t.Errorf("take from another bucket = %+v, want allowed", r)
+	}
+
+	// A refused request takes nothing, half a second refills a token
+	now = now.Add(250 * time.Millisecond)
+	if r := take("a"); r.Allowed || r.RetryAfter != 250*time.Millisecond {
+		t.Errorf("take after 250ms = %+v, want refused for 250ms", r)
+	}
+	now = now.Add(250 * time.Millisecond)
+	if r := take("a"); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("take after 500ms = %+v, want allowed", r)
+	}
+}
+
+func TestMemoryPrunesFullBuckets(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	slow := Limit{Rate: 1.0 / 3600, Burst: 1}
+	fast := Limit{Rate: 1000, Burst: 1}
+	m.Take(ctx, "slow", slow)
+	for i := range pruneEvery - 2 {
+		m.Take(ctx, fmt.Sprint("fast", i), fast)
+	}
+
+	// A second later the fast buckets are full again, the slow one is still empty
+	now = now.Add(time.Second)
+	m.Take(ctx, "slow", slow)
+	if len(m.buckets) != 1 {
+		t.Errorf("%d buckets left, want the slow one", len(m.buckets))
+	}
+	if r, _ := m.Take(ctx, "slow", slow); r.Allowed {
+		t.Errorf("take from the slow bucket = %+v, want refused", r)
+	}
+}
+
+func TestPostgres(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	p := NewPostgres(ctx, postgrestest.New(t))
+
+	// Hardly refills during the test
+	limit := Limit{Rate: 1.0 / 3600, Burst: 2}
+	take := func(key string) Result {
+		t.Helper()
+		r, err := p.Take(ctx, key, limit)
+		if err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		return r
+	}
+
+	for i := range 2 {
+		if r := take("a"); !r.Allowed || r.Remaining != 1-i {
+			t.Fatalf("take %d = %+v, want allowed", i+1, r)
+		}
+	}
+
+	// Refused and read again, for when to retry. A refused request takes nothing.
+	for range 2 {
+		r := take("a")
+		if r.Allowed || r.Remaining != 0 || r.Limit != 2 {
+			t.Errorf("take from an empty bucket = %+v, want refused", r)
+		}
+		if r.RetryAfter <= 59*time.Minute || r.RetryAfter > time.Hour {
+			t.Errorf("RetryAfter = %s, want just under an hour", r.RetryAfter)
+		}
+	}
+
+	if r := take("b"); !r.Allowed {
+		t.Errorf("take from another bucket = %+v, want allowed", r)
+	}
+}
@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"api-gateway/pkg/breaker"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// budgetCap is the most retries an upstream saves up. It is also what an upstream starts
+// with, so that one that was quiet until it failed can still be retried a little.
+const budgetCap = 10
+
+// retryPolicy is how the requests to an upstream are retried.
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	budget    *budget
+}
+
+// budget keeps retries from multiplying the load on an upstream that is down: every
+// request adds ratio to it and every retry takes one.
+type budget struct {
+	mu     sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+func newBudget(ratio float64) *budget {
+	return &budget{ratio: ratio, tokens: budgetCap}
+}
+
+func (b *budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.tokens+b.ratio, budgetCap)
+}
+
+func (b *budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// delay returns how long to wait before retry number attempt, which starts at 1.
+func (r retryPolicy) delay(attempt int) time.Duration {
+	d := min(r.baseDelay<<min(attempt-1, 30), r.maxDelay)
+	return rand.N(d + 1)
+}
+
+// upstreamTransport sends the forwarded requests through the breaker of their upstream and
+// retries those that are safe to repeat, each time on the next instance.
+type upstreamTransport struct {
+	next http.RoundTripper
+}
+
+func (t upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f := req.Context().Value(forwardingKey{}).(*forwarding)
+	up := f.route.upstream
+	up.retry.budget.deposit()
+
+	if err := up.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := up.send(t.next, f, req)
+
+	reason := retryReason(req, resp, err)
+	for attempt := 1; reason != "" && attempt <= up.retry.attempts; attempt++ {
+		if !up.retry.budget.withdraw() {
+			retryBudgetExhausted.WithLabelValues(up.name).Inc()
+			break
+		}
+		if !sleep(req.Context(), up.retry.delay(attempt)) {
+			break
+		}
+
+		// The last answer stands when the breaker opened in the meantime, or no instance
+		// is left
+		if up.allow() != nil {
+			break
+		}
+		in := up.pick()
+		if in == nil {
+			if up.breaker != nil {
+				up.breaker.Release()
+			}
+			break
+		}
+		f.instance.done()
+		f.instance = in
+
+		discard(resp)
+		upstreamRetries.WithLabelValues(up.name, reason).Inc()
+		resp, err = up.send(t.next, f, req)
+		reason = retryReason(req, resp, err)
+	}
+
+	return resp, err
+}
+
+// allow asks the breaker of the upstream whether a request may be sent. An allowed
+// request has to be sent, or released.
+func (p *pool) allow() error {
+	if p.breaker == nil {
+		return nil
+	}
+	defer p.observeBreaker()
+
+	return p.breaker.Allow()
+}
+
+// send sends req to the instance of f and tells the breaker how it went.
+func (p *pool) send(next http.RoundTripper, f *forwarding, req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = f.instance.url.Scheme
+	out.URL.Host = f.instance.url.Host
+	out.URL.Path = f.instance.url.Path + f.c.Request.URL.Path
+
+	resp, err := next.RoundTrip(out)
+	p.record(resp, err, f.c.Request.Context().Err() != nil)
+
+	return resp, err
+}
+
+// record tells the breaker how a request it allowed went: connection errors, timeouts and
+// 5xx responses are failures. A request the client gave up on says nothing about the
+// upstream.
+func (p *pool) record(resp *http.Response, err error, gaveUp bool) {
+	if p.breaker == nil {
+		return
+	}
+
+	switch {
+	case err != nil && gaveUp:
+		p.breaker.Release()
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		p.breaker.Failure()
+	default:
+		p.breaker.Success()
+	}
+	p.observeBreaker()
+}
+
+func (p *pool) observeBreaker() {
+	breakerState.WithLabelValues(p.name).Set(float64(p.breaker.State()))
+}
+
+// retryReason tells why the request is worth another attempt, or returns "" if it is not.
+// Only requests of idempotent methods without a body are retried, bodies are streamed and
+// cannot be sent twice. A request that timed out or was canceled is not retried either.
+func retryReason(req *http.Request, resp *http.Response, err error) string {
+	if !idempotent[req.Method] || (req.Body != nil && req.Body != http.NoBody) {
+		return ""
+	}
+
+	switch {
+	case err != nil:
+		if req.Context().Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+			return ""
+		}
+		return "error"
+	case resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable:
+		return strconv.Itoa(resp.StatusCode)
+	default:
+		return ""
+	}
+}
+
+var idempotent = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// sleep waits for d, false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// discard reads a little of a response that is dropped, so that its connection can be
+// used again.
+func discard(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
+
+// breakerStatus is the state of the breaker of an upstream, as the admin endpoint shows it.
+type breakerStatus struct {
+	State    string `json:"state"`
+	Failures int    `json:"failures"`           // Failed requests in a row
+	RetryIn  string `json:"retry_in,omitempty"` // Until an open breaker lets a probe through
+}
+
+// breakers answers with the state of the breaker of every upstream of the route table in
+// use. Upstreams whose breaker is turned off are missing.
+func (g *Gateway) breakers(c *gin.Context) {
+	upstreams := make(map[string]breakerStatus)
+	for name, p := range g.table.Load().upstreams {
+		if p.breaker == nil {
+			continue
+		}
+
+		s := p.breaker.Status()
+		status := breakerStatus{State: s.State.String(), Failures: s.Failures}
+		if s.State == breaker.StateOpen {
+			status.RetryIn = s.RetryIn.Round(time.Millisecond).String()
+		}
+		upstreams[name] = status
+	}
+
+	c.JSON(http.StatusOK, gin.H{"upstreams": upstreams})
+}
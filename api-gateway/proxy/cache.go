@@ -0,0 +1,285 @@
+package proxy
+
+import (
+	"api-gateway/pkg/apikey"
+	"api-gateway/pkg/httpcache"
+	"bytes"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Results of a lookup in the cache, labels of the metrics and values of X-Cache.
+const (
+	cacheHit         = "hit"
+	cacheMiss        = "miss"
+	cacheRevalidated = "revalidated" // A stale response the upstream confirmed with 304
+	cacheCoalesced   = "coalesced"   // Served the response another request was fetching
+	cacheBypass      = "bypass"
+)
+
+// cache serves GET and HEAD requests from the cache of the gateway, and stores the
+// responses to GET that the upstream lets a shared cache keep: fresh for their max-age,
+// or for the ttl of the route when they do not say. A stale response with an ETag is
+// revalidated. Concurrent misses wait for the first one, which alone asks the upstream.
+// A successful write through the route drops the responses of the route and of the
+// routes it invalidates.
+func cache(g *Gateway, rt *route) Middleware {
+	return func(next gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			req := c.Request
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				next(c)
+				if c.Writer.Status() < http.StatusBadRequest {
+					g.cache.Invalidate(rt.invalidates...)
+				}
+				return
+			}
+			if !cacheable(req) {
+				g.lookedUp(c, rt, cacheBypass)
+				next(c)
+				return
+			}
+
+			key := rt.name + " " + req.URL.Path + "?" + req.URL.Query().Encode()
+			stale, ok := g.cache.Get(key)
+			if ok && stale.Fresh(time.Now()) && !revalidate(req) {
+				g.serveCached(c, rt, stale, cacheHit)
+				return
+			}
+			if req.Method == http.MethodHead {
+				g.lookedUp(c, rt, cacheMiss)
+				next(c)
+				return
+			}
+
+			gen := g.cache.Generation(rt.name)
+			e, shared, err := g.cache.Do(req.Context(), key, func() *httpcache.Entry {
+				return g.fetch(c, rt, next, key, stale, gen)
+			})
+			switch {
+			case err != nil || !shared:
+				// The client is gone, or this request fetched the response and answered
+			case e != nil:
+				g.serveCached(c, rt, e, cacheCoalesced)
+			default:
+				g.lookedUp(c, rt, cacheMiss)
+				next(c)
+			}
+		}
+	}
+}
+
+// fetch forwards the request, stores the response if it may be kept and answers the
+// client. A stale entry with an ETag is revalidated, a 304 refreshes it. It returns the
+// entry for the requests that waited, nil if the response was not stored.
+func (g *Gateway) fetch(c *gin.Context, rt *route, next gin.HandlerFunc, key string, stale *httpcache.Entry, gen uint64) *httpcache.Entry {
+	before := c.Writer.Header().Clone()
+	revalidating := stale != nil && stale.ETag() != ""
+	ifNoneMatch := c.Request.Header.Values("If-None-Match")
+	if revalidating {
+		c.Request.Header.Set("If-None-Match", stale.ETag())
+	}
+
+	rec := &recorder{ResponseWriter: c.Writer, limit: g.cfg.Cache.MaxEntryBytes}
+	c.Writer = rec
+	next(c)
+	c.Writer = rec.ResponseWriter
+
+	if revalidating {
+		c.Request.Header["If-None-Match"] = ifNoneMatch
+	}
+
+	// Too large to keep, it went to the client as it came
+	if rec.spilled {
+		g.lookedUp(c, rt, cacheMiss)
+		return nil
+	}
+
+	now := time.Now()
+	header := upstreamHeader(c.Writer.Header(), before)
+
+	if revalidating && rec.status == http.StatusNotModified {
+		refreshed := stale.Header.Clone()
+		for k, vs := range header {
+			refreshed[k] = vs
+		}
+		e := &httpcache.Entry{Status: stale.Status, Header: refreshed, Body: stale.Body, Tag: rt.name, Stored: now}
+		if ttl, ok := httpcache.Lifetime(refreshed, rt.cacheTTL); ok {
+			e.Expires = now.Add(ttl)
+			g.store(key, e, gen)
+		}
+
+		// The 304 answered the gateway, not the client
+		for k := range c.Writer.Header() {
+			if _, ok := before[k]; !ok {
+				c.Writer.Header().Del(k)
+			}
+		}
+		g.serveCached(c, rt, e, cacheRevalidated)
+		return e
+	}
+
+	var stored *httpcache.Entry
+	if rec.status == http.StatusOK && !noStore(c.Request) {
+		if ttl, ok := httpcache.Lifetime(header, rt.cacheTTL); ok {
+			stored = &httpcache.Entry{Status: rec.status, Header: header, Body: rec.body.Bytes(), Tag: rt.name, Stored: now, Expires: now.Add(ttl)}
+			g.store(key, stored, gen)
+		}
+	}
+
+	g.lookedUp(c, rt, cacheMiss)
+	rec.flush()
+	return stored
+}
+
+func (g *Gateway) store(key string, e *httpcache.Entry, gen uint64) {
+	g.cache.Set(key, e, gen)
+	cacheBytes.Set(float64(g.cache.Size()))
+}
+
+// serveCached answers with e, or with 304 when the client already has it.
+func (g *Gateway) serveCached(c *gin.Context, rt *route, e *httpcache.Entry, result string) {
+	h := c.Writer.Header()
+	for k, vs := range e.Header {
+		h[k] = slices.Clone(vs)
+	}
+	h.Set("Age", strconv.Itoa(int(time.Since(e.Stored).Seconds())))
+	g.lookedUp(c, rt, result)
+
+	if httpcache.Matches(c.Request.Header.Get("If-None-Match"), e.ETag()) {
+		h.Del("Content-Length")
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	h.Set("Content-Length", strconv.Itoa(len(e.Body)))
+	c.Status(e.Status)
+	c.Writer.Write(e.Body)
+}
+
+// lookedUp counts the lookup and tells the client how it went in X-Cache.
+func (g *Gateway) lookedUp(c *gin.Context, rt *route, result string) {
+	cacheLookups.WithLabelValues(rt.name, result).Inc()
+	c.Header("X-Cache", strings.ToUpper(result))
+}
+
+// cacheable tells whether the response to req may be shared with other clients. Requests
+// with credentials get answers of their own, upgrades are not responses to keep.
+func cacheable(req *http.Request) bool {
+	return req.Header.Get("Authorization") == "" && req.Header.Get(apikey.Header) == "" &&
+		req.Header.Get("Upgrade") == "" && !noStore(req)
+}
+
+// revalidate tells whether the client asked for a response the upstream confirmed.
+func revalidate(req *http.Request) bool {
+	cc := httpcache.Directives(req.Header.Get("Cache-Control"))
+	_, noCache := cc["no-cache"]
+	return noCache || cc["max-age"] == "0" || req.Header.Get("Pragma") == "no-cache"
+}
+
+func noStore(req *http.Request) bool {
+	_, ok := httpcache.Directives(req.Header.Get("Cache-Control"))["no-store"]
+	return ok
+}
+
+// upstreamHeader returns the headers of h that the response added to before, those set by
+// the gateway for this request alone (X-Request-ID, RateLimit-*) are left out.
+func upstreamHeader(h, before http.Header) http.Header {
+	out := make(http.Header)
+	for k, vs := range h {
+		if !slices.Equal(vs, before[k]) {
+			out[k] = slices.Clone(vs)
+		}
+	}
+	return out
+}
+
+// recorder holds a response back until it is over, so that it can be stored. A response
+// that grows beyond limit is passed on as it comes and not stored.
+type recorder struct {
+	gin.ResponseWriter
+	limit   int64
+	status  int
+	body    bytes.Buffer
+	spilled bool
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.spilled {
+		r.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *recorder) WriteHeaderNow() {
+	if r.spilled {
+		r.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.WriteHeaderNow()
+	if !r.spilled && int64(r.body.Len()+len(b)) > r.limit {
+		r.spilled = true
+		r.ResponseWriter.WriteHeader(r.status)
+		if _, err := r.ResponseWriter.Write(r.body.Bytes()); err != nil {
+			return 0, err
+		}
+		r.body.Reset()
+	}
+	if r.spilled {
+		return r.ResponseWriter.Write(b)
+	}
+	return r.body.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	return r.Write([]byte(s))
+}
+
+func (r *recorder) Status() int {
+	if r.status == 0 {
+		return r.ResponseWriter.Status()
+	}
+	return r.status
+}
+
+func (r *recorder) Written() bool {
+	return r.status != 0 || r.ResponseWriter.Written()
+}
+
+func (r *recorder) Size() int {
+	if r.spilled || r.status == 0 {
+		return r.ResponseWriter.Size()
+	}
+	return r.body.Len()
+}
+
+// Flush passes a spilled response on, a recorded one is sent once it is over.
+func (r *recorder) Flush() {
+	if r.spilled {
+		r.ResponseWriter.Flush()
+	}
+}
+
+// flush sends the recorded response to the client.
+func (r *recorder) flush() {
+	if r.spilled || r.status == 0 {
+		return
+	}
+	r.ResponseWriter.WriteHeader(r.status)
+	r.ResponseWriter.WriteHeaderNow()
+	r.ResponseWriter.Write(r.body.Bytes())
+}
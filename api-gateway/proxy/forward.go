@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"api-gateway/config"
+	"api-gateway/pkg/breaker"
+	"api-gateway/pkg/problem"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// newTransport returns the transport shared by the requests to every upstream, so that
+// connections are pooled across routes and reloads. It passes the trace context on to
+// the services.
+func newTransport(cfg config.Transport) http.RoundTripper {
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}
+
+	return otelhttp.NewTransport(&http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	})
+}
+
+// forwarding is a request on its way to an instance. The reverse proxy is shared by every
+// route, it finds the forwarding in the context of the request.
+type forwarding struct {
+	c        *gin.Context
+	route    *route
+	instance *instance
+	start    time.Time
+}
+
+type forwardingKey struct{}
+
+// newReverseProxy returns the proxy that sends the requests to the instance picked for
+// them. It drops the hop-by-hop headers and the X-Forwarded-* headers of the client, sets
+// X-Forwarded-For, -Host and -Proto, and streams both bodies. Requests go through the
+// breaker of their upstream and are retried when that is safe.
+func newReverseProxy(transport http.RoundTripper) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Transport: upstreamTransport{next: transport},
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			f := pr.In.Context().Value(forwardingKey{}).(*forwarding)
+			pr.SetURL(f.instance.url)
+			pr.SetXForwarded()
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			f := resp.Request.Context().Value(forwardingKey{}).(*forwarding)
+			name := f.route.upstream.name
+			upstreamDuration.WithLabelValues(name).Observe(time.Since(f.start).Seconds())
+			upstreamRequests.WithLabelValues(name, strconv.Itoa(resp.StatusCode)).Inc()
+
+			// The headers of the service replace those of the gateway (e.g. X-Request-ID)
+			for k := range resp.Header {
+				f.c.Writer.Header().Del(k)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			f := r.Context().Value(forwardingKey{}).(*forwarding)
+			name := f.route.upstream.name
+
+			// The upstream failed too often lately, it is given time to recover
+			if errors.Is(err, breaker.ErrOpen) {
+				upstreamRequests.WithLabelValues(name, "breaker_open").Inc()
+				problem.Write(f.c, f.route.upstream.breakerOpen(f.c))
+				return
+			}
+
+			upstreamDuration.WithLabelValues(name).Observe(time.Since(f.start).Seconds())
+			upstreamRequests.WithLabelValues(name, "error").Inc()
+
+			ctx := f.c.Request.Context()
+			target := f.instance.url.String() + r.URL.Path
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				slog.WarnContext(ctx, "proxy: target service timed out", "route", f.route.name, "target", target, "timeout", f.route.timeout)
+				problem.Write(f.c, problem.New(http.StatusGatewayTimeout, problem.CodeGatewayTimeout, "target service did not respond in time"))
+				return
+			}
+			slog.WarnContext(ctx, "proxy: target service unavailable", "route", f.route.name, "target", target, "error", err)
+			problem.Write(f.c, problem.New(http.StatusBadGateway, problem.CodeBadGateway, "target service unavailable"))
+		},
+	}
+}
+
+// breakerOpen sets Retry-After for when the open breaker of the upstream lets a request
+// through again, and returns the problem to answer with.
+func (p *pool) breakerOpen(c *gin.Context) *problem.Problem {
+	retryIn := max(ceilSeconds(p.breaker.Status().RetryIn), 1)
+	c.Header("Retry-After", strconv.Itoa(retryIn))
+	return problem.New(http.StatusServiceUnavailable, problem.CodeServiceUnavailable,
+		fmt.Sprintf("the circuit breaker of %s is open after repeated failures, retry in %ds", p.name, retryIn))
+}
+
+// forward sends the request to an instance of the upstream of the route and streams the
+// response back.
+func (g *Gateway) forward(rt *route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		in := rt.upstream.pick()
+		if in == nil {
+			upstreamRequests.WithLabelValues(rt.upstream.name, "unavailable").Inc()
+			problem.Write(c, problem.New(http.StatusServiceUnavailable, problem.CodeServiceUnavailable, "no instance of the target service is healthy"))
+			return
+		}
+		// Retries move the request to another instance
+		f := &forwarding{c: c, route: rt, instance: in, start: time.Now()}
+		defer func() { f.instance.done() }()
+
+		// The request is canceled along with the incoming one, or when the route times out
+		ctx := c.Request.Context()
+		if rt.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, rt.timeout)
+			defer cancel()
+		}
+
+		g.proxy.ServeHTTP(c.Writer, c.Request.WithContext(context.WithValue(ctx, forwardingKey{}, f)))
+	}
+}
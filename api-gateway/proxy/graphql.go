@@ -0,0 +1,386 @@
+package proxy
+
+import (
+	"api-gateway/pkg/breaker"
+	"api-gateway/pkg/problem"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// maxQueryBody bounds the body of a GraphQL request.
+	maxQueryBody = 1 << 20
+	// maxQueryDepth bounds how deep a query nests its fields.
+	maxQueryDepth = 10
+	// maxBatchIDs is how many products inventory-service reads in one batch.
+	maxBatchIDs = 100
+	// maxUpstreamCalls bounds the requests to the services one query may make, the
+	// depth alone allows nested lists to fan out a million times.
+	maxUpstreamCalls = 50
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// graphqlRequest is a GraphQL request, in the body of a POST or in the query of a GET.
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// graphqlView answers GraphQL queries over the orders of the upstream of the route, the
+// products of the products source and the categories of the categories source. The
+// resolvers call the REST APIs of the services with the identity of the caller. The
+// products of the orders a request reads are fetched in batches, not one by one, and a page
+// of orders is read once however often the query nests it. A query makes at most
+// maxUpstreamCalls requests. GET requests may only query, mutations need POST.
+func graphqlView(g *Gateway, rt *route) gin.HandlerFunc {
+	schema := graphql.MustParseSchema(schemaSDL, &resolver{g: g, rt: rt},
+		graphql.MaxDepth(maxQueryDepth), graphql.MaxParallelism(viewConcurrency))
+
+	return func(c *gin.Context) {
+		req, err := readGraphQLRequest(c)
+		if err != nil {
+			graphqlRequests.WithLabelValues(rt.name, "invalid").Inc()
+			problem.Write(c, problem.BadRequest(err.Error()))
+			return
+		}
+
+		ctx := c.Request.Context()
+		if rt.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, rt.timeout)
+			defer cancel()
+		}
+		s := &session{header: viewHeader(c.Request.Header), readOnly: c.Request.Method != http.MethodPost}
+		s.products = &productLoader{fetch: func(ctx context.Context, ids []int64) (map[int64]*productDoc, error) {
+			return fetchProducts(ctx, g, rt, ids)
+		}}
+		s.orders = &orderLoader{fetch: func(ctx context.Context, query url.Values) ([]orderDoc, int, error) {
+			return readOrders(ctx, g, rt, query)
+		}}
+		ctx = context.WithValue(ctx, sessionKey{}, s)
+
+		resp := schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+		result := "ok"
+		if len(resp.Errors) > 0 {
+			result = "errors"
+		}
+		graphqlRequests.WithLabelValues(rt.name, result).Inc()
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// readGraphQLRequest reads the request from the query of a GET or the JSON body of a POST.
+func readGraphQLRequest(c *gin.Context) (graphqlRequest, error) {
+	var req graphqlRequest
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if v := c.Query("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return req, fmt.Errorf("invalid variables: %w", err)
+			}
+		}
+	} else {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxQueryBody+1))
+		if err != nil {
+			return req, err
+		}
+		if len(body) > maxQueryBody {
+			return req, fmt.Errorf("the request is larger than %d bytes", maxQueryBody)
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return req, fmt.Errorf("invalid request: %w", err)
+		}
+	}
+
+	if req.Query == "" {
+		return req, errors.New("the request has no query")
+	}
+	return req, nil
+}
+
+type sessionKey struct{}
+
+// session is what the resolvers of one request share: the headers passed on to the
+// services and what was read already.
+type session struct {
+	header   http.Header
+	readOnly bool // A GET, which may not change anything
+	products *productLoader
+	orders   *orderLoader
+	calls    atomic.Int32 // Requests made to the services
+}
+
+func sessionFrom(ctx context.Context) *session {
+	return ctx.Value(sessionKey{}).(*session)
+}
+
+// graphqlError is a problem reported in the errors of a GraphQL response, with its code
+// and status in the extensions.
+type graphqlError struct {
+	*problem.Problem
+}
+
+func (e graphqlError) Error() string {
+	if e.Detail == "" {
+		return e.Title
+	}
+	return e.Detail
+}
+
+func (e graphqlError) Extensions() map[string]any {
+	return map[string]any{"code": e.Code, "status": e.Status}
+}
+
+// notFound tells whether err is a 404 of a service, which a lookup answers with null.
+func notFound(err error) bool {
+	var e graphqlError
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// call sends a request to p and decodes the response into out. Failures to reach p and
+// the problems p answers are returned as graphqlError, as is a request over the
+// maxUpstreamCalls of the query.
+func call(ctx context.Context, g *Gateway, p *pool, source, method, path string, in, out any) error {
+	if p == nil {
+		return graphqlError{problem.New(http.StatusServiceUnavailable, problem.CodeServiceUnavailable, fmt.Sprintf("the route has no source %s", source))}
+	}
+	if sessionFrom(ctx).calls.Add(1) > maxUpstreamCalls {
+		return graphqlError{problem.BadRequest(fmt.Sprintf("the query needs more than %d requests to the services", maxUpstreamCalls))}
+	}
+
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	resp, respBody, err := p.call(ctx, g.client, method, path, sessionFrom(ctx).header, body)
+	switch {
+	case errors.Is(err, breaker.ErrOpen):
+		return graphqlError{problem.New(http.StatusServiceUnavailable, problem.CodeServiceUnavailable, fmt.Sprintf("the circuit breaker of %s is open", p.name))}
+	case errors.Is(err, errNoHealthyInstance):
+		return graphqlError{problem.New(http.StatusServiceUnavailable, problem.CodeServiceUnavailable, fmt.Sprintf("no instance of %s is healthy", p.name))}
+	case errors.Is(err, context.DeadlineExceeded):
+		return graphqlError{problem.New(http.StatusGatewayTimeout, problem.CodeGatewayTimeout, fmt.Sprintf("%s did not respond in time", p.name))}
+	case err != nil:
+		return graphqlError{problem.New(http.StatusBadGateway, problem.CodeBadGateway, fmt.Sprintf("%s unavailable", p.name))}
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var pr problem.Problem
+		if json.Unmarshal(respBody, &pr) != nil || pr.Code == "" {
+			pr = *problem.New(resp.StatusCode, problem.CodeBadGateway, fmt.Sprintf("%s answered %d", p.name, resp.StatusCode))
+		}
+		pr.Status = resp.StatusCode
+		return graphqlError{&pr}
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return graphqlError{problem.New(http.StatusBadGateway, problem.CodeBadGateway, fmt.Sprintf("the response of %s could not be read", p.name))}
+	}
+	return nil
+}
+
+// readOrders reads the page of the orders the caller may see that query asks for, and how
+// many orders match it.
+func readOrders(ctx context.Context, g *Gateway, rt *route, query url.Values) ([]orderDoc, int, error) {
+	var doc struct {
+		Orders   []orderDoc `json:"orders"`
+		Metadata struct {
+			TotalRecords int `json:"total_records"`
+		} `json:"metadata"`
+	}
+	if err := call(ctx, g, rt.upstream, "orders", http.MethodGet, "/orders/?"+query.Encode(), nil, &doc); err != nil {
+		return nil, 0, err
+	}
+	return doc.Orders, doc.Metadata.TotalRecords, nil
+}
+
+// fetchProducts reads the products with ids from the products source, in batches of
+// maxBatchIDs. Products that do not exist are left out.
+func fetchProducts(ctx context.Context, g *Gateway, rt *route, ids []int64) (map[int64]*productDoc, error) {
+	found := make(map[int64]*productDoc, len(ids))
+	for chunk := range slices.Chunk(ids, maxBatchIDs) {
+		var doc struct {
+			Inventory []*productDoc `json:"inventory"`
+		}
+		if err := call(ctx, g, rt.sources["products"], "products", http.MethodPost, "/products/batch/get", map[string]any{"ids": chunk}, &doc); err != nil {
+			return nil, err
+		}
+		for _, p := range doc.Inventory {
+			found[p.ID] = p
+		}
+	}
+	return found, nil
+}
+
+// productLoader reads products for the resolvers of a request. The IDs it is primed with
+// are read together with the first product a resolver asks for, so that the products of
+// a list of orders take one request instead of one per line. A product is read once.
+type productLoader struct {
+	fetch func(ctx context.Context, ids []int64) (map[int64]*productDoc, error)
+
+	mu      sync.Mutex
+	pending []int64
+	batches map[int64]*productBatch
+}
+
+// productBatch is one read of several products, done once done is closed.
+type productBatch struct {
+	done  chan struct{}
+	found map[int64]*productDoc
+	err   error
+}
+
+// prime has the next read take ids along.
+func (l *productLoader) prime(ids ...int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := l.batches[id]; !ok && !slices.Contains(l.pending, id) {
+			l.pending = append(l.pending, id)
+		}
+	}
+}
+
+// add keeps products read otherwise, such as by a list, so that they are not read again.
+func (l *productLoader) add(products ...*productDoc) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := &productBatch{done: make(chan struct{}), found: make(map[int64]*productDoc, len(products))}
+	close(b.done)
+	for _, p := range products {
+		if _, ok := l.batches[p.ID]; !ok {
+			b.found[p.ID] = p
+			l.setBatch(p.ID, b)
+		}
+	}
+}
+
+// load returns the product with id, nil if it does not exist. A product that is not read
+// yet is read together with the pending IDs.
+func (l *productLoader) load(ctx context.Context, id int64) (*productDoc, error) {
+	l.mu.Lock()
+	b, ok := l.batches[id]
+	if !ok {
+		ids := l.pending
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+		l.pending = nil
+
+		b = &productBatch{done: make(chan struct{})}
+		for _, id := range ids {
+			l.setBatch(id, b)
+		}
+		l.mu.Unlock()
+
+		b.found, b.err = l.fetch(ctx, ids)
+		close(b.done)
+	} else {
+		l.mu.Unlock()
+	}
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return b.found[id], b.err
+}
+
+func (l *productLoader) setBatch(id int64, b *productBatch) {
+	if l.batches == nil {
+		l.batches = make(map[int64]*productBatch)
+	}
+	l.batches[id] = b
+	l.pending = slices.DeleteFunc(l.pending, func(p int64) bool { return p == id })
+}
+
+// orderLoader reads pages of orders for the resolvers of a request. A page is read once,
+// so that the orders of a customer nested again under its own orders take one request.
+type orderLoader struct {
+	fetch func(ctx context.Context, query url.Values) ([]orderDoc, int, error)
+
+	mu    sync.Mutex
+	pages map[string]*orderRead
+}
+
+// orderRead is one read of a page of orders, done once done is closed.
+type orderRead struct {
+	done   chan struct{}
+	orders []orderDoc
+	total  int
+	err    error
+}
+
+// load returns the page of orders that query asks for and how many orders match it.
+func (l *orderLoader) load(ctx context.Context, query url.Values) ([]orderDoc, int, error) {
+	key := query.Encode()
+
+	l.mu.Lock()
+	r, ok := l.pages[key]
+	if !ok {
+		if l.pages == nil {
+			l.pages = make(map[string]*orderRead)
+		}
+		r = &orderRead{done: make(chan struct{})}
+		l.pages[key] = r
+		l.mu.Unlock()
+
+		r.orders, r.total, r.err = l.fetch(ctx, query)
+		close(r.done)
+	} else {
+		l.mu.Unlock()
+	}
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+	return r.orders, r.total, r.err
+}
+
+// productIDs returns the products of the lines of orders, each once.
+func productIDs(orders []orderDoc) []int64 {
+	var ids []int64
+	for _, o := range orders {
+		for _, item := range o.Items {
+			if !slices.Contains(ids, item.ProductID) {
+				ids = append(ids, item.ProductID)
+			}
+		}
+	}
+	return ids
+}
+
+// parseID reads the numeric ID of a product or an order.
+func parseID(kind string, id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, graphqlError{problem.BadRequest(fmt.Sprintf("invalid %s ID %q", kind, id))}
+	}
+	return n, nil
+}
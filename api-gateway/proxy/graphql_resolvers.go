@@ -0,0 +1,594 @@
+package proxy
+
+import (
+	"api-gateway/pkg/authz"
+	"api-gateway/pkg/problem"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// maxPageSize bounds the pageSize of a list, as inventory-service does.
+	maxPageSize = 100
+	// maxScannedProducts bounds how many products a filter of the products reads.
+	maxScannedProducts = 1000
+)
+
+// productDoc is a product as inventory-service sends it.
+type productDoc struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Price       float64   `json:"price"`
+	Available   int64     `json:"available"`
+	Version     int32     `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// orderDoc is an order as order-service sends it.
+type orderDoc struct {
+	OrderID      int64       `json:"order_id"`
+	CustomerName string      `json:"customer_name"`
+	CustomerID   string      `json:"customer_id"`
+	Items        []orderLine `json:"items"`
+	Status       string      `json:"status"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// orderLine is a line of an order, as order-service sends it and takes it.
+type orderLine struct {
+	ProductID int64 `json:"product_id"`
+	Quantity  int64 `json:"quantity"`
+}
+
+// categoryDoc is a category as the catalog sends it.
+type categoryDoc struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// resolver resolves the queries and the mutations of the schema for a route.
+type resolver struct {
+	g  *Gateway
+	rt *route
+}
+
+type pageArgs struct {
+	Page     int32
+	PageSize int32
+}
+
+type productFilter struct {
+	IDs      *[]graphql.ID
+	Name     *string
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  *bool
+}
+
+type categoryFilter struct {
+	Name *string
+}
+
+type orderFilter struct {
+	Status        *string
+	CustomerID    *graphql.ID
+	ProductID     *graphql.ID
+	CreatedAfter  *graphql.Time
+	CreatedBefore *graphql.Time
+}
+
+type ordersArgs struct {
+	Filter *orderFilter
+	pageArgs
+}
+
+func (r *resolver) Product(ctx context.Context, args struct{ ID graphql.ID }) (*productResolver, error) {
+	id, err := parseID("product", args.ID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := sessionFrom(ctx).products.load(ctx, id)
+	if p == nil || err != nil {
+		return nil, err
+	}
+	return &productResolver{p}, nil
+}
+
+// Products reads a page of inventory-service. With a filter the gateway reads the products
+// page by page and picks those that match.
+func (r *resolver) Products(ctx context.Context, args struct {
+	Filter *productFilter
+	Sort   string
+	pageArgs
+}) (*productPage, error) {
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	s := sessionFrom(ctx)
+	sort := strings.ToLower(args.Sort)
+	if name, ok := strings.CutSuffix(sort, "_desc"); ok {
+		sort = "-" + name
+	}
+
+	if args.Filter == nil {
+		products, total, _, err := r.readProducts(ctx, sort, int(args.Page), int(args.PageSize))
+		if err != nil {
+			return nil, err
+		}
+		s.products.add(products...)
+		return &productPage{products, pageInfo{args.pageArgs, total}}, nil
+	}
+
+	var products []*productDoc
+	if args.Filter.IDs != nil {
+		ids := make([]int64, 0, len(*args.Filter.IDs))
+		for _, id := range *args.Filter.IDs {
+			n, err := parseID("product", id)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, n)
+		}
+		s.products.prime(ids...)
+		for _, id := range ids {
+			p, err := s.products.load(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if p != nil && !slices.Contains(products, p) {
+				products = append(products, p)
+			}
+		}
+		slices.SortStableFunc(products, productOrder(sort))
+	} else {
+		for page := 1; ; page++ {
+			read, _, last, err := r.readProducts(ctx, sort, page, maxPageSize)
+			if err != nil {
+				return nil, err
+			}
+			s.products.add(read...)
+			products = append(products, read...)
+			if page >= last {
+				break
+			}
+			if len(products) >= maxScannedProducts {
+				return nil, graphqlError{problem.BadRequest(fmt.Sprintf("the filter reads at most %d products, narrow it down with ids", maxScannedProducts))}
+			}
+		}
+	}
+
+	products = slices.DeleteFunc(products, func(p *productDoc) bool { return !args.Filter.matches(p) })
+	return newProductPage(products, args.pageArgs), nil
+}
+
+// readProducts reads a page of inventory-service, with the total of the products and the
+// number of the last page.
+func (r *resolver) readProducts(ctx context.Context, sort string, page, size int) ([]*productDoc, int, int, error) {
+	var doc struct {
+		Inventory []*productDoc `json:"inventory"`
+		Metadata  struct {
+			LastPage     int `json:"last_page"`
+			TotalRecords int `json:"total_records"`
+		} `json:"metadata"`
+	}
+	query := url.Values{"sort": {sort}, "page": {strconv.Itoa(page)}, "page_size": {strconv.Itoa(size)}}
+	err := call(ctx, r.g, r.rt.sources["products"], "products", http.MethodGet, "/products/?"+query.Encode(), nil, &doc)
+	return doc.Inventory, doc.Metadata.TotalRecords, doc.Metadata.LastPage, err
+}
+
+func (r *resolver) Category(ctx context.Context, args struct{ ID graphql.ID }) (*categoryResolver, error) {
+	var c categoryDoc
+	err := call(ctx, r.g, r.rt.sources["categories"], "categories", http.MethodGet, "/api/v1/categories/"+url.PathEscape(string(args.ID)), nil, &c)
+	if notFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &categoryResolver{&c}, nil
+}
+
+// Categories reads every category of the catalog, which has no pages of its own.
+func (r *resolver) Categories(ctx context.Context, args struct {
+	Filter *categoryFilter
+	pageArgs
+}) (*categoryPage, error) {
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+
+	var categories []*categoryDoc
+	if err := call(ctx, r.g, r.rt.sources["categories"], "categories", http.MethodGet, "/api/v1/categories", nil, &categories); err != nil {
+		return nil, err
+	}
+	if args.Filter != nil && args.Filter.Name != nil {
+		categories = slices.DeleteFunc(categories, func(c *categoryDoc) bool { return !containsFold(c.Name, *args.Filter.Name) })
+	}
+
+	items, info := paginate(categories, args.pageArgs)
+	page := &categoryPage{info: info}
+	for _, c := range items {
+		page.items = append(page.items, &categoryResolver{c})
+	}
+	return page, nil
+}
+
+func (r *resolver) Order(ctx context.Context, args struct{ ID graphql.ID }) (*orderResolver, error) {
+	id, err := parseID("order", args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Order orderDoc `json:"order"`
+	}
+	err = call(ctx, r.g, r.rt.upstream, "orders", http.MethodGet, fmt.Sprintf("/orders/%d", id), nil, &doc)
+	if notFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.newOrders(ctx, doc.Order)[0], nil
+}
+
+func (r *resolver) Orders(ctx context.Context, args ordersArgs) (*orderPage, error) {
+	return r.orderPage(ctx, args, nil)
+}
+
+func (r *resolver) Customer(ctx context.Context, args struct{ ID graphql.ID }) (*customerResolver, error) {
+	// The orders are newest first, the latest has the current name
+	orders, _, err := sessionFrom(ctx).orders.load(ctx, url.Values{"customer_id": {string(args.ID)}, "page_size": {"1"}})
+	if err != nil || len(orders) == 0 {
+		return nil, err
+	}
+	return &customerResolver{r: r, id: orders[0].CustomerID, name: orders[0].CustomerName}, nil
+}
+
+func (r *resolver) CreateOrder(ctx context.Context, args struct {
+	Input struct {
+		CustomerName string
+		Items        []struct {
+			ProductID graphql.ID
+			Quantity  int32
+		}
+	}
+}) (*orderResolver, error) {
+	if err := mutable(ctx); err != nil {
+		return nil, err
+	}
+
+	lines := make([]orderLine, 0, len(args.Input.Items))
+	for _, item := range args.Input.Items {
+		id, err := parseID("product", item.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, orderLine{id, int64(item.Quantity)})
+	}
+
+	var placed struct {
+		Order struct {
+			OrderID int64 `json:"order_id"`
+		} `json:"order"`
+	}
+	err := call(ctx, r.g, r.rt.upstream, "orders", http.MethodPost, "/orders/", map[string]any{"customer_name": args.Input.CustomerName, "items": lines}, &placed)
+	if err != nil {
+		return nil, err
+	}
+
+	// The answer to the placement lacks the status and the time of the order
+	var doc struct {
+		Order orderDoc `json:"order"`
+	}
+	if err := call(ctx, r.g, r.rt.upstream, "orders", http.MethodGet, fmt.Sprintf("/orders/%d", placed.Order.OrderID), nil, &doc); err != nil {
+		return nil, err
+	}
+	return r.newOrders(ctx, doc.Order)[0], nil
+}
+
+func (r *resolver) SetOrderStatus(ctx context.Context, args struct {
+	ID     graphql.ID
+	Status string
+}) (*orderResolver, error) {
+	if err := mutable(ctx); err != nil {
+		return nil, err
+	}
+	// The roles of the order-status route, which the graphql route does not require
+	if p := authz.Check(authz.FromHeader(sessionFrom(ctx).header), authz.RoleAdmin, authz.RoleStaff); p != nil {
+		return nil, graphqlError{p}
+	}
+	id, err := parseID("order", args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Order orderDoc `json:"order"`
+	}
+	err = call(ctx, r.g, r.rt.upstream, "orders", http.MethodPatch, fmt.Sprintf("/orders/%d", id), map[string]any{"status": strings.ToLower(args.Status)}, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return r.newOrders(ctx, doc.Order)[0], nil
+}
+
+// mutable refuses mutations sent with GET, which may be repeated or sent by a link.
+func mutable(ctx context.Context) error {
+	if sessionFrom(ctx).readOnly {
+		return graphqlError{problem.New(http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "mutations need POST")}
+	}
+	return nil
+}
+
+// orderPage reads the page of the orders that match the filter of args, and those of the
+// customer when it is not nil. order-service filters and pages them.
+func (r *resolver) orderPage(ctx context.Context, args ordersArgs, customer *string) (*orderPage, error) {
+	if err := args.validate(); err != nil {
+		return nil, err
+	}
+	query, err := args.Filter.query()
+	if err != nil {
+		return nil, err
+	}
+	if customer != nil {
+		// The orders of one customer have none of another
+		if id := query.Get("customer_id"); id != "" && id != *customer {
+			return &orderPage{info: pageInfo{args.pageArgs, 0}}, nil
+		}
+		query.Set("customer_id", *customer)
+	}
+	query.Set("page", strconv.Itoa(int(args.Page)))
+	query.Set("page_size", strconv.Itoa(int(args.PageSize)))
+
+	orders, total, err := sessionFrom(ctx).orders.load(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &orderPage{r.newOrders(ctx, orders...), pageInfo{args.pageArgs, total}}, nil
+}
+
+// newOrders returns the resolvers of orders, and has the products of their lines read
+// together with the first one asked for.
+func (r *resolver) newOrders(ctx context.Context, orders ...orderDoc) []*orderResolver {
+	sessionFrom(ctx).products.prime(productIDs(orders)...)
+
+	out := make([]*orderResolver, 0, len(orders))
+	for _, o := range orders {
+		out = append(out, &orderResolver{r: r, doc: o})
+	}
+	return out
+}
+
+func (a pageArgs) validate() error {
+	switch {
+	case a.Page < 1:
+		return graphqlError{problem.BadRequest("page must be greater than zero")}
+	case a.PageSize < 1 || a.PageSize > maxPageSize:
+		return graphqlError{problem.BadRequest(fmt.Sprintf("pageSize must be between 1 and %d", maxPageSize))}
+	}
+	return nil
+}
+
+// paginate returns the page of items that args asks for.
+func paginate[T any](items []T, args pageArgs) ([]T, pageInfo) {
+	start := min(int(args.Page-1)*int(args.PageSize), len(items))
+	end := min(start+int(args.PageSize), len(items))
+	return items[start:end], pageInfo{args, len(items)}
+}
+
+func (f *productFilter) matches(p *productDoc) bool {
+	return (f.Name == nil || containsFold(p.Name, *f.Name)) &&
+		(f.MinPrice == nil || p.Price >= *f.MinPrice) &&
+		(f.MaxPrice == nil || p.Price <= *f.MaxPrice) &&
+		(f.InStock == nil || (p.Available > 0) == *f.InStock)
+}
+
+// query returns the query parameters of order-service for the filter.
+func (f *orderFilter) query() (url.Values, error) {
+	q := url.Values{}
+	if f == nil {
+		return q, nil
+	}
+	if f.Status != nil {
+		q.Set("status", strings.ToLower(*f.Status))
+	}
+	if f.CustomerID != nil {
+		q.Set("customer_id", string(*f.CustomerID))
+	}
+	if f.ProductID != nil {
+		id, err := parseID("product", *f.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		q.Set("product_id", strconv.FormatInt(id, 10))
+	}
+	if f.CreatedAfter != nil {
+		q.Set("created_after", f.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if f.CreatedBefore != nil {
+		q.Set("created_before", f.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	return q, nil
+}
+
+// productOrder compares products the way sort orders them in inventory-service.
+func productOrder(sort string) func(a, b *productDoc) int {
+	field, desc := strings.CutPrefix(sort, "-")
+	return func(a, b *productDoc) int {
+		var c int
+		switch field {
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
+		case "price":
+			c = cmpFloat(a.Price, b.Price)
+		case "available":
+			c = int(a.Available - b.Available)
+		}
+		if c == 0 {
+			c = int(a.ID - b.ID)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+type pageInfo struct {
+	args  pageArgs
+	total int
+}
+
+func (p pageInfo) Page() int32       { return p.args.Page }
+func (p pageInfo) PageSize() int32   { return p.args.PageSize }
+func (p pageInfo) TotalCount() int32 { return int32(p.total) }
+func (p pageInfo) HasNextPage() bool { return int(p.args.Page)*int(p.args.PageSize) < p.total }
+
+type productPage struct {
+	items []*productDoc
+	info  pageInfo
+}
+
+func newProductPage(products []*productDoc, args pageArgs) *productPage {
+	items, info := paginate(products, args)
+	return &productPage{items, info}
+}
+
+func (p *productPage) Items() []*productResolver {
+	out := make([]*productResolver, 0, len(p.items))
+	for _, doc := range p.items {
+		out = append(out, &productResolver{doc})
+	}
+	return out
+}
+
+func (p *productPage) PageInfo() pageInfo { return p.info }
+
+type categoryPage struct {
+	items []*categoryResolver
+	info  pageInfo
+}
+
+func (p *categoryPage) Items() []*categoryResolver { return p.items }
+func (p *categoryPage) PageInfo() pageInfo         { return p.info }
+
+type orderPage struct {
+	items []*orderResolver
+	info  pageInfo
+}
+
+func (p *orderPage) Items() []*orderResolver { return p.items }
+func (p *orderPage) PageInfo() pageInfo      { return p.info }
+
+type productResolver struct {
+	doc *productDoc
+}
+
+func (p *productResolver) ID() graphql.ID           { return graphql.ID(strconv.FormatInt(p.doc.ID, 10)) }
+func (p *productResolver) Name() string             { return p.doc.Name }
+func (p *productResolver) Description() string      { return p.doc.Description }
+func (p *productResolver) Price() float64           { return p.doc.Price }
+func (p *productResolver) Available() int32         { return int32(p.doc.Available) }
+func (p *productResolver) Version() int32           { return p.doc.Version }
+func (p *productResolver) CreatedAt() *graphql.Time { return optionalTime(p.doc.CreatedAt) }
+
+type categoryResolver struct {
+	doc *categoryDoc
+}
+
+func (c *categoryResolver) ID() graphql.ID           { return graphql.ID(c.doc.ID) }
+func (c *categoryResolver) Name() string             { return c.doc.Name }
+func (c *categoryResolver) Description() string      { return c.doc.Description }
+func (c *categoryResolver) CreatedAt() *graphql.Time { return optionalTime(c.doc.CreatedAt) }
+
+type orderResolver struct {
+	r   *resolver
+	doc orderDoc
+}
+
+func (o *orderResolver) ID() graphql.ID       { return graphql.ID(strconv.FormatInt(o.doc.OrderID, 10)) }
+func (o *orderResolver) CustomerName() string { return o.doc.CustomerName }
+func (o *orderResolver) Status() string       { return strings.ToUpper(o.doc.Status) }
+func (o *orderResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: o.doc.CreatedAt}
+}
+
+func (o *orderResolver) Customer() *customerResolver {
+	if o.doc.CustomerID == "" {
+		return nil
+	}
+	return &customerResolver{r: o.r, id: o.doc.CustomerID, name: o.doc.CustomerName}
+}
+
+func (o *orderResolver) Items() []*orderItemResolver {
+	out := make([]*orderItemResolver, 0, len(o.doc.Items))
+	for _, item := range o.doc.Items {
+		out = append(out, &orderItemResolver{productID: item.ProductID, quantity: item.Quantity})
+	}
+	return out
+}
+
+type orderItemResolver struct {
+	productID int64
+	quantity  int64
+}
+
+func (i *orderItemResolver) ProductID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(i.productID, 10))
+}
+func (i *orderItemResolver) Quantity() int32 { return int32(i.quantity) }
+
+// Product is read through the loader of the request, with the products of the other lines.
+func (i *orderItemResolver) Product(ctx context.Context) (*productResolver, error) {
+	p, err := sessionFrom(ctx).products.load(ctx, i.productID)
+	if p == nil || err != nil {
+		return nil, err
+	}
+	return &productResolver{p}, nil
+}
+
+type customerResolver struct {
+	r    *resolver
+	id   string
+	name string
+}
+
+func (c *customerResolver) ID() graphql.ID { return graphql.ID(c.id) }
+func (c *customerResolver) Name() string   { return c.name }
+
+func (c *customerResolver) Orders(ctx context.Context, args ordersArgs) (*orderPage, error) {
+	return c.r.orderPage(ctx, args, &c.id)
+}
+
+func optionalTime(t time.Time) *graphql.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: t}
+}
@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"api-gateway/pkg/health"
+)
+
+// livez is a readiness check of the gateway: the service has to answer its liveness probe
+// at url.
+func livez(client *http.Client, url string) health.CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		details := map[string]any{"url": url}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return details, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return details, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return details, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+
+		return details, nil
+	}
+}
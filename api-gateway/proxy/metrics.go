@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_requests_total",
+		Help: "Number of requests forwarded to a service, by service and status. The status is error when the service could not be reached, unavailable when no instance of it was healthy, breaker_open when its circuit breaker refused the request.",
+	}, []string{"upstream", "status"})
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upstream_request_duration_seconds",
+		Help:    "Time until a service responded to a forwarded request, by service.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream"})
+	rejectedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_rejected_requests_total",
+		Help: "Number of requests the gateway refused to forward because they break the OpenAPI document of the service, lack a valid token or API key, or exceed a rate limit, by service and status.",
+	}, []string{"upstream", "status"})
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_rate_limited_requests_total",
+		Help: "Number of requests refused with 429, by route and the scope of the empty bucket: key, ip or route.",
+	}, []string{"route", "scope"})
+	rateLimitErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "gateway_rate_limit_errors_total",
+		Help: "Number of requests let through unlimited because the rate limit store failed.",
+	})
+	upstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_retries_total",
+		Help: "Number of requests sent to a service again, by service and the reason: error when it could not be reached, or the status it answered.",
+	}, []string{"upstream", "reason"})
+	retryBudgetExhausted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_retry_budget_exhausted_total",
+		Help: "Number of retries skipped because the retry budget of the service was spent, by service.",
+	}, []string{"upstream"})
+	breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_breaker_state",
+		Help: "State of the circuit breaker of a service, by service: 0 closed, 1 open, 2 half-open.",
+	}, []string{"upstream"})
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_cache_lookups_total",
+		Help: "Number of requests the cache middleware handled, by route and result: hit, miss, revalidated, coalesced or bypass.",
+	}, []string{"route", "result"})
+	cacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_cache_size_bytes",
+		Help: "Bytes held by the responses in the cache.",
+	})
+	viewPartials = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_view_partial_total",
+		Help: "Number of parts a view answered without because their source failed, by route and source.",
+	}, []string{"route", "source"})
+	graphqlRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_graphql_requests_total",
+		Help: "Number of GraphQL requests, by route and result: ok, errors when the response carries errors, or invalid when it could not be read.",
+	}, []string{"route", "result"})
+	healthyInstances = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_upstream_healthy_instances",
+		Help: "Number of instances of a service in rotation, by service.",
+	}, []string{"upstream"})
+)
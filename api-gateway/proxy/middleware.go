@@ -0,0 +1,251 @@
+package proxy
+
+import (
+	"api-gateway/pkg/apikey"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/authz"
+	"api-gateway/pkg/problem"
+	"api-gateway/pkg/ratelimit"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware wraps the handler of a route. It runs after the path was rewritten, so it
+// sees the request as the upstream will.
+type Middleware func(next gin.HandlerFunc) gin.HandlerFunc
+
+// middleware are the middleware a route can name in the route file.
+var middleware = map[string]func(g *Gateway, rt *route) Middleware{
+	"validate":  validate,
+	"auth":      authenticate,
+	"ratelimit": rateLimit,
+	"cache":     cache,
+}
+
+// callerKey is where the auth middleware leaves the caller for the ratelimit middleware:
+// the API key or the subject of the token.
+const callerKey = "proxy.caller"
+
+// validate refuses requests that break the OpenAPI document of the upstream.
+func validate(g *Gateway, rt *route) Middleware {
+	spec := g.spec(rt.upstream.openAPI)
+
+	return func(next gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if !spec.validate(c) {
+				rejectedRequests.WithLabelValues(rt.upstream.name, strconv.Itoa(c.Writer.Status())).Inc()
+				return
+			}
+			next(c)
+		}
+	}
+}
+
+// authenticate refuses requests without a valid bearer token or API key, or whose caller
+// lacks the roles or the scopes of the route, and passes the subject and the roles of the
+// caller on to the upstream. A request with an API key is judged by the key alone.
+func authenticate(g *Gateway, rt *route) Middleware {
+	return func(next gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var id authz.Identity
+			if g.keys != nil && c.Request.Header.Get(apikey.Header) != "" {
+				key, err := g.keys.Verify(c.Request)
+				if err != nil {
+					refuseKey(c, rt, err)
+					return
+				}
+				if len(rt.scopes) > 0 && !key.HasScope(rt.scopes...) {
+					refuse(c, rt, problem.New(http.StatusForbidden, problem.CodeForbidden, "the API key needs one of the scopes: "+strings.Join(rt.scopes, ", ")))
+					return
+				}
+				id = key.Identity()
+				c.Set(callerKey, "apikey:"+key.ID)
+			} else {
+				var err error
+				if id, err = g.verify(c.Request); err != nil {
+					g.refuseToken(c, rt, err)
+					return
+				}
+				c.Set(callerKey, "sub:"+id.Subject)
+			}
+
+			if p := authz.Check(id, rt.roles...); p != nil {
+				refuse(c, rt, p)
+				return
+			}
+
+			id.SetHeader(c.Request.Header)
+			next(c)
+		}
+	}
+}
+
+// admin lets only admins call handler, with a bearer token or an API key. It guards the
+// endpoints of the gateway itself, which are not routes and skip the auth middleware.
+func (g *Gateway) admin(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var (
+			id  authz.Identity
+			err error
+		)
+		if g.keys != nil && c.Request.Header.Get(apikey.Header) != "" {
+			var key apikey.Key
+			key, err = g.keys.Verify(c.Request)
+			if err != nil && !errors.Is(err, apikey.ErrInvalid) {
+				slog.ErrorContext(c.Request.Context(), "auth: cannot check the API key", "path", c.Request.URL.Path, "error", err)
+				problem.Write(c, problem.New(http.StatusServiceUnavailable, problem.CodeServiceUnavailable, "API keys cannot be checked right now"))
+				return
+			}
+			id = key.Identity()
+		} else {
+			id, err = g.verify(c.Request)
+		}
+		if err != nil {
+			slog.InfoContext(c.Request.Context(), "auth: admin request refused", "path", c.Request.URL.Path, "error", err)
+			problem.Write(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "a valid bearer token or API key of an admin is required"))
+			return
+		}
+
+		if p := authz.Check(id, authz.RoleAdmin); p != nil {
+			problem.Write(c, p)
+			return
+		}
+		handler(c)
+	}
+}
+
+// verify checks the bearer token of req, when tokens can be verified at all.
+func (g *Gateway) verify(req *http.Request) (authz.Identity, error) {
+	if g.verifier == nil {
+		return authz.Identity{}, auth.ErrNoToken
+	}
+	return g.verifier.Verify(req)
+}
+
+// refuse answers with p and counts the request as rejected.
+func refuse(c *gin.Context, rt *route, p *problem.Problem) {
+	rejectedRequests.WithLabelValues(rt.upstream.name, strconv.Itoa(p.Status)).Inc()
+	problem.Write(c, p)
+}
+
+// refuseToken answers a request whose token was refused, naming the credentials the
+// gateway accepts when there was none.
+func (g *Gateway) refuseToken(c *gin.Context, rt *route, err error) {
+	if errors.Is(err, auth.ErrNoToken) {
+		detail := "a bearer token is required"
+		switch {
+		case g.verifier == nil:
+			detail = "an API key is required"
+		case g.keys != nil:
+			detail = "a bearer token or an API key is required"
+		}
+		if g.verifier != nil {
+			c.Header("WWW-Authenticate", `Bearer`)
+		}
+		refuse(c, rt, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, detail))
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "auth: token refused", "route", rt.name, "error", err)
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	refuse(c, rt, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "the bearer token is invalid: "+err.Error()))
+}
+
+func refuseKey(c *gin.Context, rt *route, err error) {
+	if errors.Is(err, apikey.ErrInvalid) {
+		slog.InfoContext(c.Request.Context(), "auth: API key refused", "route", rt.name)
+		refuse(c, rt, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "the API key is invalid"))
+		return
+	}
+
+	// The store could not be asked, which says nothing about the key
+	slog.ErrorContext(c.Request.Context(), "auth: cannot check the API key", "route", rt.name, "error", err)
+	refuse(c, rt, problem.New(http.StatusServiceUnavailable, problem.CodeServiceUnavailable, "API keys cannot be checked right now"))
+}
+
+// Scopes of the rate limit buckets.
+const (
+	scopeKey   = "key"
+	scopeIP    = "ip"
+	scopeRoute = "route"
+)
+
+// limit is a bucket of a route.
+type limit struct {
+	scope string
+	limit ratelimit.Limit
+}
+
+// bucket returns the key of the bucket of the request, false if the request has none:
+// anonymous requests are only counted per address and per route.
+func (l limit) bucket(c *gin.Context, rt *route) (string, bool) {
+	switch l.scope {
+	case scopeKey:
+		caller := c.GetString(callerKey)
+		return rt.name + ":key:" + caller, caller != ""
+	case scopeIP:
+		return rt.name + ":ip:" + c.RemoteIP(), true
+	default:
+		return rt.name + ":route", true
+	}
+}
+
+// rateLimit takes a token from every bucket of the route and refuses the request with 429
+// when one of them is empty. The RateLimit headers describe the bucket with the fewest
+// tokens left. When the store fails, requests are let through rather than refused.
+func rateLimit(g *Gateway, rt *route) Middleware {
+	return func(next gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var tightest *ratelimit.Result
+			for _, l := range rt.limits {
+				bucket, ok := l.bucket(c, rt)
+				if !ok {
+					continue
+				}
+
+				res, err := g.limiter.Take(c.Request.Context(), bucket, l.limit)
+				if err != nil {
+					rateLimitErrors.Inc()
+					slog.ErrorContext(c.Request.Context(), "ratelimit: cannot take a token, the request is let through", "route", rt.name, "scope", l.scope, "error", err)
+					continue
+				}
+
+				if !res.Allowed {
+					rateLimited.WithLabelValues(rt.name, l.scope).Inc()
+					setRateLimitHeaders(c, res)
+					c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+					refuse(c, rt, problem.New(http.StatusTooManyRequests, problem.CodeRateLimited, "too many requests, limited per "+l.scope))
+					return
+				}
+				if tightest == nil || res.Remaining < tightest.Remaining {
+					tightest = &res
+				}
+			}
+
+			if tightest != nil {
+				setRateLimitHeaders(c, *tightest)
+			}
+			next(c)
+		}
+	}
+}
+
+// setRateLimitHeaders sets the headers of the IETF draft on RateLimit header fields.
+func setRateLimitHeaders(c *gin.Context, res ratelimit.Result) {
+	c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+}
+
+// ceilSeconds rounds d up to whole seconds, so that a client that waits as long is not
+// refused again.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,281 @@
+package proxy
+
+import (
+	"api-gateway/pkg/metrics"
+	"api-gateway/pkg/problem"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// specRefreshInterval is how long a document is used before it is fetched again, so
+	// that routes added to a service become available without restarting the gateway.
+	specRefreshInterval = time.Minute
+	// specRetryInterval is how long the gateway waits before it asks again for a document
+	// that could not be fetched. Requests are not validated in the meantime.
+	specRetryInterval = 10 * time.Second
+	// specFetchTimeout bounds a request for a document.
+	specFetchTimeout = 5 * time.Second
+)
+
+// spec is the OpenAPI document of a service, fetched from url.
+type spec struct {
+	url    string
+	client *http.Client
+
+	mu         sync.Mutex
+	router     routers.Router
+	fetchedAt  time.Time
+	failedAt   time.Time
+	refreshing bool
+}
+
+func newSpec(url string, client *http.Client) *spec {
+	return &spec{url: url, client: client}
+}
+
+// validate checks the request against the document of the service. When the request does
+// not match, it responds with the reason and returns false. Requests pass unchecked while
+// the document cannot be fetched, the services validate them again anyway.
+func (s *spec) validate(c *gin.Context) bool {
+	router := s.get(c.Request.Context())
+	if router == nil {
+		return true
+	}
+
+	route, pathParams, err := findRoute(router, c.Request)
+	switch {
+	case errors.Is(err, routers.ErrPathNotFound):
+		problem.NoRoute(c)
+		return false
+	case errors.Is(err, routers.ErrMethodNotAllowed):
+		problem.Write(c, problem.New(http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "method not allowed"))
+		return false
+	case err != nil:
+		problem.Write(c, problem.BadRequest(err.Error()))
+		return false
+	}
+
+	// Labeling the metrics of the request with the route of the service
+	metrics.SetRoute(c, route.Path)
+
+	err = openapi3filter.ValidateRequest(c.Request.Context(), &openapi3filter.RequestValidationInput{
+		Request:    c.Request,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			MultiError:         true,
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	})
+	if err == nil {
+		return true
+	}
+
+	problem.Write(c, describe(err))
+	return false
+}
+
+// findRoute looks the request up like Gin does, which redirects a path with or without a
+// trailing slash to the route that has it the other way.
+func findRoute(router routers.Router, req *http.Request) (*routers.Route, map[string]string, error) {
+	route, pathParams, err := router.FindRoute(req)
+	if !errors.Is(err, routers.ErrPathNotFound) {
+		return route, pathParams, err
+	}
+
+	alt := req.Clone(req.Context())
+	if strings.HasSuffix(alt.URL.Path, "/") {
+		alt.URL.Path = strings.TrimSuffix(alt.URL.Path, "/")
+	} else {
+		alt.URL.Path += "/"
+	}
+	alt.URL.RawPath = ""
+
+	altRoute, altParams, altErr := router.FindRoute(alt)
+	if altErr != nil {
+		return route, pathParams, err
+	}
+
+	return altRoute, altParams, nil
+}
+
+// describe turns a validation error into a problem that gives the reason for every invalid
+// field, like the services do. A request that cannot be read, or that addresses a resource
+// with an invalid path parameter, is a 400, one that can be read but breaks the schema is
+// a 422.
+func describe(err error) *problem.Problem {
+	fields := make(map[string]string)
+	status := http.StatusUnprocessableEntity
+
+	var walk func(err error, field string)
+	walk = func(err error, field string) {
+		switch e := err.(type) {
+		case openapi3.MultiError:
+			for _, err := range e {
+				walk(err, field)
+			}
+
+		case *openapi3filter.RequestError:
+			field := "body"
+			if e.Parameter != nil {
+				field = e.Parameter.Name
+				if e.Parameter.In == openapi3.ParameterInPath {
+					status = http.StatusBadRequest
+				}
+			}
+
+			if e.Err == nil {
+				fields[field] = e.Reason
+				return
+			}
+			walk(e.Err, field)
+
+		case *openapi3.SchemaError:
+			if pointer := e.JSONPointer(); len(pointer) > 0 {
+				if field == "body" {
+					field = strings.Join(pointer, ".")
+				} else {
+					field += "." + strings.Join(pointer, ".")
+				}
+			}
+			fields[field] = e.Reason
+			if e.Reason == "" {
+				// Schemas like "not" have no reason of their own
+				fields[field] = "does not match the schema"
+			}
+
+		case *openapi3filter.ParseError:
+			status = http.StatusBadRequest
+			fields[field] = e.Error()
+
+		default:
+			if errors.Is(err, openapi3filter.ErrInvalidRequired) {
+				fields[field] = "must be provided"
+				return
+			}
+			if errors.Is(err, openapi3filter.ErrInvalidEmptyValue) {
+				fields[field] = "must not be empty"
+				return
+			}
+			status = http.StatusBadRequest
+			fields[field] = err.Error()
+		}
+	}
+	walk(err, "")
+
+	if status == http.StatusBadRequest {
+		p := problem.BadRequest("the request could not be read")
+		p.Errors = fields
+		return p
+	}
+	return problem.Validation(fields)
+}
+
+// get returns the router of the document, or nil while it cannot be fetched. The first
+// request waits for the document, later ones are served with the previous document while
+// it is fetched again in the background.
+func (s *spec) get(ctx context.Context) routers.Router {
+	s.mu.Lock()
+
+	if s.router == nil {
+		if time.Since(s.failedAt) < specRetryInterval {
+			s.mu.Unlock()
+			return nil
+		}
+
+		// Holding the lock, so that concurrent first requests fetch the document once
+		defer s.mu.Unlock()
+
+		router, err := s.fetch(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "openapi: fetch failed, requests are not validated for a while", "url", s.url, "retry_in", specRetryInterval, "error", err)
+			s.failedAt = time.Now()
+			return nil
+		}
+
+		s.router = router
+		s.fetchedAt = time.Now()
+		return s.router
+	}
+
+	if time.Since(s.fetchedAt) > specRefreshInterval && !s.refreshing {
+		s.refreshing = true
+		go s.refresh()
+	}
+
+	router := s.router
+	s.mu.Unlock()
+
+	return router
+}
+
+// refresh fetches the document again. The previous one stays in use if that fails.
+func (s *spec) refresh() {
+	router, err := s.fetch(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshing = false
+	if err != nil {
+		slog.Error("openapi: refresh failed", "url", s.url, "error", err)
+		// Trying again after the retry interval, not on the next request
+		s.fetchedAt = time.Now().Add(specRetryInterval - specRefreshInterval)
+		return
+	}
+
+	s.router = router
+	s.fetchedAt = time.Now()
+}
+
+func (s *spec) fetch(ctx context.Context) (routers.Router, error) {
+	ctx, cancel := context.WithTimeout(ctx, specFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	// The gateway serves the paths under its own address
+	doc.Servers = nil
+
+	return gorillamux.NewRouter(doc)
+}
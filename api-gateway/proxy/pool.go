@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"api-gateway/pkg/breaker"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// BalanceRoundRobin sends the requests to the instances in turn.
+	BalanceRoundRobin = "round_robin"
+	// BalanceLeastConnections sends a request to the instance with the fewest requests in
+	// flight.
+	BalanceLeastConnections = "least_connections"
+)
+
+const (
+	// defaultHealthInterval is how often the instances of an upstream with a health path
+	// are checked, unless the upstream sets its own interval.
+	defaultHealthInterval = 10 * time.Second
+	// unhealthyAfter is the number of checks in a row an instance has to fail before it is
+	// taken out of rotation. One passed check brings it back.
+	unhealthyAfter = 2
+
+	// Defaults of the breaker and the retries of an upstream.
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = 30 * time.Second
+	defaultRetryAttempts   = 2
+	defaultRetryBaseDelay  = 50 * time.Millisecond
+	defaultRetryMaxDelay   = 500 * time.Millisecond
+	defaultRetryBudget     = 0.2
+)
+
+var errNoHealthyInstance = errors.New("no healthy instance")
+
+// pool is the set of instances of an upstream.
+type pool struct {
+	name      string
+	openAPI   string // URL of the OpenAPI document, empty if it has none
+	instances []*instance
+	balance   string
+	health    string // Path of the liveness probe, instances are not checked if empty
+	interval  time.Duration
+	client    *http.Client
+	breaker   *breaker.Breaker // Nil when turned off
+	retry     retryPolicy
+
+	next atomic.Uint64 // Round robin counter
+	mu   sync.Mutex    // Serializes the checks, which may overlap
+}
+
+// instance is one address of an upstream. Instances start healthy, so that requests are
+// served before the first check.
+type instance struct {
+	url *url.URL
+
+	active   atomic.Int64 // Requests in flight
+	healthy  atomic.Bool
+	failures int // Checks failed in a row, guarded by the mutex of the pool
+}
+
+func newPool(name string, cfg UpstreamConfig, client *http.Client) *pool {
+	p := &pool{
+		name:     name,
+		balance:  cfg.Balance,
+		health:   cfg.Health,
+		interval: cfg.HealthInterval,
+		client:   client,
+	}
+	if p.balance == "" {
+		p.balance = BalanceRoundRobin
+	}
+	if p.interval == 0 {
+		p.interval = defaultHealthInterval
+	}
+
+	if failures := orDefault(cfg.Breaker.Failures, defaultBreakerFailures); failures > 0 {
+		p.breaker = breaker.New(failures, cmp.Or(cfg.Breaker.Cooldown, defaultBreakerCooldown))
+	}
+	breakerState.WithLabelValues(name).Set(float64(breaker.StateClosed))
+
+	p.retry = retryPolicy{
+		attempts:  orDefault(cfg.Retry.Attempts, defaultRetryAttempts),
+		baseDelay: cmp.Or(cfg.Retry.BaseDelay, defaultRetryBaseDelay),
+		maxDelay:  cmp.Or(cfg.Retry.MaxDelay, defaultRetryMaxDelay),
+		budget:    newBudget(cmp.Or(cfg.Retry.Budget, defaultRetryBudget)),
+	}
+
+	for _, raw := range cfg.urls() {
+		// Validated with the file
+		u, _ := url.Parse(strings.TrimSuffix(raw, "/"))
+		in := &instance{url: u}
+		in.healthy.Store(true)
+		p.instances = append(p.instances, in)
+	}
+	healthyInstances.WithLabelValues(name).Set(float64(len(p.instances)))
+
+	// The instances serve the same document, the first one is asked for it
+	if cfg.OpenAPI != "" {
+		p.openAPI = p.instances[0].url.String() + cfg.OpenAPI
+	}
+
+	return p
+}
+
+// pick returns the instance for the next request, or nil if none is healthy. The caller
+// releases it with done once the request is over.
+func (p *pool) pick() *instance {
+	var picked *instance
+
+	switch p.balance {
+	case BalanceLeastConnections:
+		for _, in := range p.instances {
+			if in.healthy.Load() && (picked == nil || in.active.Load() < picked.active.Load()) {
+				picked = in
+			}
+		}
+	default:
+		n := uint64(len(p.instances))
+		start := p.next.Add(1) - 1
+		for i := range n {
+			if in := p.instances[(start+i)%n]; in.healthy.Load() {
+				picked = in
+				break
+			}
+		}
+	}
+
+	if picked != nil {
+		picked.active.Add(1)
+	}
+	return picked
+}
+
+func (in *instance) done() {
+	in.active.Add(-1)
+}
+
+// orDefault returns the value of an optional setting, fallback if it is not set.
+func orDefault(v *int, fallback int) int {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
+
+// run checks the instances every interval until ctx is done.
+func (p *pool) run(ctx context.Context) {
+	if p.health == "" {
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, p.interval)
+		p.check(checkCtx)
+		cancel()
+	}
+}
+
+// check probes every instance at once and takes the ones that keep failing out of
+// rotation. It is the readiness check of the upstream as well, which fails when no
+// instance answered.
+func (p *pool) check(ctx context.Context) (map[string]any, error) {
+	errs := make([]error, len(p.instances))
+
+	var wg sync.WaitGroup
+	for i, in := range p.instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = livez(p.client, in.url.String()+p.health)(ctx)
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	answered, healthy := 0, 0
+	for i, in := range p.instances {
+		if errs[i] == nil {
+			answered++
+			if !in.healthy.Load() {
+				slog.Info("proxy: instance back in rotation", "upstream", p.name, "instance", in.url.String())
+			}
+			in.failures = 0
+			in.healthy.Store(true)
+		} else {
+			errs[i] = fmt.Errorf("%s: %w", in.url, errs[i])
+			in.failures++
+			if in.failures >= unhealthyAfter && in.healthy.Load() {
+				slog.Warn("proxy: instance taken out of rotation", "upstream", p.name, "instance", in.url.String(), "error", errs[i])
+				in.healthy.Store(false)
+			}
+		}
+		if in.healthy.Load() {
+			healthy++
+		}
+	}
+	healthyInstances.WithLabelValues(p.name).Set(float64(healthy))
+
+	// The upstream is ready as long as one instance answered
+	details := map[string]any{"instances": len(p.instances), "answered": answered, "healthy": healthy}
+	if answered == 0 {
+		return details, fmt.Errorf("%w: %w", errNoHealthyInstance, errors.Join(errs...))
+	}
+	return details, nil
+}
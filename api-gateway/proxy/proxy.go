@@ -0,0 +1,397 @@
+// Package proxy forwards the requests of the gateway to the services, by a route table
+// that is read from a file and reloaded when the file changes.
+package proxy
+
+import (
+	"api-gateway/config"
+	"api-gateway/pkg/apikey"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/authz"
+	"api-gateway/pkg/health"
+	"api-gateway/pkg/httpcache"
+	"api-gateway/pkg/logger"
+	"api-gateway/pkg/metrics"
+	"api-gateway/pkg/postgres"
+	"api-gateway/pkg/problem"
+	"api-gateway/pkg/ratelimit"
+	"api-gateway/pkg/requestid"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Gateway forwards every request to the upstream of the first route that matches it.
+type Gateway struct {
+	cfg      *config.Config
+	client   *http.Client // Fetches the OpenAPI documents and the JWKS, checks the instances
+	proxy    *httputil.ReverseProxy
+	verifier *auth.Verifier   // Nil without keys, the auth middleware refuses tokens then
+	keys     *apikey.Verifier // Nil without a store, the auth middleware refuses API keys then
+	limiter  ratelimit.Store
+	db       *postgres.PostgreDB // Nil unless a store is kept in Postgres
+	cache    *httpcache.Cache
+	engine   *gin.Engine
+	stop     context.CancelFunc
+
+	table atomic.Pointer[table]
+
+	mu      sync.Mutex       // Serializes reloads
+	specs   map[string]*spec // OpenAPI documents by URL, kept across reloads
+	modTime time.Time        // Of the route file in use
+}
+
+// table is the route table in use. A reload builds a new one and swaps it in, requests
+// in flight finish with the one they started with.
+type table struct {
+	routes    []*route
+	upstreams map[string]*pool
+	health    *health.Health
+	stop      context.CancelFunc // Stops the health checks of the instances
+}
+
+// route is a RouteConfig ready to serve requests.
+type route struct {
+	name        string
+	source      string // The prefix or path of the route, labels the metrics
+	pattern     pattern
+	methods     []string
+	upstream    *pool
+	strip       string
+	rewrite     string
+	timeout     time.Duration
+	roles       []string
+	scopes      []string
+	limits      []limit
+	cacheTTL    time.Duration
+	invalidates []string         // Routes whose cached responses a write through this one drops, itself included
+	sources     map[string]*pool // Of the view of the route
+	handler     gin.HandlerFunc
+}
+
+// New loads the route table and returns the gateway. Without a route file the gateway
+// routes /orders and /products to the services of cfg, and serves the order view at
+// /views/orders/{id} and GraphQL at /graphql.
+func New(cfg *config.Config) (*Gateway, error) {
+	transport := newTransport(cfg.Transport)
+	ctx, stop := context.WithCancel(context.Background())
+	g := &Gateway{
+		cfg:    cfg,
+		client: &http.Client{Transport: transport},
+		proxy:  newReverseProxy(transport),
+		stop:   stop,
+		specs:  make(map[string]*spec),
+		cache:  httpcache.New(cfg.Cache.MaxBytes),
+	}
+
+	if cfg.Auth.Enabled() {
+		verifier, err := auth.New(ctx, cfg.Auth, g.client)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		g.verifier = verifier
+	}
+
+	if err := g.openStores(ctx); err != nil {
+		g.closeStores()
+		return nil, err
+	}
+
+	if err := g.Reload(); err != nil {
+		g.closeStores()
+		return nil, err
+	}
+
+	r := gin.New()
+	r.Use(requestid.Middleware())
+	r.Use(otelgin.Middleware("api-gateway"))
+	r.Use(logger.Middleware())
+	r.Use(metrics.Middleware())
+	r.Use(gin.Recovery())
+
+	// Gin allows no other route next to a catch-all one, so the routes of the gateway
+	// itself are picked here. The route table cannot hide them. Those under /admin name
+	// the upstreams and how they fare, so they are for admins only.
+	own := map[string]gin.HandlerFunc{
+		"/metrics": gin.WrapH(promhttp.Handler()),
+		"/livez":   func(c *gin.Context) { g.table.Load().health.Live(c) },
+		"/readyz":  func(c *gin.Context) { g.table.Load().health.Ready(c) },
+
+		"/admin/breakers": g.admin(g.breakers),
+	}
+	r.Any("/*proxyPath", func(c *gin.Context) {
+		path := c.Param("proxyPath")
+		if handler, ok := own[path]; ok && c.Request.Method == http.MethodGet {
+			metrics.SetRoute(c, path)
+			handler(c)
+			return
+		}
+		g.serve(c)
+	})
+
+	g.engine = r
+
+	return g, nil
+}
+
+// Handler returns the router of the gateway.
+func (g *Gateway) Handler() http.Handler {
+	return g.engine
+}
+
+// Reload reads the route file again. When it is invalid, the routes in use stay.
+func (g *Gateway) Reload() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	path := g.cfg.Routes.File
+	if path == "" {
+		file := defaultRoutes(g.cfg, g.verifier != nil || g.keys != nil)
+		if err := errors.Join(file.Validate(), g.validate(file)); err != nil {
+			return fmt.Errorf("routes: %w", err)
+		}
+		g.swap(g.build(file))
+		return nil
+	}
+
+	// Taken before the file is read, so that a change made while reading is not missed
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("routes: %w", err)
+	}
+	g.modTime = info.ModTime()
+
+	file, err := LoadRoutes(path)
+	if err == nil {
+		err = g.validate(file)
+	}
+	if err != nil {
+		return fmt.Errorf("routes: %s: %w", path, err)
+	}
+	g.swap(g.build(file))
+
+	slog.Info("routes loaded", "file", path, "routes", len(file.Routes), "upstreams", len(file.Upstreams))
+
+	return nil
+}
+
+// Close stops the health checks of the instances and the refresh of the JWKS, and closes
+// the connections to Postgres.
+func (g *Gateway) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.table.Load().stop()
+	g.closeStores()
+}
+
+// closeStores stops the background work of the gateway and closes its Postgres pool.
+func (g *Gateway) closeStores() {
+	g.stop()
+	if g.db != nil {
+		g.db.Pool.Close()
+	}
+}
+
+// validate checks what the route file needs from the gateway.
+func (g *Gateway) validate(file RouteFile) error {
+	var errs []error
+	for i, rt := range file.Routes {
+		if slices.Contains(rt.Middleware, "auth") && g.verifier == nil && g.keys == nil {
+			errs = append(errs, fmt.Errorf("route %d (%s): middleware auth: %w, nor is API_KEYS_STORE", i+1, rt.Name, auth.ErrNotEnabled))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// swap puts t in use, stops the health checks of the previous table and drops the cached
+// responses, which the new routes may serve differently.
+func (g *Gateway) swap(t *table) {
+	if old := g.table.Swap(t); old != nil {
+		old.stop()
+		g.cache.Purge()
+		cacheBytes.Set(0)
+	}
+}
+
+// Watch reloads the route file whenever it changes, until ctx is done. A file that fails
+// to load is logged and not tried again until it changes once more.
+func (g *Gateway) Watch(ctx context.Context) {
+	path := g.cfg.Routes.File
+	if path == "" || g.cfg.Routes.ReloadInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(g.cfg.Routes.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			slog.Error("routes: cannot read the route file", "file", path, "error", err)
+			continue
+		}
+
+		g.mu.Lock()
+		changed := !info.ModTime().Equal(g.modTime)
+		g.mu.Unlock()
+
+		if changed {
+			if err := g.Reload(); err != nil {
+				slog.Error("routes: reload failed, the previous routes stay in use", "error", err)
+			}
+		}
+	}
+}
+
+// build turns a valid route file into a table and starts the health checks of its
+// instances.
+func (g *Gateway) build(file RouteFile) *table {
+	ctx, stop := context.WithCancel(context.Background())
+	t := &table{health: health.New(g.cfg.Health), stop: stop}
+
+	if g.db != nil {
+		t.health.Add("postgres", g.db.Check)
+		t.health.Add("migrations", g.db.CheckMigrations)
+	}
+
+	t.upstreams = make(map[string]*pool, len(file.Upstreams))
+	for name, up := range file.Upstreams {
+		p := newPool(name, up, g.client)
+		t.upstreams[name] = p
+		go p.run(ctx)
+
+		// The gateway is ready when every upstream with a probe has an instance that
+		// answers it
+		if up.Health != "" {
+			t.health.Add(name, p.check)
+		}
+	}
+
+	for _, rc := range file.Routes {
+		p, _ := rc.pattern()
+		rt := &route{
+			name:        rc.Name,
+			source:      rc.Prefix + rc.Path,
+			pattern:     p,
+			methods:     rc.Methods,
+			upstream:    t.upstreams[rc.Upstream],
+			strip:       rc.StripPrefix,
+			rewrite:     rc.Rewrite,
+			timeout:     rc.Timeout,
+			roles:       rc.Roles,
+			scopes:      rc.Scopes,
+			limits:      rc.RateLimit.limits(),
+			cacheTTL:    rc.Cache.TTL,
+			invalidates: append([]string{rc.Name}, rc.Cache.Invalidates...),
+		}
+
+		rt.handler = g.forward(rt)
+		if rc.View != nil {
+			rt.sources = make(map[string]*pool, len(rc.View.Sources))
+			for source, upstream := range rc.View.Sources {
+				rt.sources[source] = t.upstreams[upstream]
+			}
+			rt.handler = views[rc.View.Name].handler(g, rt)
+		}
+		for _, name := range slices.Backward(rc.Middleware) {
+			rt.handler = middleware[name](g, rt)(rt.handler)
+		}
+
+		t.routes = append(t.routes, rt)
+	}
+
+	return t
+}
+
+// spec returns the OpenAPI document at url. Documents are shared by the routes of an
+// upstream and survive reloads, so that they are not fetched again for every table.
+func (g *Gateway) spec(url string) *spec {
+	s, ok := g.specs[url]
+	if !ok {
+		s = newSpec(url, g.client)
+		g.specs[url] = s
+	}
+	return s
+}
+
+// serve finds the route of the request and hands it the request, with the path the
+// upstream expects.
+func (g *Gateway) serve(c *gin.Context) {
+	path := c.Request.URL.Path
+
+	// Only the auth middleware may tell the services who the client is
+	authz.StripHeader(c.Request.Header)
+
+	rt, params, pathFound := g.table.Load().match(c.Request.Method, path)
+	if rt == nil {
+		if pathFound {
+			problem.Write(c, problem.New(http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "method not allowed"))
+			return
+		}
+		problem.NoRoute(c)
+		return
+	}
+
+	metrics.SetRoute(c, rt.source)
+
+	c.Request.URL.Path = rt.target(path, params)
+	c.Request.URL.RawPath = ""
+
+	rt.handler(c)
+}
+
+// match returns the first route for the method and the path, with the parameters of the
+// path. pathFound tells whether a route has the path but not the method.
+func (t *table) match(method, path string) (rt *route, params map[string]string, pathFound bool) {
+	for _, rt := range t.routes {
+		params, ok := rt.pattern.match(path)
+		if !ok {
+			continue
+		}
+		if len(rt.methods) > 0 && !slices.Contains(rt.methods, method) {
+			pathFound = true
+			continue
+		}
+		return rt, params, true
+	}
+	return nil, nil, pathFound
+}
+
+// target returns the path the upstream is sent.
+func (rt *route) target(path string, params map[string]string) string {
+	switch {
+	case rt.rewrite != "":
+		// The services tell /orders from /orders/, so the slash is kept
+		target := expand(rt.rewrite, params)
+		if strings.HasSuffix(path, "/") && !strings.HasSuffix(target, "/") {
+			target += "/"
+		}
+		return target
+	case rt.strip != "":
+		path = strings.TrimPrefix(path, rt.strip)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	return path
+}
@@ -0,0 +1,611 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/pkg/ratelimit"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RouteFile is the route table of the gateway, read from the file in ROUTES_FILE. Values
+// can refer to environment variables as ${NAME}, or ${NAME:-default} with a default.
+type RouteFile struct {
+	Upstreams map[string]UpstreamConfig `yaml:"upstreams"`
+	Routes    []RouteConfig             `yaml:"routes"`
+}
+
+// UpstreamConfig is a service behind the gateway, with one instance at URL or several at
+// URLs.
+type UpstreamConfig struct {
+	URL            string        `yaml:"url"`
+	URLs           []string      `yaml:"urls"`
+	Balance        string        `yaml:"balance"`         // round_robin, the default, or least_connections
+	OpenAPI        string        `yaml:"openapi"`         // Path of the OpenAPI document, needed by the validate middleware
+	Health         string        `yaml:"health"`          // Path of the liveness probe, instances are not checked if empty
+	HealthInterval time.Duration `yaml:"health_interval"` // How often the instances are checked, 10s if zero
+	Breaker        BreakerConfig `yaml:"breaker"`
+	Retry          RetryConfig   `yaml:"retry"`
+}
+
+// BreakerConfig is the circuit breaker of an upstream. After Failures failed requests in a
+// row, connection errors, timeouts and 5xx responses, it opens and the gateway answers 503
+// at once for Cooldown. Then a single request probes the upstream and closes the breaker
+// again if it succeeds.
+type BreakerConfig struct {
+	Failures *int          `yaml:"failures"` // 5 if not set, 0 turns the breaker off
+	Cooldown time.Duration `yaml:"cooldown"` // 30s if zero
+}
+
+// RetryConfig retries the requests to an upstream that are safe to repeat: those of
+// idempotent methods without a body, that failed to connect or were answered with 502 or
+// 503. Every retry goes to the next instance, after a delay that grows exponentially with
+// full jitter.
+type RetryConfig struct {
+	Attempts  *int          `yaml:"attempts"`   // Retries after the first attempt, 2 if not set, 0 turns retries off
+	BaseDelay time.Duration `yaml:"base_delay"` // 50ms if zero
+	MaxDelay  time.Duration `yaml:"max_delay"`  // 500ms if zero
+	Budget    float64       `yaml:"budget"`     // Share of the requests retries may add, 0.2 if zero
+}
+
+// RouteConfig sends the requests it matches to an upstream. Routes are tried in the order
+// of the file, the first one that matches the path and the method serves the request.
+type RouteConfig struct {
+	Name        string          `yaml:"name"`
+	Prefix      string          `yaml:"prefix"`       // Matches the path and everything below it
+	Path        string          `yaml:"path"`         // Pattern such as /products/{id}, a last {name...} matches the rest
+	Methods     []string        `yaml:"methods"`      // Every method if empty
+	Upstream    string          `yaml:"upstream"`     // Name of the upstream
+	StripPrefix string          `yaml:"strip_prefix"` // Removed from the path before it is forwarded
+	Rewrite     string          `yaml:"rewrite"`      // Path forwarded instead, with the {params} of Path
+	Timeout     time.Duration   `yaml:"timeout"`      // Bounds the request to the upstream, none if zero
+	Middleware  []string        `yaml:"middleware"`   // Run in order before the request is forwarded
+	Roles       []string        `yaml:"roles"`        // The caller needs one of them, checked by the auth middleware
+	Scopes      []string        `yaml:"scopes"`       // An API key needs one of them, checked by the auth middleware. Tokens are held to Roles only
+	RateLimit   RateLimitConfig `yaml:"rate_limit"`   // Buckets of the ratelimit middleware
+	Cache       CacheConfig     `yaml:"cache"`        // How the cache middleware keeps the responses
+	View        *ViewConfig     `yaml:"view"`         // Composes the response instead of forwarding the request
+}
+
+// ViewConfig composes the response of the route from requests to several upstreams. The
+// view reads the path the route forwards to from its upstream, and what that refers to
+// from its sources.
+type ViewConfig struct {
+	Name    string            `yaml:"name"`    // order: the order with the product of every line. graphql: orders and the catalog over GraphQL
+	Sources map[string]string `yaml:"sources"` // The upstream of every source the view needs, such as products
+}
+
+// CacheConfig is how long the cache middleware keeps the responses of the route, and which
+// cached responses a write through it makes stale. Only responses to GET without
+// credentials are stored.
+type CacheConfig struct {
+	TTL         time.Duration `yaml:"ttl"`         // Freshness of the responses whose Cache-Control has no max-age, not stored if zero
+	Invalidates []string      `yaml:"invalidates"` // Routes whose responses a successful write drops, besides those of the route
+}
+
+// RateLimitConfig are the token buckets a request of the route takes from, every one that
+// is set must have a token left. A bucket is kept per caller, per client address and for
+// the route as a whole, each separate from the buckets of other routes.
+type RateLimitConfig struct {
+	Key   *LimitConfig `yaml:"key"`   // Per API key, or per subject of the token. Needs the auth middleware before ratelimit
+	IP    *LimitConfig `yaml:"ip"`    // Per address the connection came from, X-Forwarded-For is not trusted
+	Route *LimitConfig `yaml:"route"` // Shared by every caller
+}
+
+// LimitConfig is a token bucket.
+type LimitConfig struct {
+	Rate  string `yaml:"rate"`  // How fast the bucket refills, such as 100/s, 600/m or 1000/h
+	Burst int    `yaml:"burst"` // Requests allowed at once, the count of the rate if zero
+}
+
+// defaultRoutes is the route table without a file: the services of cfg, with the requests
+// validated against their OpenAPI documents, the order view and GraphQL without categories.
+// order-service serves only known callers, so the routes to it authenticate them when the
+// gateway has tokens or keys to check.
+func defaultRoutes(cfg *config.Config, authenticate bool) RouteFile {
+	var orders []string
+	if authenticate {
+		orders = []string{"auth"}
+	}
+
+	return RouteFile{
+		Upstreams: map[string]UpstreamConfig{
+			"order-service":     {URL: cfg.OrderService.Addr, OpenAPI: "/openapi.json", Health: "/livez"},
+			"inventory-service": {URL: cfg.InventoryService.Addr, OpenAPI: "/openapi.json", Health: "/livez"},
+		},
+		Routes: []RouteConfig{
+			{Name: "orders", Prefix: "/orders", Upstream: "order-service", Middleware: append(slices.Clone(orders), "validate")},
+			{Name: "products", Prefix: "/products", Upstream: "inventory-service", Middleware: []string{"validate"}},
+			{
+				Name: "order-view", Path: "/views/orders/{id}", Methods: []string{http.MethodGet, http.MethodHead},
+				Upstream: "order-service", Rewrite: "/orders/{id}", Middleware: append(slices.Clone(orders), "validate"),
+				View: &ViewConfig{Name: "order", Sources: map[string]string{"products": "inventory-service"}},
+			},
+			{
+				Name: "graphql", Path: "/graphql", Methods: []string{http.MethodGet, http.MethodPost}, Upstream: "order-service",
+				Middleware: orders,
+				View:       &ViewConfig{Name: "graphql", Sources: map[string]string{"products": "inventory-service"}},
+			},
+		},
+	}
+}
+
+// LoadRoutes reads and validates the route file at path.
+func LoadRoutes(path string) (RouteFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RouteFile{}, err
+	}
+
+	return ParseRoutes(data)
+}
+
+// ParseRoutes reads and validates a route file. Unknown fields are errors, so that a typo
+// does not go unnoticed.
+func ParseRoutes(data []byte) (RouteFile, error) {
+	var missing []string
+	data = []byte(os.Expand(string(data), func(name string) string {
+		name, fallback, hasFallback := strings.Cut(name, ":-")
+		value, ok := os.LookupEnv(name)
+		switch {
+		case ok:
+			return value
+		case hasFallback:
+			return fallback
+		}
+		missing = append(missing, name)
+		return ""
+	}))
+	if len(missing) > 0 {
+		return RouteFile{}, fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+
+	var file RouteFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return RouteFile{}, err
+	}
+
+	return file, file.Validate()
+}
+
+// Validate reports every mistake of the file at once.
+func (f RouteFile) Validate() error {
+	var errs []error
+
+	if len(f.Upstreams) == 0 {
+		errs = append(errs, errors.New("no upstreams"))
+	}
+	for name, up := range f.Upstreams {
+		if err := up.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("upstream %s: %w", name, err))
+		}
+	}
+
+	if len(f.Routes) == 0 {
+		errs = append(errs, errors.New("no routes"))
+	}
+	names := make(map[string]bool)
+	for i, rt := range f.Routes {
+		if rt.Name == "" {
+			errs = append(errs, fmt.Errorf("route %d: no name", i+1))
+		} else if names[rt.Name] {
+			errs = append(errs, fmt.Errorf("route %d: name %s is taken", i+1, rt.Name))
+		}
+		names[rt.Name] = true
+
+		if err := rt.validate(f.Upstreams); err != nil {
+			errs = append(errs, fmt.Errorf("route %d (%s): %w", i+1, rt.Name, err))
+		}
+	}
+	for i, rt := range f.Routes {
+		for _, name := range rt.Cache.Invalidates {
+			if !names[name] {
+				errs = append(errs, fmt.Errorf("route %d (%s): cache invalidates unknown route %q", i+1, rt.Name, name))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// urls returns the addresses of the instances.
+func (u UpstreamConfig) urls() []string {
+	if u.URL != "" {
+		return []string{u.URL}
+	}
+	return u.URLs
+}
+
+func (u UpstreamConfig) validate() error {
+	var errs []error
+
+	if u.URL != "" && len(u.URLs) > 0 {
+		errs = append(errs, errors.New("url and urls exclude each other"))
+	}
+	if len(u.urls()) == 0 {
+		errs = append(errs, errors.New("no url"))
+	}
+	for _, raw := range u.urls() {
+		addr, err := url.Parse(raw)
+		if err != nil || (addr.Scheme != "http" && addr.Scheme != "https") || addr.Host == "" {
+			errs = append(errs, fmt.Errorf("url %q is not an http or https URL", raw))
+		}
+	}
+
+	if u.Balance != "" && u.Balance != BalanceRoundRobin && u.Balance != BalanceLeastConnections {
+		errs = append(errs, fmt.Errorf("unknown balance %q", u.Balance))
+	}
+	if u.OpenAPI != "" && !strings.HasPrefix(u.OpenAPI, "/") {
+		errs = append(errs, fmt.Errorf("openapi %q does not start with /", u.OpenAPI))
+	}
+	if u.Health != "" && !strings.HasPrefix(u.Health, "/") {
+		errs = append(errs, fmt.Errorf("health %q does not start with /", u.Health))
+	}
+	if u.HealthInterval < 0 {
+		errs = append(errs, fmt.Errorf("negative health_interval %v", u.HealthInterval))
+	}
+
+	if u.Breaker.Failures != nil && *u.Breaker.Failures < 0 {
+		errs = append(errs, fmt.Errorf("negative breaker failures %d", *u.Breaker.Failures))
+	}
+	if u.Breaker.Cooldown < 0 {
+		errs = append(errs, fmt.Errorf("negative breaker cooldown %v", u.Breaker.Cooldown))
+	}
+	if u.Retry.Attempts != nil && *u.Retry.Attempts < 0 {
+		errs = append(errs, fmt.Errorf("negative retry attempts %d", *u.Retry.Attempts))
+	}
+	if u.Retry.BaseDelay < 0 || u.Retry.MaxDelay < 0 {
+		errs = append(errs, errors.New("negative retry delay"))
+	}
+	if u.Retry.Budget < 0 || u.Retry.Budget > 1 {
+		errs = append(errs, fmt.Errorf("retry budget %v is not between 0 and 1", u.Retry.Budget))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (rt RouteConfig) validate(upstreams map[string]UpstreamConfig) error {
+	var errs []error
+
+	pattern, err := rt.pattern()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, m := range rt.Methods {
+		if !slices.Contains(methods, m) {
+			errs = append(errs, fmt.Errorf("unknown method %q", m))
+		}
+	}
+
+	up, known := upstreams[rt.Upstream]
+	if !known {
+		errs = append(errs, fmt.Errorf("unknown upstream %q", rt.Upstream))
+	}
+
+	if rt.StripPrefix != "" {
+		if rt.Rewrite != "" {
+			errs = append(errs, errors.New("strip_prefix and rewrite exclude each other"))
+		}
+		source, strip := rt.Prefix+rt.Path, strings.TrimSuffix(rt.StripPrefix, "/")
+		if !strings.HasPrefix(rt.StripPrefix, "/") || (source != strip && !strings.HasPrefix(source, strip+"/")) {
+			errs = append(errs, fmt.Errorf("strip_prefix %q does not start the route", rt.StripPrefix))
+		}
+	}
+
+	if rt.Rewrite != "" && err == nil {
+		if rt.Path == "" {
+			errs = append(errs, errors.New("rewrite needs a path"))
+		}
+		if !strings.HasPrefix(rt.Rewrite, "/") {
+			errs = append(errs, fmt.Errorf("rewrite %q does not start with /", rt.Rewrite))
+		}
+		for _, m := range paramRef.FindAllStringSubmatch(rt.Rewrite, -1) {
+			if !slices.Contains(pattern.params(), m[1]) {
+				errs = append(errs, fmt.Errorf("rewrite refers to {%s}, which the path does not have", m[1]))
+			}
+		}
+	}
+
+	if rt.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("negative timeout %v", rt.Timeout))
+	}
+
+	if len(rt.Roles) > 0 && !slices.Contains(rt.Middleware, "auth") {
+		errs = append(errs, errors.New("roles need the auth middleware"))
+	}
+	if len(rt.Scopes) > 0 && !slices.Contains(rt.Middleware, "auth") {
+		errs = append(errs, errors.New("scopes need the auth middleware"))
+	}
+
+	if rt.Cache.TTL < 0 {
+		errs = append(errs, fmt.Errorf("negative cache ttl %v", rt.Cache.TTL))
+	}
+	if (rt.Cache.TTL != 0 || len(rt.Cache.Invalidates) > 0) && !slices.Contains(rt.Middleware, "cache") {
+		errs = append(errs, errors.New("cache needs the cache middleware"))
+	}
+
+	if rt.View != nil {
+		if err := rt.View.validate(rt.Methods, upstreams); err != nil {
+			errs = append(errs, fmt.Errorf("view: %w", err))
+		}
+	}
+
+	if err := rt.RateLimit.validate(rt.Middleware); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit: %w", err))
+	}
+
+	for _, name := range rt.Middleware {
+		if _, ok := middleware[name]; !ok {
+			errs = append(errs, fmt.Errorf("unknown middleware %q", name))
+		}
+		if name == "validate" && known && up.OpenAPI == "" {
+			errs = append(errs, fmt.Errorf("middleware validate needs the openapi path of upstream %s", rt.Upstream))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (rl RateLimitConfig) validate(mw []string) error {
+	var errs []error
+
+	limiting := slices.Index(mw, "ratelimit")
+	switch {
+	case rl == (RateLimitConfig{}) && limiting >= 0:
+		errs = append(errs, errors.New("middleware ratelimit needs a key, ip or route limit"))
+	case rl != (RateLimitConfig{}) && limiting < 0:
+		errs = append(errs, errors.New("needs the ratelimit middleware"))
+	}
+	if rl.Key != nil {
+		if auth := slices.Index(mw, "auth"); auth < 0 || auth > limiting {
+			errs = append(errs, errors.New("key needs the auth middleware before ratelimit"))
+		}
+	}
+
+	for scope, lc := range rl.buckets() {
+		if _, err := lc.limit(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", scope, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (v ViewConfig) validate(methods []string, upstreams map[string]UpstreamConfig) error {
+	var errs []error
+
+	known, ok := views[v.Name]
+	if !ok {
+		errs = append(errs, fmt.Errorf("unknown view %q", v.Name))
+	}
+	for _, source := range known.sources {
+		if _, ok := v.Sources[source]; !ok {
+			errs = append(errs, fmt.Errorf("view %s needs source %s", v.Name, source))
+		}
+	}
+	for _, source := range slices.Sorted(maps.Keys(v.Sources)) {
+		upstream := v.Sources[source]
+		if ok && !slices.Contains(known.sources, source) && !slices.Contains(known.optional, source) {
+			errs = append(errs, fmt.Errorf("view %s has no source %s", v.Name, source))
+		}
+		if _, ok := upstreams[upstream]; !ok {
+			errs = append(errs, fmt.Errorf("source %s: unknown upstream %q", source, upstream))
+		}
+	}
+
+	if ok && (len(methods) == 0 || slices.ContainsFunc(methods, func(m string) bool { return !slices.Contains(known.methods, m) })) {
+		errs = append(errs, fmt.Errorf("view %s answers %s only, methods must say so", v.Name, strings.Join(known.methods, " and ")))
+	}
+
+	return errors.Join(errs...)
+}
+
+// buckets yields the buckets that are set by their scope, the narrowest first: a caller
+// that floods the route is stopped by its own bucket before it drains the one of the route.
+func (rl RateLimitConfig) buckets() iter.Seq2[string, LimitConfig] {
+	return func(yield func(string, LimitConfig) bool) {
+		for _, b := range []struct {
+			scope string
+			cfg   *LimitConfig
+		}{{scopeKey, rl.Key}, {scopeIP, rl.IP}, {scopeRoute, rl.Route}} {
+			if b.cfg != nil && !yield(b.scope, *b.cfg) {
+				return
+			}
+		}
+	}
+}
+
+// limits returns the buckets of a valid config.
+func (rl RateLimitConfig) limits() []limit {
+	var limits []limit
+	for scope, lc := range rl.buckets() {
+		lim, _ := lc.limit()
+		limits = append(limits, limit{scope: scope, limit: lim})
+	}
+	return limits
+}
+
+func (lc LimitConfig) limit() (ratelimit.Limit, error) {
+	rate, err := ratelimit.ParseRate(lc.Rate)
+	if err != nil {
+		return ratelimit.Limit{}, err
+	}
+	if lc.Burst < 0 {
+		return ratelimit.Limit{}, fmt.Errorf("negative burst %d", lc.Burst)
+	}
+
+	burst := lc.Burst
+	if burst == 0 {
+		count, _, _ := strings.Cut(lc.Rate, "/")
+		n, _ := strconv.ParseFloat(count, 64)
+		burst = max(int(math.Ceil(n)), 1)
+	}
+
+	return ratelimit.Limit{Rate: rate, Burst: burst}, nil
+}
+
+// pattern compiles the path the route matches.
+func (rt RouteConfig) pattern() (pattern, error) {
+	switch {
+	case rt.Prefix != "" && rt.Path != "":
+		return pattern{}, errors.New("prefix and path exclude each other")
+	case rt.Prefix != "":
+		if !strings.HasPrefix(rt.Prefix, "/") {
+			return pattern{}, fmt.Errorf("prefix %q does not start with /", rt.Prefix)
+		}
+		return prefixPattern(rt.Prefix), nil
+	case rt.Path != "":
+		return compilePattern(rt.Path)
+	default:
+		return pattern{}, errors.New("no prefix or path")
+	}
+}
+
+var methods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+var (
+	paramName = regexp.MustCompile(`^\{(\w+)(\.\.\.)?\}$`)
+	paramRef  = regexp.MustCompile(`\{(\w+)(?:\.\.\.)?\}`)
+)
+
+// pattern matches a path segment by segment. A segment is either literal or a {name}
+// parameter, which matches any one segment. With rest, the segments after the pattern
+// match as well, and are the value of restName if it is set.
+type pattern struct {
+	segments []segment
+	rest     bool
+	restName string
+}
+
+type segment struct {
+	literal string
+	param   string
+}
+
+func prefixPattern(prefix string) pattern {
+	p := pattern{rest: true}
+	for _, seg := range split(prefix) {
+		p.segments = append(p.segments, segment{literal: seg})
+	}
+	return p
+}
+
+func compilePattern(path string) (pattern, error) {
+	if !strings.HasPrefix(path, "/") {
+		return pattern{}, fmt.Errorf("path %q does not start with /", path)
+	}
+
+	var p pattern
+	seen := make(map[string]bool)
+	segments := split(path)
+	for i, seg := range segments {
+		if !strings.ContainsAny(seg, "{}") {
+			p.segments = append(p.segments, segment{literal: seg})
+			continue
+		}
+
+		m := paramName.FindStringSubmatch(seg)
+		if m == nil {
+			return pattern{}, fmt.Errorf("path %q: segment %q is neither literal nor {name}", path, seg)
+		}
+		if seen[m[1]] {
+			return pattern{}, fmt.Errorf("path %q: parameter {%s} twice", path, m[1])
+		}
+		seen[m[1]] = true
+
+		if m[2] != "" {
+			if i != len(segments)-1 {
+				return pattern{}, fmt.Errorf("path %q: {%s...} is not the last segment", path, m[1])
+			}
+			p.rest, p.restName = true, m[1]
+			break
+		}
+		p.segments = append(p.segments, segment{param: m[1]})
+	}
+
+	return p, nil
+}
+
+func (p pattern) params() []string {
+	var names []string
+	for _, seg := range p.segments {
+		if seg.param != "" {
+			names = append(names, seg.param)
+		}
+	}
+	if p.restName != "" {
+		names = append(names, p.restName)
+	}
+	return names
+}
+
+// match returns the parameters of path, or false if the pattern does not match it.
+// Trailing slashes are ignored.
+func (p pattern) match(path string) (map[string]string, bool) {
+	parts := split(path)
+	if len(parts) < len(p.segments) || (!p.rest && len(parts) > len(p.segments)) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, seg := range p.segments {
+		switch {
+		case seg.param != "" && parts[i] != "":
+			params[seg.param] = parts[i]
+		case seg.param == "" && seg.literal == parts[i]:
+		default:
+			return nil, false
+		}
+	}
+	if p.restName != "" {
+		params[p.restName] = strings.Join(parts[len(p.segments):], "/")
+	}
+
+	return params, true
+}
+
+// expand fills the {params} of a rewrite template. An empty {name...} is left out along
+// with the slash in front of it, so /products/{rest...} expands to /products.
+func expand(template string, params map[string]string) string {
+	var parts []string
+	for _, seg := range split(template) {
+		m := paramName.FindStringSubmatch(seg)
+		switch {
+		case m == nil:
+			parts = append(parts, seg)
+		case m[2] != "" && params[m[1]] == "":
+		default:
+			parts = append(parts, params[m[1]])
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// split returns the segments of a path, none for the root.
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"api-gateway/migrations"
+	"api-gateway/pkg/apikey"
+	"api-gateway/pkg/postgres"
+	"api-gateway/pkg/ratelimit"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// openStores sets up the API keys and the rate limit buckets, and connects to Postgres when
+// one of them is kept there. Background work of the stores stops when ctx is done.
+func (g *Gateway) openStores(ctx context.Context) error {
+	keys, limits := g.cfg.APIKeys, g.cfg.RateLimit
+
+	if keys.Store == apikey.StorePostgres || limits.Store == ratelimit.StorePostgres {
+		if g.cfg.Postgres.Dsn == "" {
+			return errors.New("postgres: POSTGRES_DSN is not set")
+		}
+
+		db, err := postgres.New(ctx, g.cfg.Postgres)
+		if err != nil {
+			return fmt.Errorf("postgres: %w", err)
+		}
+		g.db = db
+
+		if g.cfg.Postgres.MigrateOnStart {
+			if err := postgres.Migrate(db.Pool, migrations.FS); err != nil {
+				return fmt.Errorf("postgres: %w", err)
+			}
+		}
+
+		// A second gateway in the same process, as in tests, leaves the metrics to the
+		// pool of the first
+		_ = prometheus.Register(postgres.NewCollector(db.Pool))
+	}
+
+	switch keys.Store {
+	case "":
+	case apikey.StoreMemory:
+		if keys.File == "" {
+			return errors.New("api keys: API_KEYS_FILE is not set")
+		}
+		store, err := apikey.LoadFile(keys.File)
+		if err != nil {
+			return fmt.Errorf("api keys: %s: %w", keys.File, err)
+		}
+		g.keys = apikey.NewVerifier(store)
+	case apikey.StorePostgres:
+		g.keys = apikey.NewVerifier(apikey.NewPostgres(g.db.Pool, keys.CacheTTL))
+	default:
+		return fmt.Errorf("api keys: unknown store %q", keys.Store)
+	}
+
+	switch limits.Store {
+	case "", ratelimit.StoreMemory:
+		g.limiter = ratelimit.NewMemory()
+	case ratelimit.StorePostgres:
+		g.limiter = ratelimit.NewPostgres(ctx, g.db.Pool)
+	default:
+		return fmt.Errorf("rate limit: unknown store %q", limits.Store)
+	}
+
+	return nil
+}
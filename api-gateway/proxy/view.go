@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"api-gateway/pkg/authz"
+	"api-gateway/pkg/breaker"
+	"api-gateway/pkg/problem"
+	"api-gateway/pkg/requestid"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxViewBody bounds what a view reads of a response.
+	maxViewBody = 4 << 20
+	// viewConcurrency is how many requests a view sends to a source at once.
+	viewConcurrency = 8
+)
+
+// views are the views a route can name in the route file, with the sources each one reads
+// besides the upstream of the route, those it can do without and the methods it answers.
+var views = map[string]struct {
+	sources  []string
+	optional []string
+	methods  []string
+	handler  func(g *Gateway, rt *route) gin.HandlerFunc
+}{
+	"order":   {sources: []string{"products"}, methods: []string{http.MethodGet, http.MethodHead}, handler: orderView},
+	"graphql": {sources: []string{"products"}, optional: []string{"categories"}, methods: []string{http.MethodGet, http.MethodPost}, handler: graphqlView},
+}
+
+// viewError is a part of a view that could not be read, all of the source when it has no ID.
+type viewError struct {
+	Source string `json:"source"`
+	ID     string `json:"id,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// orderView answers with the order of the upstream of the route, every line carrying its
+// product read from the products source. The products are read in one batch. When some
+// cannot be read, the lines keep a null product and the view says it is partial rather
+// than failing: the order alone is still worth showing.
+func orderView(g *Gateway, rt *route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if rt.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, rt.timeout)
+			defer cancel()
+		}
+		header := viewHeader(c.Request.Header)
+
+		resp, body, err := rt.upstream.get(ctx, g.client, c.Request.URL.Path, header)
+		if err != nil {
+			unreachable(c, rt, rt.upstream, err)
+			return
+		}
+		// Not found, forbidden and the like are the answer of the view as well
+		if resp.StatusCode != http.StatusOK {
+			c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+			return
+		}
+
+		var doc struct {
+			Order map[string]json.RawMessage `json:"order"`
+		}
+		var items []map[string]json.RawMessage
+		if err := json.Unmarshal(body, &doc); err != nil || doc.Order == nil || json.Unmarshal(doc.Order["items"], &items) != nil {
+			slog.ErrorContext(ctx, "proxy: cannot read the order of the view", "route", rt.name, "error", err)
+			problem.Write(c, problem.New(http.StatusBadGateway, problem.CodeBadGateway, "the order could not be read"))
+			return
+		}
+
+		var ids []string
+		for _, item := range items {
+			if id := string(item["product_id"]); !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		// The products are read like those of GraphQL, in a session of the request
+		ctx = context.WithValue(ctx, sessionKey{}, &session{header: header, readOnly: true})
+		found, failed := readProducts(ctx, g, rt, ids)
+
+		for _, item := range items {
+			item["product"] = found[string(item["product_id"])]
+			if item["product"] == nil {
+				item["product"] = json.RawMessage("null")
+			}
+		}
+		doc.Order["items"], _ = json.Marshal(items)
+
+		for _, e := range failed {
+			viewPartials.WithLabelValues(rt.name, e.Source).Inc()
+		}
+		c.JSON(http.StatusOK, gin.H{"order": doc.Order, "partial": len(failed) > 0, "errors": failed})
+	}
+}
+
+// readProducts reads the products with ids in batches, and tells why the others could not
+// be read. A failed batch is one error, for all of its products.
+func readProducts(ctx context.Context, g *Gateway, rt *route, ids []string) (map[string]json.RawMessage, []viewError) {
+	found := make(map[string]json.RawMessage, len(ids))
+	failed := []viewError{}
+
+	var wanted []int64
+	for _, id := range ids {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			failed = append(failed, viewError{Source: "products", ID: id, Detail: "the order line has no product ID"})
+			continue
+		}
+		wanted = append(wanted, n)
+	}
+	if len(wanted) == 0 {
+		return found, failed
+	}
+
+	products, err := fetchProducts(ctx, g, rt, wanted)
+	if err != nil {
+		return found, append(failed, viewError{Source: "products", Detail: err.Error()})
+	}
+	for _, id := range wanted {
+		key := strconv.FormatInt(id, 10)
+		p, ok := products[id]
+		if !ok {
+			failed = append(failed, viewError{Source: "products", ID: key, Detail: "product not found"})
+			continue
+		}
+		found[key], _ = json.Marshal(p)
+	}
+	return found, failed
+}
+
+// viewHeader returns the headers of the request that a view passes on to its sources: the
+// request ID and the identity the auth middleware verified.
+func viewHeader(h http.Header) http.Header {
+	out := make(http.Header)
+	for _, name := range []string{requestid.Header, authz.HeaderSubject, authz.HeaderRoles, "Accept-Language"} {
+		if v := h.Values(name); len(v) > 0 {
+			out[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+	out.Set("Accept", "application/json")
+	return out
+}
+
+// unreachable answers for the upstream of a view that could not be read.
+func unreachable(c *gin.Context, rt *route, p *pool, err error) {
+	ctx := c.Request.Context()
+	switch {
+	case errors.Is(err, breaker.ErrOpen):
+		problem.Write(c, p.breakerOpen(c))
+	case errors.Is(err, errNoHealthyInstance):
+		problem.Write(c, problem.New(http.StatusServiceUnavailable, problem.CodeServiceUnavailable, "no instance of the target service is healthy"))
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+		slog.WarnContext(ctx, "proxy: target service timed out", "route", rt.name, "upstream", p.name, "timeout", rt.timeout)
+		problem.Write(c, problem.New(http.StatusGatewayTimeout, problem.CodeGatewayTimeout, "target service did not respond in time"))
+	default:
+		slog.WarnContext(ctx, "proxy: target service unavailable", "route", rt.name, "upstream", p.name, "error", err)
+		problem.Write(c, problem.New(http.StatusBadGateway, problem.CodeBadGateway, "target service unavailable"))
+	}
+}
+
+// get reads path from an instance of the upstream, through its breaker. Views read the
+// upstreams with it. The body of the response is read and closed.
+func (p *pool) get(ctx context.Context, client *http.Client, path string, header http.Header) (*http.Response, []byte, error) {
+	return p.call(ctx, client, http.MethodGet, path, header, nil)
+}
+
+// call is get for any method, with body as the body of the request. It is not retried.
+func (p *pool) call(ctx context.Context, client *http.Client, method, path string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	in := p.pick()
+	if in == nil {
+		upstreamRequests.WithLabelValues(p.name, "unavailable").Inc()
+		return nil, nil, errNoHealthyInstance
+	}
+	defer in.done()
+
+	if err := p.allow(); err != nil {
+		upstreamRequests.WithLabelValues(p.name, "breaker_open").Inc()
+		return nil, nil, err
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, in.url.String()+path, reqBody)
+	if err != nil {
+		p.record(nil, err, true)
+		return nil, nil, err
+	}
+	req.Header = header.Clone()
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	var respBody []byte
+	if err == nil {
+		respBody, err = io.ReadAll(io.LimitReader(resp.Body, maxViewBody))
+		resp.Body.Close()
+	}
+	p.record(resp, err, errors.Is(ctx.Err(), context.Canceled))
+
+	upstreamDuration.WithLabelValues(p.name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		upstreamRequests.WithLabelValues(p.name, "error").Inc()
+		return nil, nil, err
+	}
+	upstreamRequests.WithLabelValues(p.name, strconv.Itoa(resp.StatusCode)).Inc()
+	return resp, respBody, nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"time"
+
+	"auth-service/pkg/health"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/postgres"
+	"auth-service/pkg/tracing"
+
+	"github.com/caarlos0/env/v10"
+	"github.com/joho/godotenv"
+)
+
+type (
+	Config struct {
+		Postgres postgres.Config
+		Server   Server
+		Signer   Signer
+		Auth     Auth
+		Log      logger.Config
+		Tracing  tracing.Config
+		Health   health.Config
+
+		Version string `env:"VERSION"`
+	}
+
+	// We can have multiple servers like gRPC or smth else.
+	Server struct {
+		HTTPServer HTTPServer
+
+		// On shutdown the readiness probe fails for ShutdownDelay first, so that load
+		// balancers stop sending requests. In-flight requests then get ShutdownTimeout to
+		// finish, the rest is cut off.
+		ShutdownDelay   time.Duration `env:"SHUTDOWN_DELAY" envDefault:"0s"`
+		ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s"`
+	}
+
+	HTTPServer struct {
+		Port           int           `env:"HTTP_PORT" envDefault:"8083"`
+		ReadTimeout    time.Duration `env:"HTTP_READ_TIMEOUT" envDefault:"30s"`
+		WriteTimeout   time.Duration `env:"HTTP_WRITE_TIMEOUT" envDefault:"30s"`
+		IdleTimeout    time.Duration `env:"HTTP_IDLE_TIMEOUT" envDefault:"60s"`
+		MaxHeaderBytes int           `env:"HTTP_MAX_HEADER_BYTES" envDefault:"1048576"` // 1 MB
+		TrustedProxies []string      `env:"HTTP_TRUSTED_PROXIES" envSeparator:","`
+		Mode           string        `env:"GIN_MODE" envDefault:"release"` // Can be: release, debug, test
+	}
+
+	// Signer issues the access tokens, which the gateway verifies with the keys published
+	// at /.well-known/jwks.json.
+	Signer struct {
+		Issuer    string        `env:"AUTH_ISSUER" envDefault:"auth-service"`
+		Audience  string        `env:"AUTH_AUDIENCE" envDefault:"go-ecommerce"`
+		KeyFile   string        `env:"AUTH_SIGNING_KEY_FILE"` // PEM private key, RSA or P-256. A key is generated on start without it
+		AccessTTL time.Duration `env:"AUTH_ACCESS_TTL" envDefault:"15m"`
+	}
+
+	// Auth holds the rules for passwords and the tokens kept in the database.
+	Auth struct {
+		RefreshTTL       time.Duration `env:"AUTH_REFRESH_TTL" envDefault:"720h"` // 30 days
+		ResetTTL         time.Duration `env:"AUTH_RESET_TTL" envDefault:"1h"`
+		ResetURL         string        `env:"AUTH_RESET_URL" envDefault:"http://localhost:8080/reset-password?token="` // The reset token is appended
+		PasswordHashCost int           `env:"AUTH_PASSWORD_HASH_COST" envDefault:"12"`                                 // bcrypt cost
+	}
+)
+
+func New() (*Config, error) {
+	var cfg Config
+
+	err := godotenv.Load()
+	if err != nil {
+		return &cfg, err
+	}
+
+	err = env.Parse(&cfg)
+
+	return &cfg, err
+}
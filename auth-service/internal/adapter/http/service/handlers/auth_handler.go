@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"auth-service/internal/adapter/http/service/handlers/dto"
+	"auth-service/pkg/problem"
+	"auth-service/pkg/validator"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandler
+type Auth struct {
+	uc   AuthUsecase
+	jwks []byte
+}
+
+// NewAuth serves jwks, the public keys of the access tokens, along with the use cases.
+func NewAuth(uc AuthUsecase, jwks []byte) *Auth {
+	return &Auth{
+		uc:   uc,
+		jwks: jwks,
+	}
+}
+
+func (c *Auth) Register(ctx *gin.Context) {
+	var req dto.CredentialsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Write(ctx, problem.BadRequest(err.Error()))
+		return
+	}
+
+	v := validator.New()
+	dto.ValidateEmail(v, req.Email)
+	if dto.ValidatePassword(v, "password", req.Password); !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	user, err := c.uc.Register(ctx.Request.Context(), req.Email, req.Password)
+	if err != nil {
+		c.fail(ctx, "register", err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"user": dto.ToUserResponse(user)})
+}
+
+func (c *Auth) Login(ctx *gin.Context) {
+	var req dto.CredentialsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Write(ctx, problem.BadRequest(err.Error()))
+		return
+	}
+
+	v := validator.New()
+	v.Check(req.Email != "", "email", "must be provided")
+	if v.Check(req.Password != "", "password", "must be provided"); !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	pair, err := c.uc.Login(ctx.Request.Context(), req.Email, req.Password)
+	if err != nil {
+		c.fail(ctx, "login", err)
+		return
+	}
+
+	c.tokens(ctx, dto.ToTokenResponse(pair))
+}
+
+func (c *Auth) Refresh(ctx *gin.Context) {
+	var req dto.RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Write(ctx, problem.BadRequest(err.Error()))
+		return
+	}
+
+	v := validator.New()
+	if dto.ValidateToken(v, "refresh_token", req.RefreshToken); !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	pair, err := c.uc.Refresh(ctx.Request.Context(), req.RefreshToken)
+	if err != nil {
+		c.fail(ctx, "refresh", err)
+		return
+	}
+
+	c.tokens(ctx, dto.ToTokenResponse(pair))
+}
+
+func (c *Auth) Logout(ctx *gin.Context) {
+	var req dto.RefreshRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Write(ctx, problem.BadRequest(err.Error()))
+		return
+	}
+
+	v := validator.New()
+	if dto.ValidateToken(v, "refresh_token", req.RefreshToken); !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	if err := c.uc.Logout(ctx.Request.Context(), req.RefreshToken); err != nil {
+		c.fail(ctx, "logout", err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// ForgotPassword answers the same whether the email belongs to a user or not.
+func (c *Auth) ForgotPassword(ctx *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Write(ctx, problem.BadRequest(err.Error()))
+		return
+	}
+
+	v := validator.New()
+	if dto.ValidateEmail(v, req.Email); !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	if err := c.uc.RequestReset(ctx.Request.Context(), req.Email); err != nil {
+		c.fail(ctx, "request password reset", err)
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+func (c *Auth) ResetPassword(ctx *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Write(ctx, problem.BadRequest(err.Error()))
+		return
+	}
+
+	v := validator.New()
+	dto.ValidateToken(v, "token", req.Token)
+	if dto.ValidatePassword(v, "password", req.Password); !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	if err := c.uc.ResetPassword(ctx.Request.Context(), req.Token, req.Password); err != nil {
+		c.fail(ctx, "reset password", err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// JWKS serves the public keys the access tokens are checked with.
+func (c *Auth) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.Data(http.StatusOK, "application/json", c.jwks)
+}
+
+// tokens responds with tokens, which must not be cached on the way.
+func (c *Auth) tokens(ctx *gin.Context, resp dto.TokenResponse) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// fail responds with the problem for err and logs the errors that are not the fault of
+// the client.
+func (c *Auth) fail(ctx *gin.Context, op string, err error) {
+	p := dto.FromError(err)
+	if p.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx.Request.Context(), op, "error", err)
+	}
+	problem.Write(ctx, p)
+}
@@ -0,0 +1,61 @@
+package dto
+
+import (
+	"auth-service/internal/models"
+	"time"
+)
+
+type CredentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type UserResponse struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	Roles     []string  `json:"roles"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TokenResponse follows the token response of OAuth 2.0, RFC 6749 section 5.1.
+type TokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"` // Seconds
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // Seconds
+}
+
+func ToUserResponse(user models.User) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Email:     user.Email,
+		Roles:     user.Roles,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func ToTokenResponse(pair models.TokenPair) TokenResponse {
+	now := time.Now()
+
+	return TokenResponse{
+		AccessToken:      pair.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(pair.AccessExpiresAt.Sub(now).Round(time.Second).Seconds()),
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresIn: int64(pair.RefreshExpiresAt.Sub(now).Round(time.Second).Seconds()),
+	}
+}
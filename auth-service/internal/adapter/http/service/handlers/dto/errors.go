@@ -0,0 +1,23 @@
+package dto
+
+import (
+	"errors"
+	"net/http"
+
+	"auth-service/internal/models"
+	"auth-service/pkg/problem"
+)
+
+// FromError maps use case errors to the problem reported to the client.
+func FromError(err error) *problem.Problem {
+	switch {
+	case errors.Is(err, models.ErrEmailTaken):
+		return problem.New(http.StatusConflict, problem.CodeEmailTaken, "a user with this email already exists")
+	case errors.Is(err, models.ErrInvalidCredentials):
+		return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "invalid email or password")
+	case errors.Is(err, models.ErrInvalidToken):
+		return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "the token is invalid, expired or was used already")
+	default:
+		return problem.Internal()
+	}
+}
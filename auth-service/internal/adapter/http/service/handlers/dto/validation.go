@@ -0,0 +1,25 @@
+package dto
+
+import "auth-service/pkg/validator"
+
+// bcrypt only looks at the first 72 bytes of a password.
+const (
+	minPasswordBytes = 8
+	maxPasswordBytes = 72
+)
+
+func ValidateEmail(v *validator.Validator, email string) {
+	v.Check(email != "", "email", "must be provided")
+	v.Check(len(email) <= 254, "email", "must not be more than 254 bytes long")
+	v.Check(validator.EmailRX.MatchString(email), "email", "must be a valid email address")
+}
+
+func ValidatePassword(v *validator.Validator, key, password string) {
+	v.Check(password != "", key, "must be provided")
+	v.Check(len(password) >= minPasswordBytes, key, "must be at least 8 bytes long")
+	v.Check(len(password) <= maxPasswordBytes, key, "must not be more than 72 bytes long")
+}
+
+func ValidateToken(v *validator.Validator, key, token string) {
+	v.Check(token != "", key, "must be provided")
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"auth-service/internal/models"
+	"context"
+)
+
+type AuthUsecase interface {
+	Register(ctx context.Context, email, password string) (models.User, error)
+	Login(ctx context.Context, email, password string) (models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	RequestReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, resetToken, password string) error
+}
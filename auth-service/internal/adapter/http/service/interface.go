@@ -0,0 +1,7 @@
+package service
+
+import "auth-service/internal/adapter/http/service/handlers"
+
+type AuthUsecase interface {
+	handlers.AuthUsecase
+}
@@ -0,0 +1,39 @@
+// Package openapi holds the OpenAPI 3 document of the HTTP API. It is written by hand next to
+// the routes, and a test in the service package checks that both list the same operations.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+// Load parses and validates the document.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// JSON returns the document encoded as JSON, the way it is served.
+var JSON = sync.OnceValues(func() ([]byte, error) {
+	doc, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(doc)
+})
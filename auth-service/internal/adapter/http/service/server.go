@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"auth-service/config"
+	"auth-service/internal/adapter/http/service/handlers"
+	"auth-service/internal/adapter/http/service/openapi"
+	"auth-service/pkg/health"
+	"auth-service/pkg/logger"
+	"auth-service/pkg/metrics"
+	"auth-service/pkg/problem"
+	"auth-service/pkg/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+const serverIPAddress = "127.0.0.1:%d" // Changed to 0.0.0.0 for external access
+
+type API struct {
+	router *gin.Engine
+	server *http.Server
+	cfg    config.HTTPServer
+	addr   string
+	health *health.Health
+
+	authHandler *handlers.Auth
+}
+
+// New serves authUsecase, and jwks at /.well-known/jwks.json.
+func New(cfg config.Server, authUsecase AuthUsecase, jwks []byte, checks *health.Health) *API {
+	// Setting the Gin mode
+	gin.SetMode(cfg.HTTPServer.Mode)
+	// Creating a new Gin Engine
+	router := gin.New()
+
+	// Applying middleware
+	router.Use(requestid.Middleware())
+	router.Use(otelgin.Middleware("auth-service"))
+	router.Use(logger.Middleware())
+	router.Use(metrics.Middleware())
+	router.Use(gin.Recovery())
+	router.NoRoute(problem.NoRoute)
+
+	// Binding auth
+	authHandler := handlers.NewAuth(authUsecase, jwks)
+
+	api := &API{
+		router:      router,
+		cfg:         cfg.HTTPServer,
+		addr:        fmt.Sprintf(serverIPAddress, cfg.HTTPServer.Port),
+		health:      checks,
+		authHandler: authHandler,
+	}
+
+	api.setupRoutes()
+
+	api.server = &http.Server{
+		Addr:           api.addr,
+		Handler:        router,
+		ReadTimeout:    cfg.HTTPServer.ReadTimeout,
+		WriteTimeout:   cfg.HTTPServer.WriteTimeout,
+		IdleTimeout:    cfg.HTTPServer.IdleTimeout,
+		MaxHeaderBytes: cfg.HTTPServer.MaxHeaderBytes,
+	}
+
+	return api
+}
+
+func (a *API) setupRoutes() {
+	a.router.GET("/healthcheck", a.HealthCheck)
+	a.router.GET("/livez", a.health.Live)
+	a.router.GET("/readyz", a.health.Ready)
+	a.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	a.router.GET("/openapi.json", a.OpenAPI)
+	a.router.GET("/.well-known/jwks.json", a.authHandler.JWKS)
+
+	auth := a.router.Group("/auth")
+	{
+		auth.POST("/register", a.authHandler.Register)
+		auth.POST("/login", a.authHandler.Login)
+		auth.POST("/refresh", a.authHandler.Refresh)
+		auth.POST("/logout", a.authHandler.Logout)
+		auth.POST("/password/forgot", a.authHandler.ForgotPassword)
+		auth.POST("/password/reset", a.authHandler.ResetPassword)
+	}
+}
+
+// Handler returns the router, so the API can be served by something other than Run.
+func (a *API) Handler() http.Handler {
+	return a.router
+}
+
+func (a *API) Run(errCh chan<- error) {
+	go func() {
+		slog.Info("HTTP server starting", "addr", a.addr)
+
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- fmt.Errorf("failed to start HTTP server: %w", err)
+			return
+		}
+	}()
+}
+
+// Stop stops accepting connections and waits for the requests in flight to finish. The
+// ones still running when ctx is done are cut off.
+func (a *API) Stop(ctx context.Context) error {
+	slog.Info("HTTP server shutting down gracefully")
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		a.server.Close()
+		return fmt.Errorf("HTTP server shutdown: %w", err)
+	}
+
+	slog.Info("HTTP server stopped successfully")
+
+	return nil
+}
+
+func (a *API) HealthCheck(c *gin.Context) {
+	c.JSON(200, map[string]any{
+		"status": "available",
+		"system_info": map[string]string{
+			"address": a.addr,
+			"mode":    a.cfg.Mode,
+		},
+	})
+}
+
+// OpenAPI serves the OpenAPI document of the API.
+func (a *API) OpenAPI(c *gin.Context) {
+	spec, err := openapi.JSON()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "openapi", "error", err)
+		problem.Write(c, problem.Internal())
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", spec)
+}
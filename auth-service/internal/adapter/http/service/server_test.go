@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"auth-service/config"
+	"auth-service/internal/adapter/http/service/openapi"
+	"auth-service/pkg/health"
+)
+
+// ginParam matches the path parameters of Gin, such as :id.
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+// TestRoutesMatchOpenAPI fails when a route is added without an entry in openapi.yaml, or
+// an entry is left behind after its route was removed.
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("load openapi document: %v", err)
+	}
+
+	var documented []string
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	api := New(config.Server{HTTPServer: config.HTTPServer{Mode: "test"}}, nil, nil, health.New(health.Config{}))
+
+	var routed []string
+	for _, route := range api.router.Routes() {
+		routed = append(routed, route.Method+" "+ginParam.ReplaceAllString(route.Path, "{$1}"))
+	}
+
+	for _, op := range routed {
+		if !slices.Contains(documented, op) {
+			t.Errorf("route %s is missing from openapi.yaml", op)
+		}
+	}
+	for _, op := range documented {
+		if !slices.Contains(routed, op) {
+			t.Errorf("openapi.yaml documents %s, but there is no such route", op)
+		}
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	api := New(config.Server{HTTPServer: config.HTTPServer{Mode: "test"}}, nil, nil, health.New(health.Config{}))
+
+	rec := httptest.NewRecorder()
+	api.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var doc struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || doc.Paths["/auth/login"] == nil {
+		t.Errorf("unexpected document: openapi %q, %d paths", doc.OpenAPI, len(doc.Paths))
+	}
+}
@@ -0,0 +1,30 @@
+// Package mailer gets the password reset tokens to the users.
+package mailer
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"auth-service/internal/models"
+)
+
+// Log writes the reset links to the log instead of sending them, until the shop sends
+// email. The links let anyone set the password, so the log has to be kept as private.
+type Log struct {
+	resetURL string
+}
+
+func NewLog(resetURL string) *Log {
+	return &Log{resetURL: resetURL}
+}
+
+func (l *Log) SendReset(ctx context.Context, user models.User, token string, expiresAt time.Time) error {
+	slog.InfoContext(ctx, "password reset requested",
+		"user_id", user.ID,
+		"email", user.Email,
+		"link", l.resetURL+token,
+		"expires_at", expiresAt,
+	)
+	return nil
+}
@@ -0,0 +1,20 @@
+package memory
+
+import (
+	"testing"
+
+	"auth-service/internal/usecase"
+	"auth-service/internal/usecase/usecasetest"
+)
+
+func TestUserRepository(t *testing.T) {
+	usecasetest.TestUserRepository(t, func(t *testing.T) usecase.UserRepository {
+		return NewUserRepository()
+	})
+}
+
+func TestTokenRepository(t *testing.T) {
+	usecasetest.TestTokenRepository(t, func(t *testing.T) (usecase.UserRepository, usecase.TokenRepository) {
+		return NewUserRepository(), NewTokenRepository()
+	})
+}
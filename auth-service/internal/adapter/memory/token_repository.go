@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"auth-service/internal/models"
+)
+
+// TokenRepository keeps refresh and reset tokens in memory, by the hash of their value.
+type TokenRepository struct {
+	mu      sync.Mutex
+	nextID  int64
+	refresh map[string]*refreshToken
+	resets  map[string]*resetToken
+}
+
+type refreshToken struct {
+	models.RefreshToken
+	used, revoked bool
+}
+
+type resetToken struct {
+	models.ResetToken
+	used bool
+}
+
+func NewTokenRepository() *TokenRepository {
+	return &TokenRepository{
+		refresh: make(map[string]*refreshToken),
+		resets:  make(map[string]*resetToken),
+	}
+}
+
+func (r *TokenRepository) CreateRefresh(ctx context.Context, token models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	token.ID = r.nextID
+	r.refresh[string(token.Hash)] = &refreshToken{RefreshToken: token}
+
+	return nil
+}
+
+func (r *TokenRepository) UseRefresh(ctx context.Context, hash []byte, now time.Time) (models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.refresh[string(hash)]
+	switch {
+	case !ok:
+		return models.RefreshToken{}, models.ErrInvalidToken
+	case token.used || token.revoked:
+		return token.RefreshToken, models.ErrTokenUsed
+	}
+
+	token.used = true
+	return token.RefreshToken, nil
+}
+
+func (r *TokenRepository) RevokeFamily(ctx context.Context, family string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.refresh {
+		if token.Family == family {
+			token.revoked = true
+		}
+	}
+	return nil
+}
+
+func (r *TokenRepository) RevokeUser(ctx context.Context, userID int64, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.refresh {
+		if token.UserID == userID {
+			token.revoked = true
+		}
+	}
+	return nil
+}
+
+func (r *TokenRepository) CreateReset(ctx context.Context, token models.ResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resets[string(token.Hash)] = &resetToken{ResetToken: token}
+	return nil
+}
+
+func (r *TokenRepository) UseReset(ctx context.Context, hash []byte, now time.Time) (models.ResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.resets[string(hash)]
+	switch {
+	case !ok:
+		return models.ResetToken{}, models.ErrInvalidToken
+	case token.used:
+		return models.ResetToken{}, models.ErrTokenUsed
+	}
+
+	token.used = true
+	return token.ResetToken, nil
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"auth-service/internal/models"
+)
+
+// UserRepository keeps users in memory. It is safe for concurrent use and behaves like
+// the Postgres repository, the shared tests in usecasetest hold both to the same contract.
+type UserRepository struct {
+	mu     sync.Mutex
+	nextID int64
+	users  map[int64]models.User
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{users: make(map[int64]models.User)}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user models.User) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.users {
+		if other.Email == user.Email {
+			return 0, models.ErrEmailTaken
+		}
+	}
+
+	r.nextID++
+	user.ID = r.nextID
+	user.CreatedAt = time.Now().Round(time.Second)
+	user.PasswordHash = slices.Clone(user.PasswordHash)
+	user.Roles = slices.Clone(user.Roles)
+	r.users[user.ID] = user
+
+	return user.ID, nil
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return clone(user), nil
+		}
+	}
+	return models.User{}, models.ErrUserNotFound
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, models.ErrUserNotFound
+	}
+	return clone(user), nil
+}
+
+func (r *UserRepository) SetPassword(ctx context.Context, id int64, hash []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return models.ErrUserNotFound
+	}
+	user.PasswordHash = slices.Clone(hash)
+	r.users[id] = user
+
+	return nil
+}
+
+// SetRoles replaces the roles of a user. There is no API for it, tests use it to make
+// staff and admins.
+func (r *UserRepository) SetRoles(id int64, roles ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[id]; ok {
+		user.Roles = roles
+		r.users[id] = user
+	}
+}
+
+func clone(user models.User) models.User {
+	user.PasswordHash = slices.Clone(user.PasswordHash)
+	user.Roles = slices.Clone(user.Roles)
+	return user
+}
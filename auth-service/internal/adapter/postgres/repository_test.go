@@ -0,0 +1,22 @@
+package postgres
+
+import (
+	"testing"
+
+	"auth-service/internal/usecase"
+	"auth-service/internal/usecase/usecasetest"
+	"auth-service/pkg/postgres/postgrestest"
+)
+
+func TestUserRepository(t *testing.T) {
+	usecasetest.TestUserRepository(t, func(t *testing.T) usecase.UserRepository {
+		return NewUserRepository(postgrestest.New(t))
+	})
+}
+
+func TestTokenRepository(t *testing.T) {
+	usecasetest.TestTokenRepository(t, func(t *testing.T) (usecase.UserRepository, usecase.TokenRepository) {
+		pool := postgrestest.New(t)
+		return NewUserRepository(pool), NewTokenRepository(pool)
+	})
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"auth-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Token struct {
+	db *pgxpool.Pool
+}
+
+func NewTokenRepository(db *pgxpool.Pool) *Token {
+	return &Token{db: db}
+}
+
+func (r *Token) CreateRefresh(ctx context.Context, token models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.Exec(ctx, query, token.UserID, token.Family, token.Hash, token.ExpiresAt)
+	return err
+}
+
+// UseRefresh claims the token with a single update, so that of concurrent calls only one
+// finds it unused.
+func (r *Token) UseRefresh(ctx context.Context, hash []byte, now time.Time) (models.RefreshToken, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL AND revoked_at IS NULL
+		RETURNING id, user_id, family, token_hash, expires_at
+	`
+
+	token, err := scanRefresh(r.db.QueryRow(ctx, query, hash, now))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return token, err
+	}
+
+	// Telling a token that was used or revoked from one that never existed
+	query = `
+		SELECT id, user_id, family, token_hash, expires_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	token, err = scanRefresh(r.db.QueryRow(ctx, query, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.RefreshToken{}, models.ErrInvalidToken
+	}
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
+
+	return token, models.ErrTokenUsed
+}
+
+func (r *Token) RevokeFamily(ctx context.Context, family string, now time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE family = $1 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.Exec(ctx, query, family, now)
+	return err
+}
+
+func (r *Token) RevokeUser(ctx context.Context, userID int64, now time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.Exec(ctx, query, userID, now)
+	return err
+}
+
+func (r *Token) CreateReset(ctx context.Context, token models.ResetToken) error {
+	query := `
+		INSERT INTO reset_tokens (token_hash, user_id, expires_at)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err := r.db.Exec(ctx, query, token.Hash, token.UserID, token.ExpiresAt)
+	return err
+}
+
+func (r *Token) UseReset(ctx context.Context, hash []byte, now time.Time) (models.ResetToken, error) {
+	query := `
+		UPDATE reset_tokens
+		SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL
+		RETURNING token_hash, user_id, expires_at
+	`
+
+	var token models.ResetToken
+	err := r.db.QueryRow(ctx, query, hash, now).Scan(&token.Hash, &token.UserID, &token.ExpiresAt)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return token, err
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM reset_tokens WHERE token_hash = $1)`, hash).Scan(&exists); err != nil {
+		return models.ResetToken{}, err
+	}
+	if exists {
+		return models.ResetToken{}, models.ErrTokenUsed
+	}
+	return models.ResetToken{}, models.ErrInvalidToken
+}
+
+func scanRefresh(row pgx.Row) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := row.Scan(&token.ID, &token.UserID, &token.Family, &token.Hash, &token.ExpiresAt)
+	return token, err
+}
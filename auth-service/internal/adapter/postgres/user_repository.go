@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"auth-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type User struct {
+	db *pgxpool.Pool
+}
+
+func NewUserRepository(db *pgxpool.Pool) *User {
+	return &User{db: db}
+}
+
+func (r *User) Create(ctx context.Context, user models.User) (int64, error) {
+	query := `
+		INSERT INTO users (email, password_hash, roles)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRow(ctx, query, user.Email, user.PasswordHash, user.Roles).Scan(&id)
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == "23505": // unique_violation
+		return 0, models.ErrEmailTaken
+	case err != nil:
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *User) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.get(ctx, "email = $1", email)
+}
+
+func (r *User) GetByID(ctx context.Context, id int64) (models.User, error) {
+	return r.get(ctx, "id = $1", id)
+}
+
+func (r *User) get(ctx context.Context, where string, arg any) (models.User, error) {
+	query := `
+		SELECT id, email, password_hash, roles, created_at
+		FROM users
+		WHERE ` + where
+
+	var user models.User
+	err := r.db.QueryRow(ctx, query, arg).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Roles, &user.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.User{}, models.ErrUserNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+func (r *User) SetPassword(ctx context.Context, id int64, hash []byte) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, updated_at = NOW()
+		WHERE id = $2
+	`
+
+	result, err := r.db.Exec(ctx, query, hash, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return models.ErrUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package signer issues the access tokens and publishes the public key they are checked
+// with as a JSON Web Key Set.
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"auth-service/config"
+	"auth-service/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Signer struct {
+	key    crypto.Signer
+	method jwt.SigningMethod
+	kid    string
+	jwks   []byte
+	cfg    config.Signer
+}
+
+// claims are the claims of an access token, the gateway reads the subject and the roles.
+type claims struct {
+	jwt.RegisteredClaims
+	Email string   `json:"email"`
+	Roles []string `json:"roles"`
+}
+
+// New loads the key from cfg.KeyFile. Without one it generates a key, which is fine for
+// a single instance in development: the tokens it signed are worthless after a restart
+// and other instances do not know it.
+func New(cfg config.Signer) (*Signer, error) {
+	var key crypto.Signer
+	if cfg.KeyFile != "" {
+		var err error
+		if key, err = loadKey(cfg.KeyFile); err != nil {
+			return nil, fmt.Errorf("AUTH_SIGNING_KEY_FILE: %w", err)
+		}
+	} else {
+		slog.Warn("AUTH_SIGNING_KEY_FILE is not set, signing with a generated key")
+
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+
+	return newSigner(key, cfg)
+}
+
+func newSigner(key crypto.Signer, cfg config.Signer) (*Signer, error) {
+	s := &Signer{key: key, cfg: cfg}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys need at least 2048 bits")
+		}
+		s.method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("EC keys need the P-256 curve")
+		}
+		s.method = jwt.SigningMethodES256
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, want RSA or P-256", key)
+	}
+
+	// The key ID is derived from the public key, so every instance with the same key
+	// agrees on it
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	s.kid = base64.RawURLEncoding.EncodeToString(sum[:12])
+
+	if s.jwks, err = jwkSet(s.kid, s.method.Alg(), key.Public()); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Sign issues an access token for user that expires after the access TTL.
+func (s *Signer) Sign(user models.User, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(s.cfg.AccessTTL)
+
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Issuer:    s.cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Email: user.Email,
+		Roles: user.Roles,
+	}
+	if s.cfg.Audience != "" {
+		c.Audience = jwt.ClaimStrings{s.cfg.Audience}
+	}
+
+	token := jwt.NewWithClaims(s.method, c)
+	token.Header["kid"] = s.kid
+
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// JWKS returns the JSON Web Key Set with the public key.
+func (s *Signer) JWKS() []byte {
+	return s.jwks
+}
+
+func loadKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// jwkSet encodes the public key as a JSON Web Key Set of RFC 7517.
+func jwkSet(kid, alg string, key crypto.PublicKey) ([]byte, error) {
+	enc := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	jwk := map[string]string{"kid": kid, "alg": alg, "use": "sig"}
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = enc(key.N.Bytes())
+		jwk["e"] = enc(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk["kty"] = "EC"
+		jwk["crv"] = "P-256"
+		jwk["x"] = enc(key.X.FillBytes(make([]byte, 32)))
+		jwk["y"] = enc(key.Y.FillBytes(make([]byte, 32)))
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return json.Marshal(map[string]any{"keys": []map[string]string{jwk}})
+}
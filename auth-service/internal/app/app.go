@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"auth-service/config"
+
+	httpservice "auth-service/internal/adapter/http/service"
+	"auth-service/internal/adapter/mailer"
+	postgresrepo "auth-service/internal/adapter/postgres"
+	"auth-service/internal/adapter/signer"
+	"auth-service/internal/usecase"
+	"auth-service/migrations"
+	"auth-service/pkg/health"
+	"auth-service/pkg/postgres"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const serviceName = "Auth"
+
+type App struct {
+	httpServer *httpservice.API
+	postgresDB *postgres.PostgreDB
+	health     *health.Health
+
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
+}
+
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	slog.Info("starting service", "service", serviceName)
+
+	slog.Info("connecting to postgres")
+	postgresDB, err := postgres.New(ctx, cfg.Postgres)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+
+	slog.Info("connection established")
+
+	if cfg.Postgres.MigrateOnStart {
+		if err := postgres.Migrate(postgresDB.Pool, migrations.FS); err != nil {
+			postgresDB.Pool.Close()
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+	}
+
+	// Exporting the statistics of the connection pool
+	prometheus.MustRegister(postgres.NewCollector(postgresDB.Pool))
+
+	// Signing key of the access tokens
+	tokenSigner, err := signer.New(cfg.Signer)
+	if err != nil {
+		postgresDB.Pool.Close()
+		return nil, fmt.Errorf("signer: %w", err)
+	}
+
+	app := &App{
+		postgresDB:      postgresDB,
+		health:          health.New(cfg.Health),
+		shutdownDelay:   cfg.Server.ShutdownDelay,
+		shutdownTimeout: cfg.Server.ShutdownTimeout,
+	}
+
+	// Readiness checks
+	app.health.Add("postgres", postgresDB.Check)
+	app.health.Add("migrations", postgresDB.CheckMigrations)
+
+	// UseCase
+	authUsecase, err := usecase.NewAuth(
+		postgresrepo.NewUserRepository(postgresDB.Pool),
+		postgresrepo.NewTokenRepository(postgresDB.Pool),
+		tokenSigner,
+		mailer.NewLog(cfg.Auth.ResetURL),
+		cfg.Auth,
+	)
+	if err != nil {
+		postgresDB.Pool.Close()
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+
+	// http service
+	app.httpServer = httpservice.New(cfg.Server, authUsecase, tokenSigner.JWKS(), app.health)
+
+	return app, nil
+}
+
+// Close drains the http server within the shutdown timeout, then closes the connections.
+func (a *App) Close() {
+	// Failing the readiness probe, so that no new requests are sent
+	a.health.Shutdown()
+	time.Sleep(a.shutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	// Closing http server, in-flight requests are finished first
+	err := a.httpServer.Stop(ctx)
+
+	// Closing postgres connection
+	a.postgresDB.Pool.Close()
+
+	if err != nil {
+		slog.Error("failed to shutdown service", "error", err)
+	}
+}
+
+func (a *App) Run() error {
+	errCh := make(chan error, 1)
+
+	// Running http server
+	a.httpServer.Run(errCh)
+
+	slog.Info("service started", "service", serviceName)
+
+	// Waiting signal
+	shutdownCh := make(chan os.Signal, 1)
+	signal.Notify(shutdownCh, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case errRun := <-errCh:
+		a.Close()
+		return errRun
+
+	case s := <-shutdownCh:
+		slog.Info("received signal, running graceful shutdown", "signal", s.String())
+
+		a.Close()
+		slog.Info("graceful shutdown completed")
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package models
+
+import "errors"
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrEmailTaken         = errors.New("a user with this email already exists")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+
+	// ErrInvalidToken is returned for refresh and reset tokens that are unknown, expired,
+	// revoked or used already.
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrTokenUsed is returned by the repositories for tokens that were used or revoked.
+	ErrTokenUsed = errors.New("token was used already")
+)
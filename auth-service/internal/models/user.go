@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Roles of the users. Registration gives the customer role, the others are granted in
+// the database.
+const (
+	RoleAdmin    = "admin"
+	RoleStaff    = "staff"
+	RoleCustomer = "customer"
+)
+
+type User struct {
+	ID           int64
+	Email        string
+	PasswordHash []byte
+	Roles        []string
+	CreatedAt    time.Time
+}
+
+// RefreshToken is a refresh token as it is stored, by the hash of its value.
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	Family    string // Shared by the tokens that stem from the same login
+	Hash      []byte
+	ExpiresAt time.Time
+}
+
+// ResetToken lets the user it was sent to set a new password once.
+type ResetToken struct {
+	Hash      []byte
+	UserID    int64
+	ExpiresAt time.Time
+}
+
+// TokenPair is what a login or a refresh gives the client.
+type TokenPair struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
@@ -0,0 +1,286 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"auth-service/config"
+	"auth-service/internal/models"
+	"auth-service/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var tracer = otel.Tracer("auth-service/internal/usecase")
+
+var (
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Number of login attempts, by outcome.",
+	}, []string{"outcome"})
+	refreshReuses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_refresh_token_reuses_total",
+		Help: "Number of used refresh tokens presented again, each revoked its family.",
+	})
+)
+
+type Auth struct {
+	users  UserRepository
+	tokens TokenRepository
+	signer Signer
+	resets ResetSender
+	cfg    config.Auth
+
+	// dummyHash is compared against when the email is unknown, so that a login takes as
+	// long whether the user exists or not.
+	dummyHash []byte
+}
+
+func NewAuth(users UserRepository, tokens TokenRepository, signer Signer, resets ResetSender, cfg config.Auth) (*Auth, error) {
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("not a password"), cfg.PasswordHashCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auth{
+		users:     users,
+		tokens:    tokens,
+		signer:    signer,
+		resets:    resets,
+		cfg:       cfg,
+		dummyHash: dummyHash,
+	}, nil
+}
+
+// Register creates a customer. The email is compared without regard to case.
+func (u *Auth) Register(ctx context.Context, email, password string) (models.User, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Register")
+	defer span.End()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), u.cfg.PasswordHashCost)
+	if err != nil {
+		return models.User{}, tracing.Error(span, err)
+	}
+
+	user := models.User{
+		Email:        normalizeEmail(email),
+		PasswordHash: hash,
+		Roles:        []string{models.RoleCustomer},
+	}
+	user.ID, err = u.users.Create(ctx, user)
+	if err != nil {
+		return models.User{}, tracing.Error(span, err)
+	}
+	span.SetAttributes(attribute.Int64("user.id", user.ID))
+
+	return u.users.GetByID(ctx, user.ID)
+}
+
+// Login checks the password and starts a new family of refresh tokens.
+func (u *Auth) Login(ctx context.Context, email, password string) (models.TokenPair, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Login")
+	defer span.End()
+
+	user, err := u.users.GetByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, models.ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(u.dummyHash, []byte(password))
+		logins.WithLabelValues("rejected").Inc()
+		return models.TokenPair{}, models.ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.TokenPair{}, tracing.Error(span, err)
+	}
+
+	if bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)) != nil {
+		logins.WithLabelValues("rejected").Inc()
+		return models.TokenPair{}, models.ErrInvalidCredentials
+	}
+	logins.WithLabelValues("accepted").Inc()
+	span.SetAttributes(attribute.Int64("user.id", user.ID))
+
+	pair, err := u.issue(ctx, user, randomHex(16))
+	if err != nil {
+		return models.TokenPair{}, tracing.Error(span, err)
+	}
+	return pair, nil
+}
+
+// Refresh uses up the refresh token and issues the next pair of its family. A token that
+// was used before revokes its family, since either the client or someone who stole the
+// token holds the newer one.
+func (u *Auth) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	ctx, span := tracer.Start(ctx, "Auth.Refresh")
+	defer span.End()
+
+	now := time.Now()
+
+	token, err := u.tokens.UseRefresh(ctx, hashToken(refreshToken), now)
+	switch {
+	case errors.Is(err, models.ErrTokenUsed):
+		refreshReuses.Inc()
+		if err := u.tokens.RevokeFamily(ctx, token.Family, now); err != nil {
+			return models.TokenPair{}, tracing.Error(span, err)
+		}
+		return models.TokenPair{}, models.ErrInvalidToken
+	case err != nil:
+		return models.TokenPair{}, tracing.Error(span, err)
+	case !now.Before(token.ExpiresAt):
+		return models.TokenPair{}, models.ErrInvalidToken
+	}
+	span.SetAttributes(attribute.Int64("user.id", token.UserID))
+
+	// The roles are read again, so that changes apply from the next refresh on
+	user, err := u.users.GetByID(ctx, token.UserID)
+	if err != nil {
+		return models.TokenPair{}, tracing.Error(span, err)
+	}
+
+	pair, err := u.issue(ctx, user, token.Family)
+	if err != nil {
+		return models.TokenPair{}, tracing.Error(span, err)
+	}
+	return pair, nil
+}
+
+// Logout revokes the family of the refresh token. Unknown tokens are ignored, so logging
+// out twice is not an error. Access tokens stay valid until they expire.
+func (u *Auth) Logout(ctx context.Context, refreshToken string) error {
+	ctx, span := tracer.Start(ctx, "Auth.Logout")
+	defer span.End()
+
+	now := time.Now()
+
+	token, err := u.tokens.UseRefresh(ctx, hashToken(refreshToken), now)
+	if errors.Is(err, models.ErrInvalidToken) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, models.ErrTokenUsed) {
+		return tracing.Error(span, err)
+	}
+
+	if err := u.tokens.RevokeFamily(ctx, token.Family, now); err != nil {
+		return tracing.Error(span, err)
+	}
+	return nil
+}
+
+// RequestReset sends a reset token to the user with email. It does not tell whether the
+// user exists, so that it cannot be used to find out who has an account.
+func (u *Auth) RequestReset(ctx context.Context, email string) error {
+	ctx, span := tracer.Start(ctx, "Auth.RequestReset")
+	defer span.End()
+
+	user, err := u.users.GetByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, models.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	value := randomToken()
+	token := models.ResetToken{
+		Hash:      hashToken(value),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(u.cfg.ResetTTL),
+	}
+	if err := u.tokens.CreateReset(ctx, token); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := u.resets.SendReset(ctx, user, value, token.ExpiresAt); err != nil {
+		return tracing.Error(span, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and logs the user out everywhere.
+func (u *Auth) ResetPassword(ctx context.Context, resetToken, password string) error {
+	ctx, span := tracer.Start(ctx, "Auth.ResetPassword")
+	defer span.End()
+
+	now := time.Now()
+
+	token, err := u.tokens.UseReset(ctx, hashToken(resetToken), now)
+	if errors.Is(err, models.ErrTokenUsed) || (err == nil && !now.Before(token.ExpiresAt)) {
+		return models.ErrInvalidToken
+	}
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), u.cfg.PasswordHashCost)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+	if err := u.users.SetPassword(ctx, token.UserID, hash); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := u.tokens.RevokeUser(ctx, token.UserID, now); err != nil {
+		return tracing.Error(span, err)
+	}
+	return nil
+}
+
+// issue signs an access token for user and stores a new refresh token in family.
+func (u *Auth) issue(ctx context.Context, user models.User, family string) (models.TokenPair, error) {
+	now := time.Now()
+
+	access, accessExpiresAt, err := u.signer.Sign(user, now)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	refresh := randomToken()
+	token := models.RefreshToken{
+		UserID:    user.ID,
+		Family:    family,
+		Hash:      hashToken(refresh),
+		ExpiresAt: now.Add(u.cfg.RefreshTTL),
+	}
+	if err := u.tokens.CreateRefresh(ctx, token); err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{
+		AccessToken:      access,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: token.ExpiresAt,
+	}, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// randomToken returns 256 random bits for a refresh or reset token.
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// hashToken is how tokens are stored, so that a copy of the database does not hold any
+// that can be used. The tokens are random, a plain hash is enough.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
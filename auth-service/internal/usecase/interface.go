@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"auth-service/internal/models"
+	"context"
+	"time"
+)
+
+type UserRepository interface {
+	// Create returns models.ErrEmailTaken if the email is in use.
+	Create(ctx context.Context, user models.User) (int64, error)
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	GetByID(ctx context.Context, id int64) (models.User, error)
+	SetPassword(ctx context.Context, id int64, hash []byte) error
+}
+
+type TokenRepository interface {
+	CreateRefresh(ctx context.Context, token models.RefreshToken) error
+	// UseRefresh marks the refresh token with hash as used and returns it. A token that
+	// was used or revoked before is returned with models.ErrTokenUsed, an unknown one
+	// gives models.ErrInvalidToken. Of concurrent calls for the same token only one
+	// succeeds.
+	UseRefresh(ctx context.Context, hash []byte, now time.Time) (models.RefreshToken, error)
+	RevokeFamily(ctx context.Context, family string, now time.Time) error
+	RevokeUser(ctx context.Context, userID int64, now time.Time) error
+
+	CreateReset(ctx context.Context, token models.ResetToken) error
+	// UseReset works like UseRefresh for reset tokens.
+	UseReset(ctx context.Context, hash []byte, now time.Time) (models.ResetToken, error)
+}
+
+// Signer issues the access tokens.
+type Signer interface {
+	Sign(user models.User, now time.Time) (token string, expiresAt time.Time, err error)
+}
+
+// ResetSender gets the reset token to the user, such as by email.
+type ResetSender interface {
+	SendReset(ctx context.Context, user models.User, token string, expiresAt time.Time) error
+}
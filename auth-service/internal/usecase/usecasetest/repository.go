@@ -0,0 +1,286 @@
+// Package usecasetest holds conformance tests for the repositories the use cases depend
+// on. Every implementation runs the same tests, so the in-memory repositories can stand
+// in for Postgres.
+package usecasetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"auth-service/internal/models"
+	"auth-service/internal/usecase"
+)
+
+// TestUserRepository runs the conformance tests against the repositories returned by
+// newRepo. Every test gets a new, empty repository.
+func TestUserRepository(t *testing.T, newRepo func(t *testing.T) usecase.UserRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo usecase.UserRepository)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"EmailTaken", testEmailTaken},
+		{"GetMissing", testGetMissing},
+		{"SetPassword", testSetPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+// TestTokenRepository runs the conformance tests against the repositories returned by
+// newRepos. The tokens belong to users of the user repository.
+func TestTokenRepository(t *testing.T, newRepos func(t *testing.T) (usecase.UserRepository, usecase.TokenRepository)) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, users usecase.UserRepository, tokens usecase.TokenRepository)
+	}{
+		{"UseRefreshOnce", testUseRefreshOnce},
+		{"UseRefreshUnknown", testUseRefreshUnknown},
+		{"UseRefreshConcurrent", testUseRefreshConcurrent},
+		{"RevokeFamily", testRevokeFamily},
+		{"RevokeUser", testRevokeUser},
+		{"UseResetOnce", testUseResetOnce},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, tokens := newRepos(t)
+			tt.test(t, users, tokens)
+		})
+	}
+}
+
+func testCreateAndGet(t *testing.T, repo usecase.UserRepository) {
+	ctx := context.Background()
+
+	id := createUser(t, repo, "alice@example.com")
+
+	byEmail, err := repo.GetByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	byID, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+
+	for _, user := range []models.User{byEmail, byID} {
+		if user.ID != id || user.Email != "alice@example.com" || string(user.PasswordHash) != "hash" {
+			t.Errorf("user = %+v", user)
+		}
+		if !slices.Equal(user.Roles, []string{models.RoleCustomer}) {
+			t.Errorf("roles = %v", user.Roles)
+		}
+		if user.CreatedAt.IsZero() {
+			t.Error("created_at is not set")
+		}
+	}
+}
+
+func testEmailTaken(t *testing.T, repo usecase.UserRepository) {
+	createUser(t, repo, "alice@example.com")
+
+	_, err := repo.Create(context.Background(), models.User{Email: "alice@example.com", PasswordHash: []byte("other")})
+	if !errors.Is(err, models.ErrEmailTaken) {
+		t.Errorf("Create = %v, want %v", err, models.ErrEmailTaken)
+	}
+}
+
+func testGetMissing(t *testing.T, repo usecase.UserRepository) {
+	ctx := context.Background()
+
+	if _, err := repo.GetByEmail(ctx, "nobody@example.com"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetByEmail = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if _, err := repo.GetByID(ctx, 12345); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetByID = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if err := repo.SetPassword(ctx, 12345, []byte("hash")); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("SetPassword = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func testSetPassword(t *testing.T, repo usecase.UserRepository) {
+	ctx := context.Background()
+
+	id := createUser(t, repo, "alice@example.com")
+	if err := repo.SetPassword(ctx, id, []byte("new hash")); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+
+	user, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if string(user.PasswordHash) != "new hash" {
+		t.Errorf("password hash = %q", user.PasswordHash)
+	}
+}
+
+func testUseRefreshOnce(t *testing.T, users usecase.UserRepository, tokens usecase.TokenRepository) {
+	ctx := context.Background()
+
+	userID := createUser(t, users, "alice@example.com")
+	createRefresh(t, tokens, userID, "family-1", "token-1")
+
+	token, err := tokens.UseRefresh(ctx, []byte("token-1"), time.Now())
+	if err != nil {
+		t.Fatalf("UseRefresh: %v", err)
+	}
+	if token.UserID != userID || token.Family != "family-1" || !bytes.Equal(token.Hash, []byte("token-1")) || token.ExpiresAt.IsZero() {
+		t.Errorf("token = %+v", token)
+	}
+
+	// A second use still tells the family, so that it can be revoked
+	token, err = tokens.UseRefresh(ctx, []byte("token-1"), time.Now())
+	if !errors.Is(err, models.ErrTokenUsed) {
+		t.Fatalf("second UseRefresh = %v, want %v", err, models.ErrTokenUsed)
+	}
+	if token.Family != "family-1" {
+		t.Errorf("family = %q", token.Family)
+	}
+}
+
+func testUseRefreshUnknown(t *testing.T, users usecase.UserRepository, tokens usecase.TokenRepository) {
+	_, err := tokens.UseRefresh(context.Background(), []byte("unknown"), time.Now())
+	if !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("UseRefresh = %v, want %v", err, models.ErrInvalidToken)
+	}
+}
+
+func testUseRefreshConcurrent(t *testing.T, users usecase.UserRepository, tokens usecase.TokenRepository) {
+	userID := createUser(t, users, "alice@example.com")
+	createRefresh(t, tokens, userID, "family-1", "token-1")
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := tokens.UseRefresh(context.Background(), []byte("token-1"), time.Now())
+			switch {
+			case err == nil:
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			case !errors.Is(err, models.ErrTokenUsed):
+				t.Errorf("UseRefresh: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("%d concurrent uses succeeded, want 1", succeeded)
+	}
+}
+
+func testRevokeFamily(t *testing.T, users usecase.UserRepository, tokens usecase.TokenRepository) {
+	ctx := context.Background()
+
+	userID := createUser(t, users, "alice@example.com")
+	createRefresh(t, tokens, userID, "family-1", "token-1")
+	createRefresh(t, tokens, userID, "family-2", "token-2")
+
+	if err := tokens.RevokeFamily(ctx, "family-1", time.Now()); err != nil {
+		t.Fatalf("RevokeFamily: %v", err)
+	}
+
+	if _, err := tokens.UseRefresh(ctx, []byte("token-1"), time.Now()); !errors.Is(err, models.ErrTokenUsed) {
+		t.Errorf("UseRefresh of a revoked token = %v, want %v", err, models.ErrTokenUsed)
+	}
+	if _, err := tokens.UseRefresh(ctx, []byte("token-2"), time.Now()); err != nil {
+		t.Errorf("UseRefresh of another family = %v", err)
+	}
+}
+
+func testRevokeUser(t *testing.T, users usecase.UserRepository, tokens usecase.TokenRepository) {
+	ctx := context.Background()
+
+	alice := createUser(t, users, "alice@example.com")
+	bob := createUser(t, users, "bob@example.com")
+	createRefresh(t, tokens, alice, "family-1", "token-1")
+	createRefresh(t, tokens, alice, "family-2", "token-2")
+	createRefresh(t, tokens, bob, "family-3", "token-3")
+
+	if err := tokens.RevokeUser(ctx, alice, time.Now()); err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+
+	for _, token := range []string{"token-1", "token-2"} {
+		if _, err := tokens.UseRefresh(ctx, []byte(token), time.Now()); !errors.Is(err, models.ErrTokenUsed) {
+			t.Errorf("UseRefresh of %s = %v, want %v", token, err, models.ErrTokenUsed)
+		}
+	}
+	if _, err := tokens.UseRefresh(ctx, []byte("token-3"), time.Now()); err != nil {
+		t.Errorf("UseRefresh of another user = %v", err)
+	}
+}
+
+func testUseResetOnce(t *testing.T, users usecase.UserRepository, tokens usecase.TokenRepository) {
+	ctx := context.Background()
+
+	userID := createUser(t, users, "alice@example.com")
+	expiresAt := time.Now().Add(time.Hour)
+	if err := tokens.CreateReset(ctx, models.ResetToken{Hash: []byte("reset-1"), UserID: userID, ExpiresAt: expiresAt}); err != nil {
+		t.Fatalf("CreateReset: %v", err)
+	}
+
+	token, err := tokens.UseReset(ctx, []byte("reset-1"), time.Now())
+	if err != nil {
+		t.Fatalf("UseReset: %v", err)
+	}
+	if token.UserID != userID || token.ExpiresAt.Sub(expiresAt).Abs() > time.Second {
+		t.Errorf("token = %+v", token)
+	}
+
+	if _, err := tokens.UseReset(ctx, []byte("reset-1"), time.Now()); !errors.Is(err, models.ErrTokenUsed) {
+		t.Errorf("second UseReset = %v, want %v", err, models.ErrTokenUsed)
+	}
+	if _, err := tokens.UseReset(ctx, []byte("unknown"), time.Now()); !errors.Is(err, models.ErrInvalidToken) {
+		t.Errorf("UseReset of an unknown token = %v, want %v", err, models.ErrInvalidToken)
+	}
+}
+
+func createUser(t *testing.T, repo usecase.UserRepository, email string) int64 {
+	t.Helper()
+
+	id, err := repo.Create(context.Background(), models.User{
+		Email:        email,
+		PasswordHash: []byte("hash"),
+		Roles:        []string{models.RoleCustomer},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return id
+}
+
+// createRefresh stores a refresh token with value as its hash.
+func createRefresh(t *testing.T, repo usecase.TokenRepository, userID int64, family, value string) {
+	t.Helper()
+
+	err := repo.CreateRefresh(context.Background(), models.RefreshToken{
+		UserID:    userID,
+		Family:    family,
+		Hash:      []byte(value),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateRefresh: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE, -- Stored in lower case
+    password_hash BYTEA NOT NULL,
+    roles TEXT[] NOT NULL DEFAULT '{customer}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- Refresh tokens are kept as SHA-256 hashes. Every login starts a family, each refresh
+-- uses up its token and adds the next one to the family. A used token that comes back
+-- means it was stolen, the whole family is revoked then.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id bigserial PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family TEXT NOT NULL,
+    token_hash BYTEA NOT NULL UNIQUE,
+    expires_at timestamp with time zone NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+    used_at timestamp with time zone,
+    revoked_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS reset_tokens (
+    token_hash BYTEA PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at timestamp with time zone NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+    used_at timestamp with time zone
+);
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNoMigrations is returned by MigrationVersion for a database that was never migrated.
+var ErrNoMigrations = errors.New("no migrations applied")
+
+// MigrationVersion returns the version of the last migration applied by golang-migrate,
+// and whether it failed halfway and left the schema dirty.
+func MigrationVersion(ctx context.Context, pool *pgxpool.Pool) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := pool.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows), errors.As(err, &pgErr) && pgErr.Code == "42P01": // undefined_table
+		return 0, false, ErrNoMigrations
+	case err != nil:
+		return 0, false, err
+	}
+
+	return version, dirty, nil
+}
+
+// Check is a readiness check: it pings the database and reports the connections of the
+// pool.
+func (db *PostgreDB) Check(ctx context.Context) (map[string]any, error) {
+	stat := db.Pool.Stat()
+	details := map[string]any{
+		"total_conns":    stat.TotalConns(),
+		"acquired_conns": stat.AcquiredConns(),
+		"max_conns":      stat.MaxConns(),
+	}
+
+	return details, db.Pool.Ping(ctx)
+}
+
+// CheckMigrations is a readiness check: it reports the migration version of the schema,
+// and fails for a schema that was never migrated or is dirty.
+func (db *PostgreDB) CheckMigrations(ctx context.Context) (map[string]any, error) {
+	version, dirty, err := MigrationVersion(ctx, db.Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]any{"version": version, "dirty": dirty}
+	if dirty {
+		return details, fmt.Errorf("migration %d failed halfway, the schema is dirty", version)
+	}
+
+	return details, nil
+}
@@ -0,0 +1,70 @@
+// Package requestid gives every request an ID, so that it can be followed through the
+// gateway and the services. The ID travels in the X-Request-ID header.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+const Header = "X-Request-ID"
+
+// maxLength bounds the IDs taken from clients, they end up in logs and responses.
+const maxLength = 128
+
+type ctxKey struct{}
+
+// Middleware takes the ID from the X-Request-ID header of the request, or generates one if
+// there is none, and stores it in the request context. The response carries it back.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := OrNew(c.GetHeader(Header))
+
+		c.Request.Header.Set(Header, id)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), id))
+		c.Header(Header, id)
+
+		c.Next()
+	}
+}
+
+// OrNew returns id if it can be used as a request ID, and a new ID otherwise.
+func OrNew(id string) string {
+	if !valid(id) {
+		return New()
+	}
+	return id
+}
+
+// New generates a random ID.
+func New() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the ID of the request ctx belongs to, or "" if it has none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// valid accepts IDs of printable ASCII characters only.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+// Package testserver runs auth-service on an httptest server with in-memory storage.
+// It lets tests outside this module, such as the end-to-end tests, use the real HTTP
+// routes without Postgres.
+package testserver
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"auth-service/config"
+	httpservice "auth-service/internal/adapter/http/service"
+	"auth-service/internal/adapter/memory"
+	"auth-service/internal/adapter/signer"
+	"auth-service/internal/models"
+	"auth-service/internal/usecase"
+	"auth-service/pkg/health"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Issuer and Audience are the claims of the access tokens the server issues.
+const (
+	Issuer   = "auth-service"
+	Audience = "go-ecommerce"
+)
+
+type Server struct {
+	*httptest.Server
+
+	users  *memory.UserRepository
+	mailer *mailer
+}
+
+// New starts a server with no users and a generated signing key. It is closed when the
+// test ends.
+func New(t testing.TB) *Server {
+	t.Helper()
+
+	cfg := config.Server{
+		HTTPServer: config.HTTPServer{Mode: "test"},
+	}
+
+	tokenSigner, err := signer.New(config.Signer{Issuer: Issuer, Audience: Audience, AccessTTL: 15 * time.Minute})
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+
+	s := &Server{
+		users:  memory.NewUserRepository(),
+		mailer: &mailer{tokens: make(map[string]string)},
+	}
+
+	authUsecase, err := usecase.NewAuth(s.users, memory.NewTokenRepository(), tokenSigner, s.mailer, config.Auth{
+		RefreshTTL:       time.Hour,
+		ResetTTL:         time.Hour,
+		PasswordHashCost: bcrypt.MinCost,
+	})
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+
+	api := httpservice.New(cfg, authUsecase, tokenSigner.JWKS(), health.New(health.Config{}))
+
+	s.Server = httptest.NewServer(api.Handler())
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// SetRoles replaces the roles of the user with id, for making staff and admins.
+func (s *Server) SetRoles(id int64, roles ...string) {
+	s.users.SetRoles(id, roles...)
+}
+
+// ResetToken returns the last reset token sent to email.
+func (s *Server) ResetToken(email string) string {
+	s.mailer.mu.Lock()
+	defer s.mailer.mu.Unlock()
+
+	return s.mailer.tokens[email]
+}
+
+// mailer keeps the reset tokens instead of sending them.
+type mailer struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func (m *mailer) SendReset(ctx context.Context, user models.User, token string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[user.Email] = token
+	return nil
+}
@@ -0,0 +1,250 @@
+package e2e
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/authz"
+	"api-gateway/pkg/problem"
+	"api-gateway/proxy"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const authSecret = "end-to-end-secret"
+
+// authRoutes protects /private with the auth middleware and leaves /public open.
+func authRoutes(url string) string {
+	return `
+upstreams:
+  echo:
+    url: ` + url + `
+routes:
+  - name: private
+    prefix: /private
+    upstream: echo
+    middleware: [auth]
+  - name: public
+    prefix: /public
+    upstream: echo
+`
+}
+
+// token signs claims with method and key, valid for an hour unless claims say otherwise.
+func token(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	all := jwt.MapClaims{
+		"sub": "user-1",
+		"iss": "e2e",
+		"aud": "go-ecommerce",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(all, k)
+			continue
+		}
+		all[k] = v
+	}
+
+	tok := jwt.NewWithClaims(method, all)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	signed, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+func TestAuth(t *testing.T) {
+	h := newHarness(t)
+	e := newEcho(t, "a")
+
+	routeGateway(t, h, config.Config{Auth: auth.Config{
+		Secret:   authSecret,
+		Issuer:   "e2e",
+		Audience: "go-ecommerce",
+	}}, authRoutes(e.URL))
+
+	hs := func(claims jwt.MapClaims) string {
+		return token(t, jwt.SigningMethodHS256, []byte(authSecret), "", claims)
+	}
+
+	// A valid token reaches the upstream with its subject and roles, whatever the client
+	// claimed itself
+	header := bearer(hs(jwt.MapClaims{"roles": []string{"customer", "admin"}}))
+	header.Set(authz.HeaderSubject, "someone-else")
+	var got echoed
+	h.mustDo(http.StatusOK, http.MethodGet, "/private/orders", nil, header, &got)
+	if sub := got.Header.Values(authz.HeaderSubject); len(sub) != 1 || sub[0] != "user-1" {
+		t.Errorf("%s = %v, want [user-1]", authz.HeaderSubject, sub)
+	}
+	if roles := got.Header.Get(authz.HeaderRoles); roles != "customer,admin" {
+		t.Errorf("%s = %q", authz.HeaderRoles, roles)
+	}
+
+	// Open routes do not pass on what clients claim either
+	got = echoed{}
+	h.mustDo(http.StatusOK, http.MethodGet, "/public", nil, http.Header{authz.HeaderSubject: {"admin"}, authz.HeaderRoles: {"admin"}}, &got)
+	if got.Header.Get(authz.HeaderSubject) != "" || got.Header.Get(authz.HeaderRoles) != "" {
+		t.Errorf("spoofed identity reached the upstream: %v", got.Header)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		reason string
+	}{
+		{"no token", nil, ""},
+		{"not a bearer token", http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}}, ""},
+		{"malformed", bearer("not.a.token"), "invalid_token"},
+		{"wrong secret", bearer(token(t, jwt.SigningMethodHS256, []byte("guessed"), "", nil)), "invalid_token"},
+		{"expired", bearer(hs(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})), "invalid_token"},
+		{"no expiry", bearer(hs(jwt.MapClaims{"exp": nil})), "invalid_token"},
+		{"wrong audience", bearer(hs(jwt.MapClaims{"aud": "someone-else"})), "invalid_token"},
+		{"wrong issuer", bearer(hs(jwt.MapClaims{"iss": "someone-else"})), "invalid_token"},
+		{"no subject", bearer(hs(jwt.MapClaims{"sub": nil})), "invalid_token"},
+		{"unexpected algorithm", bearer(token(t, jwt.SigningMethodRS256, otherKey, "", nil)), "invalid_token"},
+		{"none algorithm", bearer(token(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", nil)), "invalid_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, p := h.raw(http.MethodGet, "/private/orders", tt.header)
+			if resp.StatusCode != http.StatusUnauthorized || p.Code != problem.CodeUnauthorized {
+				t.Fatalf("status = %d, code = %q", resp.StatusCode, p.Code)
+			}
+			if challenge := resp.Header.Get("WWW-Authenticate"); !strings.HasPrefix(challenge, "Bearer") || !strings.Contains(challenge, tt.reason) {
+				t.Errorf("WWW-Authenticate = %q", challenge)
+			}
+		})
+	}
+}
+
+func TestAuthWithJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := jwkSet(map[string]crypto.PublicKey{"rsa-1": &rsaKey.PublicKey, "ec-1": &ecKey.PublicKey})
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, jwks, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwks)
+	}))
+	t.Cleanup(server.Close)
+
+	for name, cfg := range map[string]auth.Config{
+		"file": {JWKSFile: file},
+		"url":  {JWKSURL: server.URL},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := newHarness(t)
+			e := newEcho(t, "a")
+			routeGateway(t, h, config.Config{Auth: cfg}, authRoutes(e.URL))
+
+			h.mustDo(http.StatusOK, http.MethodGet, "/private", nil, bearer(token(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", nil)), nil)
+			h.mustDo(http.StatusOK, http.MethodGet, "/private", nil, bearer(token(t, jwt.SigningMethodES256, ecKey, "ec-1", nil)), nil)
+
+			// The key has to be the one named by the token
+			h.mustDo(http.StatusUnauthorized, http.MethodGet, "/private", nil, bearer(token(t, jwt.SigningMethodRS256, rsaKey, "rsa-2", nil)), nil)
+			h.mustDo(http.StatusUnauthorized, http.MethodGet, "/private", nil, bearer(token(t, jwt.SigningMethodES256, ecKey, "rsa-1", nil)), nil)
+			// A secret is not a key for public key algorithms
+			h.mustDo(http.StatusUnauthorized, http.MethodGet, "/private", nil, bearer(token(t, jwt.SigningMethodHS256, []byte(authSecret), "", nil)), nil)
+		})
+	}
+}
+
+func TestAuthRoutesNeedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeRoutes(t, path, authRoutes("http://localhost:8081"))
+
+	_, err := proxy.New(&config.Config{Routes: config.Routes{File: path}})
+	if err == nil || !strings.Contains(err.Error(), "middleware auth") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+// raw sends a request through the gateway and returns the response with the problem in
+// its body.
+func (h *harness) raw(method, path string, header http.Header) (*http.Response, problem.Problem) {
+	h.t.Helper()
+
+	req, err := http.NewRequest(method, h.gateway.URL+path, nil)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var p problem.Problem
+	json.NewDecoder(resp.Body).Decode(&p)
+
+	return resp, p
+}
+
+// jwkSet encodes keys as a JSON Web Key Set.
+func jwkSet(keys map[string]crypto.PublicKey) []byte {
+	enc := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "RSA", "kid": kid, "alg": "RS256", "use": "sig",
+				"n": enc(key.N.Bytes()), "e": enc(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "EC", "kid": kid, "alg": "ES256", "crv": "P-256",
+				"x": enc(key.X.FillBytes(make([]byte, 32))), "y": enc(key.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+	}
+
+	data, _ := json.Marshal(set)
+	return data
+}
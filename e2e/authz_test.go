@@ -0,0 +1,215 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"api-gateway/config"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/authz"
+	"api-gateway/pkg/problem"
+	inventorytest "inventory-service/testserver"
+	ordertest "order-service/testserver"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// authzRoutes lets anyone identified place orders and only staff change orders and
+// products.
+const authzRoutes = `
+upstreams:
+  order-service:
+    url: ${E2E_ORDER_SERVICE}
+  inventory-service:
+    url: ${E2E_INVENTORY_SERVICE}
+routes:
+  - name: order-status
+    path: /orders/{id}
+    methods: [PATCH]
+    upstream: order-service
+    middleware: [auth]
+    roles: [admin, staff]
+  - name: orders
+    prefix: /orders
+    upstream: order-service
+    middleware: [auth]
+  - name: products
+    prefix: /products
+    methods: [GET]
+    upstream: inventory-service
+  - name: product-changes
+    prefix: /products
+    upstream: inventory-service
+    middleware: [auth]
+    roles: [admin, staff]
+`
+
+func TestAuthz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The services check the roles again behind the gateway
+	h := &harness{t: t, inventory: inventorytest.New(t, inventorytest.EnforceRoles())}
+	h.orders = ordertest.New(t, h.inventory.URL, ordertest.EnforceRoles())
+	routeGateway(t, h, config.Config{Auth: auth.Config{Secret: authSecret}}, authzRoutes)
+
+	as := func(subject string, roles ...string) http.Header {
+		return bearer(token(t, jwt.SigningMethodHS256, []byte(authSecret), "", jwt.MapClaims{"sub": subject, "roles": roles}))
+	}
+	admin, alice, bob := as("admin-1", authz.RoleAdmin), as("alice", authz.RoleCustomer), as("bob", authz.RoleCustomer)
+
+	newProduct := map[string]any{"name": "keyboard", "description": "a keyboard", "price": 40, "available": 10}
+
+	// Customers cannot change the catalog
+	var p problem.Problem
+	h.mustDo(http.StatusForbidden, http.MethodPost, "/products/", newProduct, alice, &p)
+	if p.Code != problem.CodeForbidden || !strings.Contains(p.Detail, "admin, staff") {
+		t.Errorf("problem = %+v", p)
+	}
+
+	var created struct {
+		Inventory product `json:"inventory"`
+	}
+	h.mustDo(http.StatusCreated, http.MethodPost, "/products/", newProduct, admin, &created)
+	keyboard := created.Inventory.ID
+
+	place := func(header http.Header) placedOrder {
+		var resp struct {
+			Order placedOrder `json:"order"`
+		}
+		h.mustDo(http.StatusOK, http.MethodPost, "/orders/", map[string]any{
+			"customer_name": "someone",
+			"items":         []orderItem{{ProductID: keyboard, Quantity: 1}},
+		}, header, &resp)
+		return resp.Order
+	}
+
+	// order-service takes the stock as a service, not as the customer
+	aliceOrder, bobOrder := place(alice), place(bob)
+	if aliceOrder.Items[0].Status != "accepted" {
+		t.Fatalf("line of the order = %+v, want accepted", aliceOrder.Items[0])
+	}
+
+	list := func(header http.Header) []order {
+		var resp struct {
+			Orders []order `json:"orders"`
+		}
+		h.mustDo(http.StatusOK, http.MethodGet, "/orders/", nil, header, &resp)
+		return resp.Orders
+	}
+	if orders := list(alice); len(orders) != 1 || orders[0].OrderID != aliceOrder.OrderID || orders[0].CustomerID != "alice" {
+		t.Errorf("orders of alice = %+v", orders)
+	}
+	if orders := list(admin); len(orders) != 2 {
+		t.Errorf("admin sees %d orders, want 2", len(orders))
+	}
+
+	// Customers only read their own orders
+	path := fmt.Sprintf("/orders/%d", aliceOrder.OrderID)
+	h.mustDo(http.StatusOK, http.MethodGet, path, nil, alice, nil)
+	p = problem.Problem{}
+	h.mustDo(http.StatusForbidden, http.MethodGet, path, nil, bob, &p)
+	if p.Code != problem.CodeForbidden || !strings.Contains(p.Detail, "another customer") {
+		t.Errorf("problem = %+v", p)
+	}
+
+	// Only staff change the status
+	status := map[string]any{"status": "completed"}
+	h.mustDo(http.StatusForbidden, http.MethodPatch, fmt.Sprintf("/orders/%d", bobOrder.OrderID), status, bob, nil)
+	h.mustDo(http.StatusOK, http.MethodPatch, fmt.Sprintf("/orders/%d", bobOrder.OrderID), status, admin, nil)
+
+	// The services do not rely on the gateway alone
+	direct := func(method, url, body string, header http.Header) int {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = header
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	mouse := `{"name":"mouse","description":"a mouse","price":10,"available":1}`
+	if code := direct(http.MethodPost, h.inventory.URL+"/products/", mouse, http.Header{}); code != http.StatusUnauthorized {
+		t.Errorf("anonymous product creation = %d, want 401", code)
+	}
+	customer := http.Header{authz.HeaderSubject: {"alice"}, authz.HeaderRoles: {authz.RoleCustomer}}
+	if code := direct(http.MethodPost, h.inventory.URL+"/products/", mouse, customer); code != http.StatusForbidden {
+		t.Errorf("product creation by a customer = %d, want 403", code)
+	}
+	other := http.Header{authz.HeaderSubject: {"bob"}, authz.HeaderRoles: {authz.RoleCustomer}}
+	if code := direct(http.MethodGet, h.orders.URL+path, "", other); code != http.StatusForbidden {
+		t.Errorf("order of another customer = %d, want 403", code)
+	}
+}
+
+// Without AUTHZ_ENFORCE the roles are not checked, but customers still only reach their own
+// orders.
+func TestAuthzByDefault(t *testing.T) {
+	h := newHarness(t)
+
+	as := func(subject string) http.Header {
+		return bearer(token(t, jwt.SigningMethodHS256, []byte(authSecret), "", jwt.MapClaims{"sub": subject, "roles": []string{authz.RoleCustomer}}))
+	}
+	alice, bob := as("alice"), as("bob")
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+	place := func(header http.Header) placedOrder {
+		var resp struct {
+			Order placedOrder `json:"order"`
+		}
+		h.mustDo(http.StatusOK, http.MethodPost, "/orders/", map[string]any{
+			"customer_name": "someone",
+			"items":         []orderItem{{ProductID: keyboard, Quantity: 1}},
+		}, header, &resp)
+		return resp.Order
+	}
+	aliceOrder, bobOrder := place(alice), place(bob)
+
+	var list struct {
+		Orders []order `json:"orders"`
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, "/orders/", nil, bob, &list)
+	if len(list.Orders) != 1 || list.Orders[0].OrderID != bobOrder.OrderID {
+		t.Errorf("orders of bob = %+v", list.Orders)
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, "/orders/?customer_id=alice", nil, bob, &list)
+	if len(list.Orders) != 0 {
+		t.Errorf("bob lists the orders of alice: %+v", list.Orders)
+	}
+
+	var p problem.Problem
+	h.mustDo(http.StatusForbidden, http.MethodGet, fmt.Sprintf("/orders/%d", aliceOrder.OrderID), nil, bob, &p)
+	if p.Code != problem.CodeForbidden {
+		t.Errorf("problem = %+v", p)
+	}
+	h.mustDo(http.StatusUnauthorized, http.MethodGet, "/orders/", nil, nil, nil)
+
+	// order-service answers anonymous callers itself
+	resp, err := http.Get(h.orders.URL + "/orders/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous list of order-service = %d, want 401", resp.StatusCode)
+	}
+
+	// The same holds over GraphQL
+	var gql struct {
+		Orders struct {
+			Items []graphqlOrder `json:"items"`
+		} `json:"orders"`
+		Alice *struct{} `json:"alice"`
+	}
+	h.mustGraphQL(bob, `{ orders { items { id customer { id } } } alice: customer(id: "alice") { id } }`, nil, &gql)
+	if len(gql.Orders.Items) != 1 || gql.Orders.Items[0].ID != fmt.Sprint(bobOrder.OrderID) || gql.Alice != nil {
+		t.Errorf("bob over GraphQL = %+v", gql)
+	}
+}
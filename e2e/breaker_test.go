@@ -0,0 +1,115 @@
+package e2e
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"api-gateway/config"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/authz"
+	"api-gateway/pkg/problem"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// flaky is a service that answers 503 to the next failing requests, or to every request
+// while down is set.
+type flaky struct {
+	*httptest.Server
+	failing atomic.Int64
+	down    atomic.Bool
+	hits    atomic.Int64
+}
+
+func newFlaky(t *testing.T) *flaky {
+	t.Helper()
+
+	f := &flaky{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.hits.Add(1)
+		if f.down.Load() || f.failing.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+func TestBreakerAndRetries(t *testing.T) {
+	h := newHarness(t)
+	f := newFlaky(t)
+
+	routeGateway(t, h, config.Config{Auth: auth.Config{Secret: authSecret}}, `
+upstreams:
+  flaky:
+    url: `+f.URL+`
+    breaker: {failures: 3, cooldown: 200ms}
+    retry: {attempts: 2, base_delay: 1ms, max_delay: 5ms}
+routes:
+  - name: flaky
+    prefix: /flaky
+    upstream: flaky
+`)
+
+	// A read that failed once is retried
+	f.failing.Store(1)
+	h.mustDo(http.StatusOK, http.MethodGet, "/flaky", nil, nil, nil)
+	if n := f.hits.Load(); n != 2 {
+		t.Errorf("hits = %d, want 2", n)
+	}
+
+	// A write is not, it might have been done
+	f.failing.Store(1)
+	h.mustDo(http.StatusServiceUnavailable, http.MethodPost, "/flaky", map[string]any{}, nil, nil)
+	h.mustDo(http.StatusOK, http.MethodPost, "/flaky", map[string]any{}, nil, nil)
+
+	// Three failures in a row open the breaker, which then answers without asking the
+	// service
+	f.down.Store(true)
+	h.mustDo(http.StatusServiceUnavailable, http.MethodGet, "/flaky", nil, nil, nil)
+	hits := f.hits.Load()
+
+	resp, p := h.raw(http.MethodGet, "/flaky", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable || p.Code != problem.CodeServiceUnavailable {
+		t.Fatalf("status = %d, code = %q", resp.StatusCode, p.Code)
+	}
+	if resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("Retry-After = %q, want 1", resp.Header.Get("Retry-After"))
+	}
+	if n := f.hits.Load(); n != hits {
+		t.Errorf("the open breaker let %d requests through", n-hits)
+	}
+
+	var breakers struct {
+		Upstreams map[string]struct {
+			State    string `json:"state"`
+			Failures int    `json:"failures"`
+		} `json:"upstreams"`
+	}
+	as := func(role string) http.Header {
+		return bearer(token(t, jwt.SigningMethodHS256, []byte(authSecret), "", jwt.MapClaims{"sub": "someone", "roles": []string{role}}))
+	}
+	admin := as(authz.RoleAdmin)
+
+	// Only admins see the breakers
+	h.mustDo(http.StatusUnauthorized, http.MethodGet, "/admin/breakers", nil, nil, nil)
+	h.mustDo(http.StatusForbidden, http.MethodGet, "/admin/breakers", nil, as(authz.RoleCustomer), nil)
+
+	h.mustDo(http.StatusOK, http.MethodGet, "/admin/breakers", nil, admin, &breakers)
+	if b := breakers.Upstreams["flaky"]; b.State != "open" || b.Failures != 3 {
+		t.Errorf("breaker = %+v, want open after 3 failures", b)
+	}
+
+	// Once the cooldown passed a probe closes it again
+	f.down.Store(false)
+	waitFor(t, func() bool {
+		return h.do(http.MethodGet, "/flaky", nil, nil, nil) == http.StatusOK
+	})
+	h.mustDo(http.StatusOK, http.MethodGet, "/admin/breakers", nil, admin, &breakers)
+	if b := breakers.Upstreams["flaky"]; b.State != "closed" {
+		t.Errorf("breaker = %+v, want closed", b)
+	}
+}
@@ -0,0 +1,218 @@
+package e2e
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/httpcache"
+)
+
+// cachedRoutes caches the products for an hour and drops them when a product or an order
+// changes.
+const cachedRoutes = `
+upstreams:
+  order-service:
+    url: ${E2E_ORDER_SERVICE}
+  inventory-service:
+    url: ${E2E_INVENTORY_SERVICE}
+routes:
+  - name: orders
+    prefix: /orders
+    upstream: order-service
+    middleware: [auth, cache]
+    cache:
+      invalidates: [products]
+  - name: products
+    prefix: /products
+    methods: [GET, HEAD]
+    upstream: inventory-service
+    middleware: [cache]
+    cache:
+      ttl: 1h
+  - name: product-changes
+    prefix: /products
+    upstream: inventory-service
+    middleware: [cache]
+    cache:
+      invalidates: [products]
+`
+
+var cacheConfig = config.Config{
+	Auth:  auth.Config{Secret: authSecret},
+	Cache: httpcache.Config{MaxBytes: 1 << 20, MaxEntryBytes: 64 << 10},
+}
+
+// get sends a GET through the gateway and returns the response with its body.
+func get(t *testing.T, h *harness, path string, header http.Header) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, h.gateway.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestCacheServesProductsUntilTheyChange(t *testing.T) {
+	h := newHarness(t)
+	routeGateway(t, h, cacheConfig, cachedRoutes)
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+	path := fmt.Sprintf("/products/%d", keyboard)
+
+	resp, first := get(t, h, path, nil)
+	if v := resp.Header.Get("X-Cache"); v != "MISS" {
+		t.Errorf("first read: X-Cache = %q, want MISS", v)
+	}
+	resp, second := get(t, h, path, nil)
+	if v := resp.Header.Get("X-Cache"); v != "HIT" || second != first {
+		t.Errorf("second read: X-Cache = %q, body %s, want HIT and %s", v, second, first)
+	}
+	if resp.Header.Get("Age") == "" {
+		t.Error("a cached response has no Age")
+	}
+
+	// The request ID belongs to the request, not to the cached response
+	resp, _ = get(t, h, path, http.Header{"X-Request-ID": {"cached-read"}})
+	if v := resp.Header.Get("X-Request-ID"); v != "cached-read" {
+		t.Errorf("X-Request-ID = %q, want cached-read", v)
+	}
+
+	// Callers with credentials get responses of their own
+	resp, _ = get(t, h, path, bearer("token"))
+	if v := resp.Header.Get("X-Cache"); v != "BYPASS" {
+		t.Errorf("read with a token: X-Cache = %q, want BYPASS", v)
+	}
+
+	// A change through the gateway drops the cached product, so does an order of it
+	h.mustDo(http.StatusOK, http.MethodPatch, path, map[string]any{"price": 45}, nil, nil)
+	if got := h.product(keyboard); got.Price != 45 {
+		t.Errorf("price after the change = %v, want 45", got.Price)
+	}
+	h.placeOrder("alice", orderItem{keyboard, 1})
+	if got := h.product(keyboard); got.Available != 9 {
+		t.Errorf("available after the order = %d, want 9", got.Available)
+	}
+}
+
+// origin is a service that counts its requests and answers each after delay.
+type origin struct {
+	*httptest.Server
+	hits  atomic.Int64
+	delay time.Duration
+}
+
+func newOrigin(t *testing.T, delay time.Duration) *origin {
+	t.Helper()
+
+	o := &origin{delay: delay}
+	o.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := o.hits.Add(1)
+		time.Sleep(o.delay)
+
+		switch r.URL.Path {
+		case "/items/private":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/items/tagged":
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "no-cache")
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		fmt.Fprintf(w, "%s #%d", r.URL.Path, n)
+	}))
+	t.Cleanup(o.Close)
+
+	return o
+}
+
+func TestCacheHonorsTheUpstream(t *testing.T) {
+	h := newHarness(t)
+	o := newOrigin(t, 100*time.Millisecond)
+
+	routeGateway(t, h, cacheConfig, `
+upstreams:
+  origin:
+    url: `+o.URL+`
+routes:
+  - name: items
+    prefix: /items
+    upstream: origin
+    middleware: [cache]
+    cache:
+      ttl: 1h
+`)
+
+	// Concurrent misses make one request
+	var wg sync.WaitGroup
+	bodies := make([]string, 8)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, bodies[i] = get(t, h, "/items/1", nil)
+		}()
+	}
+	wg.Wait()
+	if n := o.hits.Load(); n != 1 {
+		t.Errorf("upstream requests = %d, want 1", n)
+	}
+	for _, body := range bodies {
+		if body != bodies[0] {
+			t.Errorf("bodies differ: %q and %q", body, bodies[0])
+		}
+	}
+
+	// The query is one key in any order
+	get(t, h, "/items?a=1&b=2", nil)
+	if resp, _ := get(t, h, "/items?b=2&a=1", nil); resp.Header.Get("X-Cache") != "HIT" {
+		t.Errorf("reordered query: X-Cache = %q, want HIT", resp.Header.Get("X-Cache"))
+	}
+
+	// no-store is never kept
+	get(t, h, "/items/private", nil)
+	if resp, _ := get(t, h, "/items/private", nil); resp.Header.Get("X-Cache") != "MISS" {
+		t.Errorf("no-store: X-Cache = %q, want MISS", resp.Header.Get("X-Cache"))
+	}
+
+	// no-cache with an ETag is kept, and confirmed with the upstream before every use
+	_, first := get(t, h, "/items/tagged", nil)
+	hits := o.hits.Load()
+	resp, second := get(t, h, "/items/tagged", nil)
+	if resp.Header.Get("X-Cache") != "REVALIDATED" || second != first || resp.Header.Get("ETag") != `"v1"` {
+		t.Errorf("revalidated: X-Cache = %q, ETag %q, body %q, want REVALIDATED, \"v1\" and %q", resp.Header.Get("X-Cache"), resp.Header.Get("ETag"), second, first)
+	}
+	if o.hits.Load() != hits+1 {
+		t.Errorf("revalidation made %d requests, want 1", o.hits.Load()-hits)
+	}
+
+	// A client that has the response gets a 304
+	resp, body := get(t, h, "/items/tagged", http.Header{"If-None-Match": {`"v1"`}})
+	if resp.StatusCode != http.StatusNotModified || body != "" {
+		t.Errorf("status = %d, body %q, want 304 without a body", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,5 @@
+// Package e2e holds end-to-end tests. They run the api-gateway proxy, order-service,
+// inventory-service and auth-service in the test process with in-memory storage and drive
+// them through the gateway over HTTP, the same way a client would. Checks that span the
+// services, such as of their migrations, live here as well.
+package e2e
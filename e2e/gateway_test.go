@@ -0,0 +1,214 @@
+package e2e
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+
+	"api-gateway/pkg/problem"
+	"api-gateway/pkg/requestid"
+)
+
+func TestGatewayValidatesRequests(t *testing.T) {
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		status int
+		code   string
+		fields []string
+	}{
+		{
+			name:   "invalid order line",
+			method: http.MethodPost,
+			path:   "/orders/",
+			body:   map[string]any{"customer_name": "alice", "items": []orderItem{{keyboard, 0}, {0, 1}}},
+			status: http.StatusUnprocessableEntity,
+			code:   problem.CodeValidationFailed,
+			fields: []string{"items.0.quantity", "items.1.product_id"},
+		},
+		{
+			name:   "missing customer",
+			method: http.MethodPost,
+			path:   "/orders/",
+			body:   map[string]any{"items": []orderItem{{keyboard, 1}}},
+			status: http.StatusUnprocessableEntity,
+			code:   problem.CodeValidationFailed,
+			fields: []string{"customer_name"},
+		},
+		{
+			name:   "unknown order status",
+			method: http.MethodPatch,
+			path:   "/orders/1",
+			body:   map[string]any{"status": "shipped"},
+			status: http.StatusUnprocessableEntity,
+			code:   problem.CodeValidationFailed,
+			fields: []string{"status"},
+		},
+		{
+			name:   "malformed body",
+			method: http.MethodPost,
+			path:   "/products/",
+			body:   `{"name": "mouse",`,
+			status: http.StatusBadRequest,
+			code:   problem.CodeBadRequest,
+			fields: []string{"body"},
+		},
+		{
+			name:   "invalid path parameter",
+			method: http.MethodGet,
+			path:   "/products/abc",
+			status: http.StatusBadRequest,
+			code:   problem.CodeBadRequest,
+			fields: []string{"id"},
+		},
+		{
+			name:   "invalid query parameter",
+			method: http.MethodGet,
+			path:   "/products/?page_size=1000&sort=color",
+			status: http.StatusUnprocessableEntity,
+			code:   problem.CodeValidationFailed,
+			fields: []string{"page_size", "sort"},
+		},
+		{
+			name:   "invalid adjustment",
+			method: http.MethodPost,
+			path:   "/products/1/adjust",
+			body:   map[string]any{"delta": 0, "reason": "gift"},
+			status: http.StatusUnprocessableEntity,
+			code:   problem.CodeValidationFailed,
+			fields: []string{"delta", "reason"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp problem.Problem
+			if got := h.do(tt.method, tt.path, tt.body, h.staff, &resp); got != tt.status {
+				t.Fatalf("%s %s = %d, want %d (%+v)", tt.method, tt.path, got, tt.status, resp)
+			}
+			if resp.Status != tt.status || resp.Code != tt.code {
+				t.Errorf("problem status, code = %d, %q, want %d, %q", resp.Status, resp.Code, tt.status, tt.code)
+			}
+
+			got := slices.Sorted(maps.Keys(resp.Errors))
+			if !slices.Equal(got, tt.fields) {
+				t.Errorf("invalid fields = %v, want %v (%v)", got, tt.fields, resp.Errors)
+			}
+			for field, reason := range resp.Errors {
+				if reason == "" {
+					t.Errorf("field %s has no reason", field)
+				}
+			}
+		})
+	}
+
+	// Nothing reached inventory.
+	if got := h.product(keyboard).Available; got != 10 {
+		t.Errorf("keyboard available = %d, want 10", got)
+	}
+}
+
+func TestGatewayUnknownRoutes(t *testing.T) {
+	h := newHarness(t)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{http.MethodGet, "/customers", http.StatusNotFound, problem.CodeRouteNotFound},
+		{http.MethodGet, "/products/1/history", http.StatusNotFound, problem.CodeRouteNotFound},
+		{http.MethodDelete, "/orders/1", http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		var resp problem.Problem
+		h.mustDo(tt.status, tt.method, tt.path, nil, h.staff, &resp)
+		if resp.Code != tt.code {
+			t.Errorf("%s %s code = %q, want %q", tt.method, tt.path, resp.Code, tt.code)
+		}
+	}
+}
+
+func TestGatewayAcceptsPathsWithoutTrailingSlash(t *testing.T) {
+	h := newHarness(t)
+
+	// The services redirect to the route with the slash, the client follows.
+	var created struct {
+		Inventory product `json:"inventory"`
+	}
+	h.mustDo(http.StatusCreated, http.MethodPost, "/products", map[string]any{
+		"name": "keyboard", "description": "mechanical", "price": 40, "available": 1,
+	}, nil, &created)
+
+	var list struct {
+		Inventory []product `json:"inventory"`
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, "/products", nil, nil, &list)
+	if len(list.Inventory) != 1 || list.Inventory[0].ID != created.Inventory.ID {
+		t.Errorf("products = %+v", list.Inventory)
+	}
+}
+
+func TestProblemsCarryTheRequestID(t *testing.T) {
+	h := newHarness(t)
+
+	tests := []struct {
+		name string
+		id   string // Sent by the client, a new one is expected when empty
+		path string
+		code string
+	}{
+		{"from the gateway", "e2e-gateway", "/customers", problem.CodeRouteNotFound},
+		{"from a service", "e2e-service", "/products/999", problem.CodeNotFound},
+		{"generated", "", "/orders/999", problem.CodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, h.gateway.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header = h.staff.Clone()
+			if tt.id != "" {
+				req.Header.Set(requestid.Header, tt.id)
+			}
+
+			resp, err := h.gateway.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Type"); got != problem.ContentType {
+				t.Errorf("Content-Type = %q, want %q", got, problem.ContentType)
+			}
+
+			var p problem.Problem
+			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Status != resp.StatusCode || p.Code != tt.code {
+				t.Errorf("problem status, code = %d, %q, want %d, %q", p.Status, p.Code, resp.StatusCode, tt.code)
+			}
+
+			id := resp.Header.Get(requestid.Header)
+			if id == "" || (tt.id != "" && id != tt.id) {
+				t.Errorf("%s = %q, want %q", requestid.Header, id, tt.id)
+			}
+			if p.RequestID != id {
+				t.Errorf("request_id = %q, want %q", p.RequestID, id)
+			}
+		})
+	}
+}
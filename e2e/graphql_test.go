@@ -0,0 +1,361 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"api-gateway/config"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/problem"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// graphqlRoutes serves GraphQL to the callers with a token, with the orders read from
+// orders, the products from inventory and the categories from catalog.
+func graphqlRoutes(orders, inventory, catalog string) string {
+	return `
+upstreams:
+  inventory-service:
+    url: ${E2E_INVENTORY_SERVICE}
+  orders:
+    url: ` + orders + `
+  counted:
+    url: ` + inventory + `
+  catalog:
+    url: ` + catalog + `
+routes:
+  - name: products
+    prefix: /products
+    upstream: inventory-service
+  - name: graphql
+    path: /graphql
+    methods: [GET, POST]
+    upstream: orders
+    middleware: [auth]
+    view:
+      name: graphql
+      sources: {products: counted, categories: catalog}
+`
+}
+
+// counter passes the requests on to a service and records them.
+type counter struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newCounter(t *testing.T, target string) *counter {
+	t.Helper()
+
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+
+	c := &counter{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		c.requests = append(c.requests, r.Method+" "+r.URL.RequestURI())
+		c.mu.Unlock()
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(c.Close)
+
+	return c
+}
+
+// take returns the requests recorded since the last call.
+func (c *counter) take() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	requests := c.requests
+	c.requests = nil
+	return requests
+}
+
+// newCatalog serves two categories the way the catalog does.
+func newCatalog(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	categories := []map[string]string{
+		{"id": "8d2c7f4e-0f5e-4a53-9a43-3c1b1f0e7a10", "name": "Keyboards", "description": "Mechanical and not"},
+		{"id": "1f0b5c9e-4a7d-4c2e-8d6b-2b3e9f6a5c21", "name": "Cables", "description": "Of every length"},
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v1/categories" {
+			json.NewEncoder(w).Encode(categories)
+			return
+		}
+		for _, c := range categories {
+			if r.URL.Path == "/api/v1/categories/"+c["id"] {
+				json.NewEncoder(w).Encode(c)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(problem.NotFound("category not found"))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+type graphqlErrors []struct {
+	Message    string `json:"message"`
+	Extensions struct {
+		Code string `json:"code"`
+	} `json:"extensions"`
+}
+
+// graphql sends query with vars to /graphql and decodes the data into out. It returns the
+// errors of the response.
+func (h *harness) graphql(method string, header http.Header, query string, vars map[string]any, out any) graphqlErrors {
+	h.t.Helper()
+
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors graphqlErrors   `json:"errors"`
+	}
+	if method == http.MethodGet {
+		q := url.Values{"query": {query}}
+		h.mustDo(http.StatusOK, method, "/graphql?"+q.Encode(), nil, header, &resp)
+	} else {
+		h.mustDo(http.StatusOK, method, "/graphql", map[string]any{"query": query, "variables": vars}, header, &resp)
+	}
+
+	if out != nil && len(resp.Data) > 0 && string(resp.Data) != "null" {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			h.t.Fatalf("decode %s: %v", resp.Data, err)
+		}
+	}
+	return resp.Errors
+}
+
+// mustGraphQL is graphql for queries that are expected to succeed.
+func (h *harness) mustGraphQL(header http.Header, query string, vars map[string]any, out any) {
+	h.t.Helper()
+
+	if errs := h.graphql(http.MethodPost, header, query, vars, out); len(errs) > 0 {
+		h.t.Fatalf("errors: %+v", errs)
+	}
+}
+
+type graphqlOrder struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Customer *struct {
+		ID string `json:"id"`
+	} `json:"customer"`
+	Items []struct {
+		Quantity int64 `json:"quantity"`
+		Product  *struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"product"`
+	} `json:"items"`
+}
+
+const orderFields = `id status customer { id } items { quantity product { id name } }`
+
+type pageInfo struct {
+	TotalCount  int  `json:"totalCount"`
+	HasNextPage bool `json:"hasNextPage"`
+}
+
+func TestGraphQL(t *testing.T) {
+	h := newHarness(t)
+	orders, inventory := newCounter(t, h.orders.URL), newCounter(t, h.inventory.URL)
+	routeGateway(t, h, config.Config{Auth: auth.Config{Secret: authSecret}}, graphqlRoutes(orders.URL, inventory.URL, newCatalog(t).URL))
+
+	as := func(subject, role string) http.Header {
+		return bearer(token(t, jwt.SigningMethodHS256, []byte(authSecret), "", jwt.MapClaims{"sub": subject, "roles": []string{role}}))
+	}
+	alice, staff := as("alice", "customer"), as("sam", "staff")
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+	mouse := h.createProduct("mouse", 15, 10)
+	cable := h.createProduct("cable", 5, 10)
+
+	// IDs are strings in GraphQL
+	placeOrder := `mutation ($input: CreateOrderInput!) { createOrder(input: $input) { ` + orderFields + ` } }`
+	var placed struct {
+		CreateOrder graphqlOrder `json:"createOrder"`
+	}
+	h.mustGraphQL(alice, placeOrder, map[string]any{"input": map[string]any{
+		"customerName": "Alice",
+		"items":        []map[string]any{{"productId": fmt.Sprint(keyboard), "quantity": 1}, {"productId": fmt.Sprint(mouse), "quantity": 2}},
+	}}, &placed)
+	first := placed.CreateOrder
+	if first.Status != "PENDING" || first.Customer == nil || first.Customer.ID != "alice" || len(first.Items) != 2 ||
+		first.Items[0].Product == nil || first.Items[0].Product.Name != "keyboard" {
+		t.Fatalf("placed order = %+v", first)
+	}
+	h.mustGraphQL(alice, placeOrder, map[string]any{"input": map[string]any{
+		"customerName": "Alice",
+		"items":        []map[string]any{{"productId": fmt.Sprint(cable), "quantity": 1}, {"productId": fmt.Sprint(mouse), "quantity": 1}},
+	}}, nil)
+	if got := h.product(mouse).Available; got != 7 {
+		t.Errorf("mice available = %d, want 7", got)
+	}
+
+	// The products of every line of every order are read in one request
+	orders.take()
+	inventory.take()
+	var list struct {
+		Orders struct {
+			Items    []graphqlOrder `json:"items"`
+			PageInfo pageInfo       `json:"pageInfo"`
+		} `json:"orders"`
+	}
+	h.mustGraphQL(alice, `{ orders { items { `+orderFields+` } pageInfo { totalCount hasNextPage } } }`, nil, &list)
+	if len(list.Orders.Items) != 2 || list.Orders.PageInfo.TotalCount != 2 {
+		t.Fatalf("orders = %+v", list.Orders)
+	}
+	for _, o := range list.Orders.Items {
+		for _, item := range o.Items {
+			if item.Product == nil {
+				t.Errorf("order %s has a line without its product", o.ID)
+			}
+		}
+	}
+	if got := inventory.take(); !slices.Equal(got, []string{"POST /products/batch/get"}) {
+		t.Errorf("requests to inventory-service = %v, want a single batch", got)
+	}
+	if got := orders.take(); !slices.Equal(got, []string{"GET /orders/?page=1&page_size=20"}) {
+		t.Errorf("requests to order-service = %v, want the first page", got)
+	}
+
+	// Filters and pages
+	h.mustGraphQL(alice, `query ($product: ID) { orders(filter: {productId: $product}) { items { id } } }`, map[string]any{"product": fmt.Sprint(cable)}, &list)
+	if len(list.Orders.Items) != 1 || list.Orders.Items[0].ID == first.ID {
+		t.Errorf("orders of the cable = %+v", list.Orders.Items)
+	}
+	orders.take()
+	h.mustGraphQL(alice, `{ orders(page: 2, pageSize: 1) { items { id } pageInfo { totalCount hasNextPage } } }`, nil, &list)
+	if len(list.Orders.Items) != 1 || list.Orders.PageInfo.TotalCount != 2 || list.Orders.PageInfo.HasNextPage {
+		t.Errorf("second page = %+v", list.Orders)
+	}
+	// order-service pages the orders, the gateway reads the one it needs
+	if got := orders.take(); !slices.Equal(got, []string{"GET /orders/?page=2&page_size=1"}) {
+		t.Errorf("requests to order-service = %v, want the second page", got)
+	}
+	h.mustGraphQL(alice, `{ orders(filter: {status: COMPLETED}) { items { id } pageInfo { totalCount } } }`, nil, &list)
+	if len(list.Orders.Items) != 0 || list.Orders.PageInfo.TotalCount != 0 {
+		t.Errorf("completed orders = %+v", list.Orders)
+	}
+
+	var products struct {
+		ByName struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+		} `json:"byName"`
+		ByPrice struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			PageInfo pageInfo `json:"pageInfo"`
+		} `json:"byPrice"`
+	}
+	h.mustGraphQL(alice, `{
+		byName: products(filter: {name: "MOU"}) { items { name } }
+		byPrice: products(sort: PRICE_DESC, pageSize: 2) { items { name } pageInfo { totalCount hasNextPage } }
+	}`, nil, &products)
+	if len(products.ByName.Items) != 1 || products.ByName.Items[0].Name != "mouse" {
+		t.Errorf("products named mou = %+v", products.ByName.Items)
+	}
+	if len(products.ByPrice.Items) != 2 || products.ByPrice.Items[0].Name != "keyboard" || !products.ByPrice.PageInfo.HasNextPage {
+		t.Errorf("products by price = %+v", products.ByPrice)
+	}
+
+	var customer struct {
+		Customer struct {
+			Name   string `json:"name"`
+			Orders struct {
+				PageInfo pageInfo `json:"pageInfo"`
+			} `json:"orders"`
+		} `json:"customer"`
+		Nobody *struct{} `json:"nobody"`
+	}
+	h.mustGraphQL(alice, `{ customer(id: "alice") { name orders { pageInfo { totalCount } } } nobody: customer(id: "bob") { id } }`, nil, &customer)
+	if customer.Customer.Name != "Alice" || customer.Customer.Orders.PageInfo.TotalCount != 2 || customer.Nobody != nil {
+		t.Errorf("customers = %+v", customer)
+	}
+
+	// The orders of a customer nested under its own orders are read once
+	orders.take()
+	nested := `{ orders { items { customer { orders { items { customer { orders { pageInfo { totalCount } } } } } } } } }`
+	h.mustGraphQL(alice, nested, nil, nil)
+	if got := orders.take(); len(got) != 2 {
+		t.Errorf("requests to order-service = %v, want the list and the orders of alice", got)
+	}
+
+	// Queries that fan out further are cut off
+	var fanOut strings.Builder
+	fanOut.WriteString("{")
+	for page := 1; page <= 60; page++ {
+		fmt.Fprintf(&fanOut, " p%d: orders(page: %d, pageSize: 1) { pageInfo { totalCount } }", page, page)
+	}
+	fanOut.WriteString(" }")
+	errs := h.graphql(http.MethodPost, alice, fanOut.String(), nil, nil)
+	if len(errs) != 10 || errs[0].Extensions.Code != problem.CodeBadRequest || !strings.Contains(errs[0].Message, "more than 50 requests") {
+		t.Errorf("errors of a query of 60 pages = %+v", errs)
+	}
+	if got := orders.take(); len(got) != 50 {
+		t.Errorf("a query of 60 pages made %d requests to order-service, want 50", len(got))
+	}
+
+	var categories struct {
+		Categories struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+		} `json:"categories"`
+		Missing *struct{} `json:"missing"`
+	}
+	h.mustGraphQL(alice, `{ categories(filter: {name: "cable"}) { items { name } } missing: category(id: "none") { id } }`, nil, &categories)
+	if len(categories.Categories.Items) != 1 || categories.Categories.Items[0].Name != "Cables" || categories.Missing != nil {
+		t.Errorf("categories = %+v", categories)
+	}
+
+	// Staff change the status, customers may not, mutations are refused over GET
+	setStatus := fmt.Sprintf(`mutation { setOrderStatus(id: %q, status: COMPLETED) { status } }`, first.ID)
+	var changed struct {
+		SetOrderStatus graphqlOrder `json:"setOrderStatus"`
+	}
+	if errs := h.graphql(http.MethodPost, alice, setStatus, nil, nil); len(errs) != 1 || errs[0].Extensions.Code != problem.CodeForbidden {
+		t.Errorf("status set by a customer: errors %+v", errs)
+	}
+	var unchanged struct {
+		Order graphqlOrder `json:"order"`
+	}
+	h.mustGraphQL(alice, fmt.Sprintf(`{ order(id: %q) { status } }`, first.ID), nil, &unchanged)
+	if unchanged.Order.Status != "PENDING" {
+		t.Errorf("status after a customer set it = %q, want PENDING", unchanged.Order.Status)
+	}
+	h.mustGraphQL(staff, setStatus, nil, &changed)
+	if changed.SetOrderStatus.Status != "COMPLETED" {
+		t.Errorf("status = %q, want COMPLETED", changed.SetOrderStatus.Status)
+	}
+	errs = h.graphql(http.MethodGet, staff, setStatus, nil, nil)
+	if len(errs) != 1 || errs[0].Extensions.Code != problem.CodeMethodNotAllowed {
+		t.Errorf("mutation over GET: errors %+v", errs)
+	}
+
+	// Without a token there is no GraphQL
+	h.mustDo(http.StatusUnauthorized, http.MethodPost, "/graphql", map[string]any{"query": "{ orders { items { id } } }"}, nil, nil)
+}
@@ -0,0 +1,229 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api-gateway/config"
+	"api-gateway/pkg/auth"
+	"api-gateway/proxy"
+	inventorytest "inventory-service/testserver"
+	ordertest "order-service/testserver"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// harness is one running system: order-service calls inventory-service through its
+// InventoryRouter client and both sit behind the gateway.
+type harness struct {
+	t *testing.T
+
+	gateway   *httptest.Server
+	inventory *inventorytest.Server
+	orders    *ordertest.Server
+
+	// staff is the identity the order helpers act with, which may read and change every
+	// order. order-service serves no anonymous callers.
+	staff http.Header
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	inventory := inventorytest.New(t)
+	return newHarnessOf(t, inventory, ordertest.New(t, inventory.URL))
+}
+
+// newHarnessOf serves inventory and orders behind a gateway.
+func newHarnessOf(t *testing.T, inventory *inventorytest.Server, orders *ordertest.Server) *harness {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	gw, err := proxy.New(&config.Config{
+		OrderService:     config.OrderService{Addr: orders.URL},
+		InventoryService: config.InventoryService{Addr: inventory.URL},
+		Auth:             auth.Config{Secret: authSecret},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(gw.Close)
+	gateway := httptest.NewServer(gw.Handler())
+	t.Cleanup(gateway.Close)
+
+	return &harness{
+		t:         t,
+		gateway:   gateway,
+		inventory: inventory,
+		orders:    orders,
+		staff:     bearer(token(t, jwt.SigningMethodHS256, []byte(authSecret), "", jwt.MapClaims{"sub": "staff-1", "roles": []string{"staff"}})),
+	}
+}
+
+// do sends a request through the gateway and decodes the response into out, if it is not
+// nil. It returns the status code. A body that is a string is sent as it is.
+func (h *harness) do(method, path string, body any, header http.Header, out any) int {
+	h.t.Helper()
+
+	var reqBody io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reqBody = strings.NewReader(body)
+	default:
+		b, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatalf("marshal request: %v", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, h.gateway.URL+path, reqBody)
+	if err != nil {
+		h.t.Fatalf("new request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := h.gateway.Client().Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("%s %s: read body: %v", method, path, err)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			h.t.Fatalf("%s %s: decode %q: %v", method, path, respBody, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+// mustDo is do for requests that are expected to return want.
+func (h *harness) mustDo(want int, method, path string, body any, header http.Header, out any) {
+	h.t.Helper()
+
+	if got := h.do(method, path, body, header, out); got != want {
+		h.t.Fatalf("%s %s = %d, want %d", method, path, got, want)
+	}
+}
+
+type product struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Available   int64   `json:"available"`
+	Version     int32   `json:"version"`
+}
+
+type orderItem struct {
+	ProductID int64 `json:"product_id"`
+	Quantity  int64 `json:"quantity"`
+}
+
+type placedItem struct {
+	ProductID int64  `json:"product_id"`
+	Name      string `json:"name"`
+	Price     int64  `json:"price"` // In cents
+	Status    string `json:"status"`
+	Reason    string `json:"reason"`
+}
+
+type placedOrder struct {
+	OrderID      int64        `json:"order_id"`
+	CustomerName string       `json:"customer_name"`
+	Items        []placedItem `json:"items"`
+	Total        int64        `json:"total"`
+}
+
+type order struct {
+	OrderID      int64       `json:"order_id"`
+	CustomerName string      `json:"customer_name"`
+	CustomerID   string      `json:"customer_id"`
+	Items        []orderItem `json:"items"`
+	Status       string      `json:"status"`
+}
+
+func (h *harness) createProduct(name string, price float64, available int64) int64 {
+	h.t.Helper()
+
+	var resp struct {
+		Inventory product `json:"inventory"`
+	}
+	h.mustDo(http.StatusCreated, http.MethodPost, "/products/", map[string]any{
+		"name":        name,
+		"description": name + " for the end-to-end tests",
+		"price":       price,
+		"available":   available,
+	}, nil, &resp)
+
+	return resp.Inventory.ID
+}
+
+func (h *harness) product(id int64) product {
+	h.t.Helper()
+
+	var resp struct {
+		Inventory product `json:"inventory"`
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, fmt.Sprintf("/products/%d", id), nil, nil, &resp)
+
+	return resp.Inventory
+}
+
+func (h *harness) placeOrder(customer string, items ...orderItem) placedOrder {
+	h.t.Helper()
+
+	var resp struct {
+		Order placedOrder `json:"order"`
+	}
+	h.mustDo(http.StatusOK, http.MethodPost, "/orders/", map[string]any{
+		"customer_name": customer,
+		"items":         items,
+	}, h.staff, &resp)
+
+	return resp.Order
+}
+
+func (h *harness) order(id int64) order {
+	h.t.Helper()
+
+	var resp struct {
+		Order order `json:"order"`
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, fmt.Sprintf("/orders/%d", id), nil, h.staff, &resp)
+
+	return resp.Order
+}
+
+func (h *harness) setOrderStatus(id int64, status string) order {
+	h.t.Helper()
+
+	var resp struct {
+		Order order `json:"order"`
+	}
+	h.mustDo(http.StatusOK, http.MethodPatch, fmt.Sprintf("/orders/%d", id), map[string]any{"status": status}, h.staff, &resp)
+
+	return resp.Order
+}
@@ -0,0 +1,67 @@
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"api-gateway/pkg/health"
+)
+
+func TestProbes(t *testing.T) {
+	h := newHarness(t)
+
+	var live health.Report
+	h.mustDo(http.StatusOK, http.MethodGet, "/livez", nil, nil, &live)
+	if live.Status != health.StatusUp {
+		t.Errorf("gateway /livez status = %q", live.Status)
+	}
+
+	var ready health.Report
+	h.mustDo(http.StatusOK, http.MethodGet, "/readyz", nil, nil, &ready)
+	for _, name := range []string{"order-service", "inventory-service"} {
+		if got := ready.Checks[name].Status; got != health.StatusUp {
+			t.Errorf("gateway /readyz %s = %q, want %q", name, got, health.StatusUp)
+		}
+	}
+
+	code, orders := readyz(t, h.orders.URL)
+	if code != http.StatusOK || orders.Checks["inventory-service"].Details["transport"] != "http" {
+		t.Errorf("order-service /readyz = %d %+v", code, orders)
+	}
+
+	// Without inventory-service neither order-service nor the gateway is ready, both
+	// are still live
+	h.inventory.Close()
+
+	code, orders = readyz(t, h.orders.URL)
+	if code != http.StatusServiceUnavailable || orders.Checks["inventory-service"].Status != health.StatusDown {
+		t.Errorf("order-service /readyz without inventory-service = %d %+v", code, orders)
+	}
+
+	ready = health.Report{}
+	h.mustDo(http.StatusServiceUnavailable, http.MethodGet, "/readyz", nil, nil, &ready)
+	if ready.Status != health.StatusDown || ready.Checks["inventory-service"].Error == "" || ready.Checks["order-service"].Status != health.StatusUp {
+		t.Errorf("gateway /readyz without inventory-service = %+v", ready)
+	}
+
+	h.mustDo(http.StatusOK, http.MethodGet, "/livez", nil, nil, nil)
+}
+
+// readyz asks the service at url directly whether it is ready.
+func readyz(t *testing.T, url string) (int, health.Report) {
+	t.Helper()
+
+	resp, err := http.Get(url + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var report health.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("decode /readyz: %v", err)
+	}
+
+	return resp.StatusCode, report
+}
@@ -0,0 +1,138 @@
+package e2e
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"api-gateway/config"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/problem"
+	authtest "auth-service/testserver"
+)
+
+type tokens struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+// identityRoutes sends /auth to auth-service and lets the callers it signed in reach
+// their orders.
+func identityRoutes(authURL string) string {
+	return `
+upstreams:
+  auth-service:
+    url: ` + authURL + `
+    openapi: /openapi.json
+  order-service:
+    url: ${E2E_ORDER_SERVICE}
+    openapi: /openapi.json
+routes:
+  - name: auth
+    prefix: /auth
+    upstream: auth-service
+    middleware: [validate]
+  - name: orders
+    prefix: /orders
+    upstream: order-service
+    middleware: [auth, validate]
+`
+}
+
+func TestIdentity(t *testing.T) {
+	h := newHarness(t)
+	a := authtest.New(t)
+
+	// The gateway trusts the keys auth-service publishes
+	routeGateway(t, h, config.Config{Auth: auth.Config{
+		JWKSURL:  a.URL + "/.well-known/jwks.json",
+		Issuer:   authtest.Issuer,
+		Audience: authtest.Audience,
+	}}, identityRoutes(a.URL))
+
+	credentials := map[string]any{"email": "Alice@Example.com", "password": "correct horse"}
+
+	var registered struct {
+		User struct {
+			ID    int64    `json:"id"`
+			Email string   `json:"email"`
+			Roles []string `json:"roles"`
+		} `json:"user"`
+	}
+	h.mustDo(http.StatusCreated, http.MethodPost, "/auth/register", credentials, nil, &registered)
+	if registered.User.Email != "alice@example.com" || len(registered.User.Roles) != 1 || registered.User.Roles[0] != "customer" {
+		t.Errorf("user = %+v", registered.User)
+	}
+
+	var p problem.Problem
+	h.mustDo(http.StatusConflict, http.MethodPost, "/auth/register", map[string]any{"email": "alice@example.com", "password": "another one"}, nil, &p)
+	if p.Code != problem.CodeEmailTaken {
+		t.Errorf("code = %q, want %q", p.Code, problem.CodeEmailTaken)
+	}
+	h.mustDo(http.StatusUnprocessableEntity, http.MethodPost, "/auth/register", map[string]any{"email": "bob@example.com", "password": "short"}, nil, nil)
+	h.mustDo(http.StatusUnauthorized, http.MethodPost, "/auth/login", map[string]any{"email": "alice@example.com", "password": "wrong password"}, nil, nil)
+
+	login := func(password string) tokens {
+		t.Helper()
+
+		var tok tokens
+		h.mustDo(http.StatusOK, http.MethodPost, "/auth/login", map[string]any{"email": "alice@example.com", "password": password}, nil, &tok)
+		if tok.TokenType != "Bearer" || tok.AccessToken == "" || tok.RefreshToken == "" || tok.ExpiresIn <= 0 {
+			t.Fatalf("tokens = %+v", tok)
+		}
+		return tok
+	}
+	refresh := func(refreshToken string, want int) tokens {
+		t.Helper()
+
+		var tok tokens
+		h.mustDo(want, http.MethodPost, "/auth/refresh", map[string]any{"refresh_token": refreshToken}, nil, &tok)
+		return tok
+	}
+
+	// The access token identifies the user behind the gateway
+	first := login("correct horse")
+	var placed struct {
+		Order placedOrder `json:"order"`
+	}
+	h.mustDo(http.StatusOK, http.MethodPost, "/orders/", map[string]any{"customer_name": "alice"}, bearer(first.AccessToken), &placed)
+	var got struct {
+		Order order `json:"order"`
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, "/orders/"+strconv.FormatInt(placed.Order.OrderID, 10), nil, bearer(first.AccessToken), &got)
+	if got.Order.CustomerID != strconv.FormatInt(registered.User.ID, 10) {
+		t.Errorf("customer_id = %q, want the ID of the user %d", got.Order.CustomerID, registered.User.ID)
+	}
+	h.mustDo(http.StatusUnauthorized, http.MethodGet, "/orders/", nil, bearer("not.a.token"), nil)
+
+	// Refresh tokens rotate, and one used twice ends the session
+	second := refresh(first.RefreshToken, http.StatusOK)
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, "/orders/", nil, bearer(second.AccessToken), nil)
+	refresh(first.RefreshToken, http.StatusUnauthorized)
+	refresh(second.RefreshToken, http.StatusUnauthorized)
+
+	// Logging out ends the session of the refresh token
+	third := login("correct horse")
+	h.mustDo(http.StatusNoContent, http.MethodPost, "/auth/logout", map[string]any{"refresh_token": third.RefreshToken}, nil, nil)
+	refresh(third.RefreshToken, http.StatusUnauthorized)
+
+	// A reset token sets a new password once and ends every session
+	fourth := login("correct horse")
+	h.mustDo(http.StatusAccepted, http.MethodPost, "/auth/password/forgot", map[string]any{"email": "nobody@example.com"}, nil, nil)
+	h.mustDo(http.StatusAccepted, http.MethodPost, "/auth/password/forgot", map[string]any{"email": "alice@example.com"}, nil, nil)
+	reset := a.ResetToken("alice@example.com")
+	if reset == "" {
+		t.Fatal("no reset token was sent")
+	}
+	h.mustDo(http.StatusNoContent, http.MethodPost, "/auth/password/reset", map[string]any{"token": reset, "password": "battery staple"}, nil, nil)
+	h.mustDo(http.StatusUnauthorized, http.MethodPost, "/auth/password/reset", map[string]any{"token": reset, "password": "another staple"}, nil, nil)
+	refresh(fourth.RefreshToken, http.StatusUnauthorized)
+	h.mustDo(http.StatusUnauthorized, http.MethodPost, "/auth/login", credentials, nil, nil)
+	login("battery staple")
+}
@@ -0,0 +1,50 @@
+package e2e
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 1)
+	h.placeOrder("alice", orderItem{keyboard, 2})
+	h.product(keyboard)
+	h.do(http.MethodPost, "/orders/", map[string]any{"items": []orderItem{{keyboard, 1}}}, h.staff, nil)
+
+	resp, err := h.gateway.Client().Get(h.gateway.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The gateway and both services share one registry in this process, so every metric
+	// below is served by the gateway.
+	for _, series := range []string{
+		`gateway_upstream_requests_total{status="200",upstream="order-service"}`,
+		`gateway_upstream_requests_total{status="201",upstream="inventory-service"}`,
+		`gateway_rejected_requests_total{status="422",upstream="order-service"}`,
+		`http_requests_total{method="GET",route="/products/{id}",status="200"}`,
+		`http_requests_total{method="GET",route="/products/:id",status="200"}`,
+		`http_request_duration_seconds_bucket{method="POST",route="/orders/",status="200",le="+Inf"}`,
+		`inventory_client_calls_total{operation="get_many",outcome="success",transport="http"}`,
+		`inventory_client_breaker_state{transport="http"} 0`,
+		`orders_created_total`,
+		`order_lines_rejected_total{reason="insufficient_inventory"}`,
+	} {
+		if !strings.Contains(string(body), series) {
+			t.Errorf("metrics have no %s", series)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package e2e
+
+import (
+	"io/fs"
+	"regexp"
+	"testing"
+
+	gatewaymigrations "api-gateway/migrations"
+	authmigrations "auth-service/migrations"
+	inventorymigrations "inventory-service/migrations"
+	ordermigrations "order-service/migrations"
+)
+
+var migrationName = regexp.MustCompile(`^(\d+)_\w+\.(up|down)\.sql$`)
+
+// TestMigrationVersions fails when two migrations of a service share a version, which
+// happens when branches add a migration each, or a migration lacks its up or down half.
+// The services migrate their databases on start, so this is checked for all of them.
+func TestMigrationVersions(t *testing.T) {
+	services := map[string]fs.FS{
+		"api-gateway":       gatewaymigrations.FS,
+		"auth-service":      authmigrations.FS,
+		"inventory-service": inventorymigrations.FS,
+		"order-service":     ordermigrations.FS,
+	}
+
+	for name, fsys := range services {
+		t.Run(name, func(t *testing.T) {
+			files, err := fs.Glob(fsys, "*.sql")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) == 0 {
+				t.Fatal("no migrations embedded")
+			}
+
+			seen := make(map[string]string) // Version and direction to file name
+			versions := make(map[string]bool)
+			for _, file := range files {
+				m := migrationName.FindStringSubmatch(file)
+				if m == nil {
+					t.Errorf("%s is not named VERSION_NAME.up.sql or VERSION_NAME.down.sql", file)
+					continue
+				}
+
+				key := m[1] + "." + m[2]
+				if other, ok := seen[key]; ok {
+					t.Errorf("%s and %s have the same version", other, file)
+				}
+				seen[key] = file
+				versions[m[1]] = true
+			}
+
+			for version := range versions {
+				for _, direction := range []string{"up", "down"} {
+					if _, ok := seen[version+"."+direction]; !ok {
+						t.Errorf("migration %s has no %s file", version, direction)
+					}
+				}
+			}
+		})
+	}
+}
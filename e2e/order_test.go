@@ -0,0 +1,330 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"testing"
+
+	inventorytest "inventory-service/testserver"
+	ordertest "order-service/testserver"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPlaceOrderDeductsStock(t *testing.T) {
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+	mouse := h.createProduct("mouse", 15, 5)
+
+	placed := h.placeOrder("alice", orderItem{keyboard, 2}, orderItem{mouse, 1}, orderItem{keyboard, 1})
+
+	if placed.OrderID == 0 || placed.CustomerName != "alice" {
+		t.Fatalf("placed order = %+v", placed)
+	}
+	for _, item := range placed.Items {
+		if item.Status != "accepted" {
+			t.Errorf("product %d: status %q (%s), want accepted", item.ProductID, item.Status, item.Reason)
+		}
+	}
+	// Prices are in cents
+	if want := int64(3*4000 + 1500); placed.Total != want {
+		t.Errorf("total = %d, want %d", placed.Total, want)
+	}
+
+	if got := h.product(keyboard).Available; got != 7 {
+		t.Errorf("keyboard available = %d, want 7", got)
+	}
+	if got := h.product(mouse).Available; got != 4 {
+		t.Errorf("mouse available = %d, want 4", got)
+	}
+
+	// Lines of the same product are placed and stored together.
+	if len(placed.Items) != 2 {
+		t.Errorf("placed lines = %+v, want one per product", placed.Items)
+	}
+	stored := h.order(placed.OrderID)
+	if stored.Status != "pending" || len(stored.Items) != 2 || stored.Items[0] != (orderItem{keyboard, 3}) {
+		t.Errorf("stored order = %+v", stored)
+	}
+}
+
+// Prices with cents are charged to the cent, not rounded to whole units first.
+func TestPlaceOrderChargesCents(t *testing.T) {
+	h := newHarness(t)
+
+	cable := h.createProduct("cable", 48.99, 200)
+	plug := h.createProduct("plug", 0.49, 200)
+
+	placed := h.placeOrder("alice", orderItem{cable, 100}, orderItem{plug, 3})
+	prices := map[int64]int64{}
+	for _, item := range placed.Items {
+		prices[item.ProductID] = item.Price
+	}
+	if prices[cable] != 489900 || prices[plug] != 147 {
+		t.Errorf("line prices = %v, want 489900 and 147 cents", prices)
+	}
+	if placed.Total != 490047 {
+		t.Errorf("total = %d, want 490047", placed.Total)
+	}
+}
+
+func TestPlaceOrderJudgesLinesOfAProductTogether(t *testing.T) {
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 5)
+
+	// Each line fits the stock, both together do not
+	placed := h.placeOrder("alice", orderItem{keyboard, 3}, orderItem{keyboard, 3})
+	if len(placed.Items) != 1 || placed.Items[0].Reason != "insufficient_inventory" {
+		t.Errorf("placed lines = %+v, want one rejected line", placed.Items)
+	}
+	if got := h.product(keyboard).Available; got != 5 {
+		t.Errorf("keyboard available = %d, want 5", got)
+	}
+}
+
+func TestPlaceOrderRejectsUnavailableLines(t *testing.T) {
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 1)
+	mouse := h.createProduct("mouse", 15, 5)
+
+	placed := h.placeOrder("bob", orderItem{keyboard, 2}, orderItem{mouse, 3}, orderItem{9999, 1})
+
+	want := map[int64]string{
+		keyboard: "insufficient_inventory",
+		mouse:    "",
+		9999:     "product_not_found",
+	}
+	for _, item := range placed.Items {
+		if item.Reason != want[item.ProductID] {
+			t.Errorf("product %d: reason %q, want %q", item.ProductID, item.Reason, want[item.ProductID])
+		}
+	}
+	if placed.Total != 3*1500 {
+		t.Errorf("total = %d, want %d", placed.Total, 3*1500)
+	}
+
+	// Rejected lines keep their stock.
+	if got := h.product(keyboard).Available; got != 1 {
+		t.Errorf("keyboard available = %d, want 1", got)
+	}
+	if got := h.product(mouse).Available; got != 2 {
+		t.Errorf("mouse available = %d, want 2", got)
+	}
+}
+
+func TestConcurrentOrdersDoNotOversell(t *testing.T) {
+	h := newHarness(t)
+
+	const stock = 5
+	keyboard := h.createProduct("keyboard", 40, stock)
+
+	var (
+		mu       sync.Mutex
+		accepted int
+		wg       sync.WaitGroup
+	)
+	for i := range 2 * stock {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			placed := h.placeOrder(fmt.Sprintf("customer %d", i), orderItem{keyboard, 1})
+			if placed.Items[0].Status == "accepted" {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != stock {
+		t.Errorf("%d orders were accepted, want %d", accepted, stock)
+	}
+	if got := h.product(keyboard).Available; got != 0 {
+		t.Errorf("keyboard available = %d, want 0", got)
+	}
+}
+
+func TestUpdateProductVersionConflict(t *testing.T) {
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+	version := h.product(keyboard).Version
+
+	path := fmt.Sprintf("/products/%d", keyboard)
+	expect := http.Header{"X-Expected-Version": {fmt.Sprint(version)}}
+
+	var resp struct {
+		Inventory product `json:"inventory"`
+	}
+	h.mustDo(http.StatusOK, http.MethodPatch, path, map[string]any{"price": 45}, expect, &resp)
+	if resp.Inventory.Price != 45 || resp.Inventory.Version != version+1 {
+		t.Errorf("updated product = %+v", resp.Inventory)
+	}
+
+	// A second writer that read the same version loses.
+	h.mustDo(http.StatusConflict, http.MethodPatch, path, map[string]any{"price": 50}, expect, nil)
+
+	// Placing an order changes the stock without a version check, but still bumps it.
+	before := h.product(keyboard)
+	h.placeOrder("alice", orderItem{keyboard, 1})
+
+	stale := http.Header{"X-Expected-Version": {fmt.Sprint(before.Version)}}
+	h.mustDo(http.StatusConflict, http.MethodPatch, path, map[string]any{"available": 100}, stale, nil)
+
+	if got := h.product(keyboard); got.Price != 45 || got.Available != 9 {
+		t.Errorf("product = %+v, want price 45 and 9 available", got)
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+	placed := h.placeOrder("alice", orderItem{keyboard, 4})
+
+	if got := h.setOrderStatus(placed.OrderID, "canceled"); got.Status != "canceled" {
+		t.Errorf("status = %q, want canceled", got.Status)
+	}
+	if got := h.order(placed.OrderID).Status; got != "canceled" {
+		t.Errorf("stored status = %q, want canceled", got)
+	}
+
+	h.mustDo(http.StatusNotFound, http.MethodPatch, "/orders/9999", map[string]any{"status": "canceled"}, h.staff, nil)
+}
+
+func TestExpiredOrdersReturnStock(t *testing.T) {
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+	mouse := h.createProduct("mouse", 15, 1)
+
+	pending := h.placeOrder("alice", orderItem{keyboard, 3}, orderItem{mouse, 2})
+	completed := h.placeOrder("bob", orderItem{keyboard, 2})
+	h.setOrderStatus(completed.OrderID, "completed")
+
+	if got := h.product(keyboard).Available; got != 5 {
+		t.Fatalf("keyboard available = %d, want 5", got)
+	}
+
+	n, err := h.orders.ExpireAll(context.Background())
+	if err != nil {
+		t.Fatalf("ExpireAll: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expired %d orders, want 1", n)
+	}
+
+	if got := h.order(pending.OrderID).Status; got != "canceled" {
+		t.Errorf("pending order status = %q, want canceled", got)
+	}
+	if got := h.order(completed.OrderID).Status; got != "completed" {
+		t.Errorf("completed order status = %q, want completed", got)
+	}
+
+	// Only the accepted line is given back, the rejected mouse line never took stock.
+	if got := h.product(keyboard).Available; got != 8 {
+		t.Errorf("keyboard available = %d, want 8", got)
+	}
+	if got := h.product(mouse).Available; got != 1 {
+		t.Errorf("mouse available = %d, want 1", got)
+	}
+
+	// A second sweep has nothing left to do.
+	if n, err := h.orders.ExpireAll(context.Background()); err != nil || n != 0 {
+		t.Errorf("second ExpireAll = %d, %v, want 0", n, err)
+	}
+}
+
+// The stock of an order whose lines could not be recorded is still returned, though the
+// lines stay pending.
+func TestUnrecordedStockIsSettledOnExpiry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	inventory := inventorytest.New(t)
+	h := newHarnessOf(t, inventory, ordertest.New(t, inventory.URL, ordertest.LoseItemStatuses()))
+	keyboard := h.createProduct("keyboard", 40, 10)
+	mouse := h.createProduct("mouse", 15, 1)
+
+	h.mustDo(http.StatusInternalServerError, http.MethodPost, "/orders/", map[string]any{
+		"customer_name": "alice",
+		"items":         []orderItem{{keyboard, 3}, {mouse, 2}},
+	}, h.staff, nil)
+	if got := h.product(keyboard).Available; got != 7 {
+		t.Fatalf("keyboard available = %d, want 7", got)
+	}
+
+	if n, err := h.orders.ExpireAll(context.Background()); err != nil || n != 1 {
+		t.Fatalf("ExpireAll = %d, %v, want 1", n, err)
+	}
+
+	// The keyboards go back, the mice were never taken and are not made up
+	if got := h.product(keyboard).Available; got != 10 {
+		t.Errorf("keyboard available = %d, want 10", got)
+	}
+	if got := h.product(mouse).Available; got != 1 {
+		t.Errorf("mouse available = %d, want 1", got)
+	}
+}
+
+func TestUnansweredStockIsSettledOnExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		forward bool // Whether inventory-service gets the batch before the 503
+	}{
+		{name: "the response is lost", forward: true},
+		{name: "the request is lost", forward: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+
+			inventory := inventorytest.New(t)
+			target, err := url.Parse(inventory.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			forward := httputil.NewSingleHostReverseProxy(target)
+
+			// Batches never get an answer, everything else passes
+			lossy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/products/batch/decrement" {
+					forward.ServeHTTP(w, r)
+					return
+				}
+				if tt.forward {
+					forward.ServeHTTP(httptest.NewRecorder(), r)
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			t.Cleanup(lossy.Close)
+
+			h := newHarnessOf(t, inventory, ordertest.New(t, lossy.URL))
+			keyboard := h.createProduct("keyboard", 40, 10)
+
+			placed := h.placeOrder("alice", orderItem{keyboard, 3})
+			if placed.Items[0].Status != "rejected" || placed.Items[0].Reason != "inventory_unavailable" {
+				t.Fatalf("placed line = %+v, want rejected", placed.Items[0])
+			}
+
+			if n, err := h.orders.ExpireAll(context.Background()); err != nil || n != 1 {
+				t.Fatalf("ExpireAll = %d, %v, want 1", n, err)
+			}
+
+			// Whether the batch was applied or not, the stock is whole again
+			if got := h.product(keyboard).Available; got != 10 {
+				t.Errorf("keyboard available = %d, want 10", got)
+			}
+		})
+	}
+}
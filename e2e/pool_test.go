@@ -0,0 +1,181 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/pkg/problem"
+)
+
+// echo is an instance of a service that answers every request with its name and the
+// headers it got. It fails its liveness probe while down is set.
+type echo struct {
+	*httptest.Server
+	down atomic.Bool
+}
+
+type echoed struct {
+	Instance string      `json:"instance"`
+	Path     string      `json:"path"`
+	Header   http.Header `json:"header"`
+}
+
+func newEcho(t *testing.T, name string) *echo {
+	t.Helper()
+
+	e := &echo{}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/livez" {
+			if e.down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Keep-Alive", "timeout=5")
+		json.NewEncoder(w).Encode(echoed{Instance: name, Path: r.URL.Path, Header: r.Header})
+	}))
+	t.Cleanup(e.Close)
+
+	return e
+}
+
+func TestUpstreamPool(t *testing.T) {
+	h := newHarness(t)
+	a, b := newEcho(t, "a"), newEcho(t, "b")
+
+	routeGateway(t, h, config.Config{}, fmt.Sprintf(`
+upstreams:
+  echo:
+    urls: [%s, %s]
+    health: /livez
+    health_interval: 20ms
+routes:
+  - name: echo
+    prefix: /echo
+    upstream: echo
+`, a.URL, b.URL))
+
+	// Round robin alternates between the instances
+	seen := make(map[string]int)
+	for range 4 {
+		var resp echoed
+		h.mustDo(http.StatusOK, http.MethodGet, "/echo/items", nil, nil, &resp)
+		seen[resp.Instance]++
+	}
+	if seen["a"] != 2 || seen["b"] != 2 {
+		t.Errorf("requests per instance = %v, want 2 each", seen)
+	}
+
+	// An instance that fails its checks leaves the rotation
+	a.down.Store(true)
+	waitFor(t, func() bool {
+		for range 4 {
+			var resp echoed
+			if h.do(http.MethodGet, "/echo", nil, nil, &resp) != http.StatusOK || resp.Instance != "b" {
+				return false
+			}
+		}
+		return true
+	})
+
+	// Without a healthy instance the gateway answers at once
+	b.down.Store(true)
+	waitFor(t, func() bool {
+		var resp problem.Problem
+		return h.do(http.MethodGet, "/echo", nil, nil, &resp) == http.StatusServiceUnavailable && resp.Code == problem.CodeServiceUnavailable
+	})
+
+	// And takes the instances back once they pass again
+	a.down.Store(false)
+	waitFor(t, func() bool {
+		return h.do(http.MethodGet, "/echo", nil, nil, nil) == http.StatusOK
+	})
+}
+
+func TestReverseProxyHeaders(t *testing.T) {
+	h := newHarness(t)
+	e := newEcho(t, "a")
+
+	routeGateway(t, h, config.Config{}, `
+upstreams:
+  echo:
+    url: `+e.URL+`
+routes:
+  - name: echo
+    prefix: /echo
+    upstream: echo
+`)
+
+	req, err := http.NewRequest(http.MethodGet, h.gateway.URL+"/echo/items?page=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "dropped")
+	req.Header.Set("Proxy-Authorization", "Basic dropped")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("X-Custom", "kept")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got echoed
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Path != "/echo/items" {
+		t.Errorf("path = %q", got.Path)
+	}
+	for _, name := range []string{"X-Hop", "Proxy-Authorization"} {
+		if v := got.Header.Get(name); v != "" {
+			t.Errorf("hop-by-hop header %s = %q was forwarded", name, v)
+		}
+	}
+	if got.Header.Get("X-Custom") != "kept" {
+		t.Errorf("X-Custom = %q", got.Header.Get("X-Custom"))
+	}
+	// The X-Forwarded-For of the client is not trusted, the gateway sets its own
+	if xff := got.Header.Get("X-Forwarded-For"); xff != "127.0.0.1" {
+		t.Errorf("X-Forwarded-For = %q, want 127.0.0.1", xff)
+	}
+	if host := got.Header.Get("X-Forwarded-Host"); host != strings.TrimPrefix(h.gateway.URL, "http://") {
+		t.Errorf("X-Forwarded-Host = %q", host)
+	}
+	if proto := got.Header.Get("X-Forwarded-Proto"); proto != "http" {
+		t.Errorf("X-Forwarded-Proto = %q", proto)
+	}
+	if got.Header.Get("X-Request-ID") == "" {
+		t.Error("no X-Request-ID forwarded")
+	}
+
+	if ids := resp.Header.Values("X-Request-ID"); len(ids) != 1 {
+		t.Errorf("X-Request-ID of the response = %v, want one", ids)
+	}
+	if v := resp.Header.Get("Keep-Alive"); v != "" {
+		t.Errorf("hop-by-hop response header Keep-Alive = %q was passed back", v)
+	}
+}
+
+// waitFor polls cond for up to a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met within a second")
+}
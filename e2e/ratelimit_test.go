@@ -0,0 +1,201 @@
+package e2e
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/pkg/apikey"
+	"api-gateway/pkg/postgres"
+	"api-gateway/pkg/postgres/postgrestest"
+	"api-gateway/pkg/problem"
+	"api-gateway/pkg/ratelimit"
+	"api-gateway/proxy"
+
+	"gopkg.in/yaml.v3"
+)
+
+// limitedRoutes lets partners with the orders scope reach the orders, three requests per
+// key, and anyone read the products, two requests per address.
+const limitedRoutes = `
+upstreams:
+  order-service:
+    url: ${E2E_ORDER_SERVICE}
+    openapi: /openapi.json
+  inventory-service:
+    url: ${E2E_INVENTORY_SERVICE}
+    openapi: /openapi.json
+routes:
+  - name: orders
+    prefix: /orders
+    upstream: order-service
+    middleware: [auth, ratelimit, validate]
+    scopes: [orders]
+    rate_limit:
+      key: {rate: 1/h, burst: 3}
+  - name: products
+    prefix: /products
+    methods: [GET]
+    upstream: inventory-service
+    middleware: [ratelimit]
+    rate_limit:
+      ip: {rate: 1/h, burst: 2}
+`
+
+// newKey generates a key for owner.
+func newKey(t *testing.T, owner string, scopes ...string) (string, apikey.Key) {
+	t.Helper()
+
+	raw, key, err := apikey.Generate(owner, scopes, []string{"customer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw, key
+}
+
+// keysFile writes keys to a file for the memory store and returns its path.
+func keysFile(t *testing.T, keys ...apikey.Key) string {
+	t.Helper()
+
+	data, err := yaml.Marshal(map[string][]apikey.Key{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func withKey(raw string) http.Header {
+	return http.Header{apikey.Header: {raw}}
+}
+
+func TestAPIKeysAndRateLimits(t *testing.T) {
+	h := newHarness(t)
+
+	acme, acmeKey := newKey(t, "acme", "orders")
+	second, secondKey := newKey(t, "acme", "orders")
+	catalog, catalogKey := newKey(t, "globex", "catalog")
+	revoked, revokedKey := newKey(t, "initech", "orders")
+	revokedAt := time.Now()
+	revokedKey.RevokedAt = &revokedAt
+
+	routeGateway(t, h, config.Config{
+		APIKeys:   apikey.Config{Store: apikey.StoreMemory, File: keysFile(t, acmeKey, secondKey, catalogKey, revokedKey)},
+		RateLimit: ratelimit.Config{Store: ratelimit.StoreMemory},
+	}, limitedRoutes)
+
+	refusals := []struct {
+		name   string
+		header http.Header
+		status int
+		code   string
+	}{
+		{"no key", nil, http.StatusUnauthorized, problem.CodeUnauthorized},
+		{"malformed key", withKey("not-a-key"), http.StatusUnauthorized, problem.CodeUnauthorized},
+		{"unknown key", withKey(acme + "x"), http.StatusUnauthorized, problem.CodeUnauthorized},
+		{"revoked key", withKey(revoked), http.StatusUnauthorized, problem.CodeUnauthorized},
+		{"key without the scope", withKey(catalog), http.StatusForbidden, problem.CodeForbidden},
+	}
+	for _, tt := range refusals {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, p := h.raw(http.MethodGet, "/orders/", tt.header)
+			if resp.StatusCode != tt.status || p.Code != tt.code {
+				t.Fatalf("status = %d, code = %q, want %d, %q", resp.StatusCode, p.Code, tt.status, tt.code)
+			}
+		})
+	}
+
+	// The owner of the key is who the services see
+	var placed struct {
+		Order placedOrder `json:"order"`
+	}
+	h.mustDo(http.StatusOK, http.MethodPost, "/orders/", map[string]any{"customer_name": "acme"}, withKey(acme), &placed)
+	var got struct {
+		Order order `json:"order"`
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, "/orders/"+strconv.FormatInt(placed.Order.OrderID, 10), nil, withKey(acme), &got)
+	if got.Order.CustomerID != "acme" {
+		t.Errorf("customer_id = %q, want acme", got.Order.CustomerID)
+	}
+
+	// Two of the three requests of the key are spent
+	resp, _ := h.raw(http.MethodGet, "/orders/", withKey(acme))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	for name, want := range map[string]string{"RateLimit-Limit": "3", "RateLimit-Remaining": "0", "RateLimit-Reset": "10800"} {
+		if v := resp.Header.Get(name); v != want {
+			t.Errorf("%s = %q, want %q", name, v, want)
+		}
+	}
+
+	resp, p := h.raw(http.MethodGet, "/orders/", withKey(acme))
+	if resp.StatusCode != http.StatusTooManyRequests || p.Code != problem.CodeRateLimited {
+		t.Fatalf("status = %d, code = %q", resp.StatusCode, p.Code)
+	}
+	if v := resp.Header.Get("Retry-After"); v != "3600" {
+		t.Errorf("Retry-After = %q, want 3600", v)
+	}
+	if v := resp.Header.Get("RateLimit-Remaining"); v != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", v)
+	}
+
+	// Every key has a bucket of its own
+	h.mustDo(http.StatusOK, http.MethodGet, "/orders/", nil, withKey(second), nil)
+
+	// Anonymous callers are counted by their address
+	h.mustDo(http.StatusOK, http.MethodGet, "/products/", nil, nil, nil)
+	h.mustDo(http.StatusOK, http.MethodGet, "/products/", nil, withKey(acme), nil)
+	h.mustDo(http.StatusTooManyRequests, http.MethodGet, "/products/", nil, nil, nil)
+}
+
+// TestRateLimitsInPostgres runs two gateways on one database: they share the keys and the
+// buckets.
+func TestRateLimitsInPostgres(t *testing.T) {
+	dsn := postgrestest.DSN(t)
+	h := newHarness(t)
+
+	acme, acmeKey := newKey(t, "acme", "orders")
+
+	pg := postgres.Config{Dsn: dsn, MaxOpenConns: 4, MaxIdleTime: "1m", MigrateOnStart: true}
+	cfg := config.Config{
+		APIKeys:   apikey.Config{Store: apikey.StorePostgres, CacheTTL: time.Minute},
+		RateLimit: ratelimit.Config{Store: ratelimit.StorePostgres},
+		Postgres:  pg,
+	}
+	_, path := routeGateway(t, h, cfg, limitedRoutes)
+
+	db, err := postgres.New(context.Background(), pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Pool.Close)
+	if err := apikey.NewPostgres(db.Pool, 0).Create(context.Background(), acmeKey); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Routes.File = path
+	replica, err := proxy.New(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(replica.Close)
+	other := &harness{t: t, gateway: httptest.NewServer(replica.Handler())}
+	t.Cleanup(other.gateway.Close)
+
+	h.mustDo(http.StatusOK, http.MethodGet, "/orders/", nil, withKey(acme), nil)
+	other.mustDo(http.StatusOK, http.MethodGet, "/orders/", nil, withKey(acme), nil)
+	h.mustDo(http.StatusOK, http.MethodGet, "/orders/", nil, withKey(acme), nil)
+	other.mustDo(http.StatusTooManyRequests, http.MethodGet, "/orders/", nil, withKey(acme), nil)
+
+	h.mustDo(http.StatusUnauthorized, http.MethodGet, "/orders/", nil, withKey(acme+"x"), nil)
+}
@@ -0,0 +1,222 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/problem"
+	"api-gateway/proxy"
+)
+
+// routes is a route file that moves the orders under /shop and leaves products alone.
+const routes = `
+upstreams:
+  order-service:
+    url: ${E2E_ORDER_SERVICE}
+    openapi: /openapi.json
+    health: /livez
+  inventory-service:
+    url: ${E2E_INVENTORY_SERVICE}
+    openapi: /openapi.json
+routes:
+  - name: orders
+    path: /shop/orders/{rest...}
+    methods: [GET, POST, PATCH]
+    upstream: order-service
+    rewrite: /orders/{rest...}
+    middleware: [auth, validate]
+  - name: products
+    prefix: /products
+    upstream: inventory-service
+    middleware: [validate]
+  - name: order-service-probes
+    path: /services/orders/{probe}
+    methods: [GET]
+    upstream: order-service
+    strip_prefix: /services/orders
+`
+
+// routeGateway serves h through a gateway with cfg that reads its routes from a file, and
+// returns the gateway with the path of the file.
+func routeGateway(t *testing.T, h *harness, cfg config.Config, file string) (*proxy.Gateway, string) {
+	t.Helper()
+
+	t.Setenv("E2E_ORDER_SERVICE", h.orders.URL)
+	t.Setenv("E2E_INVENTORY_SERVICE", h.inventory.URL)
+
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeRoutes(t, path, file)
+
+	cfg.Routes.File = path
+	gw, err := proxy.New(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(gw.Close)
+	h.gateway = httptest.NewServer(gw.Handler())
+	t.Cleanup(h.gateway.Close)
+
+	return gw, path
+}
+
+func TestRouteFile(t *testing.T) {
+	h := newHarness(t)
+	gw, path := routeGateway(t, h, config.Config{Auth: auth.Config{Secret: authSecret}}, routes)
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+
+	var placed struct {
+		Order placedOrder `json:"order"`
+	}
+	h.mustDo(http.StatusOK, http.MethodPost, "/shop/orders/", map[string]any{
+		"customer_name": "alice",
+		"items":         []orderItem{{keyboard, 2}},
+	}, h.staff, &placed)
+
+	var got struct {
+		Order order `json:"order"`
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, fmt.Sprintf("/shop/orders/%d", placed.Order.OrderID), nil, h.staff, &got)
+	if got.Order.CustomerName != "alice" {
+		t.Errorf("order = %+v", got.Order)
+	}
+
+	// The rewritten path is still validated against the document of order-service
+	var invalid problem.Problem
+	h.mustDo(http.StatusUnprocessableEntity, http.MethodPatch, fmt.Sprintf("/shop/orders/%d", placed.Order.OrderID), map[string]any{"status": "shipped"}, h.staff, &invalid)
+	if invalid.Code != problem.CodeValidationFailed {
+		t.Errorf("invalid status code = %q", invalid.Code)
+	}
+
+	h.mustDo(http.StatusOK, http.MethodGet, "/services/orders/livez", nil, nil, nil)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{http.MethodGet, "/orders/1", http.StatusNotFound, problem.CodeRouteNotFound},
+		{http.MethodDelete, "/shop/orders/1", http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed},
+		{http.MethodPost, "/services/orders/livez", http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		var resp problem.Problem
+		h.mustDo(tt.status, tt.method, tt.path, nil, nil, &resp)
+		if resp.Code != tt.code {
+			t.Errorf("%s %s code = %q, want %q", tt.method, tt.path, resp.Code, tt.code)
+		}
+	}
+
+	// An invalid file is refused, the routes in use stay
+	writeRoutes(t, path, strings.Replace(routes, "upstream: inventory-service", "upstream: catalog", 1))
+	if err := gw.Reload(); err == nil || !strings.Contains(err.Error(), `unknown upstream "catalog"`) {
+		t.Errorf("reload of an invalid file = %v", err)
+	}
+	h.mustDo(http.StatusOK, http.MethodGet, fmt.Sprintf("/products/%d", keyboard), nil, nil, nil)
+
+	// A valid one replaces them
+	writeRoutes(t, path, strings.Replace(routes, "prefix: /products", "prefix: /catalog/products\n    strip_prefix: /catalog", 1))
+	if err := gw.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	h.mustDo(http.StatusNotFound, http.MethodGet, fmt.Sprintf("/products/%d", keyboard), nil, nil, nil)
+	h.mustDo(http.StatusOK, http.MethodGet, fmt.Sprintf("/catalog/products/%d", keyboard), nil, nil, nil)
+}
+
+func TestRouteTimeout(t *testing.T) {
+	h := newHarness(t)
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(slow.Close)
+
+	routeGateway(t, h, config.Config{}, `
+upstreams:
+  slow:
+    url: `+slow.URL+`
+routes:
+  - name: slow
+    prefix: /slow
+    upstream: slow
+    timeout: 50ms
+`)
+
+	var resp problem.Problem
+	h.mustDo(http.StatusGatewayTimeout, http.MethodGet, "/slow", nil, nil, &resp)
+	if resp.Code != problem.CodeGatewayTimeout {
+		t.Errorf("code = %q, want %q", resp.Code, problem.CodeGatewayTimeout)
+	}
+}
+
+func TestParseRoutesReportsEveryMistake(t *testing.T) {
+	t.Setenv("E2E_ORDER_SERVICE", "http://localhost:8081")
+	t.Setenv("E2E_INVENTORY_SERVICE", "http://localhost:8082")
+
+	_, err := proxy.ParseRoutes([]byte(routes + `
+  - name: orders
+    path: /shop/{id}/{id}
+    upstream: order-service
+  - name: archive
+    prefix: /archive
+    upstream: order-service
+    rewrite: /orders/{id}
+    strip_prefix: /arch
+    middleware: [compress]
+  - name: invoice
+    path: /views/invoices/{id}
+    upstream: order-service
+    view: {name: invoice, sources: {products: catalog}}
+  - name: order-view
+    path: /views/orders/{id}
+    methods: [GET, POST]
+    upstream: order-service
+    view: {name: order, sources: {products: inventory-service}}
+`))
+	if err == nil {
+		t.Fatal("no error")
+	}
+
+	for _, want := range []string{
+		"name orders is taken",
+		"parameter {id} twice",
+		"strip_prefix and rewrite exclude each other",
+		`strip_prefix "/arch" does not start the route`,
+		"rewrite needs a path",
+		`unknown middleware "compress"`,
+		`unknown view "invoice"`,
+		`source products: unknown upstream "catalog"`,
+		"view order answers GET and HEAD only",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not report %q", err, want)
+		}
+	}
+
+	if _, err := proxy.ParseRoutes([]byte("upstreams:\n  a:\n    url: ${E2E_UNSET_SERVICE}\n")); err == nil || !strings.Contains(err.Error(), "E2E_UNSET_SERVICE") {
+		t.Errorf("unset variable: %v", err)
+	}
+	if _, err := proxy.ParseRoutes([]byte("upstream:\n  a:\n    url: http://localhost\n")); err == nil {
+		t.Error("unknown field: no error")
+	}
+}
+
+func writeRoutes(t *testing.T, path, file string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,84 @@
+package e2e
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spansOnce sync.Once
+	spans     *tracetest.SpanRecorder
+)
+
+// recordSpans records the spans of every tracer from now on. The global tracer provider
+// only hands over the tracers created before it is set once, so every test of the process
+// shares one recorder and tells its spans apart by trace ID.
+func recordSpans() *tracetest.SpanRecorder {
+	spansOnce.Do(func() {
+		spans = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spans
+}
+
+func TestTracing(t *testing.T) {
+	recorder := recordSpans()
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 5)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	header := h.staff.Clone()
+	header.Set("Traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	h.mustDo(http.StatusOK, http.MethodPost, "/orders/", map[string]any{
+		"customer_name": "alice",
+		"items":         []orderItem{{keyboard, 2}},
+	}, header, nil)
+
+	// The server spans end after the response is written, give them a moment.
+	want := []string{
+		"POST /*proxyPath",
+		"POST /orders/",
+		"Order.Create",
+		"Order.takeStock",
+		"Inventory.GetMany",
+		"Inventory.DecrementMany",
+	}
+	var names []string
+	for deadline := time.Now().Add(time.Second); ; {
+		names = names[:0]
+		for _, span := range recorder.Ended() {
+			if span.SpanContext().TraceID().String() == traceID {
+				names = append(names, span.Name())
+			}
+		}
+		if containsAll(names, want) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, name := range want {
+		if !slices.Contains(names, name) {
+			t.Errorf("trace has no span %q, it has %q", name, names)
+		}
+	}
+}
+
+func containsAll(names, want []string) bool {
+	for _, name := range want {
+		if !slices.Contains(names, name) {
+			return false
+		}
+	}
+	return true
+}
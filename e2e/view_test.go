@@ -0,0 +1,105 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"api-gateway/config"
+	"api-gateway/pkg/auth"
+	"api-gateway/pkg/problem"
+)
+
+// orderView is the order view of the gateway.
+type orderView struct {
+	Order struct {
+		OrderID int64 `json:"order_id"`
+		Items   []struct {
+			ProductID int64    `json:"product_id"`
+			Quantity  int64    `json:"quantity"`
+			Product   *product `json:"product"`
+		} `json:"items"`
+	} `json:"order"`
+	Partial bool `json:"partial"`
+	Errors  []struct {
+		Source string `json:"source"`
+		ID     string `json:"id"`
+		Detail string `json:"detail"`
+	} `json:"errors"`
+}
+
+func TestOrderView(t *testing.T) {
+	h := newHarness(t)
+
+	keyboard := h.createProduct("keyboard", 40, 10)
+	mouse := h.createProduct("mouse", 15, 10)
+	placed := h.placeOrder("alice", orderItem{keyboard, 1}, orderItem{mouse, 2})
+	path := fmt.Sprintf("/views/orders/%d", placed.OrderID)
+
+	var view orderView
+	h.mustDo(http.StatusOK, http.MethodGet, path, nil, h.staff, &view)
+	if view.Partial || len(view.Errors) > 0 || len(view.Order.Items) != 2 {
+		t.Fatalf("view = %+v, want the two lines and no errors", view)
+	}
+	for _, item := range view.Order.Items {
+		if item.Product == nil || item.Product.ID != item.ProductID {
+			t.Errorf("line of product %d carries %+v", item.ProductID, item.Product)
+		}
+	}
+	if name := view.Order.Items[1].Product.Name; name != "mouse" || view.Order.Items[1].Quantity != 2 {
+		t.Errorf("second line = %s x %d, want mouse x 2", name, view.Order.Items[1].Quantity)
+	}
+
+	// The errors of order-service are those of the view
+	var p problem.Problem
+	h.mustDo(http.StatusNotFound, http.MethodGet, "/views/orders/999999", nil, h.staff, &p)
+	if p.Code == "" {
+		t.Errorf("problem = %+v, want the one of order-service", p)
+	}
+
+	// Without the products the order is still shown
+	var requests []string
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(broken.Close)
+
+	routeGateway(t, h, config.Config{Auth: auth.Config{Secret: authSecret}}, `
+upstreams:
+  order-service:
+    url: ${E2E_ORDER_SERVICE}
+  inventory-service:
+    url: `+broken.URL+`
+routes:
+  - name: order-view
+    path: /views/orders/{id}
+    methods: [GET]
+    upstream: order-service
+    rewrite: /orders/{id}
+    middleware: [auth]
+    view:
+      name: order
+      sources: {products: inventory-service}
+`)
+
+	view = orderView{}
+	h.mustDo(http.StatusOK, http.MethodGet, path, nil, h.staff, &view)
+	if !view.Partial || len(view.Errors) != 1 || len(view.Order.Items) != 2 || view.Order.OrderID != placed.OrderID {
+		t.Fatalf("view = %+v, want the order with one error", view)
+	}
+	for _, item := range view.Order.Items {
+		if item.Product != nil {
+			t.Errorf("line of product %d carries %+v, want none", item.ProductID, item.Product)
+		}
+	}
+	// The products are read in one batch, which failed as a whole
+	if e := view.Errors[0]; e.Source != "products" || e.ID != "" || e.Detail != "inventory-service answered 500" {
+		t.Errorf("error = %+v", e)
+	}
+	if !slices.Equal(requests, []string{"POST /products/batch/get"}) {
+		t.Errorf("requests to inventory-service = %v, want one batch", requests)
+	}
+}
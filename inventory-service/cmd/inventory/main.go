@@ -4,7 +4,11 @@ import (
 	"context"
 	"inventory-service/config"
 	"inventory-service/internal/app"
-	"log"
+	"inventory-service/pkg/logger"
+	"inventory-service/pkg/tracing"
+	"log/slog"
+	"os"
+	"time"
 )
 
 func main() {
@@ -13,21 +17,57 @@ func main() {
 	// Parse config
 	cfg, err := config.New()
 	if err != nil {
-		log.Printf("failed to parse config: %v", err)
+		slog.Error("failed to parse config", "error", err)
 
 		return
 	}
 
+	// Setup logger, the log package writes through it as well
+	log, err := logger.New(os.Stdout, cfg.Log, "inventory-service")
+	if err != nil {
+		slog.Error("failed to setup logger", "error", err)
+
+		return
+	}
+	slog.SetDefault(log)
+
+	// The migrate subcommand changes the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(ctx, cfg.Postgres, os.Args[2:]); err != nil {
+			slog.Error("failed to migrate", "error", err)
+
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	// Setup tracing, the spans left are flushed on exit
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, "inventory-service", cfg.Version)
+	if err != nil {
+		slog.Error("failed to setup tracing", "error", err)
+
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
+
 	application, err := app.New(ctx, cfg)
 	if err != nil {
-		log.Println("failed to setup application:", err)
+		slog.Error("failed to setup application", "error", err)
 
 		return
 	}
 
 	err = application.Run()
 	if err != nil {
-		log.Println("failed to run application: ", err)
+		slog.Error("failed to run application", "error", err)
 
 		return
 	}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"inventory-service/migrations"
+	"inventory-service/pkg/postgres"
+)
+
+const migrateUsage = "usage: migrate up | down [N] | status | force VERSION"
+
+// migrate runs the migrate subcommand with args, the arguments after migrate.
+func migrate(ctx context.Context, cfg postgres.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := postgres.New(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("postgres: %w", err)
+	}
+	defer db.Pool.Close()
+
+	m, err := postgres.NewMigrator(db.Pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		return m.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid number of steps: %q", args[1])
+			}
+		}
+		return m.Down(steps)
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d\ndirty: %t\nlatest: %d\npending: %d\n", status.Version, status.Dirty, status.Latest, status.Pending)
+		return nil
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version: %q", args[1])
+		}
+		return m.Force(version)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -1,27 +1,36 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/config"
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/controller"
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/entity"
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/repository"
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/usecase"
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/pkg/logger"
+	"inventory-service/internal/config"
+	"inventory-service/internal/controller"
+	"inventory-service/internal/entity"
+	"inventory-service/internal/repository"
+	"inventory-service/internal/usecase"
+	"inventory-service/migrations"
+	"inventory-service/pkg/authz"
+	"inventory-service/pkg/logger"
+	"inventory-service/pkg/problem"
+	"inventory-service/pkg/requestid"
 )
 
-func runDBMigration(migrationURL string, dbSource string) error {
-	migration, err := migrate.New(migrationURL, dbSource)
+func runDBMigration(dbSource string) error {
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return err
+	}
+
+	migration, err := migrate.NewWithSourceInstance("iofs", source, dbSource)
 	if err != nil {
 		return err
 	}
@@ -36,33 +45,36 @@ func runDBMigration(migrationURL string, dbSource string) error {
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+		slog.Info("No .env file found")
 	}
 
-	// Initialize logger
-	log := logger.NewLogger()
-
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Error loading config: %v", err)
+		fatal("Error loading config", err)
 	}
 
-	// Run DB migration first (before GORM)
-	migrationURL := "file://migrations"
-	if err := runDBMigration(migrationURL, cfg.DatabaseURL); err != nil {
-		log.Fatalf("cannot run db migration: %v", err)
+	// Initialize logger
+	log, err := logger.New(os.Stdout, cfg.Log, "inventory-service")
+	if err != nil {
+		fatal("Error initializing logger", err)
+	}
+	slog.SetDefault(log)
+
+	// Run DB migration first (before GORM), the migrations are embedded in the binary
+	if err := runDBMigration(cfg.DatabaseURL); err != nil {
+		fatal("cannot run db migration", err)
 	}
 
 	// Initialize database
 	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 
 	// Migrate models (GORM auto-migration)
 	if err := db.AutoMigrate(&entity.Product{}, &entity.Category{}); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		fatal("Failed to migrate database", err)
 	}
 
 	// Initialize repositories
@@ -81,28 +93,33 @@ func main() {
 	r := gin.New()
 
 	// Middlewares
+	r.Use(requestid.Middleware())
+	r.Use(logger.Middleware())
+	r.Use(authz.Middleware())
 	r.Use(gin.Recovery())
-	r.Use(logger.GinLogger(log))
+	r.NoRoute(problem.NoRoute)
+
+	// Routes, the catalog is open to read and only staff change it
+	staff := authz.New(cfg.Authz).Require(authz.RoleAdmin, authz.RoleStaff)
 
-	// Routes
 	v1 := r.Group("/api/v1")
 	{
 		products := v1.Group("/products")
 		{
-			products.POST("", productController.CreateProduct)
+			products.POST("", staff, productController.CreateProduct)
 			products.GET("", productController.ListProducts)
 			products.GET("/:id", productController.GetProduct)
-			products.PATCH("/:id", productController.UpdateProduct)
-			products.DELETE("/:id", productController.DeleteProduct)
+			products.PATCH("/:id", staff, productController.UpdateProduct)
+			products.DELETE("/:id", staff, productController.DeleteProduct)
 		}
 
 		categories := v1.Group("/categories")
 		{
-			categories.POST("", categoryController.Create)
+			categories.POST("", staff, categoryController.Create)
 			categories.GET("", categoryController.List)
 			categories.GET("/:id", categoryController.Get)
-			categories.PATCH("/:id", categoryController.Update)
-			categories.DELETE("/:id", categoryController.Delete)
+			categories.PATCH("/:id", staff, categoryController.Update)
+			categories.DELETE("/:id", staff, categoryController.Delete)
 		}
 	}
 
@@ -116,8 +133,13 @@ func main() {
 	if port == "" {
 		port = "8001"
 	}
-	log.Infof("Starting Inventory Service on port %s", port)
+	log.Info("Starting Inventory Service", "port", port)
 	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		fatal("Failed to start server", err)
 	}
 }
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"inventory-service/pkg/authz"
+	"inventory-service/pkg/health"
+	"inventory-service/pkg/logger"
 	"inventory-service/pkg/postgres"
+	"inventory-service/pkg/tracing"
 	"time"
 
 	"github.com/caarlos0/env/v10"
@@ -12,6 +16,9 @@ type (
 	Config struct {
 		Postgres postgres.Config
 		Server   Server
+		Log      logger.Config
+		Tracing  tracing.Config
+		Health   health.Config
 
 		Version string `env:"VERSION"`
 	}
@@ -19,6 +26,14 @@ type (
 	// We can have multiple servers like gRPC or smth else.
 	Server struct {
 		HTTPServer HTTPServer
+		GRPCServer GRPCServer
+		Authz      authz.Config // Checked on HTTP only, gRPC is not exposed outside the shop
+
+		// On shutdown the readiness probe fails for ShutdownDelay first, so that load
+		// balancers stop sending requests. In-flight requests then get ShutdownTimeout to
+		// finish, the rest is cut off.
+		ShutdownDelay   time.Duration `env:"SHUTDOWN_DELAY" envDefault:"0s"`
+		ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s"`
 	}
 
 	HTTPServer struct {
@@ -30,6 +45,12 @@ type (
 		TrustedProxies []string      `env:"HTTP_TRUSTED_PROXIES" envSeparator:","`
 		Mode           string        `env:"GIN_MODE" envDefault:"release"` // Can be: release, debug, test
 	}
+
+	// GRPCServer serves the service-to-service API defined in proto/inventory/v1.
+	GRPCServer struct {
+		Port           int           `env:"GRPC_PORT" envDefault:"9082"`
+		RequestTimeout time.Duration `env:"GRPC_REQUEST_TIMEOUT" envDefault:"30s"` // Calls with a later or no deadline get this one
+	}
 )
 
 func New() (*Config, error) {
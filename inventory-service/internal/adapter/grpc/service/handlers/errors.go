@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"inventory-service/internal/adapter/http/service/handlers/dto"
+	"inventory-service/internal/adapter/postgres/dao"
+	"inventory-service/pkg/validator"
+	"log/slog"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fromError maps use case errors to gRPC statuses, the same way dto.FromError maps them
+// to HTTP responses.
+func fromError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, pgx.ErrNoRows), errors.Is(err, dao.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "the requested resource could not be found")
+	case errors.Is(err, dto.ErrInvalidFilters), errors.Is(err, dto.ErrUnprocessableEntity):
+		return status.Error(codes.InvalidArgument, "unprocessable entity")
+	case errors.Is(err, dto.ErrEditConflict), errors.Is(err, dao.ErrEditConflict):
+		return status.Error(codes.Aborted, "unable to update the record due to an edit conflict")
+	case errors.Is(err, dao.ErrInsufficientStock):
+		return status.Error(codes.FailedPrecondition, "not enough stock available")
+	default:
+		slog.ErrorContext(ctx, "call failed", "error", err)
+		return status.Error(codes.Internal, "something went wrong")
+	}
+}
+
+// invalidArgument reports the validation errors as field violations.
+func invalidArgument(v *validator.Validator) error {
+	fields := make([]string, 0, len(v.Errors))
+	for field := range v.Errors {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	details := &errdetails.BadRequest{}
+	for _, field := range fields {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: v.Errors[field],
+		})
+	}
+
+	st, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(details)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid request")
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"context"
+	"inventory-service/internal/adapter/http/service/handlers/dto"
+	"inventory-service/internal/models"
+)
+
+type InventoryUsecase interface {
+	Get(ctx context.Context, id int64) (models.Inventory, error)
+	GetListInventory(ctx context.Context, filters models.Filters) ([]models.Inventory, dto.Metadata, error)
+	GetMany(ctx context.Context, ids []int64) ([]models.Inventory, error)
+	DecrementMany(ctx context.Context, changes []models.StockChange) ([]models.StockChangeResult, bool, error)
+	Adjust(ctx context.Context, adj models.StockAdjustment) (models.StockAdjustmentResult, error)
+}
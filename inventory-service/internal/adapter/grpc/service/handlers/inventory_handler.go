@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"inventory-service/internal/adapter/grpc/service/handlers/pbdto"
+	"inventory-service/internal/adapter/http/service/handlers/dto"
+	"inventory-service/internal/models"
+	"inventory-service/pkg/inventorypb"
+	"inventory-service/pkg/validator"
+)
+
+// Inventory serves the same use cases as the HTTP handlers, with the same validation.
+type Inventory struct {
+	inventorypb.UnimplementedInventoryServiceServer
+
+	invUseCase InventoryUsecase
+}
+
+func NewInventory(invUseCase InventoryUsecase) *Inventory {
+	return &Inventory{invUseCase: invUseCase}
+}
+
+func (h *Inventory) GetProduct(ctx context.Context, req *inventorypb.GetProductRequest) (*inventorypb.GetProductResponse, error) {
+	v := validator.New()
+	if v.Check(req.GetId() > 0, "id", "must be greater than zero"); !v.Valid() {
+		return nil, invalidArgument(v)
+	}
+
+	inventory, err := h.invUseCase.Get(ctx, req.GetId())
+	if err != nil {
+		return nil, fromError(ctx, err)
+	}
+
+	return &inventorypb.GetProductResponse{Product: pbdto.ToProduct(inventory)}, nil
+}
+
+func (h *Inventory) ListProducts(ctx context.Context, req *inventorypb.ListProductsRequest) (*inventorypb.ListProductsResponse, error) {
+	filters := pbdto.ToFilters(req)
+
+	v := validator.New()
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		return nil, invalidArgument(v)
+	}
+
+	items, metadata, err := h.invUseCase.GetListInventory(ctx, filters)
+	if err != nil {
+		return nil, fromError(ctx, err)
+	}
+
+	return &inventorypb.ListProductsResponse{
+		Products: pbdto.ToProducts(items),
+		Metadata: pbdto.ToMetadata(metadata),
+	}, nil
+}
+
+func (h *Inventory) BatchGetProducts(ctx context.Context, req *inventorypb.BatchGetProductsRequest) (*inventorypb.BatchGetProductsResponse, error) {
+	v := validator.New()
+	if dto.ValidateBatchGet(v, req.GetIds()); !v.Valid() {
+		return nil, invalidArgument(v)
+	}
+
+	items, err := h.invUseCase.GetMany(ctx, req.GetIds())
+	if err != nil {
+		return nil, fromError(ctx, err)
+	}
+
+	return &inventorypb.BatchGetProductsResponse{
+		Products:   pbdto.ToProducts(items),
+		MissingIds: pbdto.MissingIDs(req.GetIds(), items),
+	}, nil
+}
+
+// ReserveStock reports a refused batch in the response, like the 409 of the HTTP API
+// still carries the result of every change.
+func (h *Inventory) ReserveStock(ctx context.Context, req *inventorypb.ReserveStockRequest) (*inventorypb.ReserveStockResponse, error) {
+	changes := pbdto.ToStockChanges(req)
+
+	v := validator.New()
+	if dto.ValidateBatchDecrement(v, changes); !v.Valid() {
+		return nil, invalidArgument(v)
+	}
+
+	results, applied, err := h.invUseCase.DecrementMany(ctx, changes)
+	if err != nil {
+		return nil, fromError(ctx, err)
+	}
+
+	return &inventorypb.ReserveStockResponse{
+		Applied: applied,
+		Results: pbdto.ToStockChangeResults(results),
+	}, nil
+}
+
+func (h *Inventory) ReleaseStock(ctx context.Context, req *inventorypb.ReleaseStockRequest) (*inventorypb.ReleaseStockResponse, error) {
+	adj := pbdto.ToReleaseAdjustment(req)
+
+	v := validator.New()
+	v.Check(req.GetProductId() > 0, "product_id", "must be greater than zero")
+	v.Check(req.GetQuantity() > 0, "quantity", "must be greater than zero")
+	if dto.ValidateAdjustment(v, adj); !v.Valid() {
+		return nil, invalidArgument(v)
+	}
+
+	result, err := h.invUseCase.Adjust(ctx, adj)
+	if err != nil {
+		return nil, fromError(ctx, err)
+	}
+
+	return &inventorypb.ReleaseStockResponse{Adjustment: pbdto.FromAdjustmentResult(result)}, nil
+}
+
+func (h *Inventory) AdjustStock(ctx context.Context, req *inventorypb.AdjustStockRequest) (*inventorypb.AdjustStockResponse, error) {
+	adj := pbdto.ToAdjustment(req)
+
+	v := validator.New()
+	v.Check(req.GetProductId() > 0, "product_id", "must be greater than zero")
+	if dto.ValidateAdjustment(v, adj); !v.Valid() {
+		return nil, invalidArgument(v)
+	}
+
+	result, err := h.invUseCase.Adjust(ctx, adj)
+	if err != nil {
+		return nil, fromError(ctx, err)
+	}
+
+	return &inventorypb.AdjustStockResponse{Adjustment: pbdto.FromAdjustmentResult(result)}, nil
+}
@@ -0,0 +1,159 @@
+package pbdto
+
+import (
+	"inventory-service/internal/adapter/http/service/handlers/dto"
+	"inventory-service/internal/adapter/postgres/dao"
+	"inventory-service/internal/models"
+	"inventory-service/pkg/inventorypb"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var stockChangeStatuses = map[string]inventorypb.StockChangeStatus{
+	models.StockChangeApplied:           inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_APPLIED,
+	models.StockChangeInsufficientStock: inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_INSUFFICIENT_STOCK,
+	models.StockChangeNotFound:          inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_NOT_FOUND,
+	models.StockChangeSkipped:           inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_SKIPPED,
+}
+
+func ToProduct(inv models.Inventory) *inventorypb.Product {
+	return &inventorypb.Product{
+		Id:          inv.ID,
+		Name:        inv.Name,
+		Description: inv.Description,
+		Price:       inv.Price,
+		Available:   inv.Available,
+		CreatedAt:   timestamppb.New(inv.CreatedAt),
+		Version:     inv.Version,
+	}
+}
+
+func ToProducts(invs []models.Inventory) []*inventorypb.Product {
+	products := make([]*inventorypb.Product, 0, len(invs))
+	for _, v := range invs {
+		products = append(products, ToProduct(v))
+	}
+
+	return products
+}
+
+// ToFilters applies the same defaults as the query parameters of the HTTP API.
+func ToFilters(req *inventorypb.ListProductsRequest) models.Filters {
+	filters := models.Filters{
+		Page:         1,
+		PageSize:     8,
+		Sort:         "id",
+		SortSafelist: dao.SafeSortList,
+	}
+
+	if req.GetPage() != 0 {
+		filters.Page = int(req.GetPage())
+	}
+	if req.GetPageSize() != 0 {
+		filters.PageSize = int(req.GetPageSize())
+	}
+	if req.GetSort() != "" {
+		filters.Sort = req.GetSort()
+	}
+
+	return filters
+}
+
+func ToMetadata(metadata dto.Metadata) *inventorypb.Metadata {
+	return &inventorypb.Metadata{
+		CurrentPage:  int32(metadata.CurrentPage),
+		PageSize:     int32(metadata.PageSize),
+		FirstPage:    int32(metadata.FirstPage),
+		LastPage:     int32(metadata.LastPage),
+		TotalRecords: int32(metadata.TotalRecords),
+	}
+}
+
+// MissingIDs returns the requested IDs that are not among the found items, each once.
+func MissingIDs(ids []int64, invs []models.Inventory) []int64 {
+	found := make(map[int64]bool, len(invs))
+	for _, v := range invs {
+		found[v.ID] = true
+	}
+
+	missing := []int64{}
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+			found[id] = true
+		}
+	}
+
+	return missing
+}
+
+// ToStockChanges uses reason models.AdjustmentReasonOrderPlaced unless the request names one.
+func ToStockChanges(req *inventorypb.ReserveStockRequest) []models.StockChange {
+	reason := req.GetReason()
+	if reason == "" {
+		reason = models.AdjustmentReasonOrderPlaced
+	}
+
+	changes := make([]models.StockChange, 0, len(req.GetItems()))
+	for _, v := range req.GetItems() {
+		changes = append(changes, models.StockChange{
+			ID:        v.GetProductId(),
+			Quantity:  v.GetQuantity(),
+			Reason:    reason,
+			Reference: req.GetReference(),
+		})
+	}
+
+	return changes
+}
+
+func ToStockChangeResults(results []models.StockChangeResult) []*inventorypb.StockChangeResult {
+	responce := make([]*inventorypb.StockChangeResult, 0, len(results))
+	for _, v := range results {
+		responce = append(responce, &inventorypb.StockChangeResult{
+			ProductId: v.ID,
+			Quantity:  v.Quantity,
+			Status:    stockChangeStatuses[v.Status],
+			Available: v.Available,
+			Version:   v.Version,
+			Replayed:  v.Replayed,
+		})
+	}
+
+	return responce
+}
+
+// ToReleaseAdjustment uses reason models.AdjustmentReasonOrderReleased unless the request
+// names one.
+func ToReleaseAdjustment(req *inventorypb.ReleaseStockRequest) models.StockAdjustment {
+	reason := req.GetReason()
+	if reason == "" {
+		reason = models.AdjustmentReasonOrderReleased
+	}
+
+	return models.StockAdjustment{
+		ID:        req.GetProductId(),
+		Delta:     req.GetQuantity(),
+		Reason:    reason,
+		Reference: req.GetReference(),
+	}
+}
+
+func ToAdjustment(req *inventorypb.AdjustStockRequest) models.StockAdjustment {
+	return models.StockAdjustment{
+		ID:        req.GetProductId(),
+		Delta:     req.GetDelta(),
+		Reason:    req.GetReason(),
+		Reference: req.GetReference(),
+	}
+}
+
+func FromAdjustmentResult(result models.StockAdjustmentResult) *inventorypb.Adjustment {
+	return &inventorypb.Adjustment{
+		ProductId: result.ID,
+		Delta:     result.Delta,
+		Available: result.Available,
+		Version:   result.Version,
+		Replayed:  result.Replayed,
+	}
+}
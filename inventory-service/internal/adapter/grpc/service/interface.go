@@ -0,0 +1,7 @@
+package service
+
+import "inventory-service/internal/adapter/grpc/service/handlers"
+
+type InventoryUsecase interface {
+	handlers.InventoryUsecase
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inventory-service/config"
+	"inventory-service/internal/adapter/grpc/service/handlers"
+	"inventory-service/pkg/inventorypb"
+	"inventory-service/pkg/requestid"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const serverIPAddress = "127.0.0.1:%d"
+
+var (
+	calls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Number of gRPC calls served, by method and status code.",
+	}, []string{"method", "code"})
+	callDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Time taken to serve gRPC calls, by method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+)
+
+type API struct {
+	server *grpc.Server
+	health *health.Server
+	cfg    config.GRPCServer
+	addr   string
+
+	inventoryHandler *handlers.Inventory
+}
+
+func New(cfg config.Server, inventoryUseCase InventoryUsecase) *API {
+	api := &API{
+		cfg:              cfg.GRPCServer,
+		addr:             fmt.Sprintf(serverIPAddress, cfg.GRPCServer.Port),
+		health:           health.NewServer(),
+		inventoryHandler: handlers.NewInventory(inventoryUseCase),
+	}
+
+	// Applying interceptors, the outermost first
+	api.server = grpc.NewServer(grpc.StatsHandler(otelgrpc.NewServerHandler()), grpc.ChainUnaryInterceptor(
+		requestID,
+		logger,
+		metrics,
+		recovery,
+		api.deadline,
+	))
+
+	inventorypb.RegisterInventoryServiceServer(api.server, api.inventoryHandler)
+
+	// Clients check the standard health service, it reports NOT_SERVING once Stop is called
+	healthpb.RegisterHealthServer(api.server, api.health)
+
+	return api
+}
+
+// Stop stops accepting connections and waits for the calls in flight to finish. The ones
+// still running when ctx is done are cut off.
+func (a *API) Stop(ctx context.Context) error {
+	a.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		a.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		a.server.Stop()
+		return fmt.Errorf("gRPC server shutdown: %w", ctx.Err())
+	}
+}
+
+func (a *API) Run(errCh chan<- error) {
+	go func() {
+		slog.Info("gRPC server starting", "addr", a.addr)
+
+		lis, err := net.Listen("tcp", a.addr)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to listen for gRPC server: %w", err)
+			return
+		}
+
+		if err := a.Serve(lis); err != nil {
+			errCh <- fmt.Errorf("failed to start gRPC server: %w", err)
+			return
+		}
+	}()
+}
+
+// Serve accepts connections on lis until Stop is called. Tests serve on an in-memory
+// listener with it.
+func (a *API) Serve(lis net.Listener) error {
+	err := a.server.Serve(lis)
+	if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+
+	return nil
+}
+
+// deadline bounds every call by cfg.RequestTimeout, a caller may only ask for less.
+func (a *API) deadline(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.RequestTimeout)
+	defer cancel()
+
+	return handler(ctx, req)
+}
+
+// requestID takes the request ID from the x-request-id metadata, or generates one, like
+// requestid.Middleware does for HTTP.
+func requestID(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestid.Header); len(values) > 0 {
+			id = values[0]
+		}
+	}
+
+	return handler(requestid.NewContext(ctx, requestid.OrNew(id)), req)
+}
+
+func logger(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+	}
+
+	slog.LogAttrs(ctx, level, "call",
+		slog.String("code", code.String()),
+		slog.String("method", info.FullMethod),
+		slog.Duration("latency", time.Since(start)),
+	)
+
+	return resp, err
+}
+
+func metrics(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err).String()
+	calls.WithLabelValues(info.FullMethod, code).Inc()
+	callDuration.WithLabelValues(info.FullMethod, code).Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
+
+func recovery(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "panic", "method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "something went wrong")
+		}
+	}()
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"inventory-service/config"
+	"inventory-service/internal/adapter/http/service/handlers/dto"
+	"inventory-service/internal/adapter/postgres/dao"
+	"inventory-service/internal/models"
+	"inventory-service/pkg/inventorypb"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// stubUsecase serves a fixed set of products. Adjustments of product 99 block until
+// their context is done.
+type stubUsecase struct {
+	products map[int64]models.Inventory
+}
+
+func (s *stubUsecase) Get(ctx context.Context, id int64) (models.Inventory, error) {
+	inv, ok := s.products[id]
+	if !ok {
+		return models.Inventory{}, dao.ErrRecordNotFound
+	}
+	return inv, nil
+}
+
+func (s *stubUsecase) GetListInventory(ctx context.Context, filters models.Filters) ([]models.Inventory, dto.Metadata, error) {
+	var items []models.Inventory
+	for _, inv := range s.products {
+		items = append(items, inv)
+	}
+	return items, dto.CalculateMetadata(len(items), filters.Page, filters.PageSize), nil
+}
+
+func (s *stubUsecase) GetMany(ctx context.Context, ids []int64) ([]models.Inventory, error) {
+	var items []models.Inventory
+	for _, id := range ids {
+		if inv, ok := s.products[id]; ok {
+			items = append(items, inv)
+		}
+	}
+	return items, nil
+}
+
+func (s *stubUsecase) DecrementMany(ctx context.Context, changes []models.StockChange) ([]models.StockChangeResult, bool, error) {
+	applied := true
+	results := make([]models.StockChangeResult, 0, len(changes))
+	for _, change := range changes {
+		result := models.StockChangeResult{ID: change.ID, Quantity: change.Quantity, Status: models.StockChangeApplied}
+		inv, ok := s.products[change.ID]
+		switch {
+		case !ok:
+			result.Status = models.StockChangeNotFound
+			applied = false
+		case inv.Available < change.Quantity:
+			result.Status = models.StockChangeInsufficientStock
+			result.Available = inv.Available
+			applied = false
+		}
+		results = append(results, result)
+	}
+	return results, applied, nil
+}
+
+func (s *stubUsecase) Adjust(ctx context.Context, adj models.StockAdjustment) (models.StockAdjustmentResult, error) {
+	if adj.ID == 99 {
+		<-ctx.Done()
+		return models.StockAdjustmentResult{}, ctx.Err()
+	}
+
+	inv, ok := s.products[adj.ID]
+	if !ok {
+		return models.StockAdjustmentResult{}, dao.ErrRecordNotFound
+	}
+	if inv.Available+adj.Delta < 0 {
+		return models.StockAdjustmentResult{}, dao.ErrInsufficientStock
+	}
+	return models.StockAdjustmentResult{ID: adj.ID, Delta: adj.Delta, Available: inv.Available + adj.Delta, Version: inv.Version + 1}, nil
+}
+
+// newTestClient serves uc on an in-memory listener and returns a client connected to it.
+func newTestClient(t *testing.T, cfg config.GRPCServer, uc InventoryUsecase) inventorypb.InventoryServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	api := New(config.Server{GRPCServer: cfg}, uc)
+	go func() {
+		if err := api.Serve(lis); err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		api.Stop(context.Background())
+	})
+
+	return inventorypb.NewInventoryServiceClient(conn)
+}
+
+func testProducts() *stubUsecase {
+	return &stubUsecase{products: map[int64]models.Inventory{
+		1: {ID: 1, Name: "Keyboard", Price: 49.5, Available: 10, Version: 1},
+		2: {ID: 2, Name: "Mouse", Price: 19, Available: 1, Version: 3},
+	}}
+}
+
+func TestGetProduct(t *testing.T) {
+	client := newTestClient(t, config.GRPCServer{RequestTimeout: time.Second}, testProducts())
+
+	resp, err := client.GetProduct(context.Background(), &inventorypb.GetProductRequest{Id: 1})
+	if err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	if got := resp.GetProduct(); got.GetName() != "Keyboard" || got.GetPrice() != 49.5 || got.GetAvailable() != 10 {
+		t.Errorf("GetProduct = %v", got)
+	}
+
+	_, err = client.GetProduct(context.Background(), &inventorypb.GetProductRequest{Id: 3})
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("GetProduct of a missing product: code = %v, want %v", code, codes.NotFound)
+	}
+}
+
+func TestBatchGetProducts(t *testing.T) {
+	client := newTestClient(t, config.GRPCServer{RequestTimeout: time.Second}, testProducts())
+
+	resp, err := client.BatchGetProducts(context.Background(), &inventorypb.BatchGetProductsRequest{Ids: []int64{1, 3, 2, 3}})
+	if err != nil {
+		t.Fatalf("BatchGetProducts: %v", err)
+	}
+	if len(resp.GetProducts()) != 2 {
+		t.Errorf("got %d products, want 2", len(resp.GetProducts()))
+	}
+	if missing := resp.GetMissingIds(); len(missing) != 1 || missing[0] != 3 {
+		t.Errorf("missing = %v, want [3]", missing)
+	}
+}
+
+func TestReserveStockRefused(t *testing.T) {
+	client := newTestClient(t, config.GRPCServer{RequestTimeout: time.Second}, testProducts())
+
+	resp, err := client.ReserveStock(context.Background(), &inventorypb.ReserveStockRequest{
+		Reference: "order:1",
+		Items: []*inventorypb.StockChange{
+			{ProductId: 1, Quantity: 2},
+			{ProductId: 2, Quantity: 5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ReserveStock: %v", err)
+	}
+	if resp.GetApplied() {
+		t.Fatal("applied = true, want false")
+	}
+
+	want := []inventorypb.StockChangeStatus{
+		inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_APPLIED,
+		inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_INSUFFICIENT_STOCK,
+	}
+	for i, result := range resp.GetResults() {
+		if result.GetStatus() != want[i] {
+			t.Errorf("result %d: status = %v, want %v", i, result.GetStatus(), want[i])
+		}
+	}
+}
+
+func TestInvalidArgument(t *testing.T) {
+	client := newTestClient(t, config.GRPCServer{RequestTimeout: time.Second}, testProducts())
+
+	_, err := client.AdjustStock(context.Background(), &inventorypb.AdjustStockRequest{ProductId: 1, Delta: 1, Reason: "gift"})
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("code = %v, want %v", st.Code(), codes.InvalidArgument)
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf("got %d details, want 1", len(st.Details()))
+	}
+	details, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok || len(details.GetFieldViolations()) != 1 || details.GetFieldViolations()[0].GetField() != "reason" {
+		t.Errorf("details = %v, want a violation of reason", st.Details()[0])
+	}
+}
+
+func TestAdjustStockInsufficient(t *testing.T) {
+	client := newTestClient(t, config.GRPCServer{RequestTimeout: time.Second}, testProducts())
+
+	_, err := client.AdjustStock(context.Background(), &inventorypb.AdjustStockRequest{ProductId: 2, Delta: -2, Reason: models.AdjustmentReasonCorrection})
+	if code := status.Code(err); code != codes.FailedPrecondition {
+		t.Errorf("code = %v, want %v", code, codes.FailedPrecondition)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	client := newTestClient(t, config.GRPCServer{RequestTimeout: 50 * time.Millisecond}, testProducts())
+
+	// The caller allows more time than the server does
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.ReleaseStock(ctx, &inventorypb.ReleaseStockRequest{ProductId: 0, Quantity: 1})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Fatalf("release of product 0: code = %v, want %v", code, codes.InvalidArgument)
+	}
+
+	start := time.Now()
+	_, err = client.AdjustStock(ctx, &inventorypb.AdjustStockRequest{ProductId: 99, Delta: 1, Reason: models.AdjustmentReasonRestock})
+	if code := status.Code(err); code != codes.DeadlineExceeded {
+		t.Errorf("code = %v, want %v", code, codes.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("call took %v, the server deadline is 50ms", elapsed)
+	}
+}
@@ -0,0 +1,64 @@
+package dto
+
+import (
+	"fmt"
+	"inventory-service/internal/models"
+	"inventory-service/pkg/validator"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdjustmentRequest struct {
+	Delta     int64  `json:"delta"`
+	Reason    string `json:"reason"`
+	Reference string `json:"reference"`
+}
+
+type AdjustmentResponse struct {
+	ID        int64 `json:"id"`
+	Delta     int64 `json:"delta"`
+	Available int64 `json:"available"`
+	Version   int32 `json:"version"`
+	Replayed  bool  `json:"replayed,omitempty"`
+}
+
+func ToAdjustmentRequest(ctx *gin.Context) (models.StockAdjustment, error) {
+	id, err := ReadParamID(ctx)
+	if err != nil {
+		return models.StockAdjustment{}, err
+	}
+
+	var req AdjustmentRequest
+	err = ctx.ShouldBindJSON(&req)
+	if err != nil {
+		return models.StockAdjustment{}, err
+	}
+
+	return models.StockAdjustment{
+		ID:        id,
+		Delta:     req.Delta,
+		Reason:    req.Reason,
+		Reference: req.Reference,
+	}, nil
+}
+
+func ToAdjustmentResponse(result models.StockAdjustmentResult) AdjustmentResponse {
+	return AdjustmentResponse{
+		ID:        result.ID,
+		Delta:     result.Delta,
+		Available: result.Available,
+		Version:   result.Version,
+		Replayed:  result.Replayed,
+	}
+}
+
+func ValidateAdjustment(v *validator.Validator, adj models.StockAdjustment) {
+	v.Check(adj.Delta != 0, "delta", "must not be zero")
+	ValidateAdjustmentReference(v, adj.Reason, adj.Reference)
+}
+
+func ValidateAdjustmentReference(v *validator.Validator, reason, reference string) {
+	v.Check(validator.PermittedValue(reason, models.AdjustmentReasons...), "reason", fmt.Sprintf("invalid reason. Available: %v", strings.Join(models.AdjustmentReasons, ", ")))
+	v.Check(len(reference) <= 100, "reference", "must not be more than 100 bytes long")
+}
@@ -0,0 +1,147 @@
+package dto
+
+import (
+	"inventory-service/internal/models"
+	"inventory-service/pkg/validator"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBatchSize is the maximum number of products a single batch request may touch.
+const MaxBatchSize = 100
+
+type BatchGetRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+type BatchGetResponse struct {
+	Inventory []InventoryResponse `json:"inventory"`
+	Missing   []int64             `json:"missing"`
+}
+
+type StockChangeRequest struct {
+	ID       int64 `json:"id"`
+	Quantity int64 `json:"quantity"`
+}
+
+type BatchDecrementRequest struct {
+	Reason    string               `json:"reason"`
+	Reference string               `json:"reference"`
+	Items     []StockChangeRequest `json:"items"`
+}
+
+type StockChangeResponse struct {
+	ID        int64  `json:"id"`
+	Quantity  int64  `json:"quantity"`
+	Status    string `json:"status"`
+	Available int64  `json:"available"`
+	Version   int32  `json:"version,omitempty"`
+	Replayed  bool   `json:"replayed,omitempty"`
+}
+
+type BatchDecrementResponse struct {
+	Applied bool                  `json:"applied"`
+	Results []StockChangeResponse `json:"results"`
+}
+
+func ToBatchGetRequest(ctx *gin.Context) ([]int64, error) {
+	var req BatchGetRequest
+
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	return req.IDs, nil
+}
+
+func ToBatchDecrementRequest(ctx *gin.Context) ([]models.StockChange, error) {
+	var req BatchDecrementRequest
+
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]models.StockChange, 0, len(req.Items))
+	for _, v := range req.Items {
+		changes = append(changes, models.StockChange{
+			ID:        v.ID,
+			Quantity:  v.Quantity,
+			Reason:    req.Reason,
+			Reference: req.Reference,
+		})
+	}
+
+	return changes, nil
+}
+
+// ToBatchGetResponse lists the found items and the requested IDs that were not found.
+func ToBatchGetResponse(ids []int64, invs []models.Inventory) BatchGetResponse {
+	found := make(map[int64]bool, len(invs))
+	for _, v := range invs {
+		found[v.ID] = true
+	}
+
+	responce := BatchGetResponse{
+		Inventory: []InventoryResponse{},
+		Missing:   []int64{},
+	}
+	for _, v := range invs {
+		responce.Inventory = append(responce.Inventory, ToInventoryResponse(v))
+	}
+	for _, id := range ids {
+		if !found[id] {
+			responce.Missing = append(responce.Missing, id)
+			found[id] = true
+		}
+	}
+
+	return responce
+}
+
+func ToBatchDecrementResponse(applied bool, results []models.StockChangeResult) BatchDecrementResponse {
+	responce := BatchDecrementResponse{
+		Applied: applied,
+		Results: []StockChangeResponse{},
+	}
+
+	for _, v := range results {
+		responce.Results = append(responce.Results, StockChangeResponse{
+			ID:        v.ID,
+			Quantity:  v.Quantity,
+			Status:    v.Status,
+			Available: v.Available,
+			Version:   v.Version,
+			Replayed:  v.Replayed,
+		})
+	}
+
+	return responce
+}
+
+func ValidateBatchGet(v *validator.Validator, ids []int64) {
+	v.Check(len(ids) > 0, "ids", "must be provided")
+	v.Check(len(ids) <= MaxBatchSize, "ids", "must not contain more than 100 ids")
+
+	for _, id := range ids {
+		v.Check(id > 0, "ids", "must be greater than zero")
+	}
+}
+
+func ValidateBatchDecrement(v *validator.Validator, changes []models.StockChange) {
+	v.Check(len(changes) > 0, "items", "must be provided")
+	v.Check(len(changes) <= MaxBatchSize, "items", "must not contain more than 100 items")
+
+	ids := make([]int64, 0, len(changes))
+	for _, change := range changes {
+		v.Check(change.ID > 0, "items_id", "must be greater than zero")
+		v.Check(change.Quantity > 0, "items_quantity", "must be greater than zero")
+		ids = append(ids, change.ID)
+	}
+	v.Check(validator.Unique(ids), "items_id", "must not contain duplicate ids")
+
+	if len(changes) > 0 {
+		ValidateAdjustmentReference(v, changes[0].Reason, changes[0].Reference)
+	}
+}
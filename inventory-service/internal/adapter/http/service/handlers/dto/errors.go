@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"inventory-service/internal/adapter/postgres/dao"
+	"inventory-service/pkg/problem"
 	"net/http"
 
 	"github.com/jackc/pgx/v5"
@@ -15,42 +16,24 @@ var (
 	ErrEditConflict        = errors.New("unable to update the record due to an edit conflict")
 )
 
-type HTTPError struct {
-	Code    int
-	Message string
-}
-
-var (
-	ErrPageNotFoundResponse = &HTTPError{
-		Code:    http.StatusNotFound,
-		Message: "the requested resource could not be found",
-	}
-	ErrUnprocessableEntityResponse = &HTTPError{
-		Code:    http.StatusUnprocessableEntity,
-		Message: "unprocessable entity",
-	}
-	ErrEditConflictResponse = &HTTPError{
-		Code:    http.StatusConflict,
-		Message: "unable to update the record due to an edit conflict",
-	}
-)
-
-func FromError(err error) *HTTPError {
+// FromError maps use case errors to the problem reported to the client.
+func FromError(err error) *problem.Problem {
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
-		return ErrPageNotFoundResponse
+		return problem.NotFound("the requested resource could not be found")
 	case errors.Is(err, pgx.ErrNoRows):
-		return ErrPageNotFoundResponse
+		return problem.NotFound("the requested resource could not be found")
 	case errors.Is(err, dao.ErrRecordNotFound):
-		return ErrPageNotFoundResponse
+		return problem.NotFound("the requested resource could not be found")
 	case errors.Is(err, ErrUnprocessableEntity):
-		return ErrUnprocessableEntityResponse
-	case errors.Is(err, ErrEditConflict):
-		return ErrEditConflictResponse
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeValidationFailed, "unprocessable entity")
+	case errors.Is(err, ErrInvalidFilters):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeValidationFailed, "invalid filters")
+	case errors.Is(err, ErrEditConflict), errors.Is(err, dao.ErrEditConflict):
+		return problem.New(http.StatusConflict, problem.CodeEditConflict, "unable to update the record due to an edit conflict")
+	case errors.Is(err, dao.ErrInsufficientStock):
+		return problem.New(http.StatusConflict, problem.CodeInsufficientStock, "not enough stock available")
 	default:
-		return &HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: "something went wrong",
-		}
+		return problem.Internal()
 	}
-}
\ No newline at end of file
+}
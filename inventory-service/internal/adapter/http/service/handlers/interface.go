@@ -12,4 +12,7 @@ type InventoryUsecase interface {
 	GetListInventory(ctx context.Context, filters models.Filters) ([]models.Inventory, dto.Metadata, error)
 	Update(ctx context.Context, request models.UpdateInventoryData) (models.Inventory, error)
 	Delete(ctx context.Context, id int64) error
+	GetMany(ctx context.Context, ids []int64) ([]models.Inventory, error)
+	DecrementMany(ctx context.Context, changes []models.StockChange) ([]models.StockChangeResult, bool, error)
+	Adjust(ctx context.Context, adj models.StockAdjustment) (models.StockAdjustmentResult, error)
 }
@@ -3,8 +3,9 @@ package handlers
 import (
 	"errors"
 	"inventory-service/internal/adapter/http/service/handlers/dto"
+	"inventory-service/pkg/problem"
 	"inventory-service/pkg/validator"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -21,20 +22,19 @@ func NewInventory(invUseCase InventoryUsecase) *Inventory {
 func (h *Inventory) Create(ctx *gin.Context) {
 	inventory, err := dto.ToInventoryCreateRequest(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Write(ctx, problem.BadRequest(err.Error()))
 		return
 	}
 
 	v := validator.New()
 	if dto.ValidateInventory(v, inventory); !v.Valid() {
-		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": v.Errors})
+		problem.Write(ctx, problem.Validation(v.Errors))
 		return
 	}
 
 	inventoryNew, err := h.invUseCase.CreateItem(ctx.Request.Context(), inventory)
 	if err != nil {
-		errCtx := dto.FromError(err)
-		ctx.JSON(errCtx.Code, gin.H{"error": errCtx.Message})
+		problem.Write(ctx, dto.FromError(err))
 		return
 	}
 
@@ -46,15 +46,14 @@ func (h *Inventory) GetList(ctx *gin.Context) {
 
 	filters := dto.ParseListRequest(ctx, v)
 	if !v.Valid() {
-		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": v.Errors})
+		problem.Write(ctx, problem.Validation(v.Errors))
 		return
 	}
 
 	items, metadata, err := h.invUseCase.GetListInventory(ctx.Request.Context(), filters)
 	if err != nil {
-		errCtx := dto.FromError(err)
-		log.Println(err)
-		ctx.JSON(errCtx.Code, gin.H{"error": errCtx.Message})
+		slog.ErrorContext(ctx.Request.Context(), "list products", "error", err)
+		problem.Write(ctx, dto.FromError(err))
 		return
 	}
 
@@ -67,15 +66,14 @@ func (h *Inventory) GetList(ctx *gin.Context) {
 func (h *Inventory) GetByID(ctx *gin.Context) {
 	id, err := dto.ReadParamID(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		problem.Write(ctx, problem.BadRequest("invalid id"))
 		return
 	}
 
 	inventory, err := h.invUseCase.Get(ctx.Request.Context(), id)
 	if err != nil {
-		log.Println(err)
-		errCtx := dto.FromError(err)
-		ctx.JSON(errCtx.Code, gin.H{"error": errCtx.Message})
+		slog.ErrorContext(ctx.Request.Context(), "get product", "error", err)
+		problem.Write(ctx, dto.FromError(err))
 		return
 	}
 
@@ -85,7 +83,7 @@ func (h *Inventory) GetByID(ctx *gin.Context) {
 func (h *Inventory) Update(ctx *gin.Context) {
 	item, err := dto.ToInventoryUpdateRequest(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Write(ctx, problem.BadRequest(err.Error()))
 		return
 	}
 
@@ -95,12 +93,11 @@ func (h *Inventory) Update(ctx *gin.Context) {
 			v := validator.New()
 			dto.ValidateInventory(v, itemUpdated)
 			if !v.Valid() {
-				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": v.Errors})
+				problem.Write(ctx, problem.Validation(v.Errors))
 				return
 			}
 		}
-		errCtx := dto.FromError(err)
-		ctx.JSON(errCtx.Code, gin.H{"error": errCtx.Message})
+		problem.Write(ctx, dto.FromError(err))
 		return
 	}
 
@@ -110,16 +107,91 @@ func (h *Inventory) Update(ctx *gin.Context) {
 func (h *Inventory) Delete(ctx *gin.Context) {
 	id, err := dto.ReadParamID(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		problem.Write(ctx, problem.BadRequest("invalid id"))
 		return
 	}
 
 	err = h.invUseCase.Delete(ctx.Request.Context(), id)
 	if err != nil {
-		errCtx := dto.FromError(err)
-		ctx.JSON(errCtx.Code, gin.H{"error": errCtx.Message})
+		problem.Write(ctx, dto.FromError(err))
 		return
 	}
 
 	ctx.Status(http.StatusNoContent)
 }
+
+func (h *Inventory) BatchGet(ctx *gin.Context) {
+	ids, err := dto.ToBatchGetRequest(ctx)
+	if err != nil {
+		problem.Write(ctx, problem.BadRequest(err.Error()))
+		return
+	}
+
+	v := validator.New()
+	if dto.ValidateBatchGet(v, ids); !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	items, err := h.invUseCase.GetMany(ctx.Request.Context(), ids)
+	if err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "batch get products", "error", err)
+		problem.Write(ctx, dto.FromError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.ToBatchGetResponse(ids, items))
+}
+
+// BatchDecrement responds with 200 when every change was applied and with 409 when none
+// was, in both cases with the result of every change.
+func (h *Inventory) BatchDecrement(ctx *gin.Context) {
+	changes, err := dto.ToBatchDecrementRequest(ctx)
+	if err != nil {
+		problem.Write(ctx, problem.BadRequest(err.Error()))
+		return
+	}
+
+	v := validator.New()
+	if dto.ValidateBatchDecrement(v, changes); !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	results, applied, err := h.invUseCase.DecrementMany(ctx.Request.Context(), changes)
+	if err != nil {
+		slog.ErrorContext(ctx.Request.Context(), "batch decrement stock", "error", err)
+		problem.Write(ctx, dto.FromError(err))
+		return
+	}
+
+	status := http.StatusOK
+	if !applied {
+		status = http.StatusConflict
+	}
+
+	ctx.JSON(status, dto.ToBatchDecrementResponse(applied, results))
+}
+
+// Adjust responds with 409 when a decrement would take more than is available.
+func (h *Inventory) Adjust(ctx *gin.Context) {
+	adj, err := dto.ToAdjustmentRequest(ctx)
+	if err != nil {
+		problem.Write(ctx, problem.BadRequest(err.Error()))
+		return
+	}
+
+	v := validator.New()
+	if dto.ValidateAdjustment(v, adj); !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	result, err := h.invUseCase.Adjust(ctx.Request.Context(), adj)
+	if err != nil {
+		problem.Write(ctx, dto.FromError(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"adjustment": dto.ToAdjustmentResponse(result)})
+}
@@ -1,74 +1,131 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"inventory-service/config"
 	"inventory-service/internal/adapter/http/service/handlers"
-	"log"
+	"inventory-service/internal/adapter/http/service/openapi"
+	"inventory-service/pkg/authz"
+	"inventory-service/pkg/health"
+	"inventory-service/pkg/logger"
+	"inventory-service/pkg/metrics"
+	"inventory-service/pkg/problem"
+	"inventory-service/pkg/requestid"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 const serverIPAddress = "127.0.0.1:%d" // Changed to 0.0.0.0 for external access
 
 type API struct {
-	server *gin.Engine
+	router *gin.Engine
+	server *http.Server
 	cfg    config.HTTPServer
 	addr   string
+	health *health.Health
+	policy *authz.Policy
 
 	inventoryHandler *handlers.Inventory
 }
 
-func New(cfg config.Server, inventoryUseCase InventoryUsecase) *API {
+func New(cfg config.Server, inventoryUseCase InventoryUsecase, checks *health.Health) *API {
 	// Setting the Gin mode
 	gin.SetMode(cfg.HTTPServer.Mode)
 	// Creating a new Gin Engine
-	server := gin.New()
+	router := gin.New()
 
 	// Applying middleware
-	server.Use(gin.Logger())
-	server.Use(gin.Recovery())
+	router.Use(requestid.Middleware())
+	router.Use(otelgin.Middleware("inventory-service"))
+	router.Use(logger.Middleware())
+	router.Use(metrics.Middleware())
+	router.Use(authz.Middleware())
+	router.Use(gin.Recovery())
+	router.NoRoute(problem.NoRoute)
 
 	// Binding inventory
 	inventoryHandler := handlers.NewInventory(inventoryUseCase)
 
 	api := &API{
-		server:           server,
+		router:           router,
 		cfg:              cfg.HTTPServer,
 		addr:             fmt.Sprintf(serverIPAddress, cfg.HTTPServer.Port),
+		health:           checks,
+		policy:           authz.New(cfg.Authz),
 		inventoryHandler: inventoryHandler,
 	}
 
 	api.setupRoutes()
 
+	api.server = &http.Server{
+		Addr:           api.addr,
+		Handler:        router,
+		ReadTimeout:    cfg.HTTPServer.ReadTimeout,
+		WriteTimeout:   cfg.HTTPServer.WriteTimeout,
+		IdleTimeout:    cfg.HTTPServer.IdleTimeout,
+		MaxHeaderBytes: cfg.HTTPServer.MaxHeaderBytes,
+	}
+
 	return api
 
 }
 func (a *API) setupRoutes() {
-	a.server.GET("/healthcheck", a.HealthCheck)
-
-	products := a.server.Group("/products")
+	a.router.GET("/healthcheck", a.HealthCheck)
+	a.router.GET("/livez", a.health.Live)
+	a.router.GET("/readyz", a.health.Ready)
+	a.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	a.router.GET("/openapi.json", a.OpenAPI)
+
+	// The catalog is open to read. Only staff change it, stock is also changed by other
+	// services such as order-service.
+	staff := a.policy.Require(authz.RoleAdmin, authz.RoleStaff)
+	stock := a.policy.Require(authz.RoleAdmin, authz.RoleStaff, authz.RoleService)
+
+	products := a.router.Group("/products")
 	{
-		products.POST("/", a.inventoryHandler.Create)
+		products.POST("/", staff, a.inventoryHandler.Create)
 		products.GET("/", a.inventoryHandler.GetList)
 		products.GET("/:id", a.inventoryHandler.GetByID)
-		products.PATCH("/:id", a.inventoryHandler.Update)
-		products.DELETE("/:id", a.inventoryHandler.Delete)
+		products.PATCH("/:id", staff, a.inventoryHandler.Update)
+		products.DELETE("/:id", staff, a.inventoryHandler.Delete)
+		products.POST("/:id/adjust", stock, a.inventoryHandler.Adjust)
+
+		products.POST("/batch/get", a.inventoryHandler.BatchGet)
+		products.POST("/batch/decrement", stock, a.inventoryHandler.BatchDecrement)
 	}
 }
 
-func (a *API) Stop() error {
+// Stop stops accepting connections and waits for the requests in flight to finish. The
+// ones still running when ctx is done are cut off.
+func (a *API) Stop(ctx context.Context) error {
+	slog.Info("HTTP server shutting down gracefully")
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		a.server.Close()
+		return fmt.Errorf("HTTP server shutdown: %w", err)
+	}
+
+	slog.Info("HTTP server stopped successfully")
+
 	return nil
 }
 
+// Handler returns the router, so the API can be served by something other than Run.
+func (a *API) Handler() http.Handler {
+	return a.router
+}
+
 func (a *API) Run(errCh chan<- error) {
 	go func() {
-		log.Printf("HTTP server starting on: %v", a.addr)
+		slog.Info("HTTP server starting", "addr", a.addr)
 
-		// No need to reinitialize `a.server` here. Just run it directly.
-		if err := a.server.Run(a.addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			errCh <- fmt.Errorf("failed to start HTTP server: %w", err)
 			return
 		}
@@ -84,3 +141,15 @@ func (a *API) HealthCheck(c *gin.Context) {
 		},
 	})
 }
+
+// OpenAPI serves the OpenAPI document of the API.
+func (a *API) OpenAPI(c *gin.Context) {
+	spec, err := openapi.JSON()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "openapi", "error", err)
+		problem.Write(c, problem.Internal())
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", spec)
+}
@@ -0,0 +1,350 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"inventory-service/internal/adapter/postgres/dao"
+	"inventory-service/internal/models"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InventoryRepository keeps inventory in memory. It is safe for concurrent use and
+// behaves like the Postgres repository, the shared tests in usecasetest hold both to
+// the same contract.
+type InventoryRepository struct {
+	mu     sync.RWMutex
+	nextID int64
+	items  map[int64]models.Inventory
+	ledger map[ledgerKey]models.StockAdjustmentResult
+}
+
+// Adjustments with a reference are recorded once per product, like the unique key of
+// the inventory_adjustments table.
+type ledgerKey struct {
+	id        int64
+	reference string
+}
+
+func NewInventoryRepository() *InventoryRepository {
+	return &InventoryRepository{
+		items:  make(map[int64]models.Inventory),
+		ledger: make(map[ledgerKey]models.StockAdjustmentResult),
+	}
+}
+
+func (r *InventoryRepository) CreateItem(ctx context.Context, item models.Inventory) (int64, error) {
+	if err := checkInventory(item); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	item.ID = r.nextID
+	item.Price = roundPrice(item.Price)
+	item.CreatedAt = time.Now().Truncate(time.Second)
+	item.Version = 1
+	item.IsDeleted = false
+	r.items[item.ID] = item
+
+	return item.ID, nil
+}
+
+func (r *InventoryRepository) Get(ctx context.Context, id int64) (models.Inventory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.items[id]
+	if !ok || item.IsDeleted {
+		return models.Inventory{}, dao.ErrRecordNotFound
+	}
+
+	return item, nil
+}
+
+// GetListInventory reports the total like count(*) OVER() does: as zero when the page
+// is past the last item.
+func (r *InventoryRepository) GetListInventory(ctx context.Context, filters models.Filters) ([]models.Inventory, int, error) {
+	column, direction := filters.SortColumn(), filters.SortDirection()
+
+	r.mu.RLock()
+	items := r.live()
+	r.mu.RUnlock()
+
+	slices.SortFunc(items, func(a, b models.Inventory) int {
+		c := compareColumn(column, a, b)
+		if direction == "DESC" {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(a.ID, b.ID))
+	})
+
+	if filters.Offset() >= len(items) {
+		return nil, 0, nil
+	}
+
+	total := len(items)
+	items = items[filters.Offset():min(filters.Offset()+filters.Limit(), total)]
+
+	return items, total, nil
+}
+
+// Update writes the item if its version is still the stored one. It returns
+// dao.ErrEditConflict when the item was changed in the meantime.
+func (r *InventoryRepository) Update(ctx context.Context, item *models.Inventory) error {
+	if err := checkInventory(*item); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.items[item.ID]
+	if !ok || stored.IsDeleted {
+		return dao.ErrRecordNotFound
+	}
+	if stored.Version != item.Version {
+		return dao.ErrEditConflict
+	}
+
+	stored.Name = item.Name
+	stored.Description = item.Description
+	stored.Price = roundPrice(item.Price)
+	stored.Available = item.Available
+	stored.Version++
+	r.items[item.ID] = stored
+
+	item.Version = stored.Version
+
+	return nil
+}
+
+func (r *InventoryRepository) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[id]
+	if !ok || item.IsDeleted {
+		return dao.ErrRecordNotFound
+	}
+
+	item.IsDeleted = true
+	item.Version++
+	r.items[id] = item
+
+	return nil
+}
+
+func (r *InventoryRepository) GetMany(ctx context.Context, ids []int64) ([]models.Inventory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var items []models.Inventory
+	for _, id := range ids {
+		item, ok := r.items[id]
+		if ok && !item.IsDeleted && !slices.ContainsFunc(items, func(v models.Inventory) bool { return v.ID == id }) {
+			items = append(items, item)
+		}
+	}
+	slices.SortFunc(items, func(a, b models.Inventory) int { return cmp.Compare(a.ID, b.ID) })
+
+	return items, nil
+}
+
+// Adjust applies a relative stock change. If the reference was already applied to the
+// product, the recorded result is returned instead.
+func (r *InventoryRepository) Adjust(ctx context.Context, adj models.StockAdjustment) (models.StockAdjustmentResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if result, found := r.findAdjustment(adj); found {
+		return result, nil
+	}
+
+	return r.applyAdjustment(adj)
+}
+
+// DecrementMany applies all changes or none of them. Changes are checked in the given
+// order and every change gets its own result. applied reports whether the batch was
+// written.
+func (r *InventoryRepository) DecrementMany(ctx context.Context, changes []models.StockChange) ([]models.StockChangeResult, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Checking every change against the current stock first
+	applied := true
+	results := make([]models.StockChangeResult, 0, len(changes))
+	for _, change := range changes {
+		result := models.StockChangeResult{ID: change.ID, Quantity: change.Quantity}
+
+		prev, replayed := r.findAdjustment(toAdjustment(change))
+		item, ok := r.items[change.ID]
+		switch {
+		case replayed:
+			result.Status = models.StockChangeApplied
+			result.Available = prev.Available
+			result.Version = prev.Version
+			result.Replayed = true
+		case !ok || item.IsDeleted:
+			result.Status = models.StockChangeNotFound
+			applied = false
+		case item.Available < change.Quantity:
+			result.Status = models.StockChangeInsufficientStock
+			result.Available = item.Available
+			result.Version = item.Version
+			applied = false
+		default:
+			result.Status = models.StockChangeApplied
+			result.Available = item.Available
+			result.Version = item.Version
+		}
+
+		results = append(results, result)
+	}
+
+	// Nothing is written, so every result reports the stock as it is
+	if !applied {
+		for i := range results {
+			if results[i].Status == models.StockChangeApplied && !results[i].Replayed {
+				results[i].Status = models.StockChangeSkipped
+			}
+		}
+
+		return results, false, nil
+	}
+
+	// Keeping a copy so that a failing change undoes the ones before it
+	items := make(map[int64]models.Inventory, len(changes))
+	ledger := make(map[ledgerKey]bool, len(changes))
+	for _, change := range changes {
+		if item, ok := r.items[change.ID]; ok {
+			items[change.ID] = item
+		}
+	}
+
+	for i, change := range changes {
+		if results[i].Replayed {
+			continue
+		}
+
+		adj := toAdjustment(change)
+		result, err := r.applyAdjustment(adj)
+		if err != nil {
+			for id, item := range items {
+				r.items[id] = item
+			}
+			for key := range ledger {
+				delete(r.ledger, key)
+			}
+			return nil, false, err
+		}
+		ledger[ledgerKey{adj.ID, adj.Reference}] = true
+
+		results[i].Available = result.Available
+		results[i].Version = result.Version
+	}
+
+	return results, true, nil
+}
+
+// findAdjustment looks up an adjustment of the product with the same reference.
+func (r *InventoryRepository) findAdjustment(adj models.StockAdjustment) (models.StockAdjustmentResult, bool) {
+	if adj.Reference == "" {
+		return models.StockAdjustmentResult{}, false
+	}
+
+	result, ok := r.ledger[ledgerKey{adj.ID, adj.Reference}]
+	if !ok {
+		return models.StockAdjustmentResult{}, false
+	}
+
+	result.Replayed = true
+	return result, true
+}
+
+// applyAdjustment changes the stock and records the adjustment. The caller holds the lock.
+func (r *InventoryRepository) applyAdjustment(adj models.StockAdjustment) (models.StockAdjustmentResult, error) {
+	if adj.Delta == 0 {
+		return models.StockAdjustmentResult{}, errors.New("adjustment delta must not be zero")
+	}
+
+	item, ok := r.items[adj.ID]
+	if !ok || item.IsDeleted {
+		return models.StockAdjustmentResult{}, dao.ErrRecordNotFound
+	}
+	if item.Available+adj.Delta < 0 {
+		return models.StockAdjustmentResult{}, dao.ErrInsufficientStock
+	}
+
+	item.Available += adj.Delta
+	item.Version++
+	r.items[adj.ID] = item
+
+	result := models.StockAdjustmentResult{
+		ID:        adj.ID,
+		Delta:     adj.Delta,
+		Available: item.Available,
+		Version:   item.Version,
+	}
+	if adj.Reference != "" {
+		r.ledger[ledgerKey{adj.ID, adj.Reference}] = result
+	}
+
+	return result, nil
+}
+
+// live returns the items that are not deleted. The caller holds the lock.
+func (r *InventoryRepository) live() []models.Inventory {
+	items := make([]models.Inventory, 0, len(r.items))
+	for _, item := range r.items {
+		if !item.IsDeleted {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// checkInventory enforces the check constraints of the inventory table.
+func checkInventory(item models.Inventory) error {
+	if item.Price <= 0 {
+		return errors.New("inventory: price must be greater than zero")
+	}
+	if item.Available < 0 {
+		return errors.New("inventory: available must not be negative")
+	}
+	return nil
+}
+
+// compareColumn compares by one of the columns of dao.SafeSortList.
+func compareColumn(column string, a, b models.Inventory) int {
+	switch column {
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	case "price":
+		return cmp.Compare(a.Price, b.Price)
+	case "available":
+		return cmp.Compare(a.Available, b.Available)
+	default:
+		return cmp.Compare(a.ID, b.ID)
+	}
+}
+
+// roundPrice stores prices with two decimals, like NUMERIC(10, 2).
+func roundPrice(price float64) float64 {
+	return math.Round(price*100) / 100
+}
+
+func toAdjustment(change models.StockChange) models.StockAdjustment {
+	return models.StockAdjustment{
+		ID:        change.ID,
+		Delta:     -change.Quantity,
+		Reason:    change.Reason,
+		Reference: change.Reference,
+	}
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"inventory-service/internal/usecase"
+	"inventory-service/internal/usecase/usecasetest"
+)
+
+func TestInventoryRepository(t *testing.T) {
+	usecasetest.TestInventoryRepository(t, func(t *testing.T) usecase.InventoryRepository {
+		return NewInventoryRepository()
+	})
+}
@@ -6,7 +6,9 @@ import (
 )
 
 var (
-	ErrRecordNotFound = errors.New("record not found")
+	ErrRecordNotFound    = errors.New("record not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrEditConflict      = errors.New("edit conflict")
 
 	SafeSortList = []string{"id", "name", "price", "available", "-id", "-name", "-price", "-available"}
 )
@@ -2,10 +2,12 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"inventory-service/internal/adapter/postgres/dao"
 	"inventory-service/internal/models"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -56,6 +58,9 @@ func (p *InventoryRepository) Get(ctx context.Context, id int64) (models.Invento
 		&item.IsDeleted,
 		&item.Version,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Inventory{}, dao.ErrRecordNotFound
+	}
 	if err != nil {
 		return models.Inventory{}, err
 	}
@@ -108,11 +113,13 @@ func (p *InventoryRepository) GetListInventory(ctx context.Context, filters mode
 
 }
 
+// Update writes the item if its version is still the stored one. It returns
+// dao.ErrEditConflict when the item was changed in the meantime.
 func (p *InventoryRepository) Update(ctx context.Context, item *models.Inventory) error {
 	query := `
 		UPDATE inventory
 		SET name = $1, description = $2, price = $3, available = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		WHERE id = $5 AND version = $6 AND isdeleted = false
 		RETURNING version
 	`
 	args := []any{
@@ -125,11 +132,21 @@ func (p *InventoryRepository) Update(ctx context.Context, item *models.Inventory
 	}
 
 	err := p.db.QueryRow(ctx, query, args...).Scan(&item.Version)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	// Telling a missing item apart from a stale version
+	var exists bool
+	err = p.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM inventory WHERE id = $1 AND isdeleted = false)`, item.ID).Scan(&exists)
 	if err != nil {
 		return err
 	}
+	if !exists {
+		return dao.ErrRecordNotFound
+	}
 
-	return nil
+	return dao.ErrEditConflict
 }
 
 func (p *InventoryRepository) Delete(ctx context.Context, id int64) error {
@@ -137,7 +154,7 @@ func (p *InventoryRepository) Delete(ctx context.Context, id int64) error {
 		UPDATE inventory 
 		SET isdeleted = true,
 			version= version + 1
-		WHERE id = $1
+		WHERE id = $1 AND isdeleted = false
 	`
 
 	result, err := p.db.Exec(ctx, query, id)
@@ -150,3 +167,281 @@ func (p *InventoryRepository) Delete(ctx context.Context, id int64) error {
 
 	return nil
 }
+
+func (p *InventoryRepository) GetMany(ctx context.Context, ids []int64) ([]models.Inventory, error) {
+	query := `
+		SELECT id, created_at, name, description, price, available, isdeleted, version
+		FROM inventory
+		WHERE id = ANY($1) AND isdeleted = false
+		ORDER BY id
+	`
+
+	rows, err := p.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.Inventory
+	for rows.Next() {
+		var item models.Inventory
+		err := rows.Scan(
+			&item.ID,
+			&item.CreatedAt,
+			&item.Name,
+			&item.Description,
+			&item.Price,
+			&item.Available,
+			&item.IsDeleted,
+			&item.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Adjust applies a relative stock change in a single conditional statement, so concurrent
+// adjustments of the same product never conflict: a decrement only fails when there is not
+// enough stock left. If the reference was already applied to the product, the recorded
+// result is returned instead.
+func (p *InventoryRepository) Adjust(ctx context.Context, adj models.StockAdjustment) (models.StockAdjustmentResult, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return models.StockAdjustmentResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, found, err := findAdjustment(ctx, tx, adj)
+	if err != nil || found {
+		return result, err
+	}
+
+	result, err = applyAdjustment(ctx, tx, adj)
+	if err != nil {
+		return models.StockAdjustmentResult{}, err
+	}
+
+	recorded, err := recordAdjustment(ctx, tx, adj, result)
+	if err != nil {
+		return models.StockAdjustmentResult{}, err
+	}
+
+	// A concurrent request with the same reference won, ours is undone
+	if !recorded {
+		tx.Rollback(ctx)
+		result, _, err = findAdjustment(ctx, p.db, adj)
+		return result, err
+	}
+
+	return result, tx.Commit(ctx)
+}
+
+// DecrementMany applies all changes in one transaction or none of them. The affected rows
+// are locked in id order, changes are checked in the given order against the locked stock
+// and every change gets its own result. applied reports whether the batch was committed.
+func (p *InventoryRepository) DecrementMany(ctx context.Context, changes []models.StockChange) ([]models.StockChangeResult, bool, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	ids := make([]int64, 0, len(changes))
+	for _, change := range changes {
+		ids = append(ids, change.ID)
+	}
+
+	lockQuery := `
+		SELECT id, available, version
+		FROM inventory
+		WHERE id = ANY($1) AND isdeleted = false
+		ORDER BY id
+		FOR UPDATE
+	`
+
+	rows, err := tx.Query(ctx, lockQuery, ids)
+	if err != nil {
+		return nil, false, err
+	}
+
+	type stock struct {
+		available int64
+		version   int32
+	}
+	stocks := make(map[int64]stock)
+	for rows.Next() {
+		var id int64
+		var s stock
+		if err := rows.Scan(&id, &s.available, &s.version); err != nil {
+			rows.Close()
+			return nil, false, err
+		}
+		stocks[id] = s
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	// Checking every change against the locked stock first
+	applied := true
+	results := make([]models.StockChangeResult, 0, len(changes))
+	for _, change := range changes {
+		result := models.StockChangeResult{ID: change.ID, Quantity: change.Quantity}
+
+		prev, replayed, err := findAdjustment(ctx, tx, toAdjustment(change))
+		if err != nil {
+			return nil, false, err
+		}
+
+		s, ok := stocks[change.ID]
+		switch {
+		case replayed:
+			result.Status = models.StockChangeApplied
+			result.Available = prev.Available
+			result.Version = prev.Version
+			result.Replayed = true
+		case !ok:
+			result.Status = models.StockChangeNotFound
+			applied = false
+		case s.available < change.Quantity:
+			result.Status = models.StockChangeInsufficientStock
+			result.Available = s.available
+			result.Version = s.version
+			applied = false
+		default:
+			result.Status = models.StockChangeApplied
+			result.Available = s.available
+			result.Version = s.version
+		}
+
+		results = append(results, result)
+	}
+
+	// Nothing is written, so every result reports the stock as it is
+	if !applied {
+		for i := range results {
+			if results[i].Status == models.StockChangeApplied && !results[i].Replayed {
+				results[i].Status = models.StockChangeSkipped
+			}
+		}
+
+		return results, false, nil
+	}
+
+	for i, change := range changes {
+		if results[i].Replayed {
+			continue
+		}
+
+		adj := toAdjustment(change)
+
+		result, err := applyAdjustment(ctx, tx, adj)
+		if err != nil {
+			return nil, false, err
+		}
+
+		recorded, err := recordAdjustment(ctx, tx, adj, result)
+		if err != nil {
+			return nil, false, err
+		}
+		if !recorded {
+			return nil, false, fmt.Errorf("adjustment %q of product %d was applied concurrently", adj.Reference, adj.ID)
+		}
+
+		results[i].Available = result.Available
+		results[i].Version = result.Version
+	}
+
+	return results, true, tx.Commit(ctx)
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// findAdjustment looks up an adjustment of the product with the same reference.
+func findAdjustment(ctx context.Context, q querier, adj models.StockAdjustment) (models.StockAdjustmentResult, bool, error) {
+	if adj.Reference == "" {
+		return models.StockAdjustmentResult{}, false, nil
+	}
+
+	query := `
+		SELECT delta, available, version
+		FROM inventory_adjustments
+		WHERE product_id = $1 AND reference = $2
+	`
+
+	result := models.StockAdjustmentResult{ID: adj.ID, Replayed: true}
+	err := q.QueryRow(ctx, query, adj.ID, adj.Reference).Scan(&result.Delta, &result.Available, &result.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.StockAdjustmentResult{}, false, nil
+	}
+	if err != nil {
+		return models.StockAdjustmentResult{}, false, err
+	}
+
+	return result, true, nil
+}
+
+// applyAdjustment changes the stock relative to its current value. The condition makes a
+// decrement fail instead of going below zero, so no read-modify-write is needed.
+func applyAdjustment(ctx context.Context, tx pgx.Tx, adj models.StockAdjustment) (models.StockAdjustmentResult, error) {
+	query := `
+		UPDATE inventory
+		SET available = available + $1, version = version + 1
+		WHERE id = $2 AND isdeleted = false AND available + $1 >= 0
+		RETURNING available, version
+	`
+
+	result := models.StockAdjustmentResult{ID: adj.ID, Delta: adj.Delta}
+	err := tx.QueryRow(ctx, query, adj.Delta, adj.ID).Scan(&result.Available, &result.Version)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return result, err
+	}
+
+	// Telling a missing product apart from a lack of stock
+	var exists bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM inventory WHERE id = $1 AND isdeleted = false)`, adj.ID).Scan(&exists)
+	if err != nil {
+		return models.StockAdjustmentResult{}, err
+	}
+	if !exists {
+		return models.StockAdjustmentResult{}, dao.ErrRecordNotFound
+	}
+
+	return models.StockAdjustmentResult{}, dao.ErrInsufficientStock
+}
+
+// recordAdjustment writes the adjustment to the ledger. It returns false if an adjustment
+// with the same reference has been recorded in the meantime.
+func recordAdjustment(ctx context.Context, tx pgx.Tx, adj models.StockAdjustment, result models.StockAdjustmentResult) (bool, error) {
+	query := `
+		INSERT INTO inventory_adjustments (product_id, delta, reason, reference, available, version)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		ON CONFLICT (product_id, reference) DO NOTHING
+	`
+
+	tag, err := tx.Exec(ctx, query, adj.ID, adj.Delta, adj.Reason, adj.Reference, result.Available, result.Version)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func toAdjustment(change models.StockChange) models.StockAdjustment {
+	return models.StockAdjustment{
+		ID:        change.ID,
+		Delta:     -change.Quantity,
+		Reason:    change.Reason,
+		Reference: change.Reference,
+	}
+}
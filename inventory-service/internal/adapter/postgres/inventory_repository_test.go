@@ -0,0 +1,16 @@
+package postgres
+
+import (
+	"testing"
+
+	"inventory-service/internal/usecase"
+	"inventory-service/internal/usecase/usecasetest"
+	"inventory-service/pkg/postgres/postgrestest"
+)
+
+func TestInventoryRepository(t *testing.T) {
+	usecasetest.TestInventoryRepository(t, func(t *testing.T) usecase.InventoryRepository {
+		pool := postgrestest.New(t)
+		return NewInventoryRepository(pool)
+	})
+}
@@ -2,58 +2,105 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"inventory-service/config"
+	grpcservice "inventory-service/internal/adapter/grpc/service"
 	httpservice "inventory-service/internal/adapter/http/service"
 	postgresrepo "inventory-service/internal/adapter/postgres"
 	"inventory-service/internal/usecase"
+	"inventory-service/migrations"
+	"inventory-service/pkg/health"
 	"inventory-service/pkg/postgres"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const serviceName = "Inventory"
 
 type Application struct {
 	httpServer *httpservice.API
+	grpcServer *grpcservice.API
 	postgresDB *postgres.PostgreDB
-	// grpcServer *grpc.Server // Example
+	health     *health.Health
+
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
 }
 
 func New(ctx context.Context, config *config.Config) (*Application, error) {
-	log.Printf("starting %v service\n", serviceName)
-	log.Println("connecting to postgres")
+	slog.Info("starting service", "service", serviceName)
+	slog.Info("connecting to postgres")
 
 	postgresDB, err := postgres.New(ctx, config.Postgres)
 	if err != nil {
 		return nil, fmt.Errorf("mongo: %w", err)
 	}
-	log.Println("connection established")
+	slog.Info("connection established")
+
+	if config.Postgres.MigrateOnStart {
+		if err := postgres.Migrate(postgresDB.Pool, migrations.FS); err != nil {
+			postgresDB.Pool.Close()
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+	}
+
+	// Exporting the statistics of the connection pool
+	prometheus.MustRegister(postgres.NewCollector(postgresDB.Pool))
 
 	inventoryRepo := postgresrepo.NewInventoryRepository(postgresDB.Pool)
 
 	inventoryUseCase := usecase.NewInventory(inventoryRepo)
-	httpServer := httpservice.New(config.Server, inventoryUseCase)
+
+	// Readiness checks
+	checks := health.New(config.Health)
+	checks.Add("postgres", postgresDB.Check)
+	checks.Add("migrations", postgresDB.CheckMigrations)
+
+	httpServer := httpservice.New(config.Server, inventoryUseCase, checks)
+	grpcServer := grpcservice.New(config.Server, inventoryUseCase)
 
 	app := &Application{
 		httpServer: httpServer,
+		grpcServer: grpcServer,
 		postgresDB: postgresDB,
+		health:     checks,
+
+		shutdownDelay:   config.Server.ShutdownDelay,
+		shutdownTimeout: config.Server.ShutdownTimeout,
 	}
 
 	return app, nil
 }
 
+// Close drains the servers within the shutdown timeout and then closes the connection pool,
+// so that no request is left with a closed pool.
 func (a *Application) Close() {
-	// Closing http server
-	err := a.httpServer.Stop()
+	// Failing the readiness probe, so that no new requests are sent
+	a.health.Shutdown()
+	time.Sleep(a.shutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	// Closing http server, in-flight requests are finished first
+	err := a.httpServer.Stop(ctx)
+
+	// Closing grpc server, in-flight calls are finished first
+	if errGRPC := a.grpcServer.Stop(ctx); errGRPC != nil {
+		err = errors.Join(err, errGRPC)
+	}
 
 	// Closing postgres connection
 	a.postgresDB.Pool.Close()
 
 	if err != nil {
-		log.Println("failed to shutdown service", err)
+		slog.Error("failed to shutdown service", "error", err)
 	}
 }
 
@@ -63,7 +110,10 @@ func (app *Application) Run() error {
 	// Running http server
 	app.httpServer.Run(errCh)
 
-	log.Printf("service %v started\n", serviceName)
+	// Running grpc server
+	app.grpcServer.Run(errCh)
+
+	slog.Info("service started", "service", serviceName)
 
 	// Waiting signal
 	shutdownCh := make(chan os.Signal, 1)
@@ -71,13 +121,14 @@ func (app *Application) Run() error {
 
 	select {
 	case errRun := <-errCh:
+		app.Close()
 		return errRun
 
 	case s := <-shutdownCh:
-		log.Printf("received signal: %v. Running graceful shutdown...\n", s)
+		slog.Info("received signal, running graceful shutdown", "signal", s.String())
 
 		app.Close()
-		log.Println("graceful shutdown completed!")
+		slog.Info("graceful shutdown completed")
 	}
 
 	return nil
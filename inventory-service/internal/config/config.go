@@ -2,14 +2,33 @@ package config
 
 import (
 	"os"
+
+	"inventory-service/pkg/authz"
+	"inventory-service/pkg/logger"
 )
 
 type Config struct {
 	DatabaseURL string
+	Log         logger.Config
+	Authz       authz.Config
 }
 
 func LoadConfig() (*Config, error) {
 	return &Config{
 		DatabaseURL: os.Getenv("DATABASE_URL"),
+		Log: logger.Config{
+			Level:  getenv("LOG_LEVEL", "info"),
+			Format: getenv("LOG_FORMAT", "json"),
+		},
+		Authz: authz.Config{
+			Enforce: getenv("AUTHZ_ENFORCE", "false") == "true",
+		},
 	}, nil
 }
+
+func getenv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -3,10 +3,11 @@ package controller
 import (
 	"net/http"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/entity"
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/usecase"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"inventory-service/internal/entity"
+	"inventory-service/internal/usecase"
+	"inventory-service/pkg/problem"
 )
 
 type CategoryController struct {
@@ -22,12 +23,12 @@ func NewCategoryController(categoryUsecase usecase.CategoryUseCase) *CategoryCon
 func (c *CategoryController) Create(ctx *gin.Context) {
 	var category entity.Category
 	if err := ctx.ShouldBindJSON(&category); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Write(ctx, problem.BadRequest(err.Error()))
 		return
 	}
 
 	if err := c.categoryUsecase.CreateCategory(ctx.Request.Context(), &category); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -37,7 +38,7 @@ func (c *CategoryController) Create(ctx *gin.Context) {
 func (c *CategoryController) List(ctx *gin.Context) {
 	categories, err := c.categoryUsecase.ListCategories(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -47,13 +48,13 @@ func (c *CategoryController) List(ctx *gin.Context) {
 func (c *CategoryController) Get(ctx *gin.Context) {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		problem.Write(ctx, problem.BadRequest("invalid category ID"))
 		return
 	}
 
 	category, err := c.categoryUsecase.GetCategory(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		problem.Write(ctx, problem.NotFound("category not found"))
 		return
 	}
 
@@ -63,7 +64,7 @@ func (c *CategoryController) Get(ctx *gin.Context) {
 func (c *CategoryController) Update(ctx *gin.Context) {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		problem.Write(ctx, problem.BadRequest("invalid category ID"))
 		return
 	}
 
@@ -72,13 +73,13 @@ func (c *CategoryController) Update(ctx *gin.Context) {
 		Description string `json:"description"`
 	}
 	if err := ctx.ShouldBindJSON(&updateData); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Write(ctx, problem.BadRequest(err.Error()))
 		return
 	}
 
 	category, err := c.categoryUsecase.GetCategory(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		problem.Write(ctx, problem.NotFound("category not found"))
 		return
 	}
 
@@ -90,7 +91,7 @@ func (c *CategoryController) Update(ctx *gin.Context) {
 	}
 
 	if err := c.categoryUsecase.UpdateCategory(ctx.Request.Context(), category); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -100,12 +101,12 @@ func (c *CategoryController) Update(ctx *gin.Context) {
 func (c *CategoryController) Delete(ctx *gin.Context) {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		problem.Write(ctx, problem.BadRequest("invalid category ID"))
 		return
 	}
 
 	if err := c.categoryUsecase.DeleteCategory(ctx.Request.Context(), id); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+
+	"inventory-service/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// writeError reports an error of a use case. The cause of internal errors is only logged.
+func writeError(ctx *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		problem.Write(ctx, problem.NotFound("the requested resource could not be found"))
+		return
+	}
+
+	slog.ErrorContext(ctx.Request.Context(), "request failed", "error", err)
+	problem.Write(ctx, problem.Internal())
+}
@@ -4,9 +4,10 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/usecase"
+	"inventory-service/internal/usecase"
+	"inventory-service/pkg/problem"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/entity"
+	"inventory-service/internal/entity"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -33,7 +34,7 @@ type createProductRequest struct {
 func (c *ProductController) CreateProduct(ctx *gin.Context) {
 	var req createProductRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Write(ctx, problem.BadRequest(err.Error()))
 		return
 	}
 
@@ -46,7 +47,7 @@ func (c *ProductController) CreateProduct(ctx *gin.Context) {
 	}
 
 	if err := c.productUsecase.CreateProduct(ctx.Request.Context(), product); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -56,13 +57,13 @@ func (c *ProductController) CreateProduct(ctx *gin.Context) {
 func (c *ProductController) GetProduct(ctx *gin.Context) {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		problem.Write(ctx, problem.BadRequest("invalid product ID"))
 		return
 	}
 
 	product, err := c.productUsecase.GetProduct(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -80,19 +81,19 @@ type updateProductRequest struct {
 func (c *ProductController) UpdateProduct(ctx *gin.Context) {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		problem.Write(ctx, problem.BadRequest("invalid product ID"))
 		return
 	}
 
 	var req updateProductRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Write(ctx, problem.BadRequest(err.Error()))
 		return
 	}
 
 	product, err := c.productUsecase.GetProduct(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		problem.Write(ctx, problem.NotFound("product not found"))
 		return
 	}
 
@@ -113,7 +114,7 @@ func (c *ProductController) UpdateProduct(ctx *gin.Context) {
 	}
 
 	if err := c.productUsecase.UpdateProduct(ctx.Request.Context(), product); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -123,12 +124,12 @@ func (c *ProductController) UpdateProduct(ctx *gin.Context) {
 func (c *ProductController) DeleteProduct(ctx *gin.Context) {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		problem.Write(ctx, problem.BadRequest("invalid product ID"))
 		return
 	}
 
 	if err := c.productUsecase.DeleteProduct(ctx.Request.Context(), id); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
@@ -151,7 +152,7 @@ func (c *ProductController) ListProducts(ctx *gin.Context) {
 
 	products, err := c.productUsecase.ListProducts(ctx.Request.Context(), page, limit, filters)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(ctx, err)
 		return
 	}
 
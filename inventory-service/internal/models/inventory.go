@@ -23,3 +23,60 @@ type UpdateInventoryData struct {
 	Version     *int32
 	IsDeleted   *bool
 }
+
+// Outcomes of a single stock change in a batch.
+const (
+	StockChangeApplied           = "applied"
+	StockChangeInsufficientStock = "insufficient_stock"
+	StockChangeNotFound          = "not_found"
+	StockChangeSkipped           = "skipped" // Would have been applied, but another change of the batch failed
+)
+
+// Reasons a stock adjustment can be made for.
+const (
+	AdjustmentReasonOrderPlaced   = "order_placed"
+	AdjustmentReasonOrderReleased = "order_released"
+	AdjustmentReasonRestock       = "restock"
+	AdjustmentReasonCorrection    = "correction"
+)
+
+var AdjustmentReasons = []string{
+	AdjustmentReasonOrderPlaced,
+	AdjustmentReasonOrderReleased,
+	AdjustmentReasonRestock,
+	AdjustmentReasonCorrection,
+}
+
+// StockAdjustment changes the available stock relative to its current value. Reference
+// identifies the caller's operation, e.g. "order:42"; an adjustment with a reference that
+// was already applied to the product is not applied again.
+type StockAdjustment struct {
+	ID        int64
+	Delta     int64
+	Reason    string
+	Reference string
+}
+
+type StockAdjustmentResult struct {
+	ID        int64
+	Delta     int64
+	Available int64
+	Version   int32
+	Replayed  bool // The reference was applied before, nothing was changed
+}
+
+type StockChange struct {
+	ID        int64
+	Quantity  int64
+	Reason    string
+	Reference string
+}
+
+type StockChangeResult struct {
+	ID        int64
+	Quantity  int64
+	Status    string
+	Available int64
+	Version   int32
+	Replayed  bool
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"inventory-service/internal/entity"
+)
+
+var errCategoryNameTaken = errors.New("category name is already taken")
+
+// InMemoryCategoryRepository keeps categories in memory. It is safe for concurrent use
+// and behaves like the GORM repository: names stay unique, also against deleted
+// categories, and deleted categories are kept but no longer found.
+type InMemoryCategoryRepository struct {
+	mu         sync.RWMutex
+	categories map[uuid.UUID]entity.Category
+}
+
+func NewInMemoryCategoryRepository() *InMemoryCategoryRepository {
+	return &InMemoryCategoryRepository{
+		categories: make(map[uuid.UUID]entity.Category),
+	}
+}
+
+func (r *InMemoryCategoryRepository) Create(ctx context.Context, category *entity.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(category.Name, uuid.Nil) {
+		return errCategoryNameTaken
+	}
+
+	now := time.Now()
+	category.ID = uuid.New()
+	if category.CreatedAt.IsZero() {
+		category.CreatedAt = now
+	}
+	if category.UpdatedAt.IsZero() {
+		category.UpdatedAt = now
+	}
+
+	r.categories[category.ID] = *category
+	return nil
+}
+
+func (r *InMemoryCategoryRepository) FindByID(ctx context.Context, id uuid.UUID) (*entity.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	category, exists := r.categories[id]
+	if !exists || category.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &category, nil
+}
+
+// Update writes every field except the creation time.
+func (r *InMemoryCategoryRepository) Update(ctx context.Context, category *entity.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.categories[category.ID]
+	if !exists || current.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	if r.nameTaken(category.Name, category.ID) {
+		return errCategoryNameTaken
+	}
+
+	category.UpdatedAt = time.Now()
+
+	updated := *category
+	updated.CreatedAt = current.CreatedAt
+	updated.DeletedAt = gorm.DeletedAt{}
+	r.categories[category.ID] = updated
+	return nil
+}
+
+func (r *InMemoryCategoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	category, exists := r.categories[id]
+	if !exists || category.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+
+	category.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.categories[id] = category
+	return nil
+}
+
+func (r *InMemoryCategoryRepository) List(ctx context.Context) ([]*entity.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	categories := make([]*entity.Category, 0, len(r.categories))
+	for _, c := range r.categories {
+		if !c.DeletedAt.Valid {
+			categories = append(categories, &c)
+		}
+	}
+
+	slices.SortFunc(categories, func(a, b *entity.Category) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID.String(), b.ID.String()))
+	})
+	return categories, nil
+}
+
+// exists reports whether a product may reference the category. Like the foreign key, it
+// accepts deleted categories.
+func (r *InMemoryCategoryRepository) exists(id uuid.UUID) bool {
+	if r == nil {
+		return true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.categories[id]
+	return exists
+}
+
+// nameTaken reports whether another category than except has the name. The caller holds
+// the lock.
+func (r *InMemoryCategoryRepository) nameTaken(name string, except uuid.UUID) bool {
+	for _, c := range r.categories {
+		if c.Name == name && c.ID != except {
+			return true
+		}
+	}
+	return false
+}
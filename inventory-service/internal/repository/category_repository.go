@@ -3,9 +3,9 @@ package repository
 import (
 	"context"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/entity"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"inventory-service/internal/entity"
 )
 
 type categoryRepository struct {
@@ -28,17 +28,33 @@ func (r *categoryRepository) FindByID(ctx context.Context, id uuid.UUID) (*entit
 	return &category, nil
 }
 
+// Update writes every field except the creation time. It returns gorm.ErrRecordNotFound
+// instead of creating the category when it does not exist or was deleted.
 func (r *categoryRepository) Update(ctx context.Context, category *entity.Category) error {
-	return r.db.WithContext(ctx).Save(category).Error
+	result := r.db.WithContext(ctx).Model(category).Select("*").Omit("CreatedAt", "DeletedAt").Updates(category)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (r *categoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&entity.Category{}, "id = ?", id).Error
+	result := r.db.WithContext(ctx).Delete(&entity.Category{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (r *categoryRepository) List(ctx context.Context) ([]*entity.Category, error) {
 	var categories []*entity.Category
-	if err := r.db.WithContext(ctx).Find(&categories).Error; err != nil {
+	if err := r.db.WithContext(ctx).Order("created_at").Order("id").Find(&categories).Error; err != nil {
 		return nil, err
 	}
 	return categories, nil
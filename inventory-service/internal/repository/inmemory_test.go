@@ -0,0 +1,21 @@
+package repository_test
+
+import (
+	"testing"
+
+	"inventory-service/internal/repository"
+	"inventory-service/internal/repository/repositorytest"
+)
+
+func TestInMemoryCategoryRepository(t *testing.T) {
+	repositorytest.TestCategoryRepository(t, func(t *testing.T) repository.CategoryRepository {
+		return repository.NewInMemoryCategoryRepository()
+	})
+}
+
+func TestInMemoryProductRepository(t *testing.T) {
+	repositorytest.TestProductRepository(t, func(t *testing.T) (repository.ProductRepository, repository.CategoryRepository) {
+		categories := repository.NewInMemoryCategoryRepository()
+		return repository.NewInMemoryProductRepository(categories), categories
+	})
+}
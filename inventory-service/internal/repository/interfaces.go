@@ -3,8 +3,8 @@ package repository
 import (
 	"context"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/entity"
 	"github.com/google/uuid"
+	"inventory-service/internal/entity"
 )
 
 type ProductRepository interface {
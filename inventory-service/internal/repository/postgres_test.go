@@ -0,0 +1,47 @@
+package repository_test
+
+import (
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"inventory-service/internal/entity"
+	"inventory-service/internal/repository"
+	"inventory-service/internal/repository/repositorytest"
+	"inventory-service/pkg/postgres/postgrestest"
+)
+
+func TestPostgresCategoryRepository(t *testing.T) {
+	repositorytest.TestCategoryRepository(t, func(t *testing.T) repository.CategoryRepository {
+		return repository.NewCategoryRepository(openDB(t))
+	})
+}
+
+func TestPostgresProductRepository(t *testing.T) {
+	repositorytest.TestProductRepository(t, func(t *testing.T) (repository.ProductRepository, repository.CategoryRepository) {
+		db := openDB(t)
+		return repository.NewProductRepository(db), repository.NewCategoryRepository(db)
+	})
+}
+
+// openDB migrates the entities into a new schema, the way cmd/main.go does.
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.Open(postgrestest.DSN(t)), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.Product{}, &entity.Category{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("db: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
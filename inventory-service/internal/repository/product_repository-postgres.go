@@ -4,9 +4,10 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/entity"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"inventory-service/internal/entity"
 )
 
 type productRepository struct {
@@ -29,18 +30,34 @@ func (r *productRepository) FindByID(ctx context.Context, id uuid.UUID) (*entity
 	return &product, nil
 }
 
+// Update writes every field except the creation time. It returns gorm.ErrRecordNotFound
+// instead of creating the product when it does not exist or was deleted.
 func (r *productRepository) Update(ctx context.Context, product *entity.Product) error {
-	return r.db.WithContext(ctx).Save(product).Error
+	result := r.db.WithContext(ctx).Model(product).Select("*").Omit("CreatedAt", "DeletedAt", clause.Associations).Updates(product)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (r *productRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&entity.Product{}, "id = ?", id).Error
+	result := r.db.WithContext(ctx).Delete(&entity.Product{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (r *productRepository) List(ctx context.Context, page, limit int, filters map[string]interface{}) ([]*entity.Product, error) {
 	var products []*entity.Product
 
-	query := r.db.WithContext(ctx).Preload("Category")
+	query := r.db.WithContext(ctx).Preload("Category").Order("created_at").Order("id")
 
 	// Apply filters
 	for key, value := range filters {
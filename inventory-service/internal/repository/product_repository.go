@@ -1,59 +1,183 @@
 package repository
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/entity"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"inventory-service/internal/entity"
 )
 
+var errCategoryMissing = errors.New("product category does not exist")
+
+// InMemoryProductRepository keeps products in memory. It is safe for concurrent use and
+// behaves like the GORM repository: deleted products are kept but no longer found,
+// products are listed by creation time and their category is loaded from categories.
+// Without categories, products are not checked against nor loaded with one.
 type InMemoryProductRepository struct {
-	products map[uuid.UUID]*entity.Product
+	mu         sync.RWMutex
+	products   map[uuid.UUID]entity.Product
+	categories *InMemoryCategoryRepository
 }
 
-func NewInMemoryProductRepository() *InMemoryProductRepository {
+func NewInMemoryProductRepository(categories *InMemoryCategoryRepository) *InMemoryProductRepository {
 	return &InMemoryProductRepository{
-		products: make(map[uuid.UUID]*entity.Product),
+		products:   make(map[uuid.UUID]entity.Product),
+		categories: categories,
 	}
 }
 
 func (r *InMemoryProductRepository) Create(ctx context.Context, product *entity.Product) error {
-	if _, exists := r.products[product.ID]; exists {
-		return errors.New("product already exists")
+	if !r.categories.exists(product.CategoryID) {
+		return errCategoryMissing
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	product.ID = uuid.New()
+	if product.CreatedAt.IsZero() {
+		product.CreatedAt = now
 	}
-	r.products[product.ID] = product
+	if product.UpdatedAt.IsZero() {
+		product.UpdatedAt = now
+	}
+
+	r.products[product.ID] = stored(*product)
 	return nil
 }
 
 func (r *InMemoryProductRepository) FindByID(ctx context.Context, id uuid.UUID) (*entity.Product, error) {
+	r.mu.RLock()
 	product, exists := r.products[id]
-	if !exists {
-		return nil, errors.New("product not found")
+	r.mu.RUnlock()
+
+	if !exists || product.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
 	}
-	return product, nil
+
+	return r.withCategory(product), nil
 }
 
+// Update writes every field except the creation time.
 func (r *InMemoryProductRepository) Update(ctx context.Context, product *entity.Product) error {
-	if _, exists := r.products[product.ID]; !exists {
-		return errors.New("product not found")
+	if !r.categories.exists(product.CategoryID) {
+		return errCategoryMissing
 	}
-	r.products[product.ID] = product
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.products[product.ID]
+	if !exists || current.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+
+	product.UpdatedAt = time.Now()
+
+	updated := stored(*product)
+	updated.CreatedAt = current.CreatedAt
+	r.products[product.ID] = updated
 	return nil
 }
 
 func (r *InMemoryProductRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	if _, exists := r.products[id]; !exists {
-		return errors.New("product not found")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, exists := r.products[id]
+	if !exists || product.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
 	}
-	delete(r.products, id)
+
+	product.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.products[id] = product
 	return nil
 }
 
+// List supports the same filter columns as the products table.
 func (r *InMemoryProductRepository) List(ctx context.Context, page, limit int, filters map[string]interface{}) ([]*entity.Product, error) {
-	var products []*entity.Product
+	r.mu.RLock()
+	var matching []entity.Product
 	for _, p := range r.products {
-		products = append(products, p)
+		if p.DeletedAt.Valid {
+			continue
+		}
+
+		ok, err := matches(p, filters)
+		if err != nil {
+			r.mu.RUnlock()
+			return nil, err
+		}
+		if ok {
+			matching = append(matching, p)
+		}
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(matching, func(a, b entity.Product) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID.String(), b.ID.String()))
+	})
+
+	// Apply pagination
+	if page > 0 && limit > 0 {
+		offset := min((page-1)*limit, len(matching))
+		matching = matching[offset:min(offset+limit, len(matching))]
+	}
+
+	products := make([]*entity.Product, 0, len(matching))
+	for _, p := range matching {
+		products = append(products, r.withCategory(p))
 	}
 	return products, nil
 }
+
+// withCategory returns a copy of the product with its category loaded, like Preload.
+func (r *InMemoryProductRepository) withCategory(product entity.Product) *entity.Product {
+	if r.categories == nil {
+		return &product
+	}
+	if category, err := r.categories.FindByID(context.Background(), product.CategoryID); err == nil {
+		product.Category = *category
+	}
+	return &product
+}
+
+// stored drops the loaded category, it is only saved as CategoryID.
+func stored(product entity.Product) entity.Product {
+	product.Category = entity.Category{}
+	return product
+}
+
+func matches(p entity.Product, filters map[string]interface{}) (bool, error) {
+	for key, value := range filters {
+		var field any
+		switch key {
+		case "name":
+			field = p.Name
+		case "description":
+			field = p.Description
+		case "price":
+			field = p.Price
+		case "stock":
+			field = p.Stock
+		case "category_id":
+			field = p.CategoryID
+		default:
+			return false, fmt.Errorf("column %q does not exist", key)
+		}
+
+		if fmt.Sprint(field) != fmt.Sprint(value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,406 @@
+// Package repositorytest holds conformance tests for the product and category
+// repositories. The GORM and the in-memory implementations run the same tests.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"inventory-service/internal/entity"
+	"inventory-service/internal/repository"
+)
+
+// TestCategoryRepository runs the conformance tests against the repositories returned by
+// newRepo. Every test gets a new, empty repository.
+func TestCategoryRepository(t *testing.T, newRepo func(t *testing.T) repository.CategoryRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo repository.CategoryRepository)
+	}{
+		{"CreateAndFind", testCategoryCreateAndFind},
+		{"UniqueName", testCategoryUniqueName},
+		{"Update", testCategoryUpdate},
+		{"SoftDelete", testCategorySoftDelete},
+		{"List", testCategoryList},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+// TestProductRepository runs the conformance tests against the repositories returned by
+// newRepos. Products refer to categories, so every test gets a new, empty pair.
+func TestProductRepository(t *testing.T, newRepos func(t *testing.T) (repository.ProductRepository, repository.CategoryRepository)) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo repository.ProductRepository, categories repository.CategoryRepository)
+	}{
+		{"CreateAndFind", testProductCreateAndFind},
+		{"UnknownCategory", testProductUnknownCategory},
+		{"Update", testProductUpdate},
+		{"SoftDelete", testProductSoftDelete},
+		{"ListPagination", testProductListPagination},
+		{"ListFilters", testProductListFilters},
+		{"ConcurrentCreate", testProductConcurrentCreate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, categories := newRepos(t)
+			tt.test(t, repo, categories)
+		})
+	}
+}
+
+func testCategoryCreateAndFind(t *testing.T, repo repository.CategoryRepository) {
+	category := createCategory(t, repo, "keyboards")
+	if category.ID == uuid.Nil || category.CreatedAt.IsZero() || category.UpdatedAt.IsZero() {
+		t.Errorf("created category = %+v, want an ID and timestamps", category)
+	}
+
+	found, err := repo.FindByID(context.Background(), category.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if found.Name != "keyboards" || found.Description != "keyboards for sale" {
+		t.Errorf("FindByID = %+v", found)
+	}
+
+	if _, err := repo.FindByID(context.Background(), uuid.New()); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByID of a missing category: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func testCategoryUniqueName(t *testing.T, repo repository.CategoryRepository) {
+	ctx := context.Background()
+	category := createCategory(t, repo, "keyboards")
+
+	if err := repo.Create(ctx, &entity.Category{Name: "keyboards"}); err == nil {
+		t.Error("Create with a taken name succeeded")
+	}
+
+	// Deleted categories keep their name
+	if err := repo.Delete(ctx, category.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Create(ctx, &entity.Category{Name: "keyboards"}); err == nil {
+		t.Error("Create with the name of a deleted category succeeded")
+	}
+}
+
+func testCategoryUpdate(t *testing.T, repo repository.CategoryRepository) {
+	ctx := context.Background()
+	category := createCategory(t, repo, "keyboards")
+	before := findCategory(t, repo, category.ID)
+
+	before.Name = "mice"
+	before.Description = "mice for sale"
+	if err := repo.Update(ctx, before); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	after := findCategory(t, repo, category.ID)
+	if after.Name != "mice" || after.Description != "mice for sale" {
+		t.Errorf("updated category = %+v", after)
+	}
+	if !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("created at changed from %v to %v", before.CreatedAt, after.CreatedAt)
+	}
+
+	missing := &entity.Category{ID: uuid.New(), Name: "ghosts"}
+	if err := repo.Update(ctx, missing); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Update of a missing category: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func testCategorySoftDelete(t *testing.T, repo repository.CategoryRepository) {
+	ctx := context.Background()
+	category := createCategory(t, repo, "keyboards")
+	keep := createCategory(t, repo, "mice")
+
+	if err := repo.Delete(ctx, category.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, category.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("second Delete: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	if _, err := repo.FindByID(ctx, category.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByID of a deleted category: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	if err := repo.Update(ctx, category); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Update of a deleted category: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+
+	categories, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(categories) != 1 || categories[0].ID != keep.ID {
+		t.Errorf("List = %v, want only %v", categoryIDs(categories), keep.ID)
+	}
+}
+
+func testCategoryList(t *testing.T, repo repository.CategoryRepository) {
+	var want []uuid.UUID
+	for _, name := range []string{"a", "b", "c"} {
+		want = append(want, createCategory(t, repo, name).ID)
+	}
+
+	categories, err := repo.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	got := categoryIDs(categories)
+	if !sameIDs(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+	if !slices.IsSortedFunc(categories, func(a, b *entity.Category) int { return a.CreatedAt.Compare(b.CreatedAt) }) {
+		t.Error("List is not ordered by creation time")
+	}
+}
+
+func testProductCreateAndFind(t *testing.T, repo repository.ProductRepository, categories repository.CategoryRepository) {
+	category := createCategory(t, categories, "keyboards")
+	product := createProduct(t, repo, category.ID, "keyboard")
+	if product.ID == uuid.Nil || product.CreatedAt.IsZero() || product.UpdatedAt.IsZero() {
+		t.Errorf("created product = %+v, want an ID and timestamps", product)
+	}
+
+	found, err := repo.FindByID(context.Background(), product.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if found.Name != "keyboard" || found.Price != 19.99 || found.Stock != 10 || found.CategoryID != category.ID {
+		t.Errorf("FindByID = %+v", found)
+	}
+	if found.Category.ID != category.ID || found.Category.Name != "keyboards" {
+		t.Errorf("category = %+v, want it loaded", found.Category)
+	}
+
+	if _, err := repo.FindByID(context.Background(), uuid.New()); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByID of a missing product: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func testProductUnknownCategory(t *testing.T, repo repository.ProductRepository, categories repository.CategoryRepository) {
+	err := repo.Create(context.Background(), &entity.Product{Name: "keyboard", Price: 1, Stock: 1, CategoryID: uuid.New()})
+	if err == nil {
+		t.Error("Create with an unknown category succeeded")
+	}
+}
+
+func testProductUpdate(t *testing.T, repo repository.ProductRepository, categories repository.CategoryRepository) {
+	ctx := context.Background()
+	category := createCategory(t, categories, "keyboards")
+	other := createCategory(t, categories, "mice")
+	product := createProduct(t, repo, category.ID, "keyboard")
+	before := findProduct(t, repo, product.ID)
+
+	before.Stock = 3
+	before.Price = 24.5
+	before.CategoryID = other.ID
+	if err := repo.Update(ctx, before); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	after := findProduct(t, repo, product.ID)
+	if after.Stock != 3 || after.Price != 24.5 || after.CategoryID != other.ID || after.Category.Name != "mice" {
+		t.Errorf("updated product = %+v", after)
+	}
+	if !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("created at changed from %v to %v", before.CreatedAt, after.CreatedAt)
+	}
+
+	missing := &entity.Product{ID: uuid.New(), Name: "ghost", Price: 1, CategoryID: category.ID}
+	if err := repo.Update(ctx, missing); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Update of a missing product: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func testProductSoftDelete(t *testing.T, repo repository.ProductRepository, categories repository.CategoryRepository) {
+	ctx := context.Background()
+	category := createCategory(t, categories, "keyboards")
+	product := createProduct(t, repo, category.ID, "keyboard")
+	keep := createProduct(t, repo, category.ID, "mouse")
+
+	if err := repo.Delete(ctx, product.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, product.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("second Delete: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	if _, err := repo.FindByID(ctx, product.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByID of a deleted product: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	if err := repo.Update(ctx, product); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Update of a deleted product: err = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+
+	products := listProducts(t, repo, 0, 0, nil)
+	if len(products) != 1 || products[0].ID != keep.ID {
+		t.Errorf("List = %v, want only %v", productIDs(products), keep.ID)
+	}
+
+	// A deleted category is no longer loaded
+	if err := categories.Delete(ctx, category.ID); err != nil {
+		t.Fatalf("Delete category: %v", err)
+	}
+	if found := findProduct(t, repo, keep.ID); found.Category.ID != uuid.Nil {
+		t.Errorf("category of the product = %+v, want none", found.Category)
+	}
+}
+
+func testProductListPagination(t *testing.T, repo repository.ProductRepository, categories repository.CategoryRepository) {
+	category := createCategory(t, categories, "keyboards")
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		createProduct(t, repo, category.ID, name)
+	}
+
+	all := listProducts(t, repo, 0, 0, nil)
+	if len(all) != 5 {
+		t.Fatalf("List without pagination returned %d products, want 5", len(all))
+	}
+	if !slices.IsSortedFunc(all, func(a, b *entity.Product) int { return a.CreatedAt.Compare(b.CreatedAt) }) {
+		t.Error("List is not ordered by creation time")
+	}
+
+	var paged []*entity.Product
+	for page := 1; page <= 3; page++ {
+		paged = append(paged, listProducts(t, repo, page, 2, nil)...)
+	}
+	if got, want := productIDs(paged), productIDs(all); !slices.Equal(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+
+	if past := listProducts(t, repo, 4, 2, nil); len(past) != 0 {
+		t.Errorf("page past the end = %v, want none", productIDs(past))
+	}
+}
+
+func testProductListFilters(t *testing.T, repo repository.ProductRepository, categories repository.CategoryRepository) {
+	keyboards := createCategory(t, categories, "keyboards")
+	mice := createCategory(t, categories, "mice")
+	keyboard := createProduct(t, repo, keyboards.ID, "keyboard")
+	mouse := createProduct(t, repo, mice.ID, "mouse")
+	createProduct(t, repo, mice.ID, "trackball")
+
+	byCategory := listProducts(t, repo, 1, 10, map[string]interface{}{"category_id": keyboards.ID})
+	if got := productIDs(byCategory); !slices.Equal(got, []uuid.UUID{keyboard.ID}) {
+		t.Errorf("products of a category = %v, want %v", got, keyboard.ID)
+	}
+
+	byName := listProducts(t, repo, 1, 10, map[string]interface{}{"name": "mouse", "category_id": mice.ID})
+	if got := productIDs(byName); !slices.Equal(got, []uuid.UUID{mouse.ID}) {
+		t.Errorf("products by name = %v, want %v", got, mouse.ID)
+	}
+
+	_, err := repo.List(context.Background(), 1, 10, map[string]interface{}{"colour": "red"})
+	if err == nil {
+		t.Error("List with an unknown column succeeded")
+	}
+}
+
+func testProductConcurrentCreate(t *testing.T, repo repository.ProductRepository, categories repository.CategoryRepository) {
+	category := createCategory(t, categories, "keyboards")
+
+	const workers = 20
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Create(context.Background(), &entity.Product{Name: "keyboard", Price: 1, Stock: 1, CategoryID: category.ID}); err != nil {
+				t.Errorf("Create: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if products := listProducts(t, repo, 0, 0, nil); len(products) != workers {
+		t.Errorf("List returned %d products, want %d", len(products), workers)
+	}
+}
+
+func createCategory(t *testing.T, repo repository.CategoryRepository, name string) *entity.Category {
+	t.Helper()
+
+	category := &entity.Category{Name: name, Description: name + " for sale"}
+	if err := repo.Create(context.Background(), category); err != nil {
+		t.Fatalf("Create category: %v", err)
+	}
+	return category
+}
+
+func findCategory(t *testing.T, repo repository.CategoryRepository, id uuid.UUID) *entity.Category {
+	t.Helper()
+
+	category, err := repo.FindByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("FindByID %v: %v", id, err)
+	}
+	return category
+}
+
+func createProduct(t *testing.T, repo repository.ProductRepository, categoryID uuid.UUID, name string) *entity.Product {
+	t.Helper()
+
+	product := &entity.Product{Name: name, Description: name, Price: 19.99, Stock: 10, CategoryID: categoryID}
+	if err := repo.Create(context.Background(), product); err != nil {
+		t.Fatalf("Create product: %v", err)
+	}
+	return product
+}
+
+func findProduct(t *testing.T, repo repository.ProductRepository, id uuid.UUID) *entity.Product {
+	t.Helper()
+
+	product, err := repo.FindByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("FindByID %v: %v", id, err)
+	}
+	return product
+}
+
+func listProducts(t *testing.T, repo repository.ProductRepository, page, limit int, filters map[string]interface{}) []*entity.Product {
+	t.Helper()
+
+	products, err := repo.List(context.Background(), page, limit, filters)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return products
+}
+
+func categoryIDs(categories []*entity.Category) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(categories))
+	for _, c := range categories {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func productIDs(products []*entity.Product) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+// sameIDs compares ignoring the order.
+func sameIDs(a, b []uuid.UUID) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	cmp := func(x, y uuid.UUID) int { return slices.Compare(x[:], y[:]) }
+	slices.SortFunc(a, cmp)
+	slices.SortFunc(b, cmp)
+	return slices.Equal(a, b)
+}
@@ -2,19 +2,19 @@ package usecase
 
 import (
 	"context"
+	"log/slog"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/entity"
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/repository"
 	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
+	"inventory-service/internal/entity"
+	"inventory-service/internal/repository"
 )
 
 type categoryUseCase struct {
 	categoryRepo repository.CategoryRepository
-	log          *logrus.Logger
+	log          *slog.Logger
 }
 
-func NewCategoryUsecase(categoryRepo repository.CategoryRepository, log *logrus.Logger) CategoryUseCase {
+func NewCategoryUsecase(categoryRepo repository.CategoryRepository, log *slog.Logger) CategoryUseCase {
 	return &categoryUseCase{
 		categoryRepo: categoryRepo,
 		log:          log,
@@ -2,11 +2,25 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"inventory-service/internal/adapter/http/service/handlers/dto"
+	"inventory-service/internal/adapter/postgres/dao"
 	"inventory-service/internal/models"
+	"inventory-service/pkg/tracing"
 	"inventory-service/pkg/validator"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("inventory-service/internal/usecase")
+
+var stockConflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "inventory_stock_conflicts_total",
+	Help: "Number of stock changes refused because they would take more than is available, by operation.",
+}, []string{"operation"})
+
 type Inventory struct {
 	invRepo InventoryRepository
 }
@@ -16,9 +30,12 @@ func NewInventory(invRepo InventoryRepository) *Inventory {
 }
 
 func (c *Inventory) CreateItem(ctx context.Context, request models.Inventory) (models.Inventory, error) {
+	ctx, span := tracer.Start(ctx, "Inventory.CreateItem")
+	defer span.End()
+
 	id, err := c.invRepo.CreateItem(ctx, request)
 	if err != nil {
-		return models.Inventory{}, err
+		return models.Inventory{}, tracing.Error(span, err)
 	}
 	request.ID = id
 	return request, nil
@@ -26,16 +43,22 @@ func (c *Inventory) CreateItem(ctx context.Context, request models.Inventory) (m
 }
 
 func (c *Inventory) Get(ctx context.Context, id int64) (models.Inventory, error) {
+	ctx, span := tracer.Start(ctx, "Inventory.Get")
+	defer span.End()
+
 	inv, err := c.invRepo.Get(ctx, id)
 
 	if err != nil {
-		return models.Inventory{}, err
+		return models.Inventory{}, tracing.Error(span, err)
 	}
 
 	return inv, nil
 }
 
 func (c *Inventory) GetListInventory(ctx context.Context, filters models.Filters) ([]models.Inventory, dto.Metadata, error) {
+	ctx, span := tracer.Start(ctx, "Inventory.GetListInventory")
+	defer span.End()
+
 	v := validator.New()
 	models.ValidateFilters(v, filters)
 
@@ -46,7 +69,7 @@ func (c *Inventory) GetListInventory(ctx context.Context, filters models.Filters
 	items, totalRecords, err := c.invRepo.GetListInventory(ctx, filters)
 
 	if err != nil {
-		return nil, dto.Metadata{}, err
+		return nil, dto.Metadata{}, tracing.Error(span, err)
 	}
 
 	metadata := dto.CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
@@ -55,9 +78,12 @@ func (c *Inventory) GetListInventory(ctx context.Context, filters models.Filters
 }
 
 func (c *Inventory) Update(ctx context.Context, request models.UpdateInventoryData) (models.Inventory, error) {
+	ctx, span := tracer.Start(ctx, "Inventory.Update")
+	defer span.End()
+
 	item, err := c.invRepo.Get(ctx, *request.ID)
 	if err != nil {
-		return models.Inventory{}, err
+		return models.Inventory{}, tracing.Error(span, err)
 	}
 
 	if request.Version != nil && item.Version != *request.Version {
@@ -90,16 +116,63 @@ func (c *Inventory) Update(ctx context.Context, request models.UpdateInventoryDa
 
 	err = c.invRepo.Update(ctx, &item)
 	if err != nil {
-		return models.Inventory{}, err
+		return models.Inventory{}, tracing.Error(span, err)
 	}
 
 	return item, nil
 }
 
 func (c *Inventory) Delete(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "Inventory.Delete")
+	defer span.End()
+
 	err := c.invRepo.Delete(ctx, id)
 	if err != nil {
-		return err
+		return tracing.Error(span, err)
 	}
 	return nil
 }
+
+func (c *Inventory) GetMany(ctx context.Context, ids []int64) ([]models.Inventory, error) {
+	ctx, span := tracer.Start(ctx, "Inventory.GetMany")
+	defer span.End()
+
+	items, err := c.invRepo.GetMany(ctx, ids)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+
+	return items, nil
+}
+
+// DecrementMany takes the given quantities out of stock, either all of them or none.
+func (c *Inventory) DecrementMany(ctx context.Context, changes []models.StockChange) ([]models.StockChangeResult, bool, error) {
+	ctx, span := tracer.Start(ctx, "Inventory.DecrementMany")
+	defer span.End()
+
+	results, applied, err := c.invRepo.DecrementMany(ctx, changes)
+	if err != nil {
+		return nil, false, tracing.Error(span, err)
+	}
+	if !applied {
+		stockConflicts.WithLabelValues("batch_decrement").Inc()
+	}
+
+	return results, applied, nil
+}
+
+// Adjust changes the available stock relative to its current value.
+func (c *Inventory) Adjust(ctx context.Context, adj models.StockAdjustment) (models.StockAdjustmentResult, error) {
+	ctx, span := tracer.Start(ctx, "Inventory.Adjust")
+	defer span.End()
+
+	result, err := c.invRepo.Adjust(ctx, adj)
+	if err != nil {
+		if errors.Is(err, dao.ErrInsufficientStock) {
+			stockConflicts.WithLabelValues("adjust").Inc()
+		}
+		return models.StockAdjustmentResult{}, tracing.Error(span, err)
+	}
+
+	return result, nil
+}
@@ -3,19 +3,19 @@ package usecase
 import (
 	"context"
 	"errors"
+	"log/slog"
 
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/entity"
-	"github.com/bakhytzhanjzz/ecommerce-platform/inventory-service/internal/repository"
 	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
+	"inventory-service/internal/entity"
+	"inventory-service/internal/repository"
 )
 
 type productUseCase struct {
 	productRepo repository.ProductRepository
-	log         *logrus.Logger
+	log         *slog.Logger
 }
 
-func NewProductUsecase(productRepo repository.ProductRepository, log *logrus.Logger) ProductUseCase {
+func NewProductUsecase(productRepo repository.ProductRepository, log *slog.Logger) ProductUseCase {
 	return &productUseCase{
 		productRepo: productRepo,
 		log:         log,
@@ -24,7 +24,7 @@ func NewProductUsecase(productRepo repository.ProductRepository, log *logrus.Log
 
 func (uc *productUseCase) CreateProduct(ctx context.Context, product *entity.Product) error {
 	if err := uc.productRepo.Create(ctx, product); err != nil {
-		uc.log.WithError(err).Error("Failed to create product")
+		uc.log.ErrorContext(ctx, "Failed to create product", "error", err)
 		return err
 	}
 	return nil
@@ -33,7 +33,7 @@ func (uc *productUseCase) CreateProduct(ctx context.Context, product *entity.Pro
 func (uc *productUseCase) GetProduct(ctx context.Context, id uuid.UUID) (*entity.Product, error) {
 	product, err := uc.productRepo.FindByID(ctx, id)
 	if err != nil {
-		uc.log.WithError(err).Error("Failed to get product")
+		uc.log.ErrorContext(ctx, "Failed to get product", "error", err)
 		return nil, err
 	}
 	return product, nil
@@ -41,7 +41,7 @@ func (uc *productUseCase) GetProduct(ctx context.Context, id uuid.UUID) (*entity
 
 func (uc *productUseCase) UpdateProduct(ctx context.Context, product *entity.Product) error {
 	if err := uc.productRepo.Update(ctx, product); err != nil {
-		uc.log.WithError(err).Error("Failed to update product")
+		uc.log.ErrorContext(ctx, "Failed to update product", "error", err)
 		return err
 	}
 	return nil
@@ -49,7 +49,7 @@ func (uc *productUseCase) UpdateProduct(ctx context.Context, product *entity.Pro
 
 func (uc *productUseCase) DeleteProduct(ctx context.Context, id uuid.UUID) error {
 	if err := uc.productRepo.Delete(ctx, id); err != nil {
-		uc.log.WithError(err).Error("Failed to delete product")
+		uc.log.ErrorContext(ctx, "Failed to delete product", "error", err)
 		return err
 	}
 	return nil
@@ -58,7 +58,7 @@ func (uc *productUseCase) DeleteProduct(ctx context.Context, id uuid.UUID) error
 func (uc *productUseCase) ListProducts(ctx context.Context, page, limit int, filters map[string]interface{}) ([]*entity.Product, error) {
 	products, err := uc.productRepo.List(ctx, page, limit, filters)
 	if err != nil {
-		uc.log.WithError(err).Error("Failed to list products")
+		uc.log.ErrorContext(ctx, "Failed to list products", "error", err)
 		return nil, err
 	}
 	return products, nil
@@ -67,7 +67,7 @@ func (uc *productUseCase) ListProducts(ctx context.Context, page, limit int, fil
 func (uc *productUseCase) DecreaseStock(ctx context.Context, productID uuid.UUID, amount int) error {
 	product, err := uc.productRepo.FindByID(ctx, productID)
 	if err != nil {
-		uc.log.WithError(err).Error("Failed to find product for stock decrease")
+		uc.log.ErrorContext(ctx, "Failed to find product for stock decrease", "error", err)
 		return err
 	}
 
@@ -82,7 +82,7 @@ func (uc *productUseCase) DecreaseStock(ctx context.Context, productID uuid.UUID
 func (uc *productUseCase) IncreaseStock(ctx context.Context, productID uuid.UUID, amount int) error {
 	product, err := uc.productRepo.FindByID(ctx, productID)
 	if err != nil {
-		uc.log.WithError(err).Error("Failed to find product for stock increase")
+		uc.log.ErrorContext(ctx, "Failed to find product for stock increase", "error", err)
 		return err
 	}
 
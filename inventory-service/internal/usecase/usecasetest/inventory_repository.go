@@ -0,0 +1,471 @@
+// Package usecasetest holds conformance tests for the repositories the use cases depend
+// on. Every implementation runs the same tests, so the in-memory repositories can stand
+// in for Postgres.
+package usecasetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"inventory-service/internal/adapter/postgres/dao"
+	"inventory-service/internal/models"
+	"inventory-service/internal/usecase"
+)
+
+// TestInventoryRepository runs the conformance tests against the repositories returned by
+// newRepo. Every test gets a new, empty repository.
+func TestInventoryRepository(t *testing.T, newRepo func(t *testing.T) usecase.InventoryRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo usecase.InventoryRepository)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"GetMissing", testGetMissing},
+		{"Update", testUpdate},
+		{"UpdateStaleVersion", testUpdateStaleVersion},
+		{"SoftDelete", testSoftDelete},
+		{"ListPagination", testListPagination},
+		{"ListSort", testListSort},
+		{"GetMany", testGetMany},
+		{"Adjust", testAdjust},
+		{"AdjustReplay", testAdjustReplay},
+		{"AdjustConcurrent", testAdjustConcurrent},
+		{"DecrementManyAllOrNothing", testDecrementManyAllOrNothing},
+		{"DecrementManyReplay", testDecrementManyReplay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+func testCreateAndGet(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+
+	id := create(t, repo, models.Inventory{Name: "keyboard", Description: "mechanical", Price: 49.99, Available: 10})
+
+	item, err := repo.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if item.ID != id || item.Name != "keyboard" || item.Description != "mechanical" || item.Price != 49.99 || item.Available != 10 {
+		t.Errorf("Get = %+v", item)
+	}
+	if item.Version != 1 || item.IsDeleted || item.CreatedAt.IsZero() {
+		t.Errorf("new item: version %d, deleted %v, created at %v", item.Version, item.IsDeleted, item.CreatedAt)
+	}
+
+	other := create(t, repo, models.Inventory{Name: "mouse", Description: "wireless", Price: 19, Available: 0})
+	if other == id {
+		t.Errorf("both items got ID %d", id)
+	}
+}
+
+func testGetMissing(t *testing.T, repo usecase.InventoryRepository) {
+	_, err := repo.Get(context.Background(), 1_000_000)
+	if !errors.Is(err, dao.ErrRecordNotFound) {
+		t.Errorf("Get of a missing item: err = %v, want %v", err, dao.ErrRecordNotFound)
+	}
+}
+
+func testUpdate(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+	id := create(t, repo, models.Inventory{Name: "keyboard", Description: "mechanical", Price: 49.99, Available: 10})
+
+	item := get(t, repo, id)
+	item.Name = "keyboard pro"
+	item.Price = 59
+	item.Available = 7
+
+	if err := repo.Update(ctx, &item); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if item.Version != 2 {
+		t.Errorf("version after update = %d, want 2", item.Version)
+	}
+
+	stored := get(t, repo, id)
+	if stored.Name != "keyboard pro" || stored.Price != 59 || stored.Available != 7 || stored.Version != 2 {
+		t.Errorf("stored = %+v", stored)
+	}
+}
+
+func testUpdateStaleVersion(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+	id := create(t, repo, models.Inventory{Name: "keyboard", Description: "mechanical", Price: 49.99, Available: 10})
+
+	first := get(t, repo, id)
+	second := get(t, repo, id)
+
+	first.Available = 5
+	if err := repo.Update(ctx, &first); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	second.Available = 3
+	if err := repo.Update(ctx, &second); !errors.Is(err, dao.ErrEditConflict) {
+		t.Errorf("Update with a stale version: err = %v, want %v", err, dao.ErrEditConflict)
+	}
+
+	if stored := get(t, repo, id); stored.Available != 5 {
+		t.Errorf("available = %d, want 5", stored.Available)
+	}
+
+	missing := models.Inventory{ID: 1_000_000, Name: "ghost", Description: "none", Price: 1, Version: 1}
+	if err := repo.Update(ctx, &missing); !errors.Is(err, dao.ErrRecordNotFound) {
+		t.Errorf("Update of a missing item: err = %v, want %v", err, dao.ErrRecordNotFound)
+	}
+}
+
+func testSoftDelete(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+	id := create(t, repo, models.Inventory{Name: "keyboard", Description: "mechanical", Price: 49.99, Available: 10})
+	keep := create(t, repo, models.Inventory{Name: "mouse", Description: "wireless", Price: 19, Available: 3})
+
+	item := get(t, repo, id)
+
+	if err := repo.Delete(ctx, id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, id); !errors.Is(err, dao.ErrRecordNotFound) {
+		t.Errorf("second Delete: err = %v, want %v", err, dao.ErrRecordNotFound)
+	}
+	if err := repo.Delete(ctx, 1_000_000); !errors.Is(err, dao.ErrRecordNotFound) {
+		t.Errorf("Delete of a missing item: err = %v, want %v", err, dao.ErrRecordNotFound)
+	}
+
+	if _, err := repo.Get(ctx, id); !errors.Is(err, dao.ErrRecordNotFound) {
+		t.Errorf("Get of a deleted item: err = %v, want %v", err, dao.ErrRecordNotFound)
+	}
+	if err := repo.Update(ctx, &item); !errors.Is(err, dao.ErrRecordNotFound) {
+		t.Errorf("Update of a deleted item: err = %v, want %v", err, dao.ErrRecordNotFound)
+	}
+	if _, err := repo.Adjust(ctx, models.StockAdjustment{ID: id, Delta: 1, Reason: models.AdjustmentReasonRestock}); !errors.Is(err, dao.ErrRecordNotFound) {
+		t.Errorf("Adjust of a deleted item: err = %v, want %v", err, dao.ErrRecordNotFound)
+	}
+
+	items, err := repo.GetMany(ctx, []int64{id, keep})
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != keep {
+		t.Errorf("GetMany = %v, want only %d", ids(items), keep)
+	}
+
+	list, total, err := repo.GetListInventory(ctx, filters(1, 10, "id"))
+	if err != nil {
+		t.Fatalf("GetListInventory: %v", err)
+	}
+	if total != 1 || len(list) != 1 || list[0].ID != keep {
+		t.Errorf("GetListInventory = %v of %d, want only %d", ids(list), total, keep)
+	}
+}
+
+func testListPagination(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+
+	var created []int64
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		created = append(created, create(t, repo, models.Inventory{Name: name, Description: name, Price: 1, Available: 1}))
+	}
+
+	pages := []struct {
+		page, size int
+		want       []int64
+	}{
+		{1, 2, created[0:2]},
+		{2, 2, created[2:4]},
+		{3, 2, created[4:5]},
+		{1, 10, created},
+	}
+	for _, p := range pages {
+		items, total, err := repo.GetListInventory(ctx, filters(p.page, p.size, "id"))
+		if err != nil {
+			t.Fatalf("GetListInventory page %d: %v", p.page, err)
+		}
+		if total != 5 {
+			t.Errorf("page %d of %d: total = %d, want 5", p.page, p.size, total)
+		}
+		if !slices.Equal(ids(items), p.want) {
+			t.Errorf("page %d of %d = %v, want %v", p.page, p.size, ids(items), p.want)
+		}
+	}
+
+	items, _, err := repo.GetListInventory(ctx, filters(4, 2, "id"))
+	if err != nil {
+		t.Fatalf("GetListInventory past the end: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("page past the end = %v, want none", ids(items))
+	}
+}
+
+func testListSort(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+
+	cheap := create(t, repo, models.Inventory{Name: "c", Description: "c", Price: 5, Available: 9})
+	dear := create(t, repo, models.Inventory{Name: "a", Description: "a", Price: 50, Available: 1})
+	tie := create(t, repo, models.Inventory{Name: "b", Description: "b", Price: 5, Available: 4})
+
+	// Ties are broken by ID in ascending order, whatever the direction
+	sorts := []struct {
+		sort string
+		want []int64
+	}{
+		{"name", []int64{dear, tie, cheap}},
+		{"-name", []int64{cheap, tie, dear}},
+		{"price", []int64{cheap, tie, dear}},
+		{"-price", []int64{dear, cheap, tie}},
+		{"-available", []int64{cheap, tie, dear}},
+		{"-id", []int64{tie, dear, cheap}},
+	}
+	for _, s := range sorts {
+		items, _, err := repo.GetListInventory(ctx, filters(1, 10, s.sort))
+		if err != nil {
+			t.Fatalf("GetListInventory sorted by %s: %v", s.sort, err)
+		}
+		if !slices.Equal(ids(items), s.want) {
+			t.Errorf("sorted by %s = %v, want %v", s.sort, ids(items), s.want)
+		}
+	}
+}
+
+func testGetMany(t *testing.T, repo usecase.InventoryRepository) {
+	first := create(t, repo, models.Inventory{Name: "a", Description: "a", Price: 1, Available: 1})
+	create(t, repo, models.Inventory{Name: "b", Description: "b", Price: 1, Available: 1})
+	third := create(t, repo, models.Inventory{Name: "c", Description: "c", Price: 1, Available: 1})
+
+	items, err := repo.GetMany(context.Background(), []int64{third, 1_000_000, first, third})
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	if want := []int64{first, third}; !slices.Equal(ids(items), want) {
+		t.Errorf("GetMany = %v, want %v", ids(items), want)
+	}
+}
+
+func testAdjust(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+	id := create(t, repo, models.Inventory{Name: "keyboard", Description: "mechanical", Price: 49.99, Available: 10})
+
+	result, err := repo.Adjust(ctx, models.StockAdjustment{ID: id, Delta: 5, Reason: models.AdjustmentReasonRestock})
+	if err != nil {
+		t.Fatalf("Adjust: %v", err)
+	}
+	if result.Available != 15 || result.Version != 2 || result.Replayed {
+		t.Errorf("Adjust = %+v, want 15 available at version 2", result)
+	}
+
+	// Without a reference nothing is deduplicated
+	result, err = repo.Adjust(ctx, models.StockAdjustment{ID: id, Delta: -15, Reason: models.AdjustmentReasonCorrection})
+	if err != nil {
+		t.Fatalf("Adjust: %v", err)
+	}
+	if result.Available != 0 || result.Version != 3 {
+		t.Errorf("Adjust = %+v, want 0 available at version 3", result)
+	}
+
+	_, err = repo.Adjust(ctx, models.StockAdjustment{ID: id, Delta: -1, Reason: models.AdjustmentReasonCorrection})
+	if !errors.Is(err, dao.ErrInsufficientStock) {
+		t.Errorf("Adjust below zero: err = %v, want %v", err, dao.ErrInsufficientStock)
+	}
+
+	_, err = repo.Adjust(ctx, models.StockAdjustment{ID: 1_000_000, Delta: 1, Reason: models.AdjustmentReasonRestock})
+	if !errors.Is(err, dao.ErrRecordNotFound) {
+		t.Errorf("Adjust of a missing item: err = %v, want %v", err, dao.ErrRecordNotFound)
+	}
+
+	if stored := get(t, repo, id); stored.Available != 0 || stored.Version != 3 {
+		t.Errorf("stored = %+v, want 0 available at version 3", stored)
+	}
+}
+
+func testAdjustReplay(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+	id := create(t, repo, models.Inventory{Name: "keyboard", Description: "mechanical", Price: 49.99, Available: 10})
+	other := create(t, repo, models.Inventory{Name: "mouse", Description: "wireless", Price: 19, Available: 10})
+
+	adj := models.StockAdjustment{ID: id, Delta: -3, Reason: models.AdjustmentReasonOrderPlaced, Reference: "order:1"}
+	first, err := repo.Adjust(ctx, adj)
+	if err != nil {
+		t.Fatalf("Adjust: %v", err)
+	}
+
+	again, err := repo.Adjust(ctx, adj)
+	if err != nil {
+		t.Fatalf("Adjust replay: %v", err)
+	}
+	if !again.Replayed || again.Available != first.Available || again.Version != first.Version || again.Delta != first.Delta {
+		t.Errorf("replay = %+v, want the result %+v replayed", again, first)
+	}
+	if stored := get(t, repo, id); stored.Available != 7 {
+		t.Errorf("available = %d after a replay, want 7", stored.Available)
+	}
+
+	// References are per product
+	result, err := repo.Adjust(ctx, models.StockAdjustment{ID: other, Delta: -3, Reason: models.AdjustmentReasonOrderPlaced, Reference: "order:1"})
+	if err != nil {
+		t.Fatalf("Adjust of another product: %v", err)
+	}
+	if result.Replayed || result.Available != 7 {
+		t.Errorf("Adjust of another product = %+v, want it applied", result)
+	}
+}
+
+func testAdjustConcurrent(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+	id := create(t, repo, models.Inventory{Name: "keyboard", Description: "mechanical", Price: 49.99, Available: 10})
+
+	const workers = 25
+	errs := make(chan error, workers)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Adjust(ctx, models.StockAdjustment{ID: id, Delta: -1, Reason: models.AdjustmentReasonCorrection})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var applied int
+	for err := range errs {
+		switch {
+		case err == nil:
+			applied++
+		case !errors.Is(err, dao.ErrInsufficientStock):
+			t.Errorf("Adjust: %v", err)
+		}
+	}
+
+	if applied != 10 {
+		t.Errorf("%d decrements applied, want 10", applied)
+	}
+	if stored := get(t, repo, id); stored.Available != 0 || stored.Version != 11 {
+		t.Errorf("stored = %+v, want 0 available at version 11", stored)
+	}
+}
+
+func testDecrementManyAllOrNothing(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+	first := create(t, repo, models.Inventory{Name: "keyboard", Description: "mechanical", Price: 49.99, Available: 10})
+	second := create(t, repo, models.Inventory{Name: "mouse", Description: "wireless", Price: 19, Available: 2})
+
+	results, applied, err := repo.DecrementMany(ctx, []models.StockChange{
+		{ID: first, Quantity: 4, Reason: models.AdjustmentReasonOrderPlaced},
+		{ID: second, Quantity: 3, Reason: models.AdjustmentReasonOrderPlaced},
+		{ID: 1_000_000, Quantity: 1, Reason: models.AdjustmentReasonOrderPlaced},
+	})
+	if err != nil {
+		t.Fatalf("DecrementMany: %v", err)
+	}
+	if applied {
+		t.Fatal("applied = true, want false")
+	}
+	wantStatus := []string{models.StockChangeSkipped, models.StockChangeInsufficientStock, models.StockChangeNotFound}
+	for i, result := range results {
+		if result.Status != wantStatus[i] {
+			t.Errorf("result %d: status = %s, want %s", i, result.Status, wantStatus[i])
+		}
+	}
+	if results[1].Available != 2 {
+		t.Errorf("refused change reports %d available, want 2", results[1].Available)
+	}
+	if a, b := get(t, repo, first), get(t, repo, second); a.Available != 10 || b.Available != 2 {
+		t.Errorf("a refused batch changed the stock to %d and %d", a.Available, b.Available)
+	}
+
+	results, applied, err = repo.DecrementMany(ctx, []models.StockChange{
+		{ID: first, Quantity: 4, Reason: models.AdjustmentReasonOrderPlaced},
+		{ID: second, Quantity: 2, Reason: models.AdjustmentReasonOrderPlaced},
+	})
+	if err != nil {
+		t.Fatalf("DecrementMany: %v", err)
+	}
+	if !applied {
+		t.Fatalf("applied = false, results %+v", results)
+	}
+	if results[0].Available != 6 || results[0].Version != 2 || results[1].Available != 0 {
+		t.Errorf("results = %+v", results)
+	}
+	if a, b := get(t, repo, first), get(t, repo, second); a.Available != 6 || b.Available != 0 {
+		t.Errorf("stock = %d and %d, want 6 and 0", a.Available, b.Available)
+	}
+}
+
+func testDecrementManyReplay(t *testing.T, repo usecase.InventoryRepository) {
+	ctx := context.Background()
+	first := create(t, repo, models.Inventory{Name: "keyboard", Description: "mechanical", Price: 49.99, Available: 10})
+	second := create(t, repo, models.Inventory{Name: "mouse", Description: "wireless", Price: 19, Available: 5})
+
+	changes := []models.StockChange{
+		{ID: first, Quantity: 4, Reason: models.AdjustmentReasonOrderPlaced, Reference: "order:7"},
+		{ID: second, Quantity: 5, Reason: models.AdjustmentReasonOrderPlaced, Reference: "order:7"},
+	}
+
+	if _, applied, err := repo.DecrementMany(ctx, changes); err != nil || !applied {
+		t.Fatalf("DecrementMany: applied %v, err %v", applied, err)
+	}
+
+	// The second product has no stock left, the replay must not check it again
+	results, applied, err := repo.DecrementMany(ctx, changes)
+	if err != nil {
+		t.Fatalf("DecrementMany replay: %v", err)
+	}
+	if !applied {
+		t.Fatalf("replay applied = false, results %+v", results)
+	}
+	for i, result := range results {
+		if !result.Replayed || result.Status != models.StockChangeApplied {
+			t.Errorf("result %d = %+v, want a replay", i, result)
+		}
+	}
+	if a, b := get(t, repo, first), get(t, repo, second); a.Available != 6 || b.Available != 0 {
+		t.Errorf("stock = %d and %d after a replay, want 6 and 0", a.Available, b.Available)
+	}
+}
+
+func create(t *testing.T, repo usecase.InventoryRepository, item models.Inventory) int64 {
+	t.Helper()
+
+	id, err := repo.CreateItem(context.Background(), item)
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	return id
+}
+
+func get(t *testing.T, repo usecase.InventoryRepository, id int64) models.Inventory {
+	t.Helper()
+
+	item, err := repo.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Get %d: %v", id, err)
+	}
+	return item
+}
+
+func filters(page, pageSize int, sort string) models.Filters {
+	return models.Filters{
+		Page:         page,
+		PageSize:     pageSize,
+		Sort:         sort,
+		SortSafelist: dao.SafeSortList,
+	}
+}
+
+func ids(items []models.Inventory) []int64 {
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
@@ -0,0 +1,13 @@
+-- Ledger of relative stock changes. A change with a reference is applied at most once
+-- per product, which makes retries of the same request safe.
+CREATE TABLE IF NOT EXISTS inventory_adjustments (
+    id bigserial PRIMARY KEY,
+    product_id bigint NOT NULL REFERENCES inventory(id),
+    delta integer NOT NULL CHECK(delta <> 0),
+    reason VARCHAR(30) NOT NULL,
+    reference VARCHAR(100),
+    available integer NOT NULL,
+    version integer NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (product_id, reference)
+);
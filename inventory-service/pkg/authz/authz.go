@@ -0,0 +1,157 @@
+// Package authz decides what a caller may do. The gateway verifies the token of the caller
+// and passes its subject and roles on in trusted headers. The services check them again,
+// so that a request that reaches them some other way is held to the same rules.
+package authz
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"inventory-service/pkg/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// HeaderSubject carries the subject of the verified token.
+	HeaderSubject = "X-User-ID"
+	// HeaderRoles carries the roles of the verified token, separated by commas.
+	HeaderRoles = "X-User-Roles"
+)
+
+const (
+	RoleAdmin    = "admin"
+	RoleStaff    = "staff"
+	RoleCustomer = "customer"
+	RoleService  = "service" // Another service of the shop, such as order-service calling inventory-service
+)
+
+type Config struct {
+	Enforce bool `env:"AUTHZ_ENFORCE" envDefault:"false"` // Checks the roles of the callers, any known caller has them all otherwise
+}
+
+// Identity is who a request was made by. It is empty for anonymous requests.
+type Identity struct {
+	Subject string
+	Roles   []string
+}
+
+// HasRole tells whether the identity has one of roles.
+func (id Identity) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(id.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Anonymous tells whether the request carries no identity.
+func (id Identity) Anonymous() bool {
+	return id.Subject == ""
+}
+
+// FromHeader reads the identity from the trusted headers.
+func FromHeader(h http.Header) Identity {
+	id := Identity{Subject: h.Get(HeaderSubject)}
+	for _, role := range strings.Split(h.Get(HeaderRoles), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			id.Roles = append(id.Roles, role)
+		}
+	}
+	return id
+}
+
+// SetHeader passes the identity on in the trusted headers.
+func (id Identity) SetHeader(h http.Header) {
+	h.Set(HeaderSubject, id.Subject)
+	if len(id.Roles) > 0 {
+		h.Set(HeaderRoles, strings.Join(id.Roles, ","))
+	}
+}
+
+// StripHeader removes the trusted headers.
+func StripHeader(h http.Header) {
+	h.Del(HeaderSubject)
+	h.Del(HeaderRoles)
+}
+
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the identity of the request ctx belongs to.
+func FromContext(ctx context.Context) Identity {
+	id, _ := ctx.Value(ctxKey{}).(Identity)
+	return id
+}
+
+// Check allows id if it has one of roles, or if it is known at all without roles. It
+// returns the problem to answer with otherwise: 401 for an anonymous request, 403 for a
+// caller without the role.
+func Check(id Identity, roles ...string) *problem.Problem {
+	switch {
+	case id.Anonymous():
+		return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "the request carries no verified identity")
+	case len(roles) == 0 || id.HasRole(roles...):
+		return nil
+	default:
+		return problem.New(http.StatusForbidden, problem.CodeForbidden, "requires one of the roles: "+strings.Join(roles, ", "))
+	}
+}
+
+// Policy applies the rules. Callers must be known where no role is required and customers
+// only reach their own orders in any case, roles are checked only when they are enforced.
+type Policy struct {
+	enforce bool
+}
+
+func New(cfg Config) *Policy {
+	return &Policy{enforce: cfg.Enforce}
+}
+
+// Middleware reads the identity from the trusted headers into the request context.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), FromHeader(c.Request.Header)))
+		c.Next()
+	}
+}
+
+// Require lets a request through if its caller has one of roles, or if it has an identity
+// at all without roles. Roles are checked only when they are enforced, an identity always.
+func (p *Policy) Require(roles ...string) gin.HandlerFunc {
+	check := p.enforce || len(roles) == 0
+	return func(c *gin.Context) {
+		if check {
+			if err := Check(FromContext(c.Request.Context()), roles...); err != nil {
+				problem.Write(c, err)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Owner allows the caller of ctx to act on the thing named what that belongs to owner: the
+// owner itself, and staff and admins on behalf of anyone.
+func (p *Policy) Owner(ctx context.Context, what, owner string) *problem.Problem {
+	id := FromContext(ctx)
+	if id.Anonymous() {
+		return Check(id)
+	}
+	if id.HasRole(RoleAdmin, RoleStaff) || (owner != "" && id.Subject == owner) {
+		return nil
+	}
+	return problem.New(http.StatusForbidden, problem.CodeForbidden, "the "+what+" belongs to another customer")
+}
+
+// All tells whether the caller of ctx may see what belongs to everyone, rather than only
+// its own.
+func (p *Policy) All(ctx context.Context) bool {
+	return FromContext(ctx).HasRole(RoleAdmin, RoleStaff)
+}
@@ -1,33 +1,96 @@
+// Package logger sets up structured logging. Every record logged with the context of a
+// request carries the ID of that request, so that a request can be followed through the
+// gateway and the services by its request_id.
 package logger
 
 import (
-	"os"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
 	"time"
 
+	"inventory-service/pkg/requestid"
+
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewLogger() *logrus.Logger {
-	log := logrus.New()
-	log.SetOutput(os.Stdout)
-	log.SetFormatter(&logrus.JSONFormatter{})
-	return log
+type Config struct {
+	Level  string `env:"LOG_LEVEL" envDefault:"info"`  // Can be: debug, info, warn, error
+	Format string `env:"LOG_FORMAT" envDefault:"json"` // Can be: json, text
+}
+
+// New creates a logger writing to w. Records of every service carry its name.
+func New(w io.Writer, cfg Config, service string) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format: %q", cfg.Format)
+	}
+
+	return slog.New(contextHandler{handler}).With("service", service), nil
+}
+
+// contextHandler adds the request ID and the trace of the context to every record.
+type contextHandler struct {
+	slog.Handler
 }
 
-func GinLogger(log *logrus.Logger) gin.HandlerFunc {
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Middleware logs every request once it is served, with the default logger. It goes
+// after requestid.Middleware, so that the record carries the request ID.
+func Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		path := c.Request.URL.Path
 		start := time.Now()
+		path := c.Request.URL.Path
+		query := c.Request.URL.RawQuery
+
 		c.Next()
-		latency := time.Since(start)
-
-		log.WithFields(logrus.Fields{
-			"status":  c.Writer.Status(),
-			"method":  c.Request.Method,
-			"path":    path,
-			"ip":      c.ClientIP(),
-			"latency": latency,
-		}).Info("request")
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+
+		slog.LogAttrs(c.Request.Context(), level, "request",
+			slog.Int("status", status),
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.String("query", query),
+			slog.String("ip", c.ClientIP()),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("size", c.Writer.Size()),
+		)
 	}
 }
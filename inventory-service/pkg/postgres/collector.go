@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector exports the statistics of a connection pool as Prometheus metrics. The pool
+// keeps them itself, so they are read when the metrics are scraped.
+type Collector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquires             *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	canceledAcquires     *prometheus.Desc
+	emptyAcquires        *prometheus.Desc
+	newConns             *prometheus.Desc
+	maxLifetimeDestroyed *prometheus.Desc
+	maxIdleDestroyed     *prometheus.Desc
+}
+
+func NewCollector(pool *pgxpool.Pool) *Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("pgxpool_"+name, help, nil, nil)
+	}
+
+	return &Collector{
+		pool: pool,
+
+		acquiredConns:        desc("acquired_conns", "Number of connections currently in use."),
+		idleConns:            desc("idle_conns", "Number of idle connections in the pool."),
+		constructingConns:    desc("constructing_conns", "Number of connections being established."),
+		totalConns:           desc("total_conns", "Number of connections in the pool, in use, idle or being established."),
+		maxConns:             desc("max_conns", "Maximum size of the pool."),
+		acquires:             desc("acquires_total", "Number of connections acquired from the pool."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Time spent acquiring connections from the pool."),
+		canceledAcquires:     desc("canceled_acquires_total", "Number of acquires canceled by their context."),
+		emptyAcquires:        desc("empty_acquires_total", "Number of acquires that had to wait for a connection because the pool was empty."),
+		newConns:             desc("new_conns_total", "Number of connections established."),
+		maxLifetimeDestroyed: desc("max_lifetime_destroyed_total", "Number of connections closed because they reached their maximum lifetime."),
+		maxIdleDestroyed:     desc("max_idle_destroyed_total", "Number of connections closed because they were idle for too long."),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+	}
+
+	gauge(c.acquiredConns, float64(stat.AcquiredConns()))
+	gauge(c.idleConns, float64(stat.IdleConns()))
+	gauge(c.constructingConns, float64(stat.ConstructingConns()))
+	gauge(c.totalConns, float64(stat.TotalConns()))
+	gauge(c.maxConns, float64(stat.MaxConns()))
+	counter(c.acquires, float64(stat.AcquireCount()))
+	counter(c.acquireDuration, stat.AcquireDuration().Seconds())
+	counter(c.canceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(c.emptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(c.newConns, float64(stat.NewConnsCount()))
+	counter(c.maxLifetimeDestroyed, float64(stat.MaxLifetimeDestroyCount()))
+	counter(c.maxIdleDestroyed, float64(stat.MaxIdleDestroyCount()))
+}
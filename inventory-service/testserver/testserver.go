@@ -0,0 +1,49 @@
+// Package testserver runs inventory-service on an httptest server with in-memory storage.
+// It lets tests outside this module, such as the end-to-end tests, use the real HTTP
+// routes without Postgres.
+package testserver
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"inventory-service/config"
+	httpservice "inventory-service/internal/adapter/http/service"
+	"inventory-service/internal/adapter/memory"
+	"inventory-service/internal/usecase"
+	"inventory-service/pkg/health"
+)
+
+type Server struct {
+	*httptest.Server
+}
+
+// Option changes the configuration the server is started with.
+type Option func(cfg *config.Server)
+
+// EnforceRoles makes the server check the roles of the callers, as AUTHZ_ENFORCE does.
+func EnforceRoles() Option {
+	return func(cfg *config.Server) {
+		cfg.Authz.Enforce = true
+	}
+}
+
+// New starts a server with an empty inventory. It is closed when the test ends.
+func New(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+
+	cfg := config.Server{
+		HTTPServer: config.HTTPServer{Mode: "test"},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	inventoryUseCase := usecase.NewInventory(memory.NewInventoryRepository())
+	api := httpservice.New(cfg, inventoryUseCase, health.New(health.Config{}))
+
+	srv := httptest.NewServer(api.Handler())
+	t.Cleanup(srv.Close)
+
+	return &Server{Server: srv}
+}
@@ -3,7 +3,11 @@ package config
 import (
 	"time"
 
+	"order-service/pkg/authz"
+	"order-service/pkg/health"
+	"order-service/pkg/logger"
 	"order-service/pkg/postgres"
+	"order-service/pkg/tracing"
 
 	"github.com/caarlos0/env/v10"
 	"github.com/joho/godotenv"
@@ -11,8 +15,13 @@ import (
 
 type (
 	Config struct {
-		Postgres postgres.Config
-		Server   Server
+		Postgres  postgres.Config
+		Server    Server
+		Sweeper   Sweeper
+		Inventory Inventory
+		Log       logger.Config
+		Tracing   tracing.Config
+		Health    health.Config
 
 		Version string `env:"VERSION"`
 	}
@@ -20,6 +29,13 @@ type (
 	// We can have multiple servers like gRPC or smth else.
 	Server struct {
 		HTTPServer HTTPServer
+		Authz      authz.Config
+
+		// On shutdown the readiness probe fails for ShutdownDelay first, so that load
+		// balancers stop sending requests. In-flight requests then get ShutdownTimeout to
+		// finish, the rest is cut off.
+		ShutdownDelay   time.Duration `env:"SHUTDOWN_DELAY" envDefault:"0s"`
+		ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s"`
 	}
 
 	HTTPServer struct {
@@ -31,6 +47,32 @@ type (
 		TrustedProxies []string      `env:"HTTP_TRUSTED_PROXIES" envSeparator:","`
 		Mode           string        `env:"GIN_MODE" envDefault:"release"` // Can be: release, debug, test
 	}
+
+	// Sweeper cancels pending orders that were never completed and returns their stock.
+	Sweeper struct {
+		Enabled    bool          `env:"ORDER_SWEEPER_ENABLED" envDefault:"true"`
+		PendingTTL time.Duration `env:"ORDER_PENDING_TTL" envDefault:"30m"`
+		Interval   time.Duration `env:"ORDER_SWEEPER_INTERVAL" envDefault:"1m"`
+		BatchSize  int           `env:"ORDER_SWEEPER_BATCH_SIZE" envDefault:"50"`
+	}
+
+	// Inventory is the client of inventory-service.
+	Inventory struct {
+		Transport string        `env:"INVENTORY_TRANSPORT" envDefault:"http"` // Can be: http, grpc
+		URL       string        `env:"INVENTORY_URL" envDefault:"http://localhost:8082"`
+		GRPCAddr  string        `env:"INVENTORY_GRPC_ADDR" envDefault:"localhost:9082"`
+		Timeout   time.Duration `env:"INVENTORY_TIMEOUT" envDefault:"5s"` // Per attempt over HTTP, per call over gRPC
+
+		// Retries are only made for calls that are safe to repeat: reads, and stock changes
+		// that inventory deduplicates by reference.
+		MaxRetries     int           `env:"INVENTORY_MAX_RETRIES" envDefault:"2"`
+		RetryBaseDelay time.Duration `env:"INVENTORY_RETRY_BASE_DELAY" envDefault:"100ms"`
+		RetryMaxDelay  time.Duration `env:"INVENTORY_RETRY_MAX_DELAY" envDefault:"1s"`
+
+		// After BreakerFailures consecutive failures calls fail fast for BreakerCooldown.
+		BreakerFailures int           `env:"INVENTORY_BREAKER_FAILURES" envDefault:"5"`
+		BreakerCooldown time.Duration `env:"INVENTORY_BREAKER_COOLDOWN" envDefault:"30s"`
+	}
 )
 
 func New() (*Config, error) {
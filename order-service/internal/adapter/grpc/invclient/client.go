@@ -0,0 +1,197 @@
+package invclient
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/adapter/grpc/invclient/pbdto"
+	"order-service/internal/models"
+	"order-service/pkg/breaker"
+	"order-service/pkg/inventorypb"
+	"order-service/pkg/requestid"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// InventoryClient talks to the gRPC API of inventory-service. It behaves like the HTTP
+// client in myrouter: the same breaker, retries and typed errors of models.
+type InventoryClient struct {
+	conn    *grpc.ClientConn
+	client  inventorypb.InventoryServiceClient
+	health  healthpb.HealthClient
+	breaker *breaker.Breaker
+	cfg     config.Inventory
+}
+
+// NewInventoryClient connects lazily, so inventory-service does not need to be up yet.
+// The options are applied after the defaults, tests use them to dial an in-memory listener.
+func NewInventoryClient(cfg config.Inventory, opts ...grpc.DialOption) (*InventoryClient, error) {
+	c := &InventoryClient{
+		breaker: breaker.New(cfg.BreakerFailures, cfg.BreakerCooldown),
+		cfg:     cfg,
+	}
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(serviceConfig(cfg)),
+		grpc.WithUnaryInterceptor(c.intercept),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}
+
+	conn, err := grpc.NewClient(cfg.GRPCAddr, append(dialOpts, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid inventory address: %v", err)
+	}
+
+	c.conn = conn
+	c.client = inventorypb.NewInventoryServiceClient(conn)
+	c.health = healthpb.NewHealthClient(conn)
+
+	return c, nil
+}
+
+func (c *InventoryClient) Close() error {
+	return c.conn.Close()
+}
+
+// Check is a readiness check: inventory-service has to report SERVING on the standard
+// health service. It goes around the breaker, so that it reports on inventory-service
+// itself even while the breaker is open.
+func (c *InventoryClient) Check(ctx context.Context) (map[string]any, error) {
+	details := map[string]any{"transport": "grpc", "breaker": c.breaker.State().String()}
+
+	resp, err := c.health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return details, err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return details, fmt.Errorf("inventory-service is %v", resp.GetStatus())
+	}
+
+	return details, nil
+}
+
+// BreakerState reports the state of the circuit breaker guarding the calls.
+func (c *InventoryClient) BreakerState() breaker.State {
+	return c.breaker.State()
+}
+
+// GetMany returns the found products by their ID. Products that do not exist are missing
+// from the map.
+func (c *InventoryClient) GetMany(ctx context.Context, ids []int64) (map[int64]models.Inventory, error) {
+	if len(ids) == 0 {
+		return map[int64]models.Inventory{}, nil
+	}
+
+	resp, err := c.client.BatchGetProducts(ctx, &inventorypb.BatchGetProductsRequest{Ids: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	return pbdto.ToBatchGetModel(resp), nil
+}
+
+// DecrementMany takes all quantities out of stock in one transaction. When inventory
+// refuses the batch, nothing is taken and models.ErrStockConflict is returned together
+// with the result of every change.
+func (c *InventoryClient) DecrementMany(ctx context.Context, reason, reference string, changes []models.StockChange) ([]models.StockChangeResult, error) {
+	resp, err := c.client.ReserveStock(ctx, pbdto.FromStockChanges(reason, reference, changes))
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.GetApplied() {
+		return pbdto.ToStockChangeResults(resp), models.ErrStockConflict
+	}
+
+	return pbdto.ToStockChangeResults(resp), nil
+}
+
+// Adjust changes the stock of a product relative to its current value. Inventory applies
+// it in one conditional statement, so it only fails when a decrement exceeds the stock.
+func (c *InventoryClient) Adjust(ctx context.Context, adj models.StockAdjustment) error {
+	_, err := c.client.AdjustStock(ctx, pbdto.FromStockAdjustment(adj))
+	return err
+}
+
+// intercept sends every call through the circuit breaker with a deadline of cfg.Timeout
+// and maps the resulting status to the typed errors of models. Retries happen inside the
+// connection, so a call counts once for the breaker however many attempts it took.
+func (c *InventoryClient) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if method == healthpb.Health_Check_FullMethodName {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	if err := c.breaker.Allow(); err != nil {
+		return fmt.Errorf("%w: %w", models.ErrInventoryUnavailable, err)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	if id := requestid.FromContext(ctx); id != "" {
+		callCtx = metadata.AppendToOutgoingContext(callCtx, requestid.Header, id)
+	}
+
+	err := invoker(callCtx, method, req, reply, cc, opts...)
+	if err == nil {
+		c.breaker.Success()
+		return nil
+	}
+
+	// A canceled caller says nothing about the health of inventory-service
+	if ctx.Err() != nil {
+		c.breaker.Release()
+		return ctx.Err()
+	}
+
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		c.breaker.Failure()
+		return fmt.Errorf("%w: %v: %s", models.ErrInventoryUnavailable, st.Code(), st.Message())
+	}
+	c.breaker.Success()
+
+	switch st.Code() {
+	case codes.NotFound:
+		return models.ErrProductNotFound
+	case codes.FailedPrecondition, codes.Aborted:
+		return models.ErrStockConflict
+	default:
+		return fmt.Errorf("unexpected status: %v: %s", st.Code(), st.Message())
+	}
+}
+
+// serviceConfig retries UNAVAILABLE calls with exponential backoff. Every method is safe
+// to repeat: reads, and stock changes that inventory deduplicates by reference.
+func serviceConfig(cfg config.Inventory) string {
+	if cfg.MaxRetries <= 0 {
+		return `{}`
+	}
+
+	return fmt.Sprintf(`{"methodConfig": [{
+		"name": [{"service": "inventory.v1.InventoryService"}],
+		"retryPolicy": {
+			"maxAttempts": %d,
+			"initialBackoff": "%s",
+			"maxBackoff": "%s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]}`, cfg.MaxRetries+1, seconds(cfg.RetryBaseDelay), seconds(cfg.RetryMaxDelay))
+}
+
+// seconds formats d the way durations are written in a service config.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
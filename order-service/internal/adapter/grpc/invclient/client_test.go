@@ -0,0 +1,179 @@
+package invclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/models"
+	"order-service/pkg/breaker"
+	"order-service/pkg/inventorypb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeInventory answers with canned responses and counts the calls that reached it.
+type fakeInventory struct {
+	inventorypb.UnimplementedInventoryServiceServer
+
+	calls       atomic.Int32
+	unavailable int32 // The first calls fail with UNAVAILABLE
+	delay       time.Duration
+}
+
+func (f *fakeInventory) BatchGetProducts(ctx context.Context, req *inventorypb.BatchGetProductsRequest) (*inventorypb.BatchGetProductsResponse, error) {
+	if f.calls.Add(1) <= f.unavailable {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return &inventorypb.BatchGetProductsResponse{
+		Products:   []*inventorypb.Product{{Id: 1, Name: "Keyboard", Price: 48.99, Available: 10}},
+		MissingIds: []int64{2},
+	}, nil
+}
+
+func (f *fakeInventory) ReserveStock(ctx context.Context, req *inventorypb.ReserveStockRequest) (*inventorypb.ReserveStockResponse, error) {
+	f.calls.Add(1)
+	return &inventorypb.ReserveStockResponse{
+		Applied: false,
+		Results: []*inventorypb.StockChangeResult{
+			{ProductId: 1, Quantity: 2, Status: inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_SKIPPED, Available: 10},
+			{ProductId: 2, Quantity: 5, Status: inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_INSUFFICIENT_STOCK, Available: 1},
+		},
+	}, nil
+}
+
+func (f *fakeInventory) AdjustStock(ctx context.Context, req *inventorypb.AdjustStockRequest) (*inventorypb.AdjustStockResponse, error) {
+	f.calls.Add(1)
+	return nil, status.Error(codes.NotFound, "the requested resource could not be found")
+}
+
+func testConfig() config.Inventory {
+	return config.Inventory{
+		GRPCAddr:        "passthrough:///bufnet",
+		Timeout:         time.Second,
+		MaxRetries:      2,
+		RetryBaseDelay:  time.Millisecond,
+		RetryMaxDelay:   10 * time.Millisecond,
+		BreakerFailures: 5,
+		BreakerCooldown: time.Minute,
+	}
+}
+
+// newTestClient serves fake on an in-memory listener and returns a client connected to it.
+func newTestClient(t *testing.T, cfg config.Inventory, fake *fakeInventory) *InventoryClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	inventorypb.RegisterInventoryServiceServer(server, fake)
+	go server.Serve(lis)
+
+	client, err := NewInventoryClient(cfg, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))
+	if err != nil {
+		t.Fatalf("NewInventoryClient: %v", err)
+	}
+
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+
+	return client
+}
+
+func TestGetMany(t *testing.T) {
+	client := newTestClient(t, testConfig(), &fakeInventory{})
+
+	items, err := client.GetMany(context.Background(), []int64{1, 2})
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	// The price is in cents, 48.99 is not 4898
+	if len(items) != 1 || items[1].Name != "Keyboard" || items[1].Price != 4899 {
+		t.Errorf("GetMany = %v, want only product 1 at 4899", items)
+	}
+}
+
+func TestGetManyRetriesUnavailable(t *testing.T) {
+	fake := &fakeInventory{unavailable: 2}
+	client := newTestClient(t, testConfig(), fake)
+
+	_, err := client.GetMany(context.Background(), []int64{1})
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	if calls := fake.calls.Load(); calls != 3 {
+		t.Errorf("server saw %d calls, want 3", calls)
+	}
+}
+
+func TestDecrementManyRefused(t *testing.T) {
+	client := newTestClient(t, testConfig(), &fakeInventory{})
+
+	results, err := client.DecrementMany(context.Background(), models.AdjustmentReasonOrderPlaced, "order:1", []models.StockChange{
+		{ProductID: 1, Quantity: 2},
+		{ProductID: 2, Quantity: 5},
+	})
+	if !errors.Is(err, models.ErrStockConflict) {
+		t.Fatalf("err = %v, want %v", err, models.ErrStockConflict)
+	}
+	if len(results) != 2 || results[1].Status != models.StockChangeInsufficientStock {
+		t.Errorf("results = %v", results)
+	}
+}
+
+func TestAdjustNotFound(t *testing.T) {
+	client := newTestClient(t, testConfig(), &fakeInventory{})
+
+	err := client.Adjust(context.Background(), models.StockAdjustment{ProductID: 3, Delta: 1, Reason: models.AdjustmentReasonOrderReleased})
+	if !errors.Is(err, models.ErrProductNotFound) {
+		t.Errorf("err = %v, want %v", err, models.ErrProductNotFound)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	cfg := testConfig()
+	cfg.Timeout = 20 * time.Millisecond
+	client := newTestClient(t, cfg, &fakeInventory{delay: time.Second})
+
+	_, err := client.GetMany(context.Background(), []int64{1})
+	if !errors.Is(err, models.ErrInventoryUnavailable) {
+		t.Errorf("err = %v, want %v", err, models.ErrInventoryUnavailable)
+	}
+}
+
+func TestBreakerOpens(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxRetries = 0
+	cfg.BreakerFailures = 2
+	fake := &fakeInventory{unavailable: 100}
+	client := newTestClient(t, cfg, fake)
+
+	for range 2 {
+		client.GetMany(context.Background(), []int64{1})
+	}
+
+	_, err := client.GetMany(context.Background(), []int64{1})
+	if !errors.Is(err, breaker.ErrOpen) || !errors.Is(err, models.ErrInventoryUnavailable) {
+		t.Errorf("err = %v, want an open breaker", err)
+	}
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("server saw %d calls, want 2", calls)
+	}
+}
@@ -0,0 +1,70 @@
+package pbdto
+
+import (
+	"order-service/internal/models"
+	"order-service/pkg/inventorypb"
+	"time"
+)
+
+var stockChangeStatuses = map[inventorypb.StockChangeStatus]string{
+	inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_APPLIED:            models.StockChangeApplied,
+	inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_INSUFFICIENT_STOCK: models.StockChangeInsufficientStock,
+	inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_NOT_FOUND:          models.StockChangeNotFound,
+	inventorypb.StockChangeStatus_STOCK_CHANGE_STATUS_SKIPPED:            models.StockChangeSkipped,
+}
+
+func ToInventoryModel(product *inventorypb.Product) models.Inventory {
+	return models.Inventory{
+		ID:          product.GetId(),
+		Name:        product.GetName(),
+		Description: product.GetDescription(),
+		Price:       models.PriceCents(product.GetPrice()),
+		Available:   product.GetAvailable(),
+		CreatedAt:   product.GetCreatedAt().AsTime().Format(time.RFC3339),
+		Version:     product.GetVersion(),
+	}
+}
+
+func ToBatchGetModel(resp *inventorypb.BatchGetProductsResponse) map[int64]models.Inventory {
+	items := make(map[int64]models.Inventory, len(resp.GetProducts()))
+	for _, v := range resp.GetProducts() {
+		items[v.GetId()] = ToInventoryModel(v)
+	}
+	return items
+}
+
+func FromStockChanges(reason, reference string, changes []models.StockChange) *inventorypb.ReserveStockRequest {
+	req := &inventorypb.ReserveStockRequest{
+		Reason:    reason,
+		Reference: reference,
+	}
+	for _, v := range changes {
+		req.Items = append(req.Items, &inventorypb.StockChange{
+			ProductId: v.ProductID,
+			Quantity:  v.Quantity,
+		})
+	}
+	return req
+}
+
+func ToStockChangeResults(resp *inventorypb.ReserveStockResponse) []models.StockChangeResult {
+	var results []models.StockChangeResult
+	for _, v := range resp.GetResults() {
+		results = append(results, models.StockChangeResult{
+			ProductID: v.GetProductId(),
+			Quantity:  v.GetQuantity(),
+			Status:    stockChangeStatuses[v.GetStatus()],
+			Available: v.GetAvailable(),
+		})
+	}
+	return results
+}
+
+func FromStockAdjustment(adj models.StockAdjustment) *inventorypb.AdjustStockRequest {
+	return &inventorypb.AdjustStockRequest{
+		ProductId: adj.ProductID,
+		Delta:     adj.Delta,
+		Reason:    adj.Reason,
+		Reference: adj.Reference,
+	}
+}
@@ -4,13 +4,13 @@ import "order-service/internal/models"
 
 // Inventory represents the inventory item structure
 type Inventory struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Price       int64  `json:"price"`
-	Available   int64  `json:"available"`
-	CreatedAt   string `json:"created_at"`
-	Version     int32  `json:"version"`
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Available   int64   `json:"available"`
+	CreatedAt   string  `json:"created_at"`
+	Version     int32   `json:"version"`
 }
 
 // InventoryResponse represents the expected API response structure
@@ -23,9 +23,85 @@ func ToInventoryModel(resp InventoryResponse) models.Inventory {
 		ID:          resp.Inventory.ID,
 		Name:        resp.Inventory.Name,
 		Description: resp.Inventory.Description,
-		Price:       resp.Inventory.Price,
+		Price:       models.PriceCents(resp.Inventory.Price),
 		Available:   resp.Inventory.Available,
 		CreatedAt:   resp.Inventory.CreatedAt,
 		Version:     resp.Inventory.Version,
 	}
 }
+
+type BatchGetRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+type BatchGetResponse struct {
+	Inventory []Inventory `json:"inventory"`
+	Missing   []int64     `json:"missing"`
+}
+
+type StockChange struct {
+	ID        int64  `json:"id"`
+	Quantity  int64  `json:"quantity"`
+	Status    string `json:"status,omitempty"`
+	Available int64  `json:"available,omitempty"`
+}
+
+type BatchDecrementRequest struct {
+	Reason    string        `json:"reason"`
+	Reference string        `json:"reference"`
+	Items     []StockChange `json:"items"`
+}
+
+type AdjustmentRequest struct {
+	Delta     int64  `json:"delta"`
+	Reason    string `json:"reason"`
+	Reference string `json:"reference"`
+}
+
+type BatchDecrementResponse struct {
+	Applied bool          `json:"applied"`
+	Results []StockChange `json:"results"`
+}
+
+func ToBatchGetModel(resp BatchGetResponse) map[int64]models.Inventory {
+	items := make(map[int64]models.Inventory, len(resp.Inventory))
+	for _, v := range resp.Inventory {
+		items[v.ID] = ToInventoryModel(InventoryResponse{Inventory: v})
+	}
+	return items
+}
+
+func FromStockChanges(reason, reference string, changes []models.StockChange) BatchDecrementRequest {
+	req := BatchDecrementRequest{
+		Reason:    reason,
+		Reference: reference,
+	}
+	for _, v := range changes {
+		req.Items = append(req.Items, StockChange{
+			ID:       v.ProductID,
+			Quantity: v.Quantity,
+		})
+	}
+	return req
+}
+
+func ToStockChangeResults(resp BatchDecrementResponse) []models.StockChangeResult {
+	var results []models.StockChangeResult
+	for _, v := range resp.Results {
+		results = append(results, models.StockChangeResult{
+			ProductID: v.ID,
+			Quantity:  v.Quantity,
+			Status:    v.Status,
+			Available: v.Available,
+		})
+	}
+	return results
+}
+
+func FromStockAdjustment(adj models.StockAdjustment) AdjustmentRequest {
+	return AdjustmentRequest{
+		Delta:     adj.Delta,
+		Reason:    adj.Reason,
+		Reference: adj.Reference,
+	}
+}
@@ -2,19 +2,36 @@ package myrouter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"time"
+
+	"order-service/config"
 	"order-service/internal/adapter/http/myrouter/invdto"
 	"order-service/internal/models"
+	"order-service/pkg/authz"
+	"order-service/pkg/breaker"
+	"order-service/pkg/requestid"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type InventoryRouter struct {
-	url string
+	url     string
+	livez   string
+	client  *http.Client
+	breaker *breaker.Breaker
+	cfg     config.Inventory
 }
 
-func NewInventoryRouter(baseURL string) (*InventoryRouter, error) {
+func NewInventoryRouter(cfg config.Inventory) (*InventoryRouter, error) {
+	baseURL := cfg.URL
+
 	// Validate the base URL
 	_, err := url.ParseRequestURI(baseURL)
 	if err != nil {
@@ -27,72 +44,237 @@ func NewInventoryRouter(baseURL string) (*InventoryRouter, error) {
 	}
 
 	return &InventoryRouter{
-		url: baseURL + "products/",
+		url:   baseURL + "products/",
+		livez: baseURL + "livez",
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// Passing the trace context on, every attempt gets a span of its own
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		breaker: breaker.New(cfg.BreakerFailures, cfg.BreakerCooldown),
+		cfg:     cfg,
 	}, nil
 }
 
-func (r *InventoryRouter) GetById(id int64) (models.Inventory, error) {
+func (r *InventoryRouter) GetById(ctx context.Context, id int64) (models.Inventory, error) {
 	// Construct the full URL
 	fullURL := r.url + fmt.Sprintf("%d", id)
 
-	// Make the HTTP GET request
-	resp, err := http.Get(fullURL)
+	var response invdto.InventoryResponse
+
+	// GET is safe, so transient failures are retried
+	err := r.retry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %v", err)
+		}
+
+		return r.do(req, http.StatusOK, &response)
+	})
 	if err != nil {
-		return models.Inventory{}, fmt.Errorf("HTTP request failed: %v", err)
+		return models.Inventory{}, err
 	}
-	defer resp.Body.Close()
 
-	// Check the status code
-	if resp.StatusCode != http.StatusOK {
-		return models.Inventory{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
+	return invdto.ToInventoryModel(response), nil
+}
 
-	// Parse the response
-	var response invdto.InventoryResponse
-	err = json.NewDecoder(resp.Body).Decode(&response)
+// Adjust changes the stock of a product relative to its current value. Inventory applies
+// it in one conditional statement, so it only fails when a decrement exceeds the stock.
+func (r *InventoryRouter) Adjust(ctx context.Context, adj models.StockAdjustment) error {
+	fullURL := r.url + fmt.Sprintf("%d/adjust", adj.ProductID)
+
+	jsonBody, err := json.Marshal(invdto.FromStockAdjustment(adj))
 	if err != nil {
-		return models.Inventory{}, fmt.Errorf("failed to parse response: %v", err)
+		return fmt.Errorf("failed to marshal request body: %v", err)
 	}
 
-	return invdto.ToInventoryModel(response), nil
+	// Retried as well: inventory applies a reference only once
+	return r.retry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, bytes.NewReader(jsonBody))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		return r.do(req, http.StatusOK, nil)
+	})
 }
 
-// Sends http PATCH request to change "availability" of product with Header "X-Expected-Version"
-func (r *InventoryRouter) Substruct(id, newAvailability int64, version int32) error {
-	fullURL := r.url + fmt.Sprintf("%d", id)
+// GetMany returns the found products by their ID. Products that do not exist are missing
+// from the map.
+func (r *InventoryRouter) GetMany(ctx context.Context, ids []int64) (map[int64]models.Inventory, error) {
+	if len(ids) == 0 {
+		return map[int64]models.Inventory{}, nil
+	}
 
-	// Create request body
-	requestBody := map[string]int64{
-		"available": newAvailability,
+	jsonBody, err := json.Marshal(invdto.BatchGetRequest{IDs: ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %v", err)
 	}
 
-	jsonBody, err := json.Marshal(requestBody)
+	var response invdto.BatchGetResponse
+
+	// Only reads, so it is retried like a GET
+	err = r.retry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url+"batch/get", bytes.NewReader(jsonBody))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		return r.do(req, http.StatusOK, &response)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal request body: %v", err)
+		return nil, err
 	}
 
-	// Create new request
-	req, err := http.NewRequest(http.MethodPatch, fullURL, bytes.NewBuffer(jsonBody))
+	return invdto.ToBatchGetModel(response), nil
+}
+
+// DecrementMany takes all quantities out of stock in one transaction. When inventory
+// refuses the batch, nothing is taken and models.ErrStockConflict is returned together
+// with the result of every change.
+func (r *InventoryRouter) DecrementMany(ctx context.Context, reason, reference string, changes []models.StockChange) ([]models.StockChangeResult, error) {
+	jsonBody, err := json.Marshal(invdto.FromStockChanges(reason, reference, changes))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to marshal request body: %v", err)
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Expected-Version", fmt.Sprintf("%d", version))
+	var response invdto.BatchDecrementResponse
 
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// Retried as well: inventory applies a reference only once
+	err = r.retry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url+"batch/decrement", bytes.NewReader(jsonBody))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		return r.do(req, http.StatusOK, &response)
+	})
+	if err != nil && !errors.Is(err, models.ErrStockConflict) {
+		return nil, err
+	}
+
+	return invdto.ToStockChangeResults(response), err
+}
+
+// Check is a readiness check: inventory-service has to answer its liveness probe. It goes
+// around the breaker, so that it reports on inventory-service itself even while the
+// breaker is open.
+func (r *InventoryRouter) Check(ctx context.Context) (map[string]any, error) {
+	details := map[string]any{"transport": "http", "breaker": r.breaker.State().String()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.livez, nil)
 	if err != nil {
-		return fmt.Errorf("request failed: %v", err)
+		return details, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return details, err
 	}
 	defer resp.Body.Close()
 
-	// Check response status
 	if resp.StatusCode != http.StatusOK {
+		return details, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return details, nil
+}
+
+// BreakerState reports the state of the circuit breaker guarding the calls.
+func (r *InventoryRouter) BreakerState() breaker.State {
+	return r.breaker.State()
+}
+
+// do sends the request through the circuit breaker and maps the response to the typed
+// errors of models. If out is not nil, a response with the expected status is decoded into it.
+func (r *InventoryRouter) do(req *http.Request, expected int, out any) error {
+	if err := r.breaker.Allow(); err != nil {
+		return fmt.Errorf("%w: %w", models.ErrInventoryUnavailable, err)
+	}
+
+	// Passing the request ID on, so the call can be found in the logs of inventory-service
+	if id := requestid.FromContext(req.Context()); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	// Calls are made on behalf of the shop, not of the customer of the order
+	authz.Identity{Subject: "order-service", Roles: []string{authz.RoleService}}.SetHeader(req.Header)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		// A canceled caller says nothing about the health of inventory-service
+		if req.Context().Err() != nil {
+			r.breaker.Release()
+			return req.Context().Err()
+		}
+
+		r.breaker.Failure()
+		return fmt.Errorf("%w: %v", models.ErrInventoryUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		r.breaker.Failure()
+		return fmt.Errorf("%w: unexpected status code: %d", models.ErrInventoryUnavailable, resp.StatusCode)
+	}
+	r.breaker.Success()
+
+	var statusErr error
+	switch resp.StatusCode {
+	case expected:
+	case http.StatusNotFound:
+		return models.ErrProductNotFound
+	case http.StatusConflict:
+		// Batch conflicts carry the result of every change, so the body is parsed as well
+		statusErr = models.ErrStockConflict
+	default:
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	return nil
+	if out == nil {
+		return statusErr
+	}
+
+	// Parse the response
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	return statusErr
+}
+
+// retry calls fn until it succeeds, fails with an error that is not worth retrying, or
+// cfg.MaxRetries retries were made. Delays grow exponentially with full jitter. Only safe
+// calls may be retried: reads, and writes that inventory deduplicates by reference.
+func (r *InventoryRouter) retry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= r.cfg.MaxRetries || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(r.backoff(attempt))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff is the delay before the retry after attempt: a random duration up to
+// RetryBaseDelay doubled per attempt, capped at RetryMaxDelay.
+func (r *InventoryRouter) backoff(attempt int) time.Duration {
+	delay := min(r.cfg.RetryBaseDelay<<attempt, r.cfg.RetryMaxDelay)
+	return rand.N(delay + 1)
+}
+
+// Only an unavailable inventory is retried, and not while its breaker is open.
+func retryable(err error) bool {
+	return errors.Is(err, models.ErrInventoryUnavailable) && !errors.Is(err, breaker.ErrOpen)
 }
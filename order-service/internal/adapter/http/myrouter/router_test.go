@@ -0,0 +1,131 @@
+package myrouter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/models"
+	"order-service/pkg/breaker"
+)
+
+// newTestRouter serves inventory with the statuses in turn, the last one for every call
+// after, and returns a router to it together with the number of calls that reached it.
+func newTestRouter(t *testing.T, cfg config.Inventory, statuses ...int) (*InventoryRouter, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		status := statuses[min(n, len(statuses))-1]
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"id":1,"name":"Keyboard","price":49,"available":10}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg.URL = server.URL
+	r, err := NewInventoryRouter(cfg)
+	if err != nil {
+		t.Fatalf("NewInventoryRouter: %v", err)
+	}
+	return r, &calls
+}
+
+func testConfig() config.Inventory {
+	return config.Inventory{
+		Timeout:         time.Second,
+		MaxRetries:      2,
+		RetryBaseDelay:  time.Millisecond,
+		RetryMaxDelay:   5 * time.Millisecond,
+		BreakerFailures: 5,
+		BreakerCooldown: time.Minute,
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantErr   error
+		wantCalls int32
+	}{
+		{name: "no retry after a success", statuses: []int{http.StatusOK}, wantCalls: 1},
+		{name: "recovers within the retries", statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, wantCalls: 3},
+		{name: "gives up after the retries", statuses: []int{http.StatusServiceUnavailable}, wantErr: models.ErrInventoryUnavailable, wantCalls: 3},
+		{name: "a missing product is not retried", statuses: []int{http.StatusNotFound}, wantErr: models.ErrProductNotFound, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, calls := newTestRouter(t, testConfig(), tt.statuses...)
+
+			_, err := r.GetById(context.Background(), 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetById() = %v, want %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("inventory saw %d calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsAtOpenBreaker(t *testing.T) {
+	cfg := testConfig()
+	cfg.BreakerFailures = 2
+	r, calls := newTestRouter(t, cfg, http.StatusServiceUnavailable)
+
+	_, err := r.GetById(context.Background(), 1)
+	if !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("GetById() = %v, want %v", err, breaker.ErrOpen)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("inventory saw %d calls, want 2", got)
+	}
+	if got := r.BreakerState(); got != breaker.StateOpen {
+		t.Errorf("breaker is %s, want open", got)
+	}
+}
+
+func TestRetryStopsWithContext(t *testing.T) {
+	cfg := testConfig()
+	cfg.RetryBaseDelay, cfg.RetryMaxDelay = time.Hour, time.Hour
+	r, calls := newTestRouter(t, cfg, http.StatusServiceUnavailable)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := r.GetById(ctx, 1)
+	if !errors.Is(err, models.ErrInventoryUnavailable) {
+		t.Errorf("GetById() = %v, want %v", err, models.ErrInventoryUnavailable)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("inventory saw %d calls, want 1", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := &InventoryRouter{cfg: config.Inventory{RetryBaseDelay: 10 * time.Millisecond, RetryMaxDelay: 50 * time.Millisecond}}
+
+	for attempt, limit := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond} {
+		var longest time.Duration
+		for range 1000 {
+			d := r.backoff(attempt)
+			if d < 0 || d > limit {
+				t.Fatalf("backoff(%d) = %s, want at most %s", attempt, d, limit)
+			}
+			longest = max(longest, d)
+		}
+		// Full jitter spreads the delays over the whole range
+		if longest < limit/2 {
+			t.Errorf("backoff(%d) was at most %s in 1000 draws, want up to %s", attempt, longest, limit)
+		}
+	}
+}
@@ -3,33 +3,22 @@ package dto
 import (
 	"database/sql"
 	"errors"
-	"net/http"
+	"order-service/internal/models"
+	"order-service/pkg/problem"
 
 	"github.com/jackc/pgx/v5"
 )
 
-type HTTPError struct {
-	Code    int
-	Message string
-}
-
-var (
-	ErrResourceNotFound = &HTTPError{
-		Code:    http.StatusNotFound,
-		Message: "the requested resource could not be found",
-	}
-)
-
-func FromError(err error) *HTTPError {
+// FromError maps use case errors to the problem reported to the client.
+func FromError(err error) *problem.Problem {
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
-		return ErrResourceNotFound
+		return problem.NotFound("the requested resource could not be found")
 	case errors.Is(err, pgx.ErrNoRows):
-		return ErrResourceNotFound
+		return problem.NotFound("the requested resource could not be found")
+	case errors.Is(err, models.ErrRecordNotFound):
+		return problem.NotFound("the requested resource could not be found")
 	default:
-		return &HTTPError{
-			Code:    http.StatusInternalServerError,
-			Message: "something went wrong",
-		}
+		return problem.Internal()
 	}
 }
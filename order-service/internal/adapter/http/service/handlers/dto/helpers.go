@@ -1,7 +1,9 @@
 package dto
 
 import (
+	"order-service/pkg/validator"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,7 +13,34 @@ func ReadIDParam(ctx *gin.Context) (int64, error) {
 
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		return -1, nil
+		return -1, err
 	}
 	return id, nil
 }
+
+// ReadInt reads the query parameter key, defaultValue if it is missing.
+func ReadInt(ctx *gin.Context, key string, defaultValue int, v *validator.Validator) int {
+	s := ctx.Query(key)
+	if s == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+	return i
+}
+
+// ReadTime reads the query parameter key in RFC 3339, the zero time if it is missing.
+func ReadTime(ctx *gin.Context, key string, v *validator.Validator) time.Time {
+	s := ctx.Query(key)
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddError(key, "must be a time in RFC 3339")
+	}
+	return t
+}
@@ -1,8 +1,8 @@
 package dto
 
 import (
-	"order-service/internal/adapter/postgres/dao"
 	"order-service/internal/models"
+	"order-service/pkg/validator"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -33,7 +33,7 @@ type OrderCreateResponceRequestV2 struct {
 type OrderItemsCreateResponceRequestV2 struct {
 	ProductID int64  `json:"product_id"`
 	Name      string `json:"name"`
-	Price     int64  `json:"price,omitempty"`  // Total price
+	Price     int64  `json:"price,omitempty"`  // Total price, in cents
 	Status    string `json:"status,omitempty"` // accepted, rejected
 	Reason    string `json:"reason,omitempty"` // if rejected
 }
@@ -41,6 +41,7 @@ type OrderItemsCreateResponceRequestV2 struct {
 type OrderResponce struct {
 	OrderID      int64               `json:"order_id"`
 	CustomerName string              `json:"customer_name"`
+	CustomerID   string              `json:"customer_id,omitempty"`
 	Items        []OrderItemsRequest `json:"items"`
 	Status       string              `json:"status"`
 	CreatedAt    time.Time           `json:"created_at"`
@@ -60,7 +61,7 @@ func FromOrderCreateRequest(ctx *gin.Context) (models.Order, error) {
 
 	var order models.Order
 	order.CustomerName = req.CustomerName
-	order.Status = dao.OrderStatusPending
+	order.Status = models.OrderStatusPending
 
 	for _, v := range req.OrderItems {
 		orderItems := models.OrderItem{
@@ -73,6 +74,22 @@ func FromOrderCreateRequest(ctx *gin.Context) (models.Order, error) {
 	return order, nil
 }
 
+// FromOrderListRequest reads the filter and the page of a list from the query.
+func FromOrderListRequest(ctx *gin.Context, v *validator.Validator) models.OrderFilter {
+	filter := models.OrderFilter{
+		CustomerID:    ctx.Query("customer_id"),
+		Status:        ctx.Query("status"),
+		ProductID:     int64(ReadInt(ctx, "product_id", 0, v)),
+		CreatedAfter:  ReadTime(ctx, "created_after", v),
+		CreatedBefore: ReadTime(ctx, "created_before", v),
+		Page:          ReadInt(ctx, "page", 1, v),
+		PageSize:      ReadInt(ctx, "page_size", 20, v),
+	}
+
+	ValidateOrderFilter(v, filter)
+	return filter
+}
+
 func ToOrderCreateResponse(order models.OrderResponce) OrderCreateResponceRequestV2 {
 	var itemsInfo []OrderItemsCreateResponceRequestV2
 
@@ -110,6 +127,7 @@ func ToOrderResponce(order models.Order) OrderResponce {
 
 	orderResponce.OrderID = order.ID
 	orderResponce.CustomerName = order.CustomerName
+	orderResponce.CustomerID = order.CustomerID
 	orderResponce.Status = order.Status
 	orderResponce.CreatedAt = order.Created_at
 
@@ -2,7 +2,6 @@ package dto
 
 import (
 	"fmt"
-	"order-service/internal/adapter/postgres/dao"
 	"order-service/internal/models"
 	"order-service/pkg/validator"
 	"strings"
@@ -13,6 +12,7 @@ func ValidateOrder(v *validator.Validator, order models.Order) {
 
 	v.Check(order.CustomerName != "", "customer_name", "must be provided")
 	v.Check(len(order.CustomerName) < 50, "customer_name", "must not be more than 50 bytes long")
+	v.Check(len(order.OrderItems) <= 100, "items", "must not contain more than 100 items")
 
 	for _, item := range order.OrderItems {
 		v.Check(item.ProductID > 0, "items_product_id", "must be greater than zero")
@@ -22,6 +22,18 @@ func ValidateOrder(v *validator.Validator, order models.Order) {
 }
 
 func ValidateSetOrderStatusRequest(v *validator.Validator, req OrderSetStatusRequest) {
-	safeList := []string{dao.OrderStatusCanceled, dao.OrderStatusCompleted, dao.OrderStatusPending}
+	safeList := []string{models.OrderStatusCanceled, models.OrderStatusCompleted, models.OrderStatusPending}
 	v.Check(validator.PermittedValue(req.Status, safeList...), "status", fmt.Sprintf("invalid status. Available: %v", strings.Join(safeList, ", ")))
 }
+
+func ValidateOrderFilter(v *validator.Validator, filter models.OrderFilter) {
+	if filter.Status != "" {
+		safeList := []string{models.OrderStatusCanceled, models.OrderStatusCompleted, models.OrderStatusPending}
+		v.Check(validator.PermittedValue(filter.Status, safeList...), "status", fmt.Sprintf("invalid status. Available: %v", strings.Join(safeList, ", ")))
+	}
+	v.Check(filter.ProductID >= 0, "product_id", "must not be negative")
+	v.Check(filter.Page > 0, "page", "must be greater than zero")
+	v.Check(filter.Page <= 10_000_000, "page", "must be maximum of 10 million")
+	v.Check(filter.PageSize > 0, "page_size", "must be greater than zero")
+	v.Check(filter.PageSize <= 100, "page_size", "must be maximum of 100")
+}
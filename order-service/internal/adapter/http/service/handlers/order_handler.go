@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"log/slog"
 	"net/http"
 	"order-service/internal/adapter/http/service/handlers/dto"
 	"order-service/internal/models"
+	"order-service/pkg/authz"
+	"order-service/pkg/problem"
 	"order-service/pkg/validator"
 
 	"github.com/gin-gonic/gin"
@@ -11,32 +14,38 @@ import (
 
 // OrderHandler
 type Order struct {
-	uc OrderUsecase
+	uc     OrderUsecase
+	policy *authz.Policy
 }
 
-func NewOrder(uc OrderUsecase) *Order {
+func NewOrder(uc OrderUsecase, policy *authz.Policy) *Order {
 	return &Order{
-		uc: uc,
+		uc:     uc,
+		policy: policy,
 	}
 }
 
 func (c *Order) Create(ctx *gin.Context) {
 	order, err := dto.FromOrderCreateRequest(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Write(ctx, problem.BadRequest(err.Error()))
 		return
 	}
 
 	v := validator.New()
 
 	if dto.ValidateOrder(v, order); !v.Valid() {
-		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": v.Errors})
+		problem.Write(ctx, problem.Validation(v.Errors))
 		return
 	}
 
+	// The order belongs to whoever placed it
+	order.CustomerID = authz.FromContext(ctx.Request.Context()).Subject
+
 	newOrder, err := c.uc.Create(ctx.Request.Context(), order)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "create order", "error", err)
+		problem.Write(ctx, dto.FromError(err))
 		return
 	}
 
@@ -44,27 +53,52 @@ func (c *Order) Create(ctx *gin.Context) {
 }
 
 func (c *Order) GetList(ctx *gin.Context) {
-	orders, err := c.uc.GetList(ctx)
+	v := validator.New()
+	filter := dto.FromOrderListRequest(ctx, v)
+	if !v.Valid() {
+		problem.Write(ctx, problem.Validation(v.Errors))
+		return
+	}
+
+	// Customers only see their own orders
+	if !c.policy.All(ctx.Request.Context()) {
+		subject := authz.FromContext(ctx.Request.Context()).Subject
+		if filter.CustomerID != "" && filter.CustomerID != subject {
+			ctx.JSON(http.StatusOK, gin.H{"orders": dto.ToOrderListResponce(nil), "metadata": dto.Metadata{}})
+			return
+		}
+		filter.CustomerID = subject
+	}
+
+	orders, total, err := c.uc.GetList(ctx.Request.Context(), filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error})
+		slog.ErrorContext(ctx.Request.Context(), "list orders", "error", err)
+		problem.Write(ctx, dto.FromError(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"orders": dto.ToOrderListResponce(orders)})
+	ctx.JSON(http.StatusOK, gin.H{
+		"orders":   dto.ToOrderListResponce(orders),
+		"metadata": dto.CalculateMetadata(total, filter.Page, filter.PageSize),
+	})
 }
 
 func (c *Order) GetByID(ctx *gin.Context) {
 	id, err := dto.ReadIDParam(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		problem.Write(ctx, problem.BadRequest("invalid order ID"))
 		return
 	}
 
 	// Get order from service
 	order, err := c.uc.Get(ctx.Request.Context(), id)
 	if err != nil {
-		errCtx := dto.FromError(err)
-		ctx.JSON(errCtx.Code, gin.H{"error": errCtx.Message})
+		problem.Write(ctx, dto.FromError(err))
+		return
+	}
+
+	if err := c.policy.Owner(ctx.Request.Context(), "order", order.CustomerID); err != nil {
+		problem.Write(ctx, err)
 		return
 	}
 
@@ -74,7 +108,7 @@ func (c *Order) GetByID(ctx *gin.Context) {
 func (c *Order) SetStatus(ctx *gin.Context) {
 	id, err := dto.ReadIDParam(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		problem.Write(ctx, problem.BadRequest("invalid order ID"))
 		return
 	}
 
@@ -82,13 +116,13 @@ func (c *Order) SetStatus(ctx *gin.Context) {
 
 	err = ctx.ShouldBindJSON(&request)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error})
+		problem.Write(ctx, problem.BadRequest(err.Error()))
 		return
 	}
 
 	v := validator.New()
 	if dto.ValidateSetOrderStatusRequest(v, request); !v.Valid() {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": v.Errors})
+		problem.Write(ctx, problem.Validation(v.Errors))
 		return
 	}
 
@@ -97,8 +131,7 @@ func (c *Order) SetStatus(ctx *gin.Context) {
 		Status:  request.Status,
 	})
 	if err != nil {
-		errCtx := dto.FromError(err)
-		ctx.JSON(errCtx.Code, gin.H{"error": errCtx.Message})
+		problem.Write(ctx, dto.FromError(err))
 		return
 	}
 
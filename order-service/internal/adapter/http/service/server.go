@@ -4,95 +4,124 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
 
 	"order-service/config"
 	"order-service/internal/adapter/http/service/handlers"
+	"order-service/internal/adapter/http/service/openapi"
+	"order-service/pkg/authz"
+	"order-service/pkg/health"
+	"order-service/pkg/logger"
+	"order-service/pkg/metrics"
+	"order-service/pkg/problem"
+	"order-service/pkg/requestid"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 const serverIPAddress = "127.0.0.1:%d" // Changed to 0.0.0.0 for external access
 
 type API struct {
-	server *gin.Engine
+	router *gin.Engine
+	server *http.Server
 	cfg    config.HTTPServer
 	addr   string
+	health *health.Health
+	policy *authz.Policy
 
 	orderHandler *handlers.Order
 }
 
-func New(cfg config.Server, orderUsecase OrderUsecase) *API {
+func New(cfg config.Server, orderUsecase OrderUsecase, checks *health.Health) *API {
 	// Setting the Gin mode
 	gin.SetMode(cfg.HTTPServer.Mode)
 	// Creating a new Gin Engine
-	server := gin.New()
+	router := gin.New()
 
 	// Applying middleware
-	server.Use(gin.Logger())
-	server.Use(gin.Recovery())
+	router.Use(requestid.Middleware())
+	router.Use(otelgin.Middleware("order-service"))
+	router.Use(logger.Middleware())
+	router.Use(metrics.Middleware())
+	router.Use(authz.Middleware())
+	router.Use(gin.Recovery())
+	router.NoRoute(problem.NoRoute)
 
 	// Binding orders
-	orderHandler := handlers.NewOrder(orderUsecase)
+	policy := authz.New(cfg.Authz)
+	orderHandler := handlers.NewOrder(orderUsecase, policy)
 
 	api := &API{
-		server:       server,
+		router:       router,
 		cfg:          cfg.HTTPServer,
 		addr:         fmt.Sprintf(serverIPAddress, cfg.HTTPServer.Port),
+		health:       checks,
+		policy:       policy,
 		orderHandler: orderHandler,
 	}
 
 	api.setupRoutes()
 
+	api.server = &http.Server{
+		Addr:           api.addr,
+		Handler:        router,
+		ReadTimeout:    cfg.HTTPServer.ReadTimeout,
+		WriteTimeout:   cfg.HTTPServer.WriteTimeout,
+		IdleTimeout:    cfg.HTTPServer.IdleTimeout,
+		MaxHeaderBytes: cfg.HTTPServer.MaxHeaderBytes,
+	}
+
 	return api
 }
 
 func (a *API) setupRoutes() {
-	a.server.GET("/healthcheck", a.HealthCheck)
+	a.router.GET("/healthcheck", a.HealthCheck)
+	a.router.GET("/livez", a.health.Live)
+	a.router.GET("/readyz", a.health.Ready)
+	a.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	a.router.GET("/openapi.json", a.OpenAPI)
 
-	orders := a.server.Group("/orders")
+	orders := a.router.Group("/orders")
 	{
-		orders.POST("/", a.orderHandler.Create)
-		orders.GET("/", a.orderHandler.GetList)
-		orders.GET("/:id", a.orderHandler.GetByID)
-		orders.PATCH("/:id", a.orderHandler.SetStatus)
+		// Anyone identified may place orders, only staff may change their status. Reads
+		// are limited to the orders of the caller in the handlers.
+		orders.POST("/", a.policy.Require(), a.orderHandler.Create)
+		orders.GET("/", a.policy.Require(), a.orderHandler.GetList)
+		orders.GET("/:id", a.policy.Require(), a.orderHandler.GetByID)
+		orders.PATCH("/:id", a.policy.Require(authz.RoleAdmin, authz.RoleStaff), a.orderHandler.SetStatus)
 	}
 }
 
+// Handler returns the router, so the API can be served by something other than Run.
+func (a *API) Handler() http.Handler {
+	return a.router
+}
+
 func (a *API) Run(errCh chan<- error) {
 	go func() {
-		log.Printf("HTTP server starting on: %v", a.addr)
+		slog.Info("HTTP server starting", "addr", a.addr)
 
-		// No need to reinitialize `a.server` here. Just run it directly.
-		if err := a.server.Run(a.addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			errCh <- fmt.Errorf("failed to start HTTP server: %w", err)
 			return
 		}
 	}()
 }
 
-func (a *API) Stop() error {
-	// Setting up the signal channel to catch termination signals
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-	// Blocking until a signal is received
-	sig := <-quit
-	log.Println("Shutdown signal received", "signal:", sig.String())
-
-	// Creating a context with timeout for graceful shutdown
-	_, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Stop stops accepting connections and waits for the requests in flight to finish. The
+// ones still running when ctx is done are cut off.
+func (a *API) Stop(ctx context.Context) error {
+	slog.Info("HTTP server shutting down gracefully")
 
-	log.Println("HTTP server shutting down gracefully")
+	if err := a.server.Shutdown(ctx); err != nil {
+		a.server.Close()
+		return fmt.Errorf("HTTP server shutdown: %w", err)
+	}
 
-	// Note: You can use `Shutdown` if you use `http.Server` instead of `gin.Engine`.
-	log.Println("HTTP server stopped successfully")
+	slog.Info("HTTP server stopped successfully")
 
 	return nil
 }
@@ -106,3 +135,15 @@ func (a *API) HealthCheck(c *gin.Context) {
 		},
 	})
 }
+
+// OpenAPI serves the OpenAPI document of the API.
+func (a *API) OpenAPI(c *gin.Context) {
+	spec, err := openapi.JSON()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "openapi", "error", err)
+		problem.Write(c, problem.Internal())
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", spec)
+}
@@ -0,0 +1,111 @@
+// Package invmetrics records Prometheus metrics of the calls made to inventory-service,
+// whichever transport makes them.
+package invmetrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/pkg/breaker"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	calls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "inventory_client_calls_total",
+		Help: "Number of calls made to inventory-service, by transport, operation and outcome. Retries are part of one call.",
+	}, []string{"transport", "operation", "outcome"})
+	callDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "inventory_client_call_duration_seconds",
+		Help:    "Time taken by calls to inventory-service, retries included, by transport and operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"transport", "operation"})
+	breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "inventory_client_breaker_state",
+		Help: "State of the circuit breaker in front of inventory-service, as of the last call: 0 closed, 1 open, 2 half-open.",
+	}, []string{"transport"})
+)
+
+type InventoryService interface {
+	GetMany(ctx context.Context, ids []int64) (map[int64]models.Inventory, error)
+	DecrementMany(ctx context.Context, reason, reference string, changes []models.StockChange) ([]models.StockChangeResult, error)
+	Adjust(ctx context.Context, adj models.StockAdjustment) error
+}
+
+// breakerStater is implemented by clients that guard their calls with a circuit breaker.
+type breakerStater interface {
+	BreakerState() breaker.State
+}
+
+// Inventory wraps a client of inventory-service and records every call it makes.
+type Inventory struct {
+	next      InventoryService
+	transport string
+}
+
+func New(transport string, next InventoryService) *Inventory {
+	return &Inventory{
+		next:      next,
+		transport: transport,
+	}
+}
+
+func (i *Inventory) GetMany(ctx context.Context, ids []int64) (map[int64]models.Inventory, error) {
+	start := time.Now()
+	products, err := i.next.GetMany(ctx, ids)
+	i.observe("get_many", start, err)
+
+	return products, err
+}
+
+func (i *Inventory) DecrementMany(ctx context.Context, reason, reference string, changes []models.StockChange) ([]models.StockChangeResult, error) {
+	start := time.Now()
+	results, err := i.next.DecrementMany(ctx, reason, reference, changes)
+	i.observe("decrement_many", start, err)
+
+	return results, err
+}
+
+func (i *Inventory) Adjust(ctx context.Context, adj models.StockAdjustment) error {
+	start := time.Now()
+	err := i.next.Adjust(ctx, adj)
+	i.observe("adjust", start, err)
+
+	return err
+}
+
+func (i *Inventory) observe(operation string, start time.Time, err error) {
+	calls.WithLabelValues(i.transport, operation, outcome(err)).Inc()
+	callDuration.WithLabelValues(i.transport, operation).Observe(time.Since(start).Seconds())
+
+	if b, ok := i.next.(breakerStater); ok {
+		breakerState.WithLabelValues(i.transport).Set(float64(b.BreakerState()))
+	}
+}
+
+// outcome names the result of a call. The breaker is checked before ErrInventoryUnavailable,
+// which wraps it.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, breaker.ErrOpen):
+		return "breaker_open"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, models.ErrProductNotFound):
+		return "not_found"
+	case errors.Is(err, models.ErrStockConflict):
+		return "conflict"
+	case errors.Is(err, models.ErrInventoryUnavailable):
+		return "unavailable"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,341 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"order-service/internal/models"
+)
+
+// OrderRepository keeps orders in memory. It is safe for concurrent use and behaves like the
+// Postgres repository, the shared tests in usecasetest hold both to the same contract.
+type OrderRepository struct {
+	mu      sync.Mutex
+	nextID  int64
+	orders  map[int64]models.Order
+	claimed map[int64]bool // Orders an ExpirePending call is releasing
+}
+
+func NewOrderRepository() *OrderRepository {
+	return &OrderRepository{
+		orders:  make(map[int64]models.Order),
+		claimed: make(map[int64]bool),
+	}
+}
+
+// Create adds the quantities of lines with the same product together.
+func (r *OrderRepository) Create(ctx context.Context, order models.Order) (int64, error) {
+	if err := checkCustomerName(order.CustomerName); err != nil {
+		return 0, err
+	}
+
+	var items []models.OrderItem
+	for _, v := range order.OrderItems {
+		if err := checkItem(v); err != nil {
+			return 0, err
+		}
+
+		i := slices.IndexFunc(items, func(item models.OrderItem) bool { return item.ProductID == v.ProductID })
+		if i >= 0 {
+			items[i].Quantity += v.Quantity
+			continue
+		}
+
+		v.Status = itemStatus(v)
+		items = append(items, v)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	for i := range items {
+		items[i].OrderID = r.nextID
+	}
+	slices.SortFunc(items, byProduct)
+
+	r.orders[r.nextID] = models.Order{
+		ID:           r.nextID,
+		CustomerName: order.CustomerName,
+		CustomerID:   order.CustomerID,
+		Status:       order.Status,
+		Created_at:   time.Now().Round(time.Second),
+		OrderItems:   items,
+	}
+
+	return r.nextID, nil
+}
+
+func (r *OrderRepository) GetWithFilter(ctx context.Context, filter models.OrderFilter) (models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[filter.ID]
+	if !ok || order.IsDeleted {
+		return models.Order{}, models.ErrRecordNotFound
+	}
+
+	order.OrderItems = slices.Clone(order.OrderItems)
+	return order, nil
+}
+
+// GetListWithFilter returns the page of filter of the orders that match it, newest first,
+// and how many orders match it.
+func (r *OrderRepository) GetListWithFilter(ctx context.Context, filter models.OrderFilter) ([]models.Order, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var orders []models.Order
+	for _, order := range r.orders {
+		if order.IsDeleted || !matches(order, filter) {
+			continue
+		}
+
+		order.OrderItems = slices.Clone(order.OrderItems)
+		if order.OrderItems == nil {
+			order.OrderItems = []models.OrderItem{}
+		}
+		orders = append(orders, order)
+	}
+
+	slices.SortFunc(orders, func(a, b models.Order) int {
+		return cmp.Or(b.Created_at.Compare(a.Created_at), cmp.Compare(b.ID, a.ID))
+	})
+
+	total := len(orders)
+	if filter.Limit() > 0 {
+		start := min(filter.Offset(), total)
+		orders = orders[start:min(start+filter.Limit(), total)]
+	}
+	return orders, total, nil
+}
+
+// matches tells whether order is one of those filter lists.
+func matches(order models.Order, filter models.OrderFilter) bool {
+	return (filter.CustomerID == "" || order.CustomerID == filter.CustomerID) &&
+		(filter.Status == "" || order.Status == filter.Status) &&
+		(filter.ProductID == 0 || slices.ContainsFunc(order.OrderItems, func(item models.OrderItem) bool { return item.ProductID == filter.ProductID })) &&
+		(filter.CreatedAfter.IsZero() || order.Created_at.After(filter.CreatedAfter)) &&
+		(filter.CreatedBefore.IsZero() || order.Created_at.Before(filter.CreatedBefore))
+}
+
+// Update changes the given fields of an order that is not deleted. Items, if given,
+// replace all items of the order. It returns models.ErrRecordNotFound if there is no such
+// order.
+func (r *OrderRepository) Update(ctx context.Context, update models.OrderUpdateData) error {
+	if update.CustomerName != nil {
+		if err := checkCustomerName(*update.CustomerName); err != nil {
+			return err
+		}
+	}
+
+	var items []models.OrderItem
+	if update.OrderItems != nil {
+		for _, v := range *update.OrderItems {
+			if err := checkItem(v); err != nil {
+				return err
+			}
+			if slices.ContainsFunc(items, func(item models.OrderItem) bool { return item.ProductID == v.ProductID }) {
+				return errors.New("order already has an item with this product")
+			}
+
+			v.OrderID = *update.ID
+			v.Status = itemStatus(v)
+			items = append(items, v)
+		}
+		slices.SortFunc(items, byProduct)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[*update.ID]
+	if !ok || order.IsDeleted {
+		return models.ErrRecordNotFound
+	}
+
+	if update.CustomerName != nil {
+		order.CustomerName = *update.CustomerName
+	}
+	if update.Status != nil {
+		order.Status = *update.Status
+	}
+	if update.IsDeleted != nil {
+		order.IsDeleted = *update.IsDeleted
+	}
+	if update.OrderItems != nil {
+		order.OrderItems = items
+	}
+
+	r.orders[order.ID] = order
+	return nil
+}
+
+// SetItemStatuses stores the outcome of the inventory deduction for every line of an order.
+func (r *OrderRepository) SetItemStatuses(ctx context.Context, orderID int64, items []models.OrderItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, item := range items {
+		r.setItemStatus(orderID, item.ProductID, itemStatus(item))
+	}
+
+	return nil
+}
+
+// ExpirePending cancels up to limit pending orders created before cutoff and returns the
+// IDs of the orders it canceled.
+//
+// An order is claimed while its lines are released, so concurrent calls never pick the
+// same order. release is called for every line that may hold stock and the line is
+// marked as released right after, so an order that fails halfway stays pending and only
+// its remaining lines are retried on the next call.
+func (r *OrderRepository) ExpirePending(ctx context.Context, cutoff time.Time, limit int, release func(ctx context.Context, item models.OrderItem) error) ([]int64, error) {
+	var expired []int64
+	attempted := []int64{}
+
+	for len(attempted) < limit {
+		orderID, items := r.claim(cutoff, attempted)
+		if orderID == 0 {
+			break
+		}
+		attempted = append(attempted, orderID)
+
+		if r.expire(ctx, orderID, items, release) {
+			expired = append(expired, orderID)
+		}
+	}
+
+	return expired, nil
+}
+
+// claim picks the oldest expired order that is neither in skip nor claimed already and
+// returns its lines that may hold stock. It returns a zero ID when there is nothing left to claim.
+func (r *OrderRepository) claim(cutoff time.Time, skip []int64) (int64, []models.OrderItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var oldest models.Order
+	for _, order := range r.orders {
+		if order.Status != models.OrderStatusPending || order.IsDeleted || !order.Created_at.Before(cutoff) {
+			continue
+		}
+		if r.claimed[order.ID] || slices.Contains(skip, order.ID) {
+			continue
+		}
+
+		if oldest.ID == 0 || cmp.Or(order.Created_at.Compare(oldest.Created_at), cmp.Compare(order.ID, oldest.ID)) < 0 {
+			oldest = order
+		}
+	}
+
+	if oldest.ID == 0 {
+		return 0, nil
+	}
+	r.claimed[oldest.ID] = true
+
+	var items []models.OrderItem
+	for _, item := range oldest.OrderItems {
+		if models.MayHoldStock(item.Status) {
+			items = append(items, item)
+		}
+	}
+
+	return oldest.ID, items
+}
+
+// expire releases the lines of a claimed order and cancels it if all of them were
+// released. Unlike the row lock Postgres holds, the lock is not held while release runs,
+// so the order is checked again before every line and before it is canceled: an order
+// completed in the meantime is left as it is.
+func (r *OrderRepository) expire(ctx context.Context, orderID int64, items []models.OrderItem, release func(ctx context.Context, item models.OrderItem) error) bool {
+	defer func() {
+		r.mu.Lock()
+		delete(r.claimed, orderID)
+		r.mu.Unlock()
+	}()
+
+	for _, item := range items {
+		r.mu.Lock()
+		pending := r.pending(orderID)
+		r.mu.Unlock()
+		if !pending {
+			return false
+		}
+
+		if err := release(ctx, item); err != nil {
+			// Keep the lines that were already released and leave the order pending.
+			return false
+		}
+
+		r.mu.Lock()
+		r.setItemStatus(orderID, item.ProductID, models.OrderItemStatusReleased)
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.pending(orderID) {
+		return false
+	}
+
+	order := r.orders[orderID]
+	order.Status = models.OrderStatusCanceled
+	r.orders[orderID] = order
+
+	return true
+}
+
+// pending reports whether an order is still pending and not deleted. The caller holds
+// the lock.
+func (r *OrderRepository) pending(orderID int64) bool {
+	order, ok := r.orders[orderID]
+	return ok && order.Status == models.OrderStatusPending && !order.IsDeleted
+}
+
+// setItemStatus changes the status of a line. The caller holds the lock.
+func (r *OrderRepository) setItemStatus(orderID, productID int64, status string) {
+	order, ok := r.orders[orderID]
+	if !ok {
+		return
+	}
+
+	for i := range order.OrderItems {
+		if order.OrderItems[i].ProductID == productID {
+			order.OrderItems[i].Status = status
+		}
+	}
+}
+
+// checkCustomerName enforces the length of the customername column.
+func checkCustomerName(name string) error {
+	if utf8.RuneCountInString(name) > 50 {
+		return errors.New("customer name must not be more than 50 characters long")
+	}
+	return nil
+}
+
+// checkItem enforces the check constraint of the order_items table.
+func checkItem(item models.OrderItem) error {
+	if item.Quantity <= 0 {
+		return errors.New("order item quantity must be greater than zero")
+	}
+	return nil
+}
+
+func itemStatus(item models.OrderItem) string {
+	if item.Status == "" {
+		return models.OrderItemStatusPending
+	}
+	return item.Status
+}
+
+func byProduct(a, b models.OrderItem) int {
+	return cmp.Compare(a.ProductID, b.ProductID)
+}
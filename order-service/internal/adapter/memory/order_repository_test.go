@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/usecase"
+	"order-service/internal/usecase/usecasetest"
+)
+
+func TestOrderRepository(t *testing.T) {
+	usecasetest.TestOrderRepository(t, func(t *testing.T) usecase.OrderRepository {
+		return NewOrderRepository()
+	})
+}
+
+// Postgres keeps the order locked while its lines are released, so a change of status
+// waits for the sweep. Here it can happen in between and has to win.
+func TestExpirePendingKeepsStatusChangedMeanwhile(t *testing.T) {
+	ctx := context.Background()
+	repo := NewOrderRepository()
+
+	id, err := repo.Create(ctx, models.Order{
+		CustomerName: "alice",
+		Status:       models.OrderStatusPending,
+		OrderItems:   []models.OrderItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	err = repo.SetItemStatuses(ctx, id, []models.OrderItem{
+		{ProductID: 1, Status: models.OrderItemStatusAccepted},
+		{ProductID: 2, Status: models.OrderItemStatusAccepted},
+	})
+	if err != nil {
+		t.Fatalf("SetItemStatuses: %v", err)
+	}
+
+	var released []int64
+	ids, err := repo.ExpirePending(ctx, time.Now().Add(time.Hour), 10, func(ctx context.Context, item models.OrderItem) error {
+		released = append(released, item.ProductID)
+
+		// Staff complete the order while its first line is released
+		status := models.OrderStatusCompleted
+		return repo.Update(ctx, models.OrderUpdateData{ID: &id, Status: &status})
+	})
+	if err != nil {
+		t.Fatalf("ExpirePending: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("ExpirePending = %v, want nothing", ids)
+	}
+	if len(released) != 1 {
+		t.Errorf("released %v, want the first line only", released)
+	}
+
+	order, err := repo.GetWithFilter(ctx, models.OrderFilter{ID: id})
+	if err != nil {
+		t.Fatalf("GetWithFilter: %v", err)
+	}
+	if order.Status != models.OrderStatusCompleted {
+		t.Errorf("status = %q, want %q", order.Status, models.OrderStatusCompleted)
+	}
+}
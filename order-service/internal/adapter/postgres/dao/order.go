@@ -14,4 +14,5 @@ type OrderItem struct {
 	OrderID   int64
 	ProductID int64
 	Quantity  int64
+	Status    string
 }
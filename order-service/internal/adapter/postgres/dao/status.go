@@ -1,7 +0,0 @@
-package dao
-
-var (
-	OrderStatusPending   = "pending"
-	OrderStatusCompleted = "completed"
-	OrderStatusCanceled  = "canceled"
-)
@@ -2,10 +2,13 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"order-service/internal/models"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -25,27 +28,27 @@ func (r *Order) Create(ctx context.Context, order models.Order) (int64, error) {
 	defer tx.Rollback(ctx)
 
 	query := `
-		INSERT INTO orders (customername, status) 
-		VALUES ($1, $2)
+		INSERT INTO orders (customername, customer_id, status) 
+		VALUES ($1, NULLIF($2, ''), $3)
 		RETURNING ID;
 	`
 
 	var orderID int64
-	err = tx.QueryRow(ctx, query, order.CustomerName, order.Status).Scan(&orderID)
+	err = tx.QueryRow(ctx, query, order.CustomerName, order.CustomerID, order.Status).Scan(&orderID)
 	if err != nil {
 		return 0, err
 	}
 
 	// Inserting order items. in case when same product id is given, it check on conflict, if so it's just adding quantity for previus row.
 	queryOrderItems := `
-		INSERT INTO order_items (OrderID, ProductID, Quantity) VALUES
-		($1, $2, $3)
+		INSERT INTO order_items (OrderID, ProductID, Quantity, Status) VALUES
+		($1, $2, $3, $4)
 		ON CONFLICT (OrderID, ProductID)
 		DO UPDATE SET Quantity = order_items.Quantity + EXCLUDED.Quantity;
 	`
 
 	for _, v := range order.OrderItems {
-		_, err = tx.Exec(ctx, queryOrderItems, orderID, v.ProductID, v.Quantity)
+		_, err = tx.Exec(ctx, queryOrderItems, orderID, v.ProductID, v.Quantity, itemStatus(v))
 		if err != nil {
 			return 0, err
 		}
@@ -56,7 +59,7 @@ func (r *Order) Create(ctx context.Context, order models.Order) (int64, error) {
 
 func (r *Order) GetWithFilter(ctx context.Context, filter models.OrderFilter) (models.Order, error) {
 	query := `
-		SELECT id, customername, status, created_at 
+		SELECT id, customername, COALESCE(customer_id, ''), status, created_at 
 		FROM orders 
 		WHERE id = $1 AND isdeleted = FALSE
 	`
@@ -65,18 +68,23 @@ func (r *Order) GetWithFilter(ctx context.Context, filter models.OrderFilter) (m
 	err := r.db.QueryRow(ctx, query, filter.ID).Scan(
 		&order.ID,
 		&order.CustomerName,
+		&order.CustomerID,
 		&order.Status,
 		&order.Created_at,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Order{}, models.ErrRecordNotFound
+	}
 	if err != nil {
 		return models.Order{}, err
 	}
 
 	// Get order items
 	itemsQuery := `
-	SELECT orderID, productID, quantity 
+	SELECT orderID, productID, quantity, status
 	FROM order_items 
 	WHERE orderID = $1
+	ORDER BY productID
 `
 
 	rows, err := r.db.Query(ctx, itemsQuery, filter.ID)
@@ -88,7 +96,7 @@ func (r *Order) GetWithFilter(ctx context.Context, filter models.OrderFilter) (m
 	var orderItems []models.OrderItem
 	for rows.Next() {
 		var item models.OrderItem
-		err := rows.Scan(&item.OrderID, &item.ProductID, &item.Quantity)
+		err := rows.Scan(&item.OrderID, &item.ProductID, &item.Quantity, &item.Status)
 		if err != nil {
 			return models.Order{}, err
 		}
@@ -103,45 +111,63 @@ func (r *Order) GetWithFilter(ctx context.Context, filter models.OrderFilter) (m
 	return order, nil
 }
 
-func (r *Order) GetListWithFilter(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
-	// First, get all orders
+// GetListWithFilter returns the page of filter of the orders that match it, newest first,
+// and how many orders match it.
+func (r *Order) GetListWithFilter(ctx context.Context, filter models.OrderFilter) ([]models.Order, int, error) {
+	// First, get the orders of the page, each with the number of all that match
 	ordersQuery := `
-        SELECT id, customername, status, created_at 
+        SELECT count(*) OVER(), id, customername, COALESCE(customer_id, ''), status, created_at 
         FROM orders 
-        WHERE isdeleted = FALSE
-        ORDER BY created_at DESC
+        WHERE ` + ordersWhere + `
+        ORDER BY created_at DESC, id DESC
+        LIMIT $6 OFFSET $7
     `
 
-	rows, err := r.db.Query(ctx, ordersQuery)
+	// No limit lists all orders
+	var limit *int
+	if l := filter.Limit(); l > 0 {
+		limit = &l
+	}
+	args := append(ordersWhereArgs(filter), limit, filter.Offset())
+
+	rows, err := r.db.Query(ctx, ordersQuery, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var orders []models.Order
+	total := 0
 	for rows.Next() {
 		var order models.Order
-		err := rows.Scan(&order.ID, &order.CustomerName, &order.Status, &order.Created_at)
+		err := rows.Scan(&total, &order.ID, &order.CustomerName, &order.CustomerID, &order.Status, &order.Created_at)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		orders = append(orders, order)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	// A page past the last one has no rows to count with
+	if len(orders) == 0 && filter.Offset() > 0 {
+		err := r.db.QueryRow(ctx, `SELECT count(*) FROM orders WHERE `+ordersWhere, ordersWhereArgs(filter)...).Scan(&total)
+		return orders, total, err
 	}
 
 	// If no orders found, return empty slice
 	if len(orders) == 0 {
-		return orders, nil
+		return orders, total, nil
 	}
 
 	// Get all order items for the fetched orders
 	itemsQuery := `
-        SELECT orderID, productID, quantity 
+        SELECT orderID, productID, quantity, status
         FROM order_items 
         WHERE orderID = ANY($1)
+        ORDER BY productID
     `
 
 	// Prepare list of order IDs
@@ -152,7 +178,7 @@ func (r *Order) GetListWithFilter(ctx context.Context, filter models.OrderFilter
 
 	itemRows, err := r.db.Query(ctx, itemsQuery, orderIDs)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer itemRows.Close()
 
@@ -160,15 +186,15 @@ func (r *Order) GetListWithFilter(ctx context.Context, filter models.OrderFilter
 	itemsMap := make(map[int64][]models.OrderItem)
 	for itemRows.Next() {
 		var item models.OrderItem
-		err := itemRows.Scan(&item.OrderID, &item.ProductID, &item.Quantity)
+		err := itemRows.Scan(&item.OrderID, &item.ProductID, &item.Quantity, &item.Status)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		itemsMap[item.OrderID] = append(itemsMap[item.OrderID], item)
 	}
 
 	if err = itemRows.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Assign order items to each order
@@ -180,9 +206,32 @@ func (r *Order) GetListWithFilter(ctx context.Context, filter models.OrderFilter
 		}
 	}
 
-	return orders, nil
+	return orders, total, nil
 }
 
+// ordersWhere selects the orders of a filter, with the arguments of ordersWhereArgs.
+const ordersWhere = `isdeleted = FALSE
+          AND ($1 = '' OR customer_id = $1)
+          AND ($2 = '' OR status = $2)
+          AND ($3 = 0 OR EXISTS (SELECT 1 FROM order_items WHERE orderID = orders.id AND productID = $3))
+          AND ($4::timestamptz IS NULL OR created_at > $4)
+          AND ($5::timestamptz IS NULL OR created_at < $5)`
+
+func ordersWhereArgs(filter models.OrderFilter) []any {
+	return []any{filter.CustomerID, filter.Status, filter.ProductID, optionalTime(filter.CreatedAfter), optionalTime(filter.CreatedBefore)}
+}
+
+// optionalTime passes the zero time as NULL.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Update changes the given fields of an order that is not deleted. Items, if given,
+// replace all items of the order. It returns models.ErrRecordNotFound if there is no such
+// order.
 func (r *Order) Update(ctx context.Context, update models.OrderUpdateData) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
@@ -190,8 +239,8 @@ func (r *Order) Update(ctx context.Context, update models.OrderUpdateData) error
 	}
 	defer tx.Rollback(ctx)
 
-	// Build dynamic update query for order fields
-	query := "UPDATE orders SET "
+	// Build dynamic update query for order fields, with no fields the order is only locked
+	query := "UPDATE orders SET id = id, "
 	params := []any{}
 	paramCount := 1
 
@@ -215,15 +264,18 @@ func (r *Order) Update(ctx context.Context, update models.OrderUpdateData) error
 
 	// Remove trailing comma and add WHERE clause
 	query = strings.TrimSuffix(query, ", ")
-	query += fmt.Sprintf(" WHERE id = $%d", paramCount)
+	query += fmt.Sprintf(" WHERE id = $%d AND isdeleted = FALSE", paramCount)
 	params = append(params, update.ID)
 	paramCount++
 
 	// Execute order update
-	_, err = tx.Exec(ctx, query, params...)
+	result, err := tx.Exec(ctx, query, params...)
 	if err != nil {
 		return fmt.Errorf("failed to update order: %w", err)
 	}
+	if result.RowsAffected() == 0 {
+		return models.ErrRecordNotFound
+	}
 
 	// Handle order items update if provided
 	if update.OrderItems != nil {
@@ -236,17 +288,17 @@ func (r *Order) Update(ctx context.Context, update models.OrderUpdateData) error
 		// Insert new items if any
 		items := *update.OrderItems
 		if len(items) > 0 {
-			itemQuery := "INSERT INTO order_items (orderID, productID, quantity) VALUES "
+			itemQuery := "INSERT INTO order_items (orderID, productID, quantity, status) VALUES "
 			itemParams := []any{}
 			itemParamCount := 1
 
 			valueClauses := []string{}
 			for _, item := range items {
-				valueClause := fmt.Sprintf("($%d, $%d, $%d)",
-					itemParamCount, itemParamCount+1, itemParamCount+2)
+				valueClause := fmt.Sprintf("($%d, $%d, $%d, $%d)",
+					itemParamCount, itemParamCount+1, itemParamCount+2, itemParamCount+3)
 				valueClauses = append(valueClauses, valueClause)
-				itemParams = append(itemParams, update.ID, item.ProductID, item.Quantity)
-				itemParamCount += 3
+				itemParams = append(itemParams, update.ID, item.ProductID, item.Quantity, itemStatus(item))
+				itemParamCount += 4
 			}
 
 			itemQuery += strings.Join(valueClauses, ", ")
@@ -259,3 +311,136 @@ func (r *Order) Update(ctx context.Context, update models.OrderUpdateData) error
 
 	return tx.Commit(ctx)
 }
+
+// SetItemStatuses stores the outcome of the inventory deduction for every line of an order.
+func (r *Order) SetItemStatuses(ctx context.Context, orderID int64, items []models.OrderItem) error {
+	query := `
+		UPDATE order_items
+		SET status = $1
+		WHERE orderID = $2 AND productID = $3
+	`
+
+	batch := &pgx.Batch{}
+	for _, item := range items {
+		batch.Queue(query, itemStatus(item), orderID, item.ProductID)
+	}
+
+	return r.db.SendBatch(ctx, batch).Close()
+}
+
+// ExpirePending cancels up to limit pending orders created before cutoff and returns the
+// IDs of the orders it canceled.
+//
+// Each order is claimed in its own transaction with FOR UPDATE SKIP LOCKED, so several
+// replicas can sweep at the same time without picking the same order. release is called
+// for every line that may hold stock while the order is locked and the line is marked as
+// released right after, so an order that fails halfway stays pending and only its
+// remaining lines are retried on the next sweep.
+func (r *Order) ExpirePending(ctx context.Context, cutoff time.Time, limit int, release func(ctx context.Context, item models.OrderItem) error) ([]int64, error) {
+	var expired []int64
+	attempted := []int64{}
+
+	for len(attempted) < limit {
+		orderID, ok, err := r.expireOne(ctx, cutoff, attempted, release)
+		if err != nil {
+			return expired, err
+		}
+		if orderID == 0 {
+			break
+		}
+
+		attempted = append(attempted, orderID)
+		if ok {
+			expired = append(expired, orderID)
+		}
+	}
+
+	return expired, nil
+}
+
+// expireOne claims the oldest expired order that is not in skip. It returns a zero ID when
+// there is nothing left to claim and ok = false when some line could not be released.
+func (r *Order) expireOne(ctx context.Context, cutoff time.Time, skip []int64, release func(ctx context.Context, item models.OrderItem) error) (int64, bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT id
+		FROM orders
+		WHERE status = $1 AND isdeleted = FALSE AND created_at < $2 AND id <> ALL($3)
+		ORDER BY created_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	var orderID int64
+	err = tx.QueryRow(ctx, query, models.OrderStatusPending, cutoff, skip).Scan(&orderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	itemsQuery := `
+		SELECT orderID, productID, quantity, status
+		FROM order_items
+		WHERE orderID = $1 AND status = ANY($2)
+		ORDER BY productID
+	`
+
+	// Lines that may hold stock
+	holding := []string{models.OrderItemStatusAccepted, models.OrderItemStatusUnknown, models.OrderItemStatusPending}
+	rows, err := tx.Query(ctx, itemsQuery, orderID, holding)
+	if err != nil {
+		return 0, false, err
+	}
+
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OrderItem, error) {
+		var item models.OrderItem
+		err := row.Scan(&item.OrderID, &item.ProductID, &item.Quantity, &item.Status)
+		return item, err
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	releaseQuery := `
+		UPDATE order_items
+		SET status = $1
+		WHERE orderID = $2 AND productID = $3
+	`
+
+	for _, item := range items {
+		if err := release(ctx, item); err != nil {
+			// Keep the lines that were already released and leave the order pending.
+			return orderID, false, tx.Commit(ctx)
+		}
+
+		if _, err := tx.Exec(ctx, releaseQuery, models.OrderItemStatusReleased, orderID, item.ProductID); err != nil {
+			return 0, false, err
+		}
+	}
+
+	cancelQuery := `
+		UPDATE orders
+		SET status = $1
+		WHERE id = $2
+	`
+
+	if _, err := tx.Exec(ctx, cancelQuery, models.OrderStatusCanceled, orderID); err != nil {
+		return 0, false, err
+	}
+
+	return orderID, true, tx.Commit(ctx)
+}
+
+func itemStatus(item models.OrderItem) string {
+	if item.Status == "" {
+		return models.OrderItemStatusPending
+	}
+	return item.Status
+}
@@ -0,0 +1,16 @@
+package postgres
+
+import (
+	"testing"
+
+	"order-service/internal/usecase"
+	"order-service/internal/usecase/usecasetest"
+	"order-service/pkg/postgres/postgrestest"
+)
+
+func TestOrderRepository(t *testing.T) {
+	usecasetest.TestOrderRepository(t, func(t *testing.T) usecase.OrderRepository {
+		pool := postgrestest.New(t)
+		return NewOrderRepository(pool)
+	})
+}
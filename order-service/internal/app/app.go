@@ -2,73 +2,145 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"order-service/config"
 
+	"order-service/internal/adapter/grpc/invclient"
 	"order-service/internal/adapter/http/myrouter"
 	httpservice "order-service/internal/adapter/http/service"
+	"order-service/internal/adapter/invmetrics"
 	postgresrepo "order-service/internal/adapter/postgres"
 	"order-service/internal/usecase"
+	"order-service/internal/worker"
+	"order-service/migrations"
+	"order-service/pkg/health"
 	"order-service/pkg/postgres"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const serviceName = "Order"
 
 type App struct {
-	httpServer *httpservice.API
-	postgresDB *postgres.PostgreDB
-	// grpcServer *grpc.Server // Example
+	httpServer      *httpservice.API
+	postgresDB      *postgres.PostgreDB
+	sweeper         *worker.Sweeper
+	inventoryClient *invclient.InventoryClient // Only with the gRPC transport
+	health          *health.Health
+
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
 }
 
 func New(ctx context.Context, cfg *config.Config) (*App, error) {
-	log.Printf("starting %v service\n", serviceName)
+	slog.Info("starting service", "service", serviceName)
 
-	log.Println("connecting to postgres")
+	slog.Info("connecting to postgres")
 	postgresDB, err := postgres.New(ctx, cfg.Postgres)
 	if err != nil {
 		return nil, fmt.Errorf("mongo: %w", err)
 	}
 
-	log.Println("connection established")
+	slog.Info("connection established")
+
+	if cfg.Postgres.MigrateOnStart {
+		if err := postgres.Migrate(postgresDB.Pool, migrations.FS); err != nil {
+			postgresDB.Pool.Close()
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+	}
+
+	// Exporting the statistics of the connection pool
+	prometheus.MustRegister(postgres.NewCollector(postgresDB.Pool))
 
 	// Repository
 	orderRepo := postgresrepo.NewOrderRepository(postgresDB.Pool)
 
+	app := &App{
+		postgresDB:      postgresDB,
+		health:          health.New(cfg.Health),
+		shutdownDelay:   cfg.Server.ShutdownDelay,
+		shutdownTimeout: cfg.Server.ShutdownTimeout,
+	}
+
+	// Readiness checks
+	app.health.Add("postgres", postgresDB.Check)
+	app.health.Add("migrations", postgresDB.CheckMigrations)
+
 	// Inventory Service
-	inv_router, err := myrouter.NewInventoryRouter("http://localhost:8082") // HardCode
-	if err != nil {
-		return nil, fmt.Errorf("inventory router: %w", err)
+	var inventoryService usecase.InventoryService
+	switch cfg.Inventory.Transport {
+	case "http":
+		inv_router, err := myrouter.NewInventoryRouter(cfg.Inventory)
+		if err != nil {
+			return nil, fmt.Errorf("inventory router: %w", err)
+		}
+		inventoryService = inv_router
+		app.health.Add("inventory-service", inv_router.Check)
+	case "grpc":
+		app.inventoryClient, err = invclient.NewInventoryClient(cfg.Inventory)
+		if err != nil {
+			return nil, fmt.Errorf("inventory client: %w", err)
+		}
+		inventoryService = app.inventoryClient
+		app.health.Add("inventory-service", app.inventoryClient.Check)
+	default:
+		return nil, fmt.Errorf("unknown inventory transport: %q", cfg.Inventory.Transport)
 	}
+	inventoryService = invmetrics.New(cfg.Inventory.Transport, inventoryService)
 
 	// UseCase
-	orderUsecase := usecase.NewOrder(orderRepo, inv_router)
+	orderUsecase := usecase.NewOrder(orderRepo, inventoryService)
 
 	// http service
-	httpServer := httpservice.New(cfg.Server, orderUsecase)
+	app.httpServer = httpservice.New(cfg.Server, orderUsecase, app.health)
 
-	app := &App{
-		httpServer: httpServer,
-		postgresDB: postgresDB,
+	// Background workers
+	if cfg.Sweeper.Enabled {
+		app.sweeper = worker.NewSweeper(cfg.Sweeper, orderUsecase)
 	}
 
 	return app, nil
 }
 
-// TODO: close postgres connection
+// Close drains the http server within the shutdown timeout, then stops the background
+// workers and closes the connections, so that nothing still running is left with a closed
+// pool.
 func (a *App) Close() {
-	// Closing http server
-	err := a.httpServer.Stop()
+	// Failing the readiness probe, so that no new requests are sent
+	a.health.Shutdown()
+	time.Sleep(a.shutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	// Closing http server, in-flight requests are finished first
+	err := a.httpServer.Stop(ctx)
+
+	// Stopping background workers, the current run is canceled and waited for
+	if a.sweeper != nil {
+		a.sweeper.Stop()
+	}
+
+	// Closing inventory connection
+	if a.inventoryClient != nil {
+		if errClient := a.inventoryClient.Close(); errClient != nil {
+			err = errors.Join(err, errClient)
+		}
+	}
 
 	// Closing postgres connection
 	a.postgresDB.Pool.Close()
 
 	if err != nil {
-		log.Println("failed to shutdown service", err)
+		slog.Error("failed to shutdown service", "error", err)
 	}
 }
 
@@ -78,7 +150,12 @@ func (a *App) Run() error {
 	// Running http server
 	a.httpServer.Run(errCh)
 
-	log.Printf("service %v started\n", serviceName)
+	// Running background workers
+	if a.sweeper != nil {
+		a.sweeper.Run()
+	}
+
+	slog.Info("service started", "service", serviceName)
 
 	// Waiting signal
 	shutdownCh := make(chan os.Signal, 1)
@@ -86,13 +163,14 @@ func (a *App) Run() error {
 
 	select {
 	case errRun := <-errCh:
+		a.Close()
 		return errRun
 
 	case s := <-shutdownCh:
-		log.Printf("received signal: %v. Running graceful shutdown...\n", s)
+		slog.Info("received signal, running graceful shutdown", "signal", s.String())
 
 		a.Close()
-		log.Println("graceful shutdown completed!")
+		slog.Info("graceful shutdown completed")
 	}
 
 	return nil
@@ -0,0 +1,13 @@
+package models
+
+import "errors"
+
+// ErrRecordNotFound is returned by the order repositories for an order that does not exist.
+var ErrRecordNotFound = errors.New("record not found")
+
+// Errors returned by the inventory service client.
+var (
+	ErrProductNotFound      = errors.New("product not found")
+	ErrStockConflict        = errors.New("product stock was changed concurrently")
+	ErrInventoryUnavailable = errors.New("inventory service unavailable")
+)
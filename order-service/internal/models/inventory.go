@@ -1,26 +1,79 @@
 package models
 
+import "math"
+
 type Inventory struct {
 	ID          int64
 	Name        string
 	Description string
-	Price       int64
+	Price       int64 // In cents, see PriceCents
 	Available   int64
 	CreatedAt   string
 	Version     int32
 }
 
+// PriceCents converts a price of inventory-service, a decimal with two places, to the
+// cents order-service counts money in. Rounding only drops the error of the float, so
+// 48.99 is 4899 although 48.99 * 100 is slightly less.
+func PriceCents(price float64) int64 {
+	return int64(math.Round(price * 100))
+}
+
 type OrderResponce struct {
 	OrderID      int64
 	CustomerName string
 	Items        []OrderItemResponce
-	Total        int64
+	Total        int64 // In cents
 }
 
+// Reasons an order line can be rejected with.
+const (
+	ReasonInsufficientInventory = "insufficient_inventory"
+	ReasonProductNotFound       = "product_not_found"
+	ReasonStockConflict         = "stock_conflict"
+	ReasonInventoryUnavailable  = "inventory_unavailable"
+	ReasonInventoryError        = "inventory_error"
+)
+
 type OrderItemResponce struct {
 	ProductID int64
 	Name      string
-	Price     int64
+	Price     int64 // Of the whole line, in cents
 	Status    string
 	Reason    string
 }
+
+// Outcomes of a single stock change in a batch, as reported by inventory-service.
+const (
+	StockChangeApplied           = "applied"
+	StockChangeInsufficientStock = "insufficient_stock"
+	StockChangeNotFound          = "not_found"
+	StockChangeSkipped           = "skipped"
+)
+
+// Reasons this service adjusts stock for.
+const (
+	AdjustmentReasonOrderPlaced   = "order_placed"
+	AdjustmentReasonOrderReleased = "order_released"
+)
+
+// StockAdjustment changes the available stock of a product relative to its current value.
+// Inventory applies an adjustment with the same reference to a product only once.
+type StockAdjustment struct {
+	ProductID int64
+	Delta     int64
+	Reason    string
+	Reference string
+}
+
+type StockChange struct {
+	ProductID int64
+	Quantity  int64
+}
+
+type StockChangeResult struct {
+	ProductID int64
+	Quantity  int64
+	Status    string
+	Available int64
+}
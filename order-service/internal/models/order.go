@@ -2,9 +2,40 @@ package models
 
 import "time"
 
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusCompleted = "completed"
+	OrderStatusCanceled  = "canceled"
+)
+
+// Order item statuses. An item is "accepted" once its stock has been deducted in
+// inventory-service and "released" once that stock has been given back. It is "unknown"
+// when inventory-service did not answer whether it deducted the stock, which the sweeper
+// settles when the order expires. A line stays "pending" when its outcome was never
+// recorded, so it may have taken stock as well.
+const (
+	OrderItemStatusPending  = "pending"
+	OrderItemStatusAccepted = "accepted"
+	OrderItemStatusRejected = "rejected"
+	OrderItemStatusReleased = "released"
+	OrderItemStatusUnknown  = "unknown"
+)
+
+// MayHoldStock tells whether a line of the status may have taken stock that has not been
+// given back.
+func MayHoldStock(status string) bool {
+	switch status {
+	case OrderItemStatusAccepted, OrderItemStatusUnknown, OrderItemStatusPending:
+		return true
+	default:
+		return false
+	}
+}
+
 type Order struct {
 	ID           int64
 	CustomerName string
+	CustomerID   string // Subject of the caller that placed the order, empty for older orders
 	OrderItems   []OrderItem
 	Status       string
 	Created_at   time.Time
@@ -16,6 +47,7 @@ type OrderItem struct {
 	OrderID   int64
 	ProductID int64
 	Quantity  int64
+	Status    string
 }
 
 type OrderUpdateData struct {
@@ -38,8 +70,26 @@ type UpdateStatus struct {
 	Status  string
 }
 
+// OrderFilter selects orders. The fields left zero select every order.
 type OrderFilter struct {
-	ID int64
+	ID            int64
+	CustomerID    string    // Lists only the orders of this customer if set
+	Status        string    // Lists only the orders with this status if set
+	ProductID     int64     // Lists only the orders with a line of this product if set
+	CreatedAfter  time.Time // Lists only the orders placed after it if set
+	CreatedBefore time.Time // Lists only the orders placed before it if set
+
+	// A list is cut into pages of PageSize orders, unless PageSize is zero
+	Page     int
+	PageSize int
+}
+
+func (f OrderFilter) Limit() int {
+	return f.PageSize
+}
+
+func (f OrderFilter) Offset() int {
+	return max(f.Page-1, 0) * f.PageSize
 }
 
 // OrderInfo
@@ -3,16 +3,20 @@ package usecase
 import (
 	"context"
 	"order-service/internal/models"
+	"time"
 )
 
 type OrderRepository interface {
 	Create(ctx context.Context, order models.Order) (int64, error)
 	GetWithFilter(ctx context.Context, filter models.OrderFilter) (models.Order, error)
-	GetListWithFilter(ctx context.Context, filter models.OrderFilter) ([]models.Order, error)
+	GetListWithFilter(ctx context.Context, filter models.OrderFilter) ([]models.Order, int, error)
 	Update(ctx context.Context, update models.OrderUpdateData) error
+	SetItemStatuses(ctx context.Context, orderID int64, items []models.OrderItem) error
+	ExpirePending(ctx context.Context, cutoff time.Time, limit int, release func(ctx context.Context, item models.OrderItem) error) ([]int64, error)
 }
 
 type InventoryService interface {
-	GetById(id int64) (models.Inventory, error)
-	Substruct(id, newAvailability int64, version int32) error
+	GetMany(ctx context.Context, ids []int64) (map[int64]models.Inventory, error)
+	DecrementMany(ctx context.Context, reason, reference string, changes []models.StockChange) ([]models.StockChangeResult, error)
+	Adjust(ctx context.Context, adj models.StockAdjustment) error
 }
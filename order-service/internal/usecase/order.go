@@ -2,8 +2,34 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"order-service/internal/models"
+	"order-service/pkg/tracing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = otel.Tracer("order-service/internal/usecase")
+
+var (
+	ordersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Number of orders placed, whether their lines were accepted or not.",
+	})
+	linesRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_lines_rejected_total",
+		Help: "Number of order lines rejected, by reason.",
+	}, []string{"reason"})
+	stockConflicts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_stock_conflicts_total",
+		Help: "Number of stock batches inventory-service refused because a product ran out.",
+	})
 )
 
 type Order struct {
@@ -19,57 +45,88 @@ func NewOrder(orderRepo OrderRepository, inventoryService InventoryService) *Ord
 }
 
 func (u *Order) Create(ctx context.Context, request models.Order) (models.OrderResponce, error) {
+	ctx, span := tracer.Start(ctx, "Order.Create")
+	defer span.End()
+
+	// The lines of a product are stored as one, so they are accepted or rejected as one
+	request.OrderItems = mergeLines(request.OrderItems)
+
 	// Inserting order to database
 	orderID, err := u.orderRepo.Create(ctx, request)
 	if err != nil {
-		return models.OrderResponce{}, err
+		return models.OrderResponce{}, tracing.Error(span, err)
 	}
+	span.SetAttributes(attribute.Int64("order.id", orderID))
 
-	// Metadata of items
-	var orderItemResponces []models.OrderItemResponce
-	var totalPrice int64
-	for _, item := range request.OrderItems {
-		var orderItemResp models.OrderItemResponce
-		orderItemResp.ProductID = item.ProductID
+	// Getting all products from inventory service at once
+	products, err := u.inventoryService.GetMany(ctx, productIDs(request.OrderItems))
 
-		// Getting inventory from inventory service
-		inventoryItem, err := u.inventoryService.GetById(item.ProductID)
+	// Checking every line against the stock, a rejected line keeps its reason
+	reasons := make([]string, len(request.OrderItems))
+	for i, item := range request.OrderItems {
 		if err != nil {
-			orderItemResp.Status = "rejected"
-			orderItemResp.Reason = err.Error()
-			orderItemResponces = append(orderItemResponces, orderItemResp)
+			reasons[i] = rejectionReason(err)
 			continue
 		}
 
-		price := inventoryItem.Price * item.Quantity
-		newAvailability := inventoryItem.Available - item.Quantity
-
-		if newAvailability < 0 {
-			orderItemResp.Status = "rejected"
-			orderItemResp.Reason = "insufficient_inventory"
-			orderItemResponces = append(orderItemResponces, orderItemResp)
+		inventoryItem, ok := products[item.ProductID]
+		if !ok {
+			reasons[i] = models.ReasonProductNotFound
 			continue
 		}
 
-		// Trying to set new availability
-		err = u.inventoryService.Substruct(item.ProductID, newAvailability, inventoryItem.Version)
-		if err != nil {
-			orderItemResp.Status = "rejected"
-			orderItemResp.Reason = err.Error()
+		if inventoryItem.Available < item.Quantity {
+			reasons[i] = models.ReasonInsufficientInventory
+		}
+	}
+
+	// Taking the stock of the remaining lines in one batch
+	unknown := u.takeStock(ctx, orderID, request.OrderItems, reasons)
+
+	// Metadata of items
+	var orderItemResponces []models.OrderItemResponce
+	var totalPrice int64
+	for i, item := range request.OrderItems {
+		var orderItemResp models.OrderItemResponce
+		orderItemResp.ProductID = item.ProductID
+
+		if reasons[i] != "" {
+			orderItemResp.Status = models.OrderItemStatusRejected
+			orderItemResp.Reason = reasons[i]
 			orderItemResponces = append(orderItemResponces, orderItemResp)
+			request.OrderItems[i].Status = models.OrderItemStatusRejected
+			if unknown[i] {
+				// Rejected for the customer, but the stock may have been taken
+				request.OrderItems[i].Status = models.OrderItemStatusUnknown
+			}
 			continue
 		}
 
+		inventoryItem := products[item.ProductID]
+		price := inventoryItem.Price * item.Quantity
+
 		orderItemResp.Name = inventoryItem.Name
 		orderItemResp.Price = price
-		orderItemResp.Status = "accepted"
+		orderItemResp.Status = models.OrderItemStatusAccepted
+		request.OrderItems[i].Status = models.OrderItemStatusAccepted
 
 		totalPrice += price
 
 		orderItemResponces = append(orderItemResponces, orderItemResp)
 	}
 
-	fmt.Println(orderItemResponces)
+	// Remembering which lines took stock, so that only those are given back on expiry
+	err = u.orderRepo.SetItemStatuses(ctx, orderID, request.OrderItems)
+	if err != nil {
+		return models.OrderResponce{}, tracing.Error(span, err)
+	}
+
+	ordersCreated.Inc()
+	for _, reason := range reasons {
+		if reason != "" {
+			linesRejected.WithLabelValues(reason).Inc()
+		}
+	}
 
 	responce := models.OrderResponce{
 		OrderID:      orderID,
@@ -81,27 +138,37 @@ func (u *Order) Create(ctx context.Context, request models.Order) (models.OrderR
 	return responce, nil
 }
 
-func (u *Order) GetList(ctx context.Context) ([]models.Order, error) {
-	orders, err := u.orderRepo.GetListWithFilter(ctx, models.OrderFilter{})
+// GetList returns the page of the orders that match filter, and how many match it.
+func (u *Order) GetList(ctx context.Context, filter models.OrderFilter) ([]models.Order, int, error) {
+	ctx, span := tracer.Start(ctx, "Order.GetList")
+	defer span.End()
+
+	orders, total, err := u.orderRepo.GetListWithFilter(ctx, filter)
 	if err != nil {
-		return nil, err
+		return nil, 0, tracing.Error(span, err)
 	}
-	return orders, nil
+	return orders, total, nil
 }
 
 func (u *Order) Get(ctx context.Context, id int64) (models.Order, error) {
+	ctx, span := tracer.Start(ctx, "Order.Get")
+	defer span.End()
+
 	order, err := u.orderRepo.GetWithFilter(ctx, models.OrderFilter{ID: id})
 	if err != nil {
-		return models.Order{}, err
+		return models.Order{}, tracing.Error(span, err)
 	}
 
 	return order, nil
 }
 
 func (u *Order) SetStatus(ctx context.Context, req models.UpdateStatus) (models.Order, error) {
+	ctx, span := tracer.Start(ctx, "Order.SetStatus")
+	defer span.End()
+
 	order, err := u.Get(ctx, req.OrderID)
 	if err != nil {
-		return models.Order{}, err
+		return models.Order{}, tracing.Error(span, err)
 	}
 
 	var updatedOrder models.OrderUpdateData
@@ -111,9 +178,196 @@ func (u *Order) SetStatus(ctx context.Context, req models.UpdateStatus) (models.
 	err = u.orderRepo.Update(ctx, updatedOrder)
 
 	if err != nil {
-		return models.Order{}, err
+		return models.Order{}, tracing.Error(span, err)
 	}
 
 	order.Status = req.Status
 	return order, nil
 }
+
+// takeStock decrements the stock of every line that has no rejection reason yet. Inventory
+// applies a batch completely or not at all, so the products it refuses get rejected and
+// the rest is sent again. Every round rejects at least one product.
+//
+// A batch that fails otherwise, such as with a lost response, may still have been
+// applied. Its lines are rejected as well, and returned so that they are stored as
+// unknown rather than as rejected.
+func (u *Order) takeStock(ctx context.Context, orderID int64, items []models.OrderItem, reasons []string) map[int]bool {
+	ctx, span := tracer.Start(ctx, "Order.takeStock")
+	defer span.End()
+
+	reference := orderReference(orderID)
+
+	for {
+		var changes []models.StockChange
+		lines := make(map[int64]int)
+		for i, item := range items {
+			if reasons[i] != "" {
+				continue
+			}
+			changes = append(changes, models.StockChange{ProductID: item.ProductID, Quantity: item.Quantity})
+			lines[item.ProductID] = i
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+
+		results, err := u.inventoryService.DecrementMany(ctx, models.AdjustmentReasonOrderPlaced, reference, changes)
+		if err == nil {
+			return nil
+		}
+
+		if errors.Is(err, models.ErrStockConflict) {
+			stockConflicts.Inc()
+		}
+
+		rejected := false
+		if errors.Is(err, models.ErrStockConflict) && len(results) == len(changes) {
+			for _, result := range results {
+				switch result.Status {
+				case models.StockChangeInsufficientStock:
+					reasons[lines[result.ProductID]] = models.ReasonInsufficientInventory
+				case models.StockChangeNotFound:
+					reasons[lines[result.ProductID]] = models.ReasonProductNotFound
+				default:
+					continue
+				}
+				rejected = true
+			}
+		}
+
+		if rejected {
+			continue
+		}
+
+		// Only a refused batch or a missing product is known to have taken nothing
+		var unknown map[int]bool
+		if !errors.Is(err, models.ErrStockConflict) && !errors.Is(err, models.ErrProductNotFound) {
+			unknown = make(map[int]bool, len(lines))
+		}
+		for _, i := range lines {
+			reasons[i] = rejectionReason(err)
+			if unknown != nil {
+				unknown[i] = true
+			}
+		}
+		return unknown
+	}
+}
+
+// ExpirePending cancels pending orders older than ttl and returns the stock of their
+// accepted lines to inventory. It handles at most limit orders and returns how many of
+// them were canceled.
+func (u *Order) ExpirePending(ctx context.Context, ttl time.Duration, limit int) (int, error) {
+	ctx, span := tracer.Start(ctx, "Order.ExpirePending")
+	defer span.End()
+
+	cutoff := time.Now().Add(-ttl)
+
+	expired, err := u.orderRepo.ExpirePending(ctx, cutoff, limit, u.releaseItem)
+	span.SetAttributes(attribute.Int("order.expired", len(expired)))
+	if err != nil {
+		return len(expired), tracing.Error(span, err)
+	}
+
+	return len(expired), nil
+}
+
+// releaseItem gives the quantity of an accepted line back to inventory. A line whose
+// outcome is unknown, or was never recorded, is settled first.
+func (u *Order) releaseItem(ctx context.Context, item models.OrderItem) error {
+	if item.Status != models.OrderItemStatusAccepted {
+		taken, err := u.settleItem(ctx, item)
+		if err != nil || !taken {
+			return err
+		}
+	}
+
+	err := u.inventoryService.Adjust(ctx, models.StockAdjustment{
+		ProductID: item.ProductID,
+		Delta:     item.Quantity,
+		Reason:    models.AdjustmentReasonOrderReleased,
+		Reference: orderReference(item.OrderID) + ":release",
+	})
+	if err == nil {
+		return nil
+	}
+
+	// A deleted product has no stock to give back
+	if errors.Is(err, models.ErrProductNotFound) {
+		slog.WarnContext(ctx, "release: product no longer exists", "product_id", item.ProductID, "order_id", item.OrderID)
+		return nil
+	}
+
+	return fmt.Errorf("release product %d of order %d: %w", item.ProductID, item.OrderID, err)
+}
+
+// settleItem finds out whether the stock of a line was taken when its outcome is not known.
+// It decrements the stock again under the reference of the order: inventory applies a
+// reference only once, so this either replays the decrement that was made or makes it
+// now. Either way the stock is taken, and it reports true, unless inventory refuses it,
+// which it only does when nothing was taken.
+func (u *Order) settleItem(ctx context.Context, item models.OrderItem) (bool, error) {
+	err := u.inventoryService.Adjust(ctx, models.StockAdjustment{
+		ProductID: item.ProductID,
+		Delta:     -item.Quantity,
+		Reason:    models.AdjustmentReasonOrderPlaced,
+		Reference: orderReference(item.OrderID),
+	})
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, models.ErrStockConflict), errors.Is(err, models.ErrProductNotFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("settle product %d of order %d: %w", item.ProductID, item.OrderID, err)
+	}
+}
+
+// rejectionReason maps an inventory client error to the reason reported for the order line.
+func rejectionReason(err error) string {
+	switch {
+	case errors.Is(err, models.ErrProductNotFound):
+		return models.ReasonProductNotFound
+	case errors.Is(err, models.ErrStockConflict):
+		return models.ReasonStockConflict
+	case errors.Is(err, models.ErrInventoryUnavailable):
+		return models.ReasonInventoryUnavailable
+	default:
+		return models.ReasonInventoryError
+	}
+}
+
+// mergeLines returns items with the lines of a product summed into the first one.
+func mergeLines(items []models.OrderItem) []models.OrderItem {
+	merged := make([]models.OrderItem, 0, len(items))
+	index := make(map[int64]int, len(items))
+	for _, item := range items {
+		if i, ok := index[item.ProductID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// productIDs returns the distinct products of the given lines.
+func productIDs(items []models.OrderItem) []int64 {
+	seen := make(map[int64]bool, len(items))
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		if !seen[item.ProductID] {
+			seen[item.ProductID] = true
+			ids = append(ids, item.ProductID)
+		}
+	}
+	return ids
+}
+
+// orderReference identifies the stock adjustments made for an order in inventory-service.
+func orderReference(orderID int64) string {
+	return fmt.Sprintf("order:%d", orderID)
+}
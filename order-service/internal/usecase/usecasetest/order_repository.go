@@ -0,0 +1,572 @@
+// Package usecasetest holds conformance tests for the repositories the use cases depend
+// on. Every implementation runs the same tests, so the in-memory repositories can stand
+// in for Postgres.
+package usecasetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/usecase"
+)
+
+// TestOrderRepository runs the conformance tests against the repositories returned by
+// newRepo. Every test gets a new, empty repository.
+func TestOrderRepository(t *testing.T, newRepo func(t *testing.T) usecase.OrderRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo usecase.OrderRepository)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateMergesProducts", testCreateMergesProducts},
+		{"CreateInvalidQuantity", testCreateInvalidQuantity},
+		{"GetMissing", testGetMissing},
+		{"List", testList},
+		{"ListByCustomer", testListByCustomer},
+		{"ListFilters", testListFilters},
+		{"ListPages", testListPages},
+		{"Update", testUpdate},
+		{"UpdateMissing", testUpdateMissing},
+		{"SoftDelete", testSoftDelete},
+		{"SetItemStatuses", testSetItemStatuses},
+		{"ExpirePending", testExpirePending},
+		{"ExpirePendingReleaseFailure", testExpirePendingReleaseFailure},
+		{"ExpirePendingLimit", testExpirePendingLimit},
+		{"ExpirePendingConcurrent", testExpirePendingConcurrent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+func testCreateAndGet(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	id := create(t, repo, "alice", item(2, 1), item(1, 3))
+
+	order, err := repo.GetWithFilter(ctx, models.OrderFilter{ID: id})
+	if err != nil {
+		t.Fatalf("GetWithFilter: %v", err)
+	}
+	if order.ID != id || order.CustomerName != "alice" || order.Status != models.OrderStatusPending || order.IsDeleted {
+		t.Errorf("GetWithFilter = %+v", order)
+	}
+	if order.Created_at.IsZero() {
+		t.Error("created_at is not set")
+	}
+
+	want := []models.OrderItem{
+		{OrderID: id, ProductID: 1, Quantity: 3, Status: models.OrderItemStatusPending},
+		{OrderID: id, ProductID: 2, Quantity: 1, Status: models.OrderItemStatusPending},
+	}
+	if !slices.Equal(order.OrderItems, want) {
+		t.Errorf("items = %+v, want %+v", order.OrderItems, want)
+	}
+}
+
+func testCreateMergesProducts(t *testing.T, repo usecase.OrderRepository) {
+	id := create(t, repo, "bob", item(7, 2), item(7, 5))
+
+	order := get(t, repo, id)
+	if len(order.OrderItems) != 1 || order.OrderItems[0].Quantity != 7 {
+		t.Errorf("items = %+v, want one line with quantity 7", order.OrderItems)
+	}
+}
+
+func testCreateInvalidQuantity(t *testing.T, repo usecase.OrderRepository) {
+	_, err := repo.Create(context.Background(), models.Order{
+		CustomerName: "carol",
+		Status:       models.OrderStatusPending,
+		OrderItems:   []models.OrderItem{item(1, 0)},
+	})
+	if err == nil {
+		t.Fatal("Create with a zero quantity succeeded")
+	}
+
+	orders, _, err := repo.GetListWithFilter(context.Background(), models.OrderFilter{})
+	if err != nil {
+		t.Fatalf("GetListWithFilter: %v", err)
+	}
+	if len(orders) != 0 {
+		t.Errorf("a failed Create left %d orders", len(orders))
+	}
+}
+
+func testGetMissing(t *testing.T, repo usecase.OrderRepository) {
+	_, err := repo.GetWithFilter(context.Background(), models.OrderFilter{ID: 12345})
+	if !errors.Is(err, models.ErrRecordNotFound) {
+		t.Errorf("GetWithFilter = %v, want %v", err, models.ErrRecordNotFound)
+	}
+}
+
+func testList(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	first := create(t, repo, "alice", item(1, 1))
+	second := create(t, repo, "bob")
+	deleted := create(t, repo, "carol", item(2, 1))
+
+	setDeleted(t, repo, deleted)
+
+	orders, _, err := repo.GetListWithFilter(ctx, models.OrderFilter{})
+	if err != nil {
+		t.Fatalf("GetListWithFilter: %v", err)
+	}
+
+	// Orders created within the same second are ordered by ID.
+	var ids []int64
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	if want := []int64{second, first}; !slices.Equal(ids, want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+
+	if orders[0].OrderItems == nil || len(orders[0].OrderItems) != 0 {
+		t.Errorf("order without items has items %#v, want an empty slice", orders[0].OrderItems)
+	}
+	if len(orders[1].OrderItems) != 1 || orders[1].OrderItems[0].ProductID != 1 {
+		t.Errorf("items = %+v", orders[1].OrderItems)
+	}
+}
+
+func testListByCustomer(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	var ids []int64
+	for _, customerID := range []string{"user-1", "user-2", ""} {
+		id, err := repo.Create(ctx, models.Order{
+			CustomerName: "alice",
+			CustomerID:   customerID,
+			Status:       models.OrderStatusPending,
+		})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if order := get(t, repo, ids[0]); order.CustomerID != "user-1" {
+		t.Errorf("CustomerID = %q, want user-1", order.CustomerID)
+	}
+
+	orders, _, err := repo.GetListWithFilter(ctx, models.OrderFilter{CustomerID: "user-1"})
+	if err != nil {
+		t.Fatalf("GetListWithFilter: %v", err)
+	}
+	if len(orders) != 1 || orders[0].ID != ids[0] || orders[0].CustomerID != "user-1" {
+		t.Errorf("orders of user-1 = %+v", orders)
+	}
+
+	orders, _, err = repo.GetListWithFilter(ctx, models.OrderFilter{})
+	if err != nil {
+		t.Fatalf("GetListWithFilter: %v", err)
+	}
+	if len(orders) != 3 {
+		t.Errorf("got %d orders without a filter, want 3", len(orders))
+	}
+}
+
+func testListFilters(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	keyboard := create(t, repo, "alice", item(1, 1), item(2, 1))
+	cable := create(t, repo, "bob", item(3, 1))
+	completed := create(t, repo, "carol", item(1, 2))
+	status := models.OrderStatusCompleted
+	if err := repo.Update(ctx, models.OrderUpdateData{ID: &completed, Status: &status}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	created := get(t, repo, keyboard).Created_at
+	latest := get(t, repo, completed).Created_at
+
+	tests := []struct {
+		name   string
+		filter models.OrderFilter
+		want   []int64
+	}{
+		{name: "status", filter: models.OrderFilter{Status: models.OrderStatusPending}, want: []int64{cable, keyboard}},
+		{name: "product", filter: models.OrderFilter{ProductID: 1}, want: []int64{completed, keyboard}},
+		{name: "product and status", filter: models.OrderFilter{ProductID: 1, Status: models.OrderStatusCompleted}, want: []int64{completed}},
+		{name: "unknown product", filter: models.OrderFilter{ProductID: 9}},
+		{name: "placed around", filter: models.OrderFilter{CreatedAfter: created.Add(-time.Second), CreatedBefore: latest.Add(time.Second)}, want: []int64{completed, cable, keyboard}},
+		{name: "placed after the latest", filter: models.OrderFilter{CreatedAfter: latest}},
+		{name: "placed before the first", filter: models.OrderFilter{CreatedBefore: created}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, total, err := repo.GetListWithFilter(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("GetListWithFilter: %v", err)
+			}
+			var ids []int64
+			for _, order := range orders {
+				ids = append(ids, order.ID)
+			}
+			if !slices.Equal(ids, tt.want) || total != len(tt.want) {
+				t.Errorf("ids = %v of %d, want %v", ids, total, tt.want)
+			}
+		})
+	}
+}
+
+func testListPages(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	var ids []int64
+	for range 5 {
+		ids = append(ids, create(t, repo, "alice", item(1, 1)))
+	}
+	slices.Reverse(ids)
+
+	tests := []struct {
+		page int
+		want []int64
+	}{
+		{page: 1, want: ids[:2]},
+		{page: 3, want: ids[4:]},
+		{page: 4},
+	}
+
+	for _, tt := range tests {
+		orders, total, err := repo.GetListWithFilter(ctx, models.OrderFilter{Page: tt.page, PageSize: 2})
+		if err != nil {
+			t.Fatalf("GetListWithFilter: %v", err)
+		}
+		var got []int64
+		for _, order := range orders {
+			got = append(got, order.ID)
+			if len(order.OrderItems) != 1 {
+				t.Errorf("order %d has items %+v", order.ID, order.OrderItems)
+			}
+		}
+		// Every page counts all orders, even one past the last
+		if !slices.Equal(got, tt.want) || total != 5 {
+			t.Errorf("page %d = %v of %d, want %v of 5", tt.page, got, total, tt.want)
+		}
+	}
+}
+
+func testUpdate(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	id := create(t, repo, "alice", item(1, 1), item(2, 2))
+
+	name := "alice smith"
+	status := models.OrderStatusCompleted
+	items := []models.OrderItem{item(3, 4)}
+	err := repo.Update(ctx, models.OrderUpdateData{ID: &id, CustomerName: &name, Status: &status, OrderItems: &items})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	order := get(t, repo, id)
+	if order.CustomerName != name || order.Status != status {
+		t.Errorf("order = %+v", order)
+	}
+	want := []models.OrderItem{{OrderID: id, ProductID: 3, Quantity: 4, Status: models.OrderItemStatusPending}}
+	if !slices.Equal(order.OrderItems, want) {
+		t.Errorf("items = %+v, want %+v", order.OrderItems, want)
+	}
+
+	// Without items the lines are left alone.
+	status = models.OrderStatusCanceled
+	if err := repo.Update(ctx, models.OrderUpdateData{ID: &id, Status: &status}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	order = get(t, repo, id)
+	if order.Status != status || order.CustomerName != name || !slices.Equal(order.OrderItems, want) {
+		t.Errorf("order = %+v", order)
+	}
+}
+
+func testUpdateMissing(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	status := models.OrderStatusCompleted
+
+	id := int64(12345)
+	err := repo.Update(ctx, models.OrderUpdateData{ID: &id, Status: &status})
+	if !errors.Is(err, models.ErrRecordNotFound) {
+		t.Errorf("Update of a missing order = %v, want %v", err, models.ErrRecordNotFound)
+	}
+
+	deleted := create(t, repo, "alice")
+	setDeleted(t, repo, deleted)
+
+	err = repo.Update(ctx, models.OrderUpdateData{ID: &deleted, Status: &status})
+	if !errors.Is(err, models.ErrRecordNotFound) {
+		t.Errorf("Update of a deleted order = %v, want %v", err, models.ErrRecordNotFound)
+	}
+}
+
+func testSoftDelete(t *testing.T, repo usecase.OrderRepository) {
+	id := create(t, repo, "alice", item(1, 1))
+	setDeleted(t, repo, id)
+
+	_, err := repo.GetWithFilter(context.Background(), models.OrderFilter{ID: id})
+	if !errors.Is(err, models.ErrRecordNotFound) {
+		t.Errorf("GetWithFilter of a deleted order = %v, want %v", err, models.ErrRecordNotFound)
+	}
+}
+
+func testSetItemStatuses(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	id := create(t, repo, "alice", item(1, 1), item(2, 1))
+
+	err := repo.SetItemStatuses(ctx, id, []models.OrderItem{
+		{ProductID: 1, Status: models.OrderItemStatusAccepted},
+		{ProductID: 2, Status: models.OrderItemStatusRejected},
+	})
+	if err != nil {
+		t.Fatalf("SetItemStatuses: %v", err)
+	}
+
+	order := get(t, repo, id)
+	if got := statuses(order); !slices.Equal(got, []string{models.OrderItemStatusAccepted, models.OrderItemStatusRejected}) {
+		t.Errorf("statuses = %v", got)
+	}
+}
+
+func testExpirePending(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	// The outcome of the fourth line was never recorded
+	expired := create(t, repo, "alice", item(1, 2), item(2, 3), item(3, 1), item(4, 5))
+	accept(t, repo, expired, 1)
+	err := repo.SetItemStatuses(ctx, expired, []models.OrderItem{
+		{ProductID: 2, Status: models.OrderItemStatusRejected},
+		{ProductID: 3, Status: models.OrderItemStatusUnknown},
+	})
+	if err != nil {
+		t.Fatalf("SetItemStatuses: %v", err)
+	}
+
+	completed := create(t, repo, "bob", item(1, 1))
+	status := models.OrderStatusCompleted
+	if err := repo.Update(ctx, models.OrderUpdateData{ID: &completed, Status: &status}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	var released []models.OrderItem
+	ids, err := repo.ExpirePending(ctx, time.Now().Add(time.Hour), 10, func(ctx context.Context, item models.OrderItem) error {
+		released = append(released, item)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExpirePending: %v", err)
+	}
+	if !slices.Equal(ids, []int64{expired}) {
+		t.Errorf("ExpirePending = %v, want [%d]", ids, expired)
+	}
+
+	// Only the lines that took stock or may have taken it are given back.
+	want := []models.OrderItem{
+		{OrderID: expired, ProductID: 1, Quantity: 2, Status: models.OrderItemStatusAccepted},
+		{OrderID: expired, ProductID: 3, Quantity: 1, Status: models.OrderItemStatusUnknown},
+		{OrderID: expired, ProductID: 4, Quantity: 5, Status: models.OrderItemStatusPending},
+	}
+	if !slices.Equal(released, want) {
+		t.Errorf("released = %+v, want %+v", released, want)
+	}
+
+	order := get(t, repo, expired)
+	if order.Status != models.OrderStatusCanceled {
+		t.Errorf("status = %q, want %q", order.Status, models.OrderStatusCanceled)
+	}
+	if got := statuses(order); !slices.Equal(got, []string{models.OrderItemStatusReleased, models.OrderItemStatusRejected, models.OrderItemStatusReleased, models.OrderItemStatusReleased}) {
+		t.Errorf("statuses = %v", got)
+	}
+
+	// Orders created after the cutoff are left alone.
+	recent := create(t, repo, "carol", item(1, 1))
+	ids, err = repo.ExpirePending(ctx, time.Now().Add(-time.Hour), 10, func(ctx context.Context, item models.OrderItem) error {
+		return nil
+	})
+	if err != nil || len(ids) != 0 {
+		t.Errorf("ExpirePending = %v, %v, want nothing", ids, err)
+	}
+	if order := get(t, repo, recent); order.Status != models.OrderStatusPending {
+		t.Errorf("status = %q, want %q", order.Status, models.OrderStatusPending)
+	}
+}
+
+func testExpirePendingReleaseFailure(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	id := create(t, repo, "alice", item(1, 1), item(2, 1))
+	accept(t, repo, id, 1, 2)
+
+	ids, err := repo.ExpirePending(ctx, time.Now().Add(time.Hour), 10, func(ctx context.Context, item models.OrderItem) error {
+		if item.ProductID == 2 {
+			return errors.New("inventory is down")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExpirePending: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("ExpirePending = %v, want nothing", ids)
+	}
+
+	order := get(t, repo, id)
+	if order.Status != models.OrderStatusPending {
+		t.Errorf("status = %q, want %q", order.Status, models.OrderStatusPending)
+	}
+	if got := statuses(order); !slices.Equal(got, []string{models.OrderItemStatusReleased, models.OrderItemStatusAccepted}) {
+		t.Errorf("statuses = %v", got)
+	}
+
+	// The next sweep only retries the line that is left.
+	var released []int64
+	ids, err = repo.ExpirePending(ctx, time.Now().Add(time.Hour), 10, func(ctx context.Context, item models.OrderItem) error {
+		released = append(released, item.ProductID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExpirePending: %v", err)
+	}
+	if !slices.Equal(ids, []int64{id}) || !slices.Equal(released, []int64{2}) {
+		t.Errorf("ExpirePending = %v, released %v", ids, released)
+	}
+}
+
+func testExpirePendingLimit(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	var created []int64
+	for range 3 {
+		created = append(created, create(t, repo, "alice"))
+	}
+
+	ids, err := repo.ExpirePending(ctx, time.Now().Add(time.Hour), 2, func(ctx context.Context, item models.OrderItem) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExpirePending: %v", err)
+	}
+
+	// The oldest orders go first.
+	if !slices.Equal(ids, created[:2]) {
+		t.Errorf("ExpirePending = %v, want %v", ids, created[:2])
+	}
+}
+
+func testExpirePendingConcurrent(t *testing.T, repo usecase.OrderRepository) {
+	ctx := context.Background()
+
+	const orders = 20
+	for range orders {
+		id := create(t, repo, "alice", item(1, 1))
+		accept(t, repo, id, 1)
+	}
+
+	var (
+		mu       sync.Mutex
+		released = make(map[int64]int)
+		expired  []int64
+		wg       sync.WaitGroup
+	)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ids, err := repo.ExpirePending(ctx, time.Now().Add(time.Hour), orders, func(ctx context.Context, item models.OrderItem) error {
+				mu.Lock()
+				released[item.OrderID]++
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Errorf("ExpirePending: %v", err)
+			}
+
+			mu.Lock()
+			expired = append(expired, ids...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// Every order is canceled exactly once and its stock is given back exactly once.
+	slices.Sort(expired)
+	if len(slices.Compact(expired)) != orders || len(expired) != orders {
+		t.Errorf("expired %d orders, want %d", len(expired), orders)
+	}
+	for id, n := range released {
+		if n != 1 {
+			t.Errorf("order %d released %d times", id, n)
+		}
+	}
+}
+
+func item(productID, quantity int64) models.OrderItem {
+	return models.OrderItem{ProductID: productID, Quantity: quantity}
+}
+
+func create(t *testing.T, repo usecase.OrderRepository, customer string, items ...models.OrderItem) int64 {
+	t.Helper()
+
+	id, err := repo.Create(context.Background(), models.Order{
+		CustomerName: customer,
+		Status:       models.OrderStatusPending,
+		OrderItems:   items,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return id
+}
+
+func get(t *testing.T, repo usecase.OrderRepository, id int64) models.Order {
+	t.Helper()
+
+	order, err := repo.GetWithFilter(context.Background(), models.OrderFilter{ID: id})
+	if err != nil {
+		t.Fatalf("GetWithFilter: %v", err)
+	}
+	return order
+}
+
+func setDeleted(t *testing.T, repo usecase.OrderRepository, id int64) {
+	t.Helper()
+
+	deleted := true
+	if err := repo.Update(context.Background(), models.OrderUpdateData{ID: &id, IsDeleted: &deleted}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+}
+
+// accept marks the given products of an order as deducted from inventory.
+func accept(t *testing.T, repo usecase.OrderRepository, orderID int64, productIDs ...int64) {
+	t.Helper()
+
+	var items []models.OrderItem
+	for _, id := range productIDs {
+		items = append(items, models.OrderItem{ProductID: id, Status: models.OrderItemStatusAccepted})
+	}
+	if err := repo.SetItemStatuses(context.Background(), orderID, items); err != nil {
+		t.Fatalf("SetItemStatuses: %v", err)
+	}
+}
+
+func statuses(order models.Order) []string {
+	var statuses []string
+	for _, item := range order.OrderItems {
+		statuses = append(statuses, item.Status)
+	}
+	return statuses
+}
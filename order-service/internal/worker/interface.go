@@ -0,0 +1,10 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+type OrderExpirer interface {
+	ExpirePending(ctx context.Context, ttl time.Duration, limit int) (int, error)
+}
@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"order-service/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	expiredOrders = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_sweeper_expired_orders_total",
+		Help: "Number of pending orders canceled by the sweeper because they exceeded the TTL.",
+	})
+	sweeps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_sweeper_runs_total",
+		Help: "Number of sweeper runs by result.",
+	}, []string{"result"})
+	lastSweep = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "order_sweeper_last_run_timestamp_seconds",
+		Help: "Unix time of the last finished sweeper run.",
+	})
+)
+
+// Sweeper periodically cancels stale pending orders. It is safe to run one per replica:
+// orders are claimed with row locks, so replicas never expire the same order twice.
+type Sweeper struct {
+	uc  OrderExpirer
+	cfg config.Sweeper
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewSweeper(cfg config.Sweeper, uc OrderExpirer) *Sweeper {
+	return &Sweeper{
+		uc:  uc,
+		cfg: cfg,
+	}
+}
+
+func (s *Sweeper) Run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		slog.Info("order sweeper started", "ttl", s.cfg.PendingTTL, "interval", s.cfg.Interval)
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			s.sweep(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the current run to finish.
+func (s *Sweeper) Stop() {
+	if s.cancel == nil {
+		return
+	}
+
+	s.cancel()
+	<-s.done
+}
+
+// sweep expires batches until a batch comes back short, so a backlog is drained in one run.
+func (s *Sweeper) sweep(ctx context.Context) {
+	defer lastSweep.SetToCurrentTime()
+
+	for ctx.Err() == nil {
+		n, err := s.uc.ExpirePending(ctx, s.cfg.PendingTTL, s.cfg.BatchSize)
+		expiredOrders.Add(float64(n))
+		if err != nil {
+			sweeps.WithLabelValues("error").Inc()
+			slog.Error("order sweeper", "error", err)
+			return
+		}
+		if n > 0 {
+			slog.Info("order sweeper: expired orders", "count", n)
+		}
+		if n < s.cfg.BatchSize {
+			sweeps.WithLabelValues("success").Inc()
+			return
+		}
+	}
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_orders_pending_created_at;
+ALTER TABLE order_items DROP COLUMN IF EXISTS status;
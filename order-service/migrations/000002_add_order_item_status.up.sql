@@ -0,0 +1,6 @@
+-- Lines keep track of whether their stock was actually deducted from inventory,
+-- so that expired orders only give back what they took. Existing rows stay
+-- 'pending' because we cannot tell whether they were deducted.
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending'; -- pending, accepted, rejected, released
+
+CREATE INDEX IF NOT EXISTS idx_orders_pending_created_at ON orders (created_at) WHERE status = 'pending' AND isdeleted = FALSE;
@@ -0,0 +1,5 @@
+-- Orders belong to the subject of the token they were placed with, so customers
+-- can be limited to their own orders. Orders placed before stay without one.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS customer_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders (customer_id);
@@ -0,0 +1,121 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// step is one call on a breaker: allow expects the error of Allow, the others record an
+// outcome. state is the state the breaker is in afterwards.
+type step struct {
+	op    string // allow, success, failure or release
+	err   error
+	state State
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		cooldown  time.Duration
+		steps     []step
+	}{
+		{
+			name:      "opens after threshold failures in a row",
+			threshold: 2,
+			cooldown:  time.Hour,
+			steps: []step{
+				{op: "allow", state: StateClosed},
+				{op: "failure", state: StateClosed},
+				{op: "allow", state: StateClosed},
+				{op: "failure", state: StateOpen},
+				{op: "allow", err: ErrOpen, state: StateOpen},
+			},
+		},
+		{
+			name:      "a success resets the failures",
+			threshold: 2,
+			cooldown:  time.Hour,
+			steps: []step{
+				{op: "failure", state: StateClosed},
+				{op: "success", state: StateClosed},
+				{op: "failure", state: StateClosed},
+				{op: "allow", state: StateClosed},
+			},
+		},
+		{
+			name:      "a threshold below one is one",
+			threshold: 0,
+			cooldown:  time.Hour,
+			steps: []step{
+				{op: "failure", state: StateOpen},
+			},
+		},
+		{
+			name:      "lets a single probe through after the cooldown",
+			threshold: 1,
+			steps: []step{
+				{op: "failure", state: StateOpen},
+				{op: "allow", state: StateHalfOpen},
+				{op: "allow", err: ErrOpen, state: StateHalfOpen},
+			},
+		},
+		{
+			name:      "a successful probe closes",
+			threshold: 1,
+			steps: []step{
+				{op: "failure", state: StateOpen},
+				{op: "allow", state: StateHalfOpen},
+				{op: "success", state: StateClosed},
+				{op: "allow", state: StateClosed},
+				{op: "allow", state: StateClosed},
+			},
+		},
+		{
+			name:      "a failed probe reopens",
+			threshold: 3,
+			steps: []step{
+				{op: "failure", state: StateClosed},
+				{op: "failure", state: StateClosed},
+				{op: "failure", state: StateOpen},
+				{op: "allow", state: StateHalfOpen},
+				{op: "failure", state: StateOpen},
+			},
+		},
+		{
+			name:      "a released probe lets the next one through",
+			threshold: 1,
+			steps: []step{
+				{op: "failure", state: StateOpen},
+				{op: "allow", state: StateHalfOpen},
+				{op: "release", state: StateHalfOpen},
+				{op: "allow", state: StateHalfOpen},
+				{op: "allow", err: ErrOpen, state: StateHalfOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.threshold, tt.cooldown)
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if err := b.Allow(); !errors.Is(err, s.err) {
+						t.Fatalf("step %d: Allow() = %v, want %v", i, err, s.err)
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure()
+				case "release":
+					b.Release()
+				}
+				if got := b.State(); got != s.state {
+					t.Fatalf("step %d (%s): state = %s, want %s", i, s.op, got, s.state)
+				}
+			}
+		})
+	}
+}
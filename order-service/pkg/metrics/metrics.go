@@ -0,0 +1,76 @@
+// Package metrics records Prometheus metrics of the HTTP requests a service serves. They are
+// exposed together with every other metric of the default registry by promhttp.Handler.
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// routeKey holds the route set with SetRoute in the Gin context.
+const routeKey = "metrics.route"
+
+// unmatchedRoute labels requests that matched no route, so that unknown paths do not add
+// a series each.
+const unmatchedRoute = "unmatched"
+
+var (
+	requests = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests served, by route and status.",
+	}, []string{"method", "route", "status"}))
+	requestDuration = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"}))
+)
+
+// register adds c to the default registry. The gateway and the services may run in one
+// process, as in the end-to-end tests, each with its own copy of this package; they share
+// the metrics registered first then.
+func register[T prometheus.Collector](c T) T {
+	err := prometheus.Register(c)
+
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		return registered.ExistingCollector.(T)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// Middleware records every request once it is served. Requests are labeled with the route
+// template, such as /products/:id, never with the path itself.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.GetString(routeKey)
+		if route == "" {
+			route = c.FullPath()
+		}
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		status := strconv.Itoa(c.Writer.Status())
+		requests.WithLabelValues(c.Request.Method, route, status).Inc()
+		requestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// SetRoute labels the request with route instead of the Gin route, for handlers that serve
+// many routes behind one wildcard.
+func SetRoute(c *gin.Context, route string) {
+	c.Set(routeKey, route)
+}
@@ -17,6 +17,9 @@ type Config struct {
 	MaxOpenConns int32  `env:"POSTGRES_MAX_OPEN_CONN" envDefault:"25"`
 	MaxIdleConns int    `env:"POSTGRES_MAX_IDLE_CONN" envDefault:"25"`
 	MaxIdleTime  string `env:"POSTGRES_MAX_IDLE_TIME" envDefault:"15m"`
+
+	// Apply the pending migrations on start, the migrate subcommand does it on demand.
+	MigrateOnStart bool `env:"POSTGRES_MIGRATE_ON_START" envDefault:"false"`
 }
 
 func New(ctx context.Context, config Config) (*PostgreDB, error) {
@@ -35,6 +38,7 @@ func New(ctx context.Context, config Config) (*PostgreDB, error) {
 	}
 
 	dbConfig.MaxConnIdleTime = duration
+	dbConfig.ConnConfig.Tracer = NewTracer()
 
 	pool, err := pgxpool.NewWithConfig(ctx, dbConfig)
 	if err != nil {
@@ -0,0 +1,89 @@
+// Package postgrestest gives every test its own schema in the database named by
+// TEST_POSTGRES_DSN. Tests that need Postgres are skipped when it is not set.
+package postgrestest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"order-service/migrations"
+	"order-service/pkg/postgres"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const EnvDSN = "TEST_POSTGRES_DSN"
+
+// dsn creates an empty schema that is dropped when the test ends and returns a DSN whose
+// connections use it.
+func dsn(t *testing.T) string {
+	t.Helper()
+
+	base := os.Getenv(EnvDSN)
+	if base == "" {
+		t.Skipf("%s is not set", EnvDSN)
+	}
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	schema := "test_" + hex.EncodeToString(suffix)
+
+	exec(t, base, "CREATE SCHEMA "+schema)
+	t.Cleanup(func() {
+		exec(t, base, "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	// Both URLs and key/value strings are accepted by pgx
+	if !strings.Contains(base, "://") {
+		return base + " search_path=" + schema
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		t.Fatalf("parse %s: %v", EnvDSN, err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// New returns a pool on a new schema, migrated the way order-service migrates its
+// database on start.
+func New(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := pgxpool.New(context.Background(), dsn(t))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := postgres.Migrate(pool, migrations.FS); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return pool
+}
+
+func exec(t *testing.T, dsn, sql string) {
+	t.Helper()
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, sql); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Package tracing sets up OpenTelemetry tracing. The trace context travels between the
+// gateway and the services in the W3C traceparent header, and in gRPC metadata.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Config struct {
+	Exporter    string  `env:"OTEL_TRACES_EXPORTER" envDefault:"none"` // Can be: otlp, stdout, none
+	Endpoint    string  `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`            // OTLP over gRPC, e.g. http://localhost:4317
+	SampleRatio float64 `env:"OTEL_TRACES_SAMPLER_ARG" envDefault:"1"` // Share of the traces started here that are kept
+}
+
+// Setup installs the global tracer provider and propagator. The returned function flushes
+// the spans that were not exported yet and has to be called before the process exits.
+// With the none exporter no spans are recorded, but the trace context of incoming requests
+// is still passed on.
+func Setup(ctx context.Context, cfg Config, service, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cfg.Exporter) {
+	case "none", "":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		var opts []otlptracegrpc.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown traces exporter: %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("traces exporter: %w", err)
+	}
+
+	attrs := []attribute.KeyValue{semconv.ServiceName(service)}
+	if version != "" {
+		attrs = append(attrs, semconv.ServiceVersion(version))
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attrs...))
+	if err != nil {
+		return nil, fmt.Errorf("resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Error marks span as failed with err and returns err, so that it can end a return
+// statement.
+func Error(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+// Package testserver runs order-service on an httptest server with in-memory storage.
+// It lets tests outside this module, such as the end-to-end tests, use the real HTTP
+// routes without Postgres.
+package testserver
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/adapter/http/myrouter"
+	httpservice "order-service/internal/adapter/http/service"
+	"order-service/internal/adapter/invmetrics"
+	"order-service/internal/adapter/memory"
+	"order-service/internal/models"
+	"order-service/internal/usecase"
+	"order-service/pkg/health"
+)
+
+type Server struct {
+	*httptest.Server
+
+	orderUsecase *usecase.Order
+}
+
+// Option changes the configuration or the storage the server is started with.
+type Option func(s *settings)
+
+type settings struct {
+	cfg  config.Server
+	repo usecase.OrderRepository
+}
+
+// EnforceRoles makes the server check the roles of the callers, as AUTHZ_ENFORCE does.
+func EnforceRoles() Option {
+	return func(s *settings) {
+		s.cfg.Authz.Enforce = true
+	}
+}
+
+// LoseItemStatuses makes recording the outcome of the order lines fail, as if the database
+// went away after the stock was taken. The lines stay pending.
+func LoseItemStatuses() Option {
+	return func(s *settings) {
+		s.repo = lostItemStatuses{s.repo}
+	}
+}
+
+type lostItemStatuses struct {
+	usecase.OrderRepository
+}
+
+func (lostItemStatuses) SetItemStatuses(context.Context, int64, []models.OrderItem) error {
+	return errors.New("testserver: item statuses lost")
+}
+
+// New starts a server that talks to the inventory-service at inventoryURL over HTTP. It is
+// closed when the test ends. The sweeper does not run, tests call ExpireAll instead.
+func New(t testing.TB, inventoryURL string, opts ...Option) *Server {
+	t.Helper()
+
+	s := settings{
+		cfg:  config.Server{HTTPServer: config.HTTPServer{Mode: "test"}},
+		repo: memory.NewOrderRepository(),
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	inventoryRouter, err := myrouter.NewInventoryRouter(config.Inventory{
+		URL:             inventoryURL,
+		Timeout:         5 * time.Second,
+		BreakerFailures: 5,
+		BreakerCooldown: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("inventory router: %v", err)
+	}
+
+	orderUsecase := usecase.NewOrder(s.repo, invmetrics.New("http", inventoryRouter))
+	checks := health.New(health.Config{Timeout: time.Second})
+	checks.Add("inventory-service", inventoryRouter.Check)
+
+	api := httpservice.New(s.cfg, orderUsecase, checks)
+
+	srv := httptest.NewServer(api.Handler())
+	t.Cleanup(srv.Close)
+
+	return &Server{
+		Server:       srv,
+		orderUsecase: orderUsecase,
+	}
+}
+
+// ExpireAll runs one sweep as if every pending order had outlived its TTL, canceling them
+// and returning their stock. It returns how many orders were canceled.
+func (s *Server) ExpireAll(ctx context.Context) (int, error) {
+	// created_at is rounded to the second, so a new order can lie slightly in the future.
+	return s.orderUsecase.ExpirePending(ctx, -time.Minute, 100)
+}